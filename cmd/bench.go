@@ -0,0 +1,150 @@
+package cmd
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/denysvitali/mcp-ssh/pkg/ssh"
+	"github.com/spf13/cobra"
+)
+
+var (
+	benchHost     string
+	benchPort     int
+	benchUser     string
+	benchPassword string
+	benchKey      string
+	benchCommands int
+	benchCommand  string
+)
+
+// benchCmd connects to a host and measures the persistent shell's latency
+var benchCmd = &cobra.Command{
+	Use:   "bench",
+	Short: "Measure connection time and command latency against a host",
+	Long: `Connects to a host and runs a trivial command repeatedly through the same
+persistent shell used by the MCP tools, then reports the connection time,
+the per-command latency distribution and the throughput.
+
+Use it to check that the persistent-shell protocol works against a host and
+to tune timeouts for your environment.`,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		// The host given on the command line is trusted unless an allow-list
+		// was passed explicitly
+		if !cmd.Flags().Changed("allowed-hosts") && !cmd.Flags().Changed("allowed-hosts-file") {
+			return cmd.Flags().Set("allowed-hosts", benchHost)
+		}
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runBench()
+	},
+}
+
+func init() {
+	benchCmd.Flags().StringVar(&benchHost, "host", "", "Remote host to benchmark")
+	_ = benchCmd.MarkFlagRequired("host") // Flag name is hardcoded, safe to ignore error
+
+	benchCmd.Flags().IntVar(&benchPort, "port", 22, "SSH port")
+
+	benchCmd.Flags().StringVar(&benchUser, "user", "", "SSH username")
+	_ = benchCmd.MarkFlagRequired("user") // Flag name is hardcoded, safe to ignore error
+
+	benchCmd.Flags().StringVar(&benchPassword, "password", "",
+		"SSH password (default: $MCP_SSH_PASSWORD)")
+
+	benchCmd.Flags().StringVar(&benchKey, "private-key", "", "Path to SSH private key file")
+
+	benchCmd.Flags().IntVar(&benchCommands, "commands", 100, "Number of commands to run")
+
+	benchCmd.Flags().StringVar(&benchCommand, "command", "true", "Command to run")
+
+	rootCmd.AddCommand(benchCmd)
+}
+
+// runBench connects to the benchmark host and prints the measurements
+func runBench() error {
+	if benchCommands <= 0 {
+		return fmt.Errorf("--commands must be positive")
+	}
+
+	password := benchPassword
+	if password == "" {
+		password = os.Getenv("MCP_SSH_PASSWORD")
+	}
+	if password == "" && benchKey == "" {
+		return fmt.Errorf("either --password or --private-key is required")
+	}
+
+	validator, err := NewHostValidator()
+	if err != nil {
+		return fmt.Errorf("failed to create host validator: %w", err)
+	}
+
+	manager := ssh.NewManager(validator, ssh.WithIdleOutputThreshold(idleOutputThreshold))
+	defer manager.CloseAll()
+
+	fmt.Println(infoStyle.Render(fmt.Sprintf("Connecting to %s@%s:%d...", benchUser, benchHost, benchPort)))
+
+	started := time.Now()
+	_, err = manager.Connect(ssh.ConnectParams{
+		ID:             "bench",
+		Host:           benchHost,
+		Port:           benchPort,
+		Username:       benchUser,
+		Password:       password,
+		PrivateKeyPath: benchKey,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+	connectTime := time.Since(started)
+
+	fmt.Println(infoStyle.Render(fmt.Sprintf("Running %d commands...", benchCommands)))
+
+	latencies := make([]time.Duration, 0, benchCommands)
+	failures := 0
+	started = time.Now()
+	for i := 0; i < benchCommands; i++ {
+		commandStarted := time.Now()
+		result, err := manager.Execute("bench", benchCommand)
+		if err != nil {
+			return fmt.Errorf("command %d failed: %w", i+1, err)
+		}
+		latencies = append(latencies, time.Since(commandStarted))
+		if result.ExitCode != 0 {
+			failures++
+		}
+	}
+	total := time.Since(started)
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+	fmt.Println(successStyle.Render("Benchmark complete"))
+	fmt.Printf("  connect:    %v\n", connectTime.Round(time.Microsecond))
+	fmt.Printf("  commands:   %d (%d non-zero exit codes)\n", len(latencies), failures)
+	fmt.Printf("  min:        %v\n", latencies[0].Round(time.Microsecond))
+	fmt.Printf("  p50:        %v\n", percentile(latencies, 0.50).Round(time.Microsecond))
+	fmt.Printf("  p90:        %v\n", percentile(latencies, 0.90).Round(time.Microsecond))
+	fmt.Printf("  p99:        %v\n", percentile(latencies, 0.99).Round(time.Microsecond))
+	fmt.Printf("  max:        %v\n", latencies[len(latencies)-1].Round(time.Microsecond))
+	fmt.Printf("  mean:       %v\n", (total / time.Duration(len(latencies))).Round(time.Microsecond))
+	fmt.Printf("  throughput: %.1f commands/s\n", float64(len(latencies))/total.Seconds())
+
+	return nil
+}
+
+// percentile returns the p-th percentile (0 < p <= 1) of sorted durations
+// using the nearest-rank method
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	return sorted[rank]
+}
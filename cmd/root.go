@@ -3,16 +3,64 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/charmbracelet/lipgloss"
+	"github.com/denysvitali/mcp-ssh/pkg/mcp"
+	"github.com/denysvitali/mcp-ssh/pkg/ssh"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
 
 var (
-	allowedHosts string
-	logLevel     string
-	logFile      string
+	allowedHosts         string
+	allowedHostsFile     string
+	caseInsensitiveHosts bool
+	logLevel             string
+	logFile              string
+
+	idleOutputThreshold time.Duration
+	allowProxyCommand   bool
+	strictKeyPerms      bool
+	useSSHConfig        bool
+	enableListKeys      bool
+	enablePTY           bool
+	allowSessionCmds    bool
+	maxConcurrentExecs  int
+	execQueueSize       int
+	shutdownGrace       time.Duration
+	timeBudget          time.Duration
+	maxConnections      int
+	maxCommandsPerMin   int
+	idleTimeout         time.Duration
+	maxSessionLifetime  time.Duration
+	keepaliveInterval   time.Duration
+
+	transport   string
+	listenAddr  string
+	metricsAddr string
+	healthAddr  string
+
+	tlsCert     string
+	tlsKey      string
+	tlsClientCA string
+	authToken   string
+
+	sftpAllowedPaths string
+	sftpDeniedPaths  string
+
+	outputFilters string
+
+	commandAllow []string
+	commandDeny  []string
+	auditLog     string
+
+	artifactsDir    string
+	maxLocalOutput  int64
+	maxTransferSize int64
+
+	stateFile  string
+	knownHosts string
 
 	// Styles for terminal output
 	errorStyle = lipgloss.NewStyle().
@@ -58,8 +106,13 @@ func Execute() {
 func init() {
 	// Define flags
 	rootCmd.PersistentFlags().StringVar(&allowedHosts, "allowed-hosts", "",
-		"Comma-separated list of allowed hosts (supports glob patterns, e.g., '*.example.com,10.0.*')")
-	_ = rootCmd.MarkPersistentFlagRequired("allowed-hosts") // Flag name is hardcoded, safe to ignore error
+		"Comma-separated list of allowed hosts (supports glob patterns and an optional port, e.g., '*.example.com,10.0.*:2200')")
+
+	rootCmd.PersistentFlags().StringVar(&allowedHostsFile, "allowed-hosts-file", "",
+		"File listing allowed host patterns, one per line ('#' starts a comment); combined with --allowed-hosts")
+
+	rootCmd.PersistentFlags().BoolVar(&caseInsensitiveHosts, "case-insensitive-hosts", true,
+		"Match hostnames against --allowed-hosts regardless of case, as DNS does (IP address patterns are matched as given)")
 
 	rootCmd.PersistentFlags().StringVar(&logLevel, "log-level", "info",
 		"Log level (trace, debug, info, warn, error, fatal, panic)")
@@ -67,6 +120,107 @@ func init() {
 	rootCmd.PersistentFlags().StringVar(&logFile, "log-file", "",
 		"Log file path (default: stderr)")
 
+	rootCmd.PersistentFlags().DurationVar(&idleOutputThreshold, "idle-output-threshold", 10*time.Second,
+		"Output silence after which a timed out command is reported as possibly waiting for input or hung")
+
+	rootCmd.PersistentFlags().IntVar(&maxConcurrentExecs, "max-concurrent-execs", 0,
+		"Maximum number of commands running at once across all connections (0: unlimited)")
+
+	rootCmd.PersistentFlags().IntVar(&execQueueSize, "exec-queue-size", 100,
+		"Commands that may wait for a slot once --max-concurrent-execs is reached; further commands are rejected as busy")
+
+	rootCmd.PersistentFlags().DurationVar(&shutdownGrace, "shutdown-grace", 0,
+		"On SIGINT/SIGTERM, time to let running commands finish before connections are closed; new commands are rejected meanwhile")
+	rootCmd.PersistentFlags().DurationVar(&timeBudget, "time-budget", 0,
+		"Cumulative command time each connection may use before further commands are rejected; connections may lower but not raise or reset it (0: unlimited)")
+
+	rootCmd.PersistentFlags().IntVar(&maxConnections, "max-connections", ssh.MaxConnections,
+		"Maximum connections open at once across all clients; further ssh_connect calls fail until one is closed")
+	rootCmd.PersistentFlags().IntVar(&maxCommandsPerMin, "max-commands-per-minute", 0,
+		"Maximum commands each connection may run a minute; further commands are rejected until the limit allows them (0: unlimited)")
+
+	rootCmd.PersistentFlags().DurationVar(&idleTimeout, "idle-timeout", 0,
+		"Close connections that have not been used for this long (0: never)")
+	rootCmd.PersistentFlags().DurationVar(&maxSessionLifetime, "max-session-lifetime", 0,
+		"Close connections open for this long, however active, aborting any running command (0: never)")
+	rootCmd.PersistentFlags().DurationVar(&keepaliveInterval, "keepalive-interval", 0,
+		"Send a keepalive on every connection this often and drop connections that stop answering (0: never)")
+
+	rootCmd.PersistentFlags().BoolVar(&allowProxyCommand, "allow-proxy-command", false,
+		"Allow ssh_connect to run a local proxy_command as the SSH transport (executes commands on this machine)")
+
+	rootCmd.PersistentFlags().BoolVar(&strictKeyPerms, "strict-key-perms", false,
+		"Refuse private key files readable by the group or others, as OpenSSH does, instead of warning about them")
+
+	rootCmd.PersistentFlags().BoolVar(&useSSHConfig, "use-ssh-config", false,
+		"Resolve the host given to ssh_connect as an alias of ~/.ssh/config, which supplies the host name, user, port and key not given explicitly")
+
+	rootCmd.PersistentFlags().BoolVar(&allowSessionCmds, "allow-session-commands", false,
+		"Allow commands that replace or exit the persistent shell (exec, exit, bare bash, su, sudo -i)")
+
+	rootCmd.PersistentFlags().BoolVar(&enablePTY, "enable-pty", false,
+		"Allow ssh_connect to run the shell on a pseudo-terminal with request_pty")
+
+	rootCmd.PersistentFlags().BoolVar(&enableListKeys, "enable-list-keys", false,
+		"Enable the ssh_list_keys tool, which reveals the public keys in the SSH agent and ~/.ssh")
+
+	rootCmd.PersistentFlags().StringVar(&transport, "transport", "stdio",
+		"Transport to serve MCP clients on: stdio (a single client) or sse (any number of clients over HTTP)")
+
+	rootCmd.PersistentFlags().StringVar(&metricsAddr, "metrics-addr", "",
+		"Address to serve Prometheus metrics on at /metrics, e.g. 127.0.0.1:9090 (default: none)")
+
+	rootCmd.PersistentFlags().StringVar(&healthAddr, "health-addr", "",
+		"Address to serve liveness and readiness probes on at /healthz and /readyz, e.g. 0.0.0.0:8081 (default: none)")
+
+	rootCmd.PersistentFlags().StringVar(&listenAddr, "listen-addr", "127.0.0.1:8080",
+		"Address the sse transport listens on")
+
+	rootCmd.PersistentFlags().StringVar(&tlsCert, "tls-cert", "",
+		"TLS certificate file for the HTTP transport")
+
+	rootCmd.PersistentFlags().StringVar(&tlsKey, "tls-key", "",
+		"TLS private key file for the HTTP transport")
+
+	rootCmd.PersistentFlags().StringVar(&tlsClientCA, "tls-client-ca", "",
+		"CA file used to require and verify client certificates on the HTTP transport (mutual TLS)")
+
+	rootCmd.PersistentFlags().StringVar(&authToken, "auth-token", "",
+		"Bearer token required from clients of the HTTP transport (default: $MCP_SSH_AUTH_TOKEN)")
+
+	rootCmd.PersistentFlags().StringVar(&sftpAllowedPaths, "sftp-allowed-paths", "",
+		"Comma-separated remote path patterns the SFTP tools may access (default: all, e.g. '/srv/app,/var/log/*.log')")
+
+	rootCmd.PersistentFlags().StringVar(&sftpDeniedPaths, "sftp-denied-paths", "",
+		"Comma-separated remote path patterns the SFTP tools may never access; takes precedence over --sftp-allowed-paths")
+
+	rootCmd.PersistentFlags().StringVar(&outputFilters, "output-filters", "",
+		"Comma-separated filters applied to command output before it is returned (redact-secrets, strip-ansi)")
+
+	rootCmd.PersistentFlags().StringArrayVar(&commandAllow, "command-allow", nil,
+		"Regular expression commands must match to be run; repeat for several, any of which may match (default: all commands allowed)")
+
+	rootCmd.PersistentFlags().StringArrayVar(&commandDeny, "command-deny", nil,
+		"Regular expression of commands that are never run; repeat for several; takes precedence over --command-allow")
+
+	rootCmd.PersistentFlags().StringVar(&auditLog, "audit-log", "",
+		"File every command run by ssh_execute is appended to as a JSON line, regardless of --log-level (default: none)")
+
+	rootCmd.PersistentFlags().StringVar(&artifactsDir, "artifacts-dir", "",
+		"Local directory ssh_execute_to_local may write command output into (the tool is disabled when empty)")
+
+	rootCmd.PersistentFlags().Int64Var(&maxLocalOutput, "max-local-output", 1<<30,
+		"Maximum bytes ssh_execute_to_local writes per command; the command is stopped once reached")
+
+	rootCmd.PersistentFlags().Int64Var(&maxTransferSize, "max-transfer-size", 100<<20,
+		"Maximum size in bytes of a file copied by ssh_upload")
+
+	rootCmd.PersistentFlags().StringVar(&stateFile, "state-file", "",
+		"File recording the connection ids in use (no hosts or secrets), so that ids lost by a restart are reported as such")
+
+	rootCmd.PersistentFlags().StringVar(&knownHosts, "known-hosts", "",
+		"OpenSSH known_hosts file to verify server host keys against; without it any host key is accepted")
+
 	// Set up cobra completion
 	rootCmd.CompletionOptions.DisableDefaultCmd = true
 }
@@ -76,6 +230,48 @@ func GetAllowedHosts() string {
 	return allowedHosts
 }
 
+// GetAllowedHostsFile returns the allowed hosts file flag value
+func GetAllowedHostsFile() string {
+	return allowedHostsFile
+}
+
+// NewHostValidator creates the host validator for the patterns given with
+// --allowed-hosts and --allowed-hosts-file, at least one of which is required
+func NewHostValidator() (*ssh.HostValidator, error) {
+	options := []ssh.ValidatorOption{ssh.WithCaseInsensitiveHosts(caseInsensitiveHosts)}
+	switch {
+	case allowedHostsFile != "":
+		return ssh.NewHostValidatorFromFile(allowedHostsFile, allowedHosts, options...)
+	case allowedHosts != "":
+		return ssh.NewHostValidator(allowedHosts, options...)
+	}
+	return nil, fmt.Errorf("--allowed-hosts or --allowed-hosts-file is required")
+}
+
+// NewCommandPolicy compiles the --command-allow and --command-deny patterns,
+// returning nil when there are none
+func NewCommandPolicy() (*mcp.CommandPolicy, error) {
+	if len(commandAllow) == 0 && len(commandDeny) == 0 {
+		return nil, nil
+	}
+	return mcp.NewCommandPolicy(commandAllow, commandDeny)
+}
+
+// GetCommandAllow returns the command allow patterns
+func GetCommandAllow() []string {
+	return commandAllow
+}
+
+// GetCommandDeny returns the command deny patterns
+func GetCommandDeny() []string {
+	return commandDeny
+}
+
+// GetCaseInsensitiveHosts returns the case insensitive hosts flag value
+func GetCaseInsensitiveHosts() bool {
+	return caseInsensitiveHosts
+}
+
 // GetLogLevel returns the log level flag value
 func GetLogLevel() string {
 	return logLevel
@@ -86,6 +282,176 @@ func GetLogFile() string {
 	return logFile
 }
 
+// GetIdleOutputThreshold returns the idle output threshold flag value
+func GetIdleOutputThreshold() time.Duration {
+	return idleOutputThreshold
+}
+
+// GetMaxConcurrentExecs returns the max concurrent execs flag value
+func GetMaxConcurrentExecs() int {
+	return maxConcurrentExecs
+}
+
+// GetExecQueueSize returns the exec queue size flag value
+func GetExecQueueSize() int {
+	return execQueueSize
+}
+
+// GetShutdownGrace returns the shutdown grace flag value
+func GetShutdownGrace() time.Duration {
+	return shutdownGrace
+}
+
+// GetTimeBudget returns the time budget flag value
+func GetTimeBudget() time.Duration {
+	return timeBudget
+}
+
+// GetMaxConnections returns the max connections flag value
+func GetMaxConnections() int {
+	return maxConnections
+}
+
+// GetMaxCommandsPerMinute returns the max commands per minute flag value
+func GetMaxCommandsPerMinute() int {
+	return maxCommandsPerMin
+}
+
+// GetMaxSessionLifetime returns the max session lifetime flag value
+func GetMaxSessionLifetime() time.Duration {
+	return maxSessionLifetime
+}
+
+// GetIdleTimeout returns the idle timeout flag value
+func GetIdleTimeout() time.Duration {
+	return idleTimeout
+}
+
+// GetKeepaliveInterval returns the keepalive interval flag value
+func GetKeepaliveInterval() time.Duration {
+	return keepaliveInterval
+}
+
+// GetAllowProxyCommand returns the allow proxy command flag value
+func GetAllowProxyCommand() bool {
+	return allowProxyCommand
+}
+
+// GetUseSSHConfig returns the use SSH config flag value
+func GetUseSSHConfig() bool {
+	return useSSHConfig
+}
+
+// GetStrictKeyPerms returns the strict key permissions flag value
+func GetStrictKeyPerms() bool {
+	return strictKeyPerms
+}
+
+// GetAllowSessionCommands returns the allow session commands flag value
+func GetAllowSessionCommands() bool {
+	return allowSessionCmds
+}
+
+// GetEnableListKeys returns the enable list keys flag value
+func GetEnableListKeys() bool {
+	return enableListKeys
+}
+
+// GetEnablePTY returns the enable PTY flag value
+func GetEnablePTY() bool {
+	return enablePTY
+}
+
+// GetTransport returns the transport flag value
+func GetTransport() string {
+	return transport
+}
+
+// GetListenAddr returns the listen address flag value
+func GetListenAddr() string {
+	return listenAddr
+}
+
+// GetMetricsAddr returns the metrics address flag value
+func GetMetricsAddr() string {
+	return metricsAddr
+}
+
+// GetHealthAddr returns the health address flag value
+func GetHealthAddr() string {
+	return healthAddr
+}
+
+// GetTLSCert returns the TLS certificate flag value
+func GetTLSCert() string {
+	return tlsCert
+}
+
+// GetTLSKey returns the TLS key flag value
+func GetTLSKey() string {
+	return tlsKey
+}
+
+// GetTLSClientCA returns the TLS client CA flag value
+func GetTLSClientCA() string {
+	return tlsClientCA
+}
+
+// GetAuthToken returns the auth token flag value, falling back to the
+// MCP_SSH_AUTH_TOKEN environment variable so the token can be kept out of
+// process listings
+func GetAuthToken() string {
+	if authToken != "" {
+		return authToken
+	}
+	return os.Getenv("MCP_SSH_AUTH_TOKEN")
+}
+
+// GetSFTPAllowedPaths returns the SFTP allowed paths flag value
+func GetSFTPAllowedPaths() string {
+	return sftpAllowedPaths
+}
+
+// GetSFTPDeniedPaths returns the SFTP denied paths flag value
+func GetSFTPDeniedPaths() string {
+	return sftpDeniedPaths
+}
+
+// GetOutputFilters returns the output filters flag value
+func GetOutputFilters() string {
+	return outputFilters
+}
+
+// GetAuditLog returns the audit log flag value
+func GetAuditLog() string {
+	return auditLog
+}
+
+// GetArtifactsDir returns the artifacts directory flag value
+func GetArtifactsDir() string {
+	return artifactsDir
+}
+
+// GetMaxLocalOutput returns the max local output flag value
+func GetMaxLocalOutput() int64 {
+	return maxLocalOutput
+}
+
+// GetMaxTransferSize returns the max transfer size flag value
+func GetMaxTransferSize() int64 {
+	return maxTransferSize
+}
+
+// GetStateFile returns the state file flag value
+func GetStateFile() string {
+	return stateFile
+}
+
+// GetKnownHosts returns the known_hosts file flag value
+func GetKnownHosts() string {
+	return knownHosts
+}
+
 // SetupLogger configures the logrus logger and returns a cleanup function
 func SetupLogger() (*logrus.Logger, func() error, error) {
 	logger := logrus.New()
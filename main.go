@@ -5,11 +5,14 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
+	"sort"
 	"syscall"
+	"time"
 
 	"github.com/denysvitali/mcp-ssh/cmd"
 	"github.com/denysvitali/mcp-ssh/pkg/mcp"
 	"github.com/denysvitali/mcp-ssh/pkg/ssh"
+	"github.com/denysvitali/mcp-ssh/pkg/sshconfig"
 	mcpgo "github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
 	"github.com/sirupsen/logrus"
@@ -19,6 +22,10 @@ var (
 	Version = "dev"
 )
 
+// httpShutdownTimeout bounds how long shutdown waits for HTTP requests in
+// progress once the SSH connections are closed
+const httpShutdownTimeout = 5 * time.Second
+
 func main() {
 	// Set up the server function
 	cmd.ServerFunc = runServer
@@ -41,27 +48,201 @@ func runServer() error {
 
 	logger.Info("Starting MCP SSH Server")
 
-	// Get allowed hosts
-	allowedHosts := cmd.GetAllowedHosts()
-	if allowedHosts == "" {
-		return fmt.Errorf("--allowed-hosts flag is required")
+	// TLS and authentication only apply to the HTTP transport; refuse them
+	// rather than letting operators believe stdio is protected by them
+	transport := cmd.GetTransport()
+	var httpOptions mcp.HTTPOptions
+	switch transport {
+	case mcp.TransportStdio:
+		if cmd.GetTLSCert() != "" || cmd.GetTLSKey() != "" || cmd.GetTLSClientCA() != "" || cmd.GetAuthToken() != "" {
+			return fmt.Errorf("--tls-cert, --tls-key, --tls-client-ca and --auth-token require --transport sse")
+		}
+	case mcp.TransportSSE:
+		httpOptions = mcp.HTTPOptions{
+			Addr:      cmd.GetListenAddr(),
+			AuthToken: cmd.GetAuthToken(),
+		}
+		if cmd.GetTLSCert() != "" || cmd.GetTLSKey() != "" || cmd.GetTLSClientCA() != "" {
+			httpOptions.TLS, err = mcp.NewTLSConfig(cmd.GetTLSCert(), cmd.GetTLSKey(), cmd.GetTLSClientCA())
+			if err != nil {
+				return fmt.Errorf("invalid TLS configuration: %w", err)
+			}
+		}
+	default:
+		return fmt.Errorf("invalid --transport '%s': must be %s or %s", transport, mcp.TransportStdio, mcp.TransportSSE)
+	}
+
+	if cmd.GetMaxConnections() < 1 {
+		return fmt.Errorf("invalid --max-connections %d: must be at least 1", cmd.GetMaxConnections())
 	}
 
 	// Create host validator
-	validator, err := ssh.NewHostValidator(allowedHosts)
+	validator, err := cmd.NewHostValidator()
 	if err != nil {
 		return fmt.Errorf("failed to create host validator: %w", err)
 	}
 
 	logger.WithFields(logrus.Fields{
-		"allowed_hosts": allowedHosts,
+		"allowed_hosts":      cmd.GetAllowedHosts(),
+		"allowed_hosts_file": cmd.GetAllowedHostsFile(),
+		"patterns":           validator.PatternCount(),
 	}).Info("Host validator initialized")
 
+	// Load connection presets
+	presets, presetErrs := ssh.LoadPresets(os.Environ(), validator)
+	for _, err := range presetErrs {
+		logger.WithError(err).Error("Invalid connection preset")
+	}
+	if len(presetErrs) > 0 {
+		return fmt.Errorf("%d invalid connection preset(s) in %s* environment variables", len(presetErrs), ssh.PresetEnvPrefix)
+	}
+	for _, preset := range presets {
+		logger.WithFields(logrus.Fields{
+			"preset":   preset.Name,
+			"host":     preset.Host,
+			"port":     preset.Port,
+			"username": preset.Username,
+			"auth":     preset.Auth(),
+		}).Info("Connection preset loaded")
+	}
+
+	// Create SFTP path policy
+	pathPolicy, err := ssh.NewPathPolicy(cmd.GetSFTPAllowedPaths(), cmd.GetSFTPDeniedPaths())
+	if err != nil {
+		return fmt.Errorf("failed to create SFTP path policy: %w", err)
+	}
+
+	// Create output filters
+	outputFilter, err := ssh.ParseOutputFilters(cmd.GetOutputFilters())
+	if err != nil {
+		return fmt.Errorf("invalid --output-filters: %w", err)
+	}
+
+	// Load the connection index kept across restarts
+	var connectionIndex *ssh.ConnectionIndex
+	if path := cmd.GetStateFile(); path != "" {
+		connectionIndex, err = ssh.LoadConnectionIndex(path)
+		if err != nil {
+			return fmt.Errorf("invalid --state-file: %w", err)
+		}
+		if lost := connectionIndex.Lost(); len(lost) > 0 {
+			logger.WithFields(logrus.Fields{
+				"connection_ids": lost,
+			}).Info("Connections open before the restart are gone")
+		}
+	}
+
+	// Load the known_hosts file host keys are verified against
+	var knownHosts *ssh.KnownHosts
+	if path := cmd.GetKnownHosts(); path != "" {
+		knownHosts, err = ssh.LoadKnownHosts(path)
+		if err != nil {
+			return fmt.Errorf("invalid --known-hosts: %w", err)
+		}
+	}
+
 	// Create SSH manager
-	sshManager := ssh.NewManager(validator)
+	sshManager := ssh.NewManager(validator,
+		ssh.WithMaxConnections(cmd.GetMaxConnections()),
+		ssh.WithIdleOutputThreshold(cmd.GetIdleOutputThreshold()),
+		ssh.WithAllowProxyCommand(cmd.GetAllowProxyCommand()),
+		ssh.WithAllowSessionCommands(cmd.GetAllowSessionCommands()),
+		ssh.WithAllowPTY(cmd.GetEnablePTY()),
+		ssh.WithStrictKeyPermissions(cmd.GetStrictKeyPerms()),
+		ssh.WithMaxConcurrentExecs(cmd.GetMaxConcurrentExecs(), cmd.GetExecQueueSize()),
+		ssh.WithPathPolicy(pathPolicy),
+		ssh.WithOutputFilter(outputFilter),
+		ssh.WithLocalOutput(cmd.GetArtifactsDir(), cmd.GetMaxLocalOutput()),
+		ssh.WithMaxTransferSize(cmd.GetMaxTransferSize()),
+		ssh.WithPresets(presets),
+		ssh.WithConnectionIndex(connectionIndex),
+		ssh.WithTimeBudget(cmd.GetTimeBudget()),
+		ssh.WithMaxCommandsPerMinute(cmd.GetMaxCommandsPerMinute()),
+		ssh.WithKnownHosts(knownHosts),
+		ssh.WithIdleTimeout(cmd.GetIdleTimeout(), func(info ssh.ConnectionInfo) {
+			logger.WithFields(logrus.Fields{
+				"connection_id": info.ID,
+				"host":          info.Host,
+				"idle":          time.Since(info.LastUsed).Round(time.Second).String(),
+			}).Info("Closed idle SSH connection")
+		}),
+		ssh.WithConnectRetryHook(func(id string, attempt int, wait time.Duration, err error) {
+			logger.WithError(err).WithFields(logrus.Fields{
+				"connection_id": id,
+				"attempt":       attempt,
+				"wait":          wait.String(),
+			}).Warn("SSH connection attempt failed, retrying")
+		}),
+		ssh.WithMaxSessionLifetime(cmd.GetMaxSessionLifetime(), func(info ssh.ConnectionInfo) {
+			logger.WithFields(logrus.Fields{
+				"connection_id": info.ID,
+				"host":          info.Host,
+				"age":           time.Since(info.Created).Round(time.Second).String(),
+				"reason":        "session expired",
+			}).Info("Closed SSH connection at its maximum session lifetime")
+		}),
+		ssh.WithKeepalive(cmd.GetKeepaliveInterval(), func(info ssh.ConnectionInfo, err error) {
+			entry := logger.WithError(err).WithFields(logrus.Fields{
+				"connection_id": info.ID,
+				"host":          info.Host,
+			})
+			if info.AutoReconnect {
+				entry.Warn("SSH connection keepalive failed, reconnecting on the next command")
+			} else {
+				entry.Warn("SSH connection keepalive failed, closed the connection")
+			}
+		}),
+	)
+
+	if knownHosts == nil {
+		logger.Warn("Host keys are not verified: connections may be intercepted; set --known-hosts to verify them")
+	}
+
+	if cmd.GetAllowProxyCommand() {
+		logger.Warn("Proxy commands are enabled: ssh_connect may run arbitrary local commands")
+	}
+
+	if transport == mcp.TransportSSE && httpOptions.AuthToken == "" && cmd.GetTLSClientCA() == "" {
+		logger.Warn("The sse transport does not authenticate clients: anyone reaching the listen address can use the SSH connections; set --auth-token or --tls-client-ca")
+	}
+
+	commandPolicy, err := cmd.NewCommandPolicy()
+	if err != nil {
+		return fmt.Errorf("failed to create command policy: %w", err)
+	}
+	if commandPolicy != nil {
+		logger.WithFields(logrus.Fields{
+			"allow": cmd.GetCommandAllow(),
+			"deny":  cmd.GetCommandDeny(),
+		}).Info("Command policy enabled")
+	}
+
+	handlerOptions := []mcp.HandlerOption{mcp.WithCommandPolicy(commandPolicy)}
+	if path := cmd.GetAuditLog(); path != "" {
+		auditLog, err := mcp.OpenAuditLog(path)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = auditLog.Close() }()
+		handlerOptions = append(handlerOptions, mcp.WithAuditLog(auditLog))
+		logger.WithField("audit_log", path).Info("Audit logging enabled")
+	}
+
+	if cmd.GetUseSSHConfig() {
+		path, err := sshconfig.DefaultPath()
+		if err != nil {
+			return err
+		}
+		sshConfig, err := sshconfig.Load(path)
+		if err != nil {
+			return err
+		}
+		handlerOptions = append(handlerOptions, mcp.WithSSHConfig(sshConfig))
+		logger.WithField("ssh_config", path).Info("Resolving host aliases from SSH config")
+	}
 
 	// Create MCP handlers
-	handlers := mcp.NewHandlers(sshManager, logger)
+	handlers := mcp.NewHandlers(sshManager, logger, handlerOptions...)
 
 	// Create MCP server
 	mcpServer := server.NewMCPServer(
@@ -76,26 +257,110 @@ func runServer() error {
 	connectTool := mcpgo.NewTool(
 		"ssh_connect",
 		mcpgo.WithDescription("Establish an SSH connection to a remote host"),
+		mcpgo.WithOutputSchema[mcp.ConnectResponse](),
 		mcpgo.WithString("connection_id",
 			mcpgo.Required(),
 			mcpgo.Description("Unique identifier for this connection"),
 		),
+		mcpgo.WithString("preset",
+			mcpgo.Description("Name of a connection preset defined by the server operator (see ssh_server_config). Supplies host, port, username and credentials, which must then be omitted."),
+		),
 		mcpgo.WithString("host",
-			mcpgo.Required(),
-			mcpgo.Description("Remote host address (hostname or IP)"),
+			mcpgo.Description("Remote host address (hostname or IP); required unless preset is given. With the server's --use-ssh-config, it may be a Host alias of ~/.ssh/config, whose HostName, User, Port and IdentityFile apply unless given explicitly."),
 		),
 		mcpgo.WithNumber("port",
 			mcpgo.Description("SSH port (default: 22)"),
 		),
 		mcpgo.WithString("username",
-			mcpgo.Required(),
-			mcpgo.Description("SSH username"),
+			mcpgo.Description("SSH username; required unless preset is given or the SSH config sets User for the host"),
 		),
 		mcpgo.WithString("password",
-			mcpgo.Description("SSH password (optional if using private_key_path)"),
+			mcpgo.Description("SSH password (optional if using private_key_path or use_agent)"),
 		),
 		mcpgo.WithString("private_key_path",
-			mcpgo.Description("Path to SSH private key file (optional if using password)"),
+			mcpgo.Description("Path to SSH private key file, or several comma-separated paths offered in order (optional if using password or use_agent)"),
+		),
+		mcpgo.WithArray("private_key_paths",
+			mcpgo.Description("Further private key files offered in order after private_key_path, like several IdentityFile lines. Of several keys, one that cannot be read or parsed is skipped with a warning as long as another key or authentication method remains (max 16 in all)"),
+			mcpgo.WithStringItems(),
+		),
+		mcpgo.WithString("private_key",
+			mcpgo.Description("SSH private key itself, PEM-encoded or as base64 of the PEM, for deployments without a key file. It is never written to disk. Cannot be combined with private_key_path."),
+		),
+		mcpgo.WithString("passphrase",
+			mcpgo.Description("Passphrase of an encrypted private key. Connecting with an encrypted key and no passphrase fails with an error saying one is required."),
+		),
+		mcpgo.WithBoolean("use_agent",
+			mcpgo.Description("Authenticate with the keys of the local SSH agent at $SSH_AUTH_SOCK. They are tried before private_key_path and password, which may be given as fallbacks. (default: false)"),
+		),
+		mcpgo.WithString("proxy_command",
+			mcpgo.Description("Local command whose stdin/stdout is used as the transport, like OpenSSH's ProxyCommand (%h, %p and %r are expanded). Requires --allow-proxy-command."),
+		),
+		mcpgo.WithArray("ciphers",
+			mcpgo.Description("Ciphers to offer instead of the defaults, in order of preference, e.g. [\"aes128-cbc\"] for a legacy appliance or [\"chacha20-poly1305@openssh.com\"] to restrict a hardened server. Unknown names are rejected with the list of supported ones."),
+			mcpgo.WithStringItems(),
+		),
+		mcpgo.WithArray("macs",
+			mcpgo.Description("MACs to offer instead of the defaults, in order of preference, e.g. [\"hmac-sha1\"]"),
+			mcpgo.WithStringItems(),
+		),
+		mcpgo.WithArray("kex_algorithms",
+			mcpgo.Description("Key exchange algorithms to offer instead of the defaults, in order of preference, e.g. [\"diffie-hellman-group14-sha1\"]"),
+			mcpgo.WithStringItems(),
+		),
+		mcpgo.WithString("jump_host",
+			mcpgo.Description("Bastion host to tunnel the connection through, like OpenSSH's ProxyJump. It must be in the allowed hosts too. Not supported with proxy_command."),
+		),
+		mcpgo.WithNumber("jump_port",
+			mcpgo.Description("SSH port of the jump host (default: 22)"),
+		),
+		mcpgo.WithString("jump_username",
+			mcpgo.Description("Username on the jump host (default: username)"),
+		),
+		mcpgo.WithString("jump_private_key_path",
+			mcpgo.Description("Private key for the jump host, which must not be encrypted. With use_agent, the agent's keys are tried first."),
+		),
+		mcpgo.WithString("on_conflict",
+			mcpgo.Description("What to do if connection_id is already in use: 'error' (default), 'reuse' the existing connection if host, port and username match, or 'replace' it with a new one"),
+			mcpgo.Enum(ssh.ConflictError, ssh.ConflictReuse, ssh.ConflictReplace),
+		),
+		mcpgo.WithBoolean("disable_history",
+			mcpgo.Description("Keep executed commands out of the remote shell history (unsets HISTFILE and sets HISTSIZE=0)"),
+		),
+		mcpgo.WithString("host_key_fingerprint",
+			mcpgo.Description("Expected SHA256 fingerprint of the server's host key (as returned by ssh_hostkey); the connection is refused if it does not match. With --known-hosts the key must also be listed there; without either, any host key is accepted."),
+		),
+		mcpgo.WithBoolean("auto_reconnect",
+			mcpgo.Description("Re-establish the connection when it drops, keeping the credentials in memory until the connection is closed. A command is retried on the new connection only if it never reached the old one; the new shell starts without the previous working directory and variables."),
+		),
+		mcpgo.WithBoolean("subshell_per_command",
+			mcpgo.Description("Run each command in a fresh child shell so that shell options (set -e, set -o pipefail), unexported variables such as IFS, functions and aliases do not leak into later commands. Only the working directory and exported variables carry over. Background jobs are not tracked by ssh_jobs."),
+		),
+		mcpgo.WithString("shell",
+			mcpgo.Description("POSIX shell to run commands in instead of the user's login shell, e.g. /bin/sh or bash, for hosts whose login shell is fish, csh or another shell commands cannot be run in. The login shell is replaced with it through exec before anything else is sent. (default: the login shell)"),
+		),
+		mcpgo.WithBoolean("skip_shell_init",
+			mcpgo.Description("Do not send the commands that turn off the shell's echo and prompt when it starts, for restricted or unusual shells that fail on them. They only matter with request_pty. (default: false)"),
+		),
+		mcpgo.WithBoolean("request_pty",
+			mcpgo.Description("Run the shell on a pseudo-terminal, for commands that require one, such as sudo prompting for a password or anything checking isatty. The terminal merges stderr into stdout, so stderr is always empty. Requires --enable-pty."),
+		),
+		mcpgo.WithNumber("time_budget_seconds",
+			mcpgo.Description("Cumulative command time the connection may use before further commands are rejected, at most the server's --time-budget (default: the server's budget, unlimited if none). See ssh_time_budget."),
+		),
+		mcpgo.WithString("log_level",
+			mcpgo.Description("Log level for operations on this connection, overriding the server's --log-level, e.g. 'debug' to troubleshoot a single host (default: the server's level)"),
+			mcpgo.Enum("trace", "debug", "info", "warn", "error"),
+		),
+		mcpgo.WithNumber("max_retries",
+			mcpgo.Description("Times to retry connecting after a transient failure: the connection refused or reset, the network unreachable, a timeout or a temporary DNS failure. Failed authentication, host key or allowed hosts checks are not retried. At most 10. (default: 0)"),
+		),
+		mcpgo.WithNumber("retry_backoff_ms",
+			mcpgo.Description("Milliseconds to wait before the first retry, doubled after each up to 30 seconds, at most 60000 (default: 500)"),
+		),
+		mcpgo.WithObject("tags",
+			mcpgo.Description("Labels for grouping connections, as an object of string values, e.g. {\"env\": \"prod\", \"role\": \"db\"}. Shown by ssh_list, which can filter on them with tag_filter."),
+			mcpgo.AdditionalProperties(map[string]any{"type": "string"}),
 		),
 	)
 
@@ -103,6 +368,7 @@ func runServer() error {
 	executeTool := mcpgo.NewTool(
 		"ssh_execute",
 		mcpgo.WithDescription("Execute a command on an active SSH connection. Environment variables and working directory persist between commands."),
+		mcpgo.WithOutputSchema[mcp.ExecuteResponse](),
 		mcpgo.WithString("connection_id",
 			mcpgo.Required(),
 			mcpgo.Description("Connection identifier"),
@@ -111,29 +377,684 @@ func runServer() error {
 			mcpgo.Required(),
 			mcpgo.Description("Command to execute"),
 		),
+		mcpgo.WithString("output_to",
+			mcpgo.Description("Remote file to write the command's stdout to instead of returning it; only the exit code, stderr and bytes written are returned"),
+		),
+		mcpgo.WithString("tee_to",
+			mcpgo.Description("Remote file to save the command's full stdout to while returning only a preview. Shell state changes made by the command do not persist in this mode."),
+		),
+		mcpgo.WithNumber("preview_bytes",
+			mcpgo.Description("Maximum stdout bytes returned when using tee_to (default: 65536)"),
+		),
+		mcpgo.WithBoolean("interleaved",
+			mcpgo.Description("Also return the output as an ordered list of stdout/stderr chunks preserving the order they were written in (default: false)"),
+		),
+		mcpgo.WithBoolean("combine_output",
+			mcpgo.Description("Redirect stderr to stdout (2>&1) so that stdout holds both in the order they were written, e.g. for build logs, and stderr is empty. Not supported with output_to, tee_to or idle_complete_seconds. (default: false)"),
+		),
+		mcpgo.WithBoolean("timing",
+			mcpgo.Description("Also return a timing breakdown: connect_wait_ms (waiting for other commands on the connection), exec_ms (command runtime) and read_ms (collecting remaining output) (default: false)"),
+		),
+		mcpgo.WithString("request_id",
+			mcpgo.Description("Caller-chosen identifier for this command, unique among commands in flight, so that it can be interrupted with ssh_cancel (not supported with output_to or tee_to)"),
+		),
+		mcpgo.WithNumber("idle_complete_seconds",
+			mcpgo.Description("For commands that go quiet instead of exiting, such as a server starting up: return once the command exits or has produced no output for this many seconds, whichever comes first. If it went quiet, completed_by_idle is true, exit_code is -1 and the command is left running with its further output discarded. The command runs in a separate session in the shell's working directory, so exported variables do not apply and shell state changes do not persist. Not supported with output_to, tee_to, request_id, hash_output or interleaved (max 600)"),
+		),
+		mcpgo.WithNumber("timeout_seconds",
+			mcpgo.Description("Give up on this command after this many seconds instead of the configured command timeout, e.g. for a long build; the command keeps running in the shell after a timeout (not supported with output_to, tee_to or idle_complete_seconds) (min 1, max 3600)"),
+		),
+		mcpgo.WithString("stdin",
+			mcpgo.Description("Input for the command's stdin, which ends after it, e.g. file contents for 'cat > file' or answers to prompts. Without it the command reads from the shell's own input and must not expect any. It is sent as is: no newline is added (not supported with output_to, tee_to or idle_complete_seconds)"),
+		),
+		mcpgo.WithString("cwd",
+			mcpgo.Description("Directory to run this command in, without changing the persistent shell's working directory. The command runs in a subshell, so changes it makes to shell state (cd, exported variables) do not persist. A leading '~' stands for the home directory; the rest is taken literally. If the directory cannot be entered, the command does not run and exit_code is non-zero (not supported with output_to, tee_to or idle_complete_seconds)"),
+		),
+		mcpgo.WithBoolean("hash_output",
+			mcpgo.Description("Also return stdout_sha256 and stdout_bytes, the SHA-256 and length of stdout exactly as the command wrote it, before output filters and trimming, for cheaply comparing output across runs or hosts (not supported with output_to or tee_to) (default: false)"),
+		),
+	)
+
+	// Define ssh_execute_stream tool
+	executeStreamTool := mcpgo.NewTool(
+		"ssh_execute_stream",
+		mcpgo.WithDescription("Execute a command on an active SSH connection like ssh_execute, sending each line of stdout as a progress notification as soon as it is written, so that long builds or deployments show progress. Notifications are only sent when the request carries a progress token. The response still holds the complete stdout, stderr and exit code."),
+		mcpgo.WithOutputSchema[mcp.ExecuteResponse](),
+		mcpgo.WithString("connection_id",
+			mcpgo.Required(),
+			mcpgo.Description("Connection identifier"),
+		),
+		mcpgo.WithString("command",
+			mcpgo.Required(),
+			mcpgo.Description("Command to execute"),
+		),
+		mcpgo.WithString("request_id",
+			mcpgo.Description("Caller-chosen identifier for this command, unique among commands in flight, so that it can be interrupted with ssh_cancel"),
+		),
+		mcpgo.WithNumber("timeout_seconds",
+			mcpgo.Description("Give up on this command after this many seconds instead of the configured command timeout (min 1, max 3600)"),
+		),
+	)
+
+	// Define ssh_execute_table tool
+	executeTableTool := mcpgo.NewTool(
+		"ssh_execute_table",
+		mcpgo.WithDescription("Execute a command on an active SSH connection and return its stdout split into rows (at newlines, skipping blank lines) and cells (at the delimiter, trimmed). Useful for tabular output such as df, ps, or CSV. With header, the first row names the columns and rows are returned as objects."),
+		mcpgo.WithOutputSchema[mcp.ExecuteTableResponse](),
+		mcpgo.WithString("connection_id",
+			mcpgo.Required(),
+			mcpgo.Description("Connection identifier"),
+		),
+		mcpgo.WithString("command",
+			mcpgo.Required(),
+			mcpgo.Description("Command to execute"),
+		),
+		mcpgo.WithString("delimiter",
+			mcpgo.Description("String separating cells, e.g. ',' or a tab (default: runs of whitespace)"),
+		),
+		mcpgo.WithBoolean("header",
+			mcpgo.Description("Use the first row as column names and return records instead of rows. Rows are split into at most as many cells as there are columns, so the last column keeps the rest of the line (default: false)"),
+		),
+	)
+
+	// Define ssh_execute_glob tool
+	executeGlobTool := mcpgo.NewTool(
+		"ssh_execute_glob",
+		mcpgo.WithDescription("Execute a command concurrently on every open connection whose host matches a glob (e.g. 'web-*.example.com'), returning per-connection results keyed by connection ID. Connections the command could not be run on are reported separately under failed; a non-zero exit code is a result, not a failure. Runs in each connection's persistent shell."),
+		mcpgo.WithOutputSchema[mcp.ExecuteGlobResponse](),
+		mcpgo.WithString("host_glob",
+			mcpgo.Required(),
+			mcpgo.Description("Glob matched case-insensitively against the host of each open connection: '*' matches any run of characters, '?' one character and '[...]' a character class"),
+		),
+		mcpgo.WithString("command",
+			mcpgo.Required(),
+			mcpgo.Description("Command to execute"),
+		),
+		mcpgo.WithNumber("parallelism",
+			mcpgo.Description(fmt.Sprintf("Number of connections to run the command on at once (default and max: %d)", ssh.MaxGlobParallelism)),
+		),
+	)
+
+	// Define ssh_cancel tool
+	cancelTool := mcpgo.NewTool(
+		"ssh_cancel",
+		mcpgo.WithDescription("Interrupt a command started by ssh_execute with a request_id. A running command's processes are sent SIGINT, like Ctrl-C, while the persistent shell survives; a command still waiting for its shell is dropped. Reports whether a matching in-flight command was found and cancelled."),
+		mcpgo.WithOutputSchema[mcp.CancelResponse](),
+		mcpgo.WithString("request_id",
+			mcpgo.Required(),
+			mcpgo.Description("The request_id given to ssh_execute"),
+		),
+	)
+
+	// Define ssh_interrupt tool
+	interruptTool := mcpgo.NewTool(
+		"ssh_interrupt",
+		mcpgo.WithDescription("Interrupt the command running on a connection, like Ctrl-C, whether or not it was given a request_id, e.g. a long ssh_execute_stream. Its processes are sent SIGINT while the persistent shell survives for the next command. Fails if no command is running."),
+		mcpgo.WithOutputSchema[mcp.InterruptResponse](),
+		mcpgo.WithString("connection_id",
+			mcpgo.Required(),
+			mcpgo.Description("Connection identifier"),
+		),
 	)
 
 	// Define ssh_close tool
 	closeTool := mcpgo.NewTool(
 		"ssh_close",
-		mcpgo.WithDescription("Close an active SSH connection"),
+		mcpgo.WithDescription("Close an active SSH connection. Background jobs started with '&' keep running on the remote host; list them with ssh_jobs and kill them first."),
+		mcpgo.WithOutputSchema[mcp.CloseResponse](),
 		mcpgo.WithString("connection_id",
 			mcpgo.Required(),
 			mcpgo.Description("Connection identifier to close"),
 		),
 	)
 
+	// Define ssh_reconnect tool
+	reconnectTool := mcpgo.NewTool(
+		"ssh_reconnect",
+		mcpgo.WithDescription("Re-establish a connection opened with auto_reconnect now, with a fresh shell, and clear its reconnect backoff. Use it to retry a connection that ssh_list reports as reconnect_state 'failed'. Commands running on the connection are aborted."),
+		mcpgo.WithOutputSchema[mcp.ReconnectResponse](),
+		mcpgo.WithString("connection_id",
+			mcpgo.Required(),
+			mcpgo.Description("Connection identifier"),
+		),
+	)
+
+	// Define ssh_hostkey tool
+	hostKeyTool := mcpgo.NewTool(
+		"ssh_hostkey",
+		mcpgo.WithDescription("Retrieve a server's host key type and SHA256 fingerprint without authenticating, so it can be verified and then pinned with host_key_fingerprint on ssh_connect"),
+		mcpgo.WithOutputSchema[mcp.HostKeyResponse](),
+		mcpgo.WithString("host",
+			mcpgo.Required(),
+			mcpgo.Description("Remote host address (hostname or IP)"),
+		),
+		mcpgo.WithNumber("port",
+			mcpgo.Description("SSH port (default: 22)"),
+		),
+	)
+
+	// Define ssh_tcp_check tool
+	tcpCheckTool := mcpgo.NewTool(
+		"ssh_tcp_check",
+		mcpgo.WithDescription("Check whether a host's port accepts TCP connections, without any SSH handshake, and report the connect latency. When ssh_connect fails, this tells a network problem (DNS, firewall, host down) apart from an SSH or authentication one."),
+		mcpgo.WithOutputSchema[mcp.TCPCheckResponse](),
+		mcpgo.WithString("host",
+			mcpgo.Required(),
+			mcpgo.Description("Remote host address (hostname or IP)"),
+		),
+		mcpgo.WithNumber("port",
+			mcpgo.Description("TCP port (default: 22)"),
+		),
+		mcpgo.WithNumber("timeout",
+			mcpgo.Description("Connect timeout in seconds (default: the SSH dial timeout, max 60)"),
+		),
+	)
+
+	// Define ssh_forget_credentials tool
+	forgetCredentialsTool := mcpgo.NewTool(
+		"ssh_forget_credentials",
+		mcpgo.WithDescription("Wipe the password and key path retained in memory for a connection. The live connection keeps working; re-authenticating it later requires supplying credentials again."),
+		mcpgo.WithOutputSchema[mcp.ForgetCredentialsResponse](),
+		mcpgo.WithString("connection_id",
+			mcpgo.Required(),
+			mcpgo.Description("Connection identifier"),
+		),
+	)
+
 	// Define ssh_list tool
 	listTool := mcpgo.NewTool(
 		"ssh_list",
 		mcpgo.WithDescription("List all active SSH connections"),
+		mcpgo.WithOutputSchema[mcp.ListResponse](),
+		mcpgo.WithObject("tag_filter",
+			mcpgo.Description("Only list the connections having every one of these tags with the same value, as given with tags on ssh_connect, e.g. {\"env\": \"prod\"}"),
+			mcpgo.AdditionalProperties(map[string]any{"type": "string"}),
+		),
+	)
+
+	// Define ssh_info tool
+	infoTool := mcpgo.NewTool(
+		"ssh_info",
+		mcpgo.WithDescription("Show one connection's details, as ssh_list does, and probe its live state: whether the server still answers a keepalive, and, unless a command is running, the shell's working directory and the remote OS (uname -s). The probe is not charged to the time budget."),
+		mcpgo.WithOutputSchema[mcp.InfoResponse](),
+		mcpgo.WithString("connection_id",
+			mcpgo.Required(),
+			mcpgo.Description("Connection identifier"),
+		),
+	)
+
+	// Define ssh_server_config tool
+	serverConfigTool := mcpgo.NewTool(
+		"ssh_server_config",
+		mcpgo.WithDescription("Show the effective server configuration (timeouts, limits, enabled tools, host key mode). Never includes secrets."),
+		mcpgo.WithOutputSchema[mcp.ServerConfigResponse](),
+	)
+
+	// Define ssh_shell_settings tool
+	shellSettingsTool := mcpgo.NewTool(
+		"ssh_shell_settings",
+		mcpgo.WithDescription("Show the persistent shell settings of a connection (history recording, HISTFILE, HISTSIZE, timeouts) and optionally disable history recording"),
+		mcpgo.WithOutputSchema[mcp.ShellSettingsResponse](),
+		mcpgo.WithString("connection_id",
+			mcpgo.Required(),
+			mcpgo.Description("Connection identifier"),
+		),
+		mcpgo.WithBoolean("disable_history",
+			mcpgo.Description("Disable history recording on the live shell before reporting (cannot be re-enabled)"),
+		),
+	)
+
+	// Define ssh_set_prefix tool
+	setPrefixTool := mcpgo.NewTool(
+		"ssh_set_prefix",
+		mcpgo.WithDescription("Set words placed before every subsequent command on a connection, e.g. 'timeout 60' or 'nice -n 19', or clear them with an empty prefix. The prefix covers the whole command, which runs in a child shell: only the working directory and exported variables carry over to later commands, as with subshell_per_command. It is kept across reconnects and shown by ssh_list."),
+		mcpgo.WithOutputSchema[mcp.SetPrefixResponse](),
+		mcpgo.WithString("connection_id",
+			mcpgo.Required(),
+			mcpgo.Description("Connection identifier"),
+		),
+		mcpgo.WithString("prefix",
+			mcpgo.Description("Plain words separated by spaces: letters, digits and -_./:=+,@% only, no quotes, variables, redirections or separators (default: empty, clearing the prefix)"),
+		),
+	)
+
+	// Define ssh_time_budget tool
+	timeBudgetTool := mcpgo.NewTool(
+		"ssh_time_budget",
+		mcpgo.WithDescription("Show, change or reset the cumulative command time budget of a connection. Once the time used reaches the budget, further commands are rejected with a 'time budget exhausted' error; ssh_execute responses carry a budget_warning once 80% is used. A budget enforced by the server (--time-budget) can only be lowered, never raised, removed or reset."),
+		mcpgo.WithOutputSchema[mcp.TimeBudgetResponse](),
+		mcpgo.WithString("connection_id",
+			mcpgo.Required(),
+			mcpgo.Description("Connection identifier"),
+		),
+		mcpgo.WithNumber("budget_seconds",
+			mcpgo.Description("New budget in seconds; 0 removes it, or restores the server's budget (optional, default: keep the current budget)"),
+		),
+		mcpgo.WithBoolean("reset",
+			mcpgo.Description("Reset the command time used to zero (default: false)"),
+		),
+	)
+
+	// Define ssh_sudo tool
+	sudoTool := mcpgo.NewTool(
+		"ssh_sudo",
+		mcpgo.WithDescription("Execute a command as root through sudo on an active SSH connection. Reports sudo authentication failures (e.g. a wrong password) separately from the command's own failure."),
+		mcpgo.WithOutputSchema[mcp.SudoResponse](),
+		mcpgo.WithString("connection_id",
+			mcpgo.Required(),
+			mcpgo.Description("Connection identifier"),
+		),
+		mcpgo.WithString("command",
+			mcpgo.Required(),
+			mcpgo.Description("Command to run as root"),
+		),
+		mcpgo.WithString("password",
+			mcpgo.Description("Password for sudo; without it sudo fails instead of prompting if a password is required"),
+		),
+		mcpgo.WithBoolean("strip_lecture",
+			mcpgo.Description("Remove the sudo lecture, password prompts and retry messages from stderr (default: true)"),
+		),
+	)
+
+	// Define ssh_exec_script tool
+	execScriptTool := mcpgo.NewTool(
+		"ssh_exec_script",
+		mcpgo.WithDescription("Run a multi-line script on an active SSH connection. The script is written to a temporary file on the remote, run with the interpreter and removed, so it behaves as a script file would; unlike ssh_execute, its working directory and variables do not carry over to later commands. Returns its combined stdout and stderr and its exit code."),
+		mcpgo.WithOutputSchema[mcp.ScriptResponse](),
+		mcpgo.WithString("connection_id",
+			mcpgo.Required(),
+			mcpgo.Description("Connection identifier"),
+		),
+		mcpgo.WithString("script",
+			mcpgo.Required(),
+			mcpgo.Description("Script to run, up to 512KB"),
+		),
+		mcpgo.WithString("interpreter",
+			mcpgo.Description("Program the script file is passed to, e.g. bash or python3: a single path made of letters, digits and '/._+-' (default: /bin/sh)"),
+		),
+		mcpgo.WithNumber("timeout_seconds",
+			mcpgo.Description("Time the script may run, between 1 and 3600 (default: the server's command timeout)"),
+		),
+	)
+
+	// Define ssh_run_workflow tool
+	runWorkflowTool := mcpgo.NewTool(
+		"ssh_run_workflow",
+		mcpgo.WithDescription("Run an ordered multi-host workflow. Each step runs a command on a connection once the steps it depends on have succeeded; independent steps run concurrently."),
+		mcpgo.WithOutputSchema[mcp.WorkflowResponse](),
+		mcpgo.WithArray("steps",
+			mcpgo.Required(),
+			mcpgo.Description("Workflow steps"),
+			mcpgo.Items(map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"id": map[string]any{
+						"type":        "string",
+						"description": "Unique step identifier",
+					},
+					"connection_id": map[string]any{
+						"type":        "string",
+						"description": "Connection to run the command on",
+					},
+					"command": map[string]any{
+						"type":        "string",
+						"description": "Command to execute",
+					},
+					"depends_on": map[string]any{
+						"type":        "array",
+						"items":       map[string]any{"type": "string"},
+						"description": "Ids of steps that must succeed before this one runs",
+					},
+				},
+				"required": []string{"id", "connection_id", "command"},
+			}),
+		),
+		mcpgo.WithString("on_failure",
+			mcpgo.Description("'stop' (default) to start no further steps after a failure, 'continue' to keep running steps that don't depend on the failed one"),
+			mcpgo.Enum("stop", "continue"),
+		),
+		mcpgo.WithNumber("deadline",
+			mcpgo.Description("Time budget in seconds for the whole workflow (max 3600). Each step's timeout is capped to the time left when it starts; once the budget is used up, remaining steps are skipped. Default: no overall budget."),
+		),
+	)
+
+	// Define ssh_git tool
+	gitTool := mcpgo.NewTool(
+		"ssh_git",
+		mcpgo.WithDescription("Run a git operation (status, pull, checkout, log) in a remote repository and return structured results: current branch, upstream, ahead/behind counts, changed files and commits. Runs 'git -C <repo_path>' so the shell's working directory is unchanged, and never prompts for credentials."),
+		mcpgo.WithOutputSchema[mcp.GitResponse](),
+		mcpgo.WithString("connection_id",
+			mcpgo.Required(),
+			mcpgo.Description("Connection identifier"),
+		),
+		mcpgo.WithString("repo_path",
+			mcpgo.Required(),
+			mcpgo.Description("Path of the repository on the remote host"),
+		),
+		mcpgo.WithString("operation",
+			mcpgo.Required(),
+			mcpgo.Description("Git operation to run"),
+			mcpgo.Enum(ssh.GitStatus, ssh.GitPull, ssh.GitCheckout, ssh.GitLog),
+		),
+		mcpgo.WithArray("args",
+			mcpgo.Description("Extra arguments passed to git after the operation, e.g. [\"main\"] for checkout or [\"-n\", \"5\"] for log (max 50)"),
+			mcpgo.WithStringItems(),
+		),
+	)
+
+	// Define ssh_command_stats tool
+	commandStatsTool := mcpgo.NewTool(
+		"ssh_command_stats",
+		mcpgo.WithDescription("Report the CPU and memory usage of the command currently running on a connection, e.g. a long build, by listing the processes it started. Can be called while ssh_execute is still running. cpu_percent is averaged over each process's lifetime; call twice and compare cpu_time_ms to see recent progress."),
+		mcpgo.WithOutputSchema[mcp.CommandStatsResponse](),
+		mcpgo.WithString("connection_id",
+			mcpgo.Required(),
+			mcpgo.Description("Connection identifier"),
+		),
+	)
+
+	// Define ssh_jobs tool
+	jobsTool := mcpgo.NewTool(
+		"ssh_jobs",
+		mcpgo.WithDescription("List the background jobs of a connection's persistent shell, i.e. commands started with '&' in earlier ssh_execute calls, with their process IDs and state. Background jobs keep running between commands and after ssh_close; kill them by PID when no longer needed."),
+		mcpgo.WithOutputSchema[mcp.JobsResponse](),
+		mcpgo.WithString("connection_id",
+			mcpgo.Required(),
+			mcpgo.Description("Connection identifier"),
+		),
+	)
+
+	// Define ssh_container_info tool
+	containerInfoTool := mcpgo.NewTool(
+		"ssh_container_info",
+		mcpgo.WithDescription("Detect the container runtimes installed on the remote host (checked in order: docker, podman, nerdctl) and report their versions, whether they are usable and how many containers are running"),
+		mcpgo.WithOutputSchema[mcp.ContainerInfoResponse](),
+		mcpgo.WithString("connection_id",
+			mcpgo.Required(),
+			mcpgo.Description("Connection identifier"),
+		),
+	)
+
+	// Define ssh_listening_ports tool
+	listeningPortsTool := mcpgo.NewTool(
+		"ssh_listening_ports",
+		mcpgo.WithDescription("List the TCP ports listening on the remote host (via ss, or netstat as a fallback) with their local address and, when visible to the user, the owning pid and program"),
+		mcpgo.WithOutputSchema[mcp.ListeningPortsResponse](),
+		mcpgo.WithString("connection_id",
+			mcpgo.Required(),
+			mcpgo.Description("Connection identifier"),
+		),
+	)
+
+	// Define ssh_read_files tool
+	readFilesTool := mcpgo.NewTool(
+		"ssh_read_files",
+		mcpgo.WithDescription("Read several remote files over SFTP in one call. Files are read concurrently; a file that cannot be read is reported with an error without failing the others."),
+		mcpgo.WithOutputSchema[mcp.ReadFilesResponse](),
+		mcpgo.WithString("connection_id",
+			mcpgo.Required(),
+			mcpgo.Description("Connection identifier"),
+		),
+		mcpgo.WithArray("paths",
+			mcpgo.Required(),
+			mcpgo.Description("Remote file paths to read (max 50)"),
+			mcpgo.WithStringItems(),
+		),
+		mcpgo.WithNumber("max_bytes",
+			mcpgo.Description("Maximum bytes returned per file (default: 262144, max: 1048576)"),
+		),
+	)
+
+	// Define ssh_ls tool
+	listDirTool := mcpgo.NewTool(
+		"ssh_ls",
+		mcpgo.WithDescription(fmt.Sprintf("List a remote directory over SFTP as structured entries (name, size, mode, modtime, is_dir, type), sorted by name, instead of parsing ls output. At most %d entries are returned.", ssh.MaxListDirEntries)),
+		mcpgo.WithOutputSchema[mcp.ListDirResponse](),
+		mcpgo.WithString("connection_id",
+			mcpgo.Required(),
+			mcpgo.Description("Connection identifier"),
+		),
+		mcpgo.WithString("path",
+			mcpgo.Description("Remote directory to list; relative paths are relative to the login directory (default: the login directory)"),
+		),
+	)
+
+	// Define ssh_watch tool
+	watchTool := mcpgo.NewTool(
+		"ssh_watch",
+		mcpgo.WithDescription("Wait for a remote file to be created, modified or deleted by polling its size and modification time over SFTP. Returns on the first change or when the timeout elapses."),
+		mcpgo.WithOutputSchema[mcp.WatchResponse](),
+		mcpgo.WithString("connection_id",
+			mcpgo.Required(),
+			mcpgo.Description("Connection identifier"),
+		),
+		mcpgo.WithString("path",
+			mcpgo.Required(),
+			mcpgo.Description("Remote file to watch; it does not need to exist yet"),
+		),
+		mcpgo.WithNumber("timeout",
+			mcpgo.Description("Seconds to wait for a change (default: 30, max: 600)"),
+		),
+		mcpgo.WithNumber("interval",
+			mcpgo.Description("Seconds between two polls (default: 1, min: 0.1)"),
+		),
+	)
+
+	// Define ssh_load_env tool
+	loadEnvTool := mcpgo.NewTool(
+		"ssh_load_env",
+		mcpgo.WithDescription("Read a remote dotenv (.env) file over SFTP and export its variables into the persistent shell of the connection. Returns the names of the loaded variables, never their values, and the lines that were skipped."),
+		mcpgo.WithOutputSchema[mcp.LoadEnvResponse](),
+		mcpgo.WithString("connection_id",
+			mcpgo.Required(),
+			mcpgo.Description("Connection identifier"),
+		),
+		mcpgo.WithString("path",
+			mcpgo.Required(),
+			mcpgo.Description("Remote dotenv file to load"),
+		),
+	)
+
+	// Define ssh_getenv tool
+	getEnvTool := mcpgo.NewTool(
+		"ssh_getenv",
+		mcpgo.WithDescription("Read the environment of the persistent shell of a connection, as the commands it runs see it, e.g. to find out why a command behaves differently than expected. With name, returns that variable's value and whether it is set at all; without, returns every variable."),
+		mcpgo.WithOutputSchema[mcp.GetEnvResponse](),
+		mcpgo.WithString("connection_id",
+			mcpgo.Required(),
+			mcpgo.Description("Connection identifier"),
+		),
+		mcpgo.WithString("name",
+			mcpgo.Description("Variable to read (default: all of them)"),
+		),
+	)
+
+	// Define ssh_setenv tool
+	setEnvTool := mcpgo.NewTool(
+		"ssh_setenv",
+		mcpgo.WithDescription("Export an environment variable into the persistent shell of a connection, so that later commands see it. The value is set literally, with no quoting needed and no variable or command expansion, which avoids the quoting pitfalls of running 'export' with ssh_execute. The value is neither logged nor returned."),
+		mcpgo.WithOutputSchema[mcp.SetEnvResponse](),
+		mcpgo.WithString("connection_id",
+			mcpgo.Required(),
+			mcpgo.Description("Connection identifier"),
+		),
+		mcpgo.WithString("name",
+			mcpgo.Required(),
+			mcpgo.Description("Variable name: a letter or underscore followed by letters, digits and underscores"),
+		),
+		mcpgo.WithString("value",
+			mcpgo.Required(),
+			mcpgo.Description("Value to set, taken literally; it may contain spaces, quotes, '$' and newlines"),
+		),
+	)
+
+	// Define ssh_capture tool
+	captureTool := mcpgo.NewTool(
+		"ssh_capture",
+		mcpgo.WithDescription("Run a command and store its full output server-side as an artifact. Returns the artifact id and a summary; fetch the output in pages with ssh_artifact_get. Artifacts expire after 30 minutes."),
+		mcpgo.WithOutputSchema[mcp.CaptureResponse](),
+		mcpgo.WithString("connection_id",
+			mcpgo.Required(),
+			mcpgo.Description("Connection identifier"),
+		),
+		mcpgo.WithString("command",
+			mcpgo.Required(),
+			mcpgo.Description("Command to execute"),
+		),
+	)
+
+	// Define ssh_artifact_get tool
+	artifactGetTool := mcpgo.NewTool(
+		"ssh_artifact_get",
+		mcpgo.WithDescription("Retrieve a page of a captured command's output"),
+		mcpgo.WithOutputSchema[mcp.ArtifactGetResponse](),
+		mcpgo.WithString("artifact_id",
+			mcpgo.Required(),
+			mcpgo.Description("Artifact identifier returned by ssh_capture"),
+		),
+		mcpgo.WithNumber("offset",
+			mcpgo.Description("Byte offset to start reading from (default: 0)"),
+		),
+		mcpgo.WithNumber("length",
+			mcpgo.Description("Maximum number of bytes to return (default: 65536, max: 1048576)"),
+		),
+		mcpgo.WithString("stream",
+			mcpgo.Description("Output stream to read (default: stdout)"),
+			mcpgo.Enum("stdout", "stderr"),
+		),
 	)
 
 	// Add tools to server
 	mcpServer.AddTool(connectTool, handlers.HandleConnect)
 	mcpServer.AddTool(executeTool, handlers.HandleExecute)
+	mcpServer.AddTool(executeStreamTool, handlers.HandleExecuteStream)
+	mcpServer.AddTool(executeTableTool, handlers.HandleExecuteTable)
+	mcpServer.AddTool(executeGlobTool, handlers.HandleExecuteGlob)
+	mcpServer.AddTool(cancelTool, handlers.HandleCancel)
+	mcpServer.AddTool(interruptTool, handlers.HandleInterrupt)
 	mcpServer.AddTool(closeTool, handlers.HandleClose)
+	mcpServer.AddTool(reconnectTool, handlers.HandleReconnect)
+	mcpServer.AddTool(hostKeyTool, handlers.HandleHostKey)
+	mcpServer.AddTool(tcpCheckTool, handlers.HandleTCPCheck)
+	mcpServer.AddTool(forgetCredentialsTool, handlers.HandleForgetCredentials)
 	mcpServer.AddTool(listTool, handlers.HandleList)
+	mcpServer.AddTool(infoTool, handlers.HandleInfo)
+	mcpServer.AddTool(serverConfigTool, handlers.HandleServerConfig)
+	mcpServer.AddTool(shellSettingsTool, handlers.HandleShellSettings)
+	mcpServer.AddTool(setPrefixTool, handlers.HandleSetPrefix)
+	mcpServer.AddTool(timeBudgetTool, handlers.HandleTimeBudget)
+	mcpServer.AddTool(sudoTool, handlers.HandleSudo)
+	mcpServer.AddTool(execScriptTool, handlers.HandleExecScript)
+	mcpServer.AddTool(runWorkflowTool, handlers.HandleRunWorkflow)
+	mcpServer.AddTool(gitTool, handlers.HandleGit)
+	mcpServer.AddTool(commandStatsTool, handlers.HandleCommandStats)
+	mcpServer.AddTool(jobsTool, handlers.HandleJobs)
+	mcpServer.AddTool(containerInfoTool, handlers.HandleContainerInfo)
+	mcpServer.AddTool(listeningPortsTool, handlers.HandleListeningPorts)
+	mcpServer.AddTool(readFilesTool, handlers.HandleReadFiles)
+	mcpServer.AddTool(listDirTool, handlers.HandleListDir)
+	mcpServer.AddTool(watchTool, handlers.HandleWatch)
+	mcpServer.AddTool(loadEnvTool, handlers.HandleLoadEnv)
+	mcpServer.AddTool(setEnvTool, handlers.HandleSetEnv)
+	mcpServer.AddTool(getEnvTool, handlers.HandleGetEnv)
+	mcpServer.AddTool(captureTool, handlers.HandleCapture)
+	mcpServer.AddTool(artifactGetTool, handlers.HandleArtifactGet)
+
+	if cmd.GetEnableListKeys() {
+		listKeysTool := mcpgo.NewTool(
+			"ssh_list_keys",
+			mcpgo.WithDescription("List the public keys loaded in the local SSH agent and the key files in ~/.ssh (type, SHA256 fingerprint, comment). Private key material is never returned."),
+			mcpgo.WithOutputSchema[mcp.ListKeysResponse](),
+		)
+		mcpServer.AddTool(listKeysTool, handlers.HandleListKeys)
+	}
+
+	// Define ssh_download tool
+	downloadTool := mcpgo.NewTool(
+		"ssh_download",
+		mcpgo.WithDescription(fmt.Sprintf("Copy a remote file over SFTP, either into a file on the machine running mcp-ssh below --artifacts-dir (local_path), or, for files up to %d bytes, returned base64-encoded in the response (inline) without touching the local filesystem.", ssh.MaxInlineDownloadSize)),
+		mcpgo.WithOutputSchema[mcp.TransferResponse](),
+		mcpgo.WithString("connection_id",
+			mcpgo.Required(),
+			mcpgo.Description("Connection identifier"),
+		),
+		mcpgo.WithString("remote_path",
+			mcpgo.Required(),
+			mcpgo.Description("Remote file to download; relative paths are relative to the login directory"),
+		),
+		mcpgo.WithString("local_path",
+			mcpgo.Description("File to write, relative to --artifacts-dir or absolute within it. Missing parent directories are created. Requires --artifacts-dir."),
+		),
+		mcpgo.WithBoolean("inline",
+			mcpgo.Description("Return the content base64-encoded as content_base64 instead of writing a local file (default: false)"),
+		),
+		mcpgo.WithBoolean("overwrite",
+			mcpgo.Description("Replace local_path if it already exists (default: false)"),
+		),
+	)
+	mcpServer.AddTool(downloadTool, handlers.HandleDownload)
+
+	if cmd.GetArtifactsDir() != "" {
+		executeToLocalTool := mcpgo.NewTool(
+			"ssh_execute_to_local",
+			mcpgo.WithDescription("Run a command and stream its stdout into a file on the machine running mcp-ssh, below --artifacts-dir, without returning the output. For archiving large output such as logs. Runs in a separate session started in the persistent shell's working directory; other shell state does not apply."),
+			mcpgo.WithOutputSchema[mcp.ExecuteToLocalResponse](),
+			mcpgo.WithString("connection_id",
+				mcpgo.Required(),
+				mcpgo.Description("Connection identifier"),
+			),
+			mcpgo.WithString("command",
+				mcpgo.Required(),
+				mcpgo.Description("Command to execute"),
+			),
+			mcpgo.WithString("local_path",
+				mcpgo.Required(),
+				mcpgo.Description("File to write, relative to --artifacts-dir or absolute within it. Missing parent directories are created."),
+			),
+			mcpgo.WithBoolean("overwrite",
+				mcpgo.Description("Replace local_path if it already exists (default: false)"),
+			),
+			mcpgo.WithNumber("timeout",
+				mcpgo.Description("Timeout in seconds (default: the command timeout, max 3600)"),
+			),
+		)
+		mcpServer.AddTool(executeToLocalTool, handlers.HandleExecuteToLocal)
+
+		uploadTool := mcpgo.NewTool(
+			"ssh_upload",
+			mcpgo.WithDescription("Copy a file from the machine running mcp-ssh, below --artifacts-dir, to the remote host over SFTP, e.g. a config file or script to run. Missing remote parent directories are created and the file's permission bits are preserved."),
+			mcpgo.WithOutputSchema[mcp.TransferResponse](),
+			mcpgo.WithString("connection_id",
+				mcpgo.Required(),
+				mcpgo.Description("Connection identifier"),
+			),
+			mcpgo.WithString("local_path",
+				mcpgo.Required(),
+				mcpgo.Description("File to upload, relative to --artifacts-dir or absolute within it"),
+			),
+			mcpgo.WithString("remote_path",
+				mcpgo.Required(),
+				mcpgo.Description("Remote file to write; relative paths are relative to the login directory"),
+			),
+			mcpgo.WithBoolean("overwrite",
+				mcpgo.Description("Replace remote_path if it already exists (default: false)"),
+			),
+		)
+		mcpServer.AddTool(uploadTool, handlers.HandleUpload)
+	}
+
+	toolNames := make([]string, 0, len(mcpServer.ListTools()))
+	for name := range mcpServer.ListTools() {
+		toolNames = append(toolNames, name)
+	}
+	sort.Strings(toolNames)
+
+	handlers.SetServerInfo(mcp.ServerInfo{
+		Version:   Version,
+		Transport: transport,
+		LogLevel:  cmd.GetLogLevel(),
+		Tools:     toolNames,
+	})
 
 	logger.Info("MCP tools registered")
 
@@ -141,6 +1062,35 @@ func runServer() error {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	var httpServer *mcp.HTTPServer
+	if transport == mcp.TransportSSE {
+		httpServer = mcp.NewHTTPServer(mcpServer, httpOptions)
+	}
+
+	var metricsServer *mcp.MetricsServer
+	if addr := cmd.GetMetricsAddr(); addr != "" {
+		metricsServer = mcp.NewMetricsServer(sshManager, addr)
+		err := metricsServer.Start(func(err error) {
+			logger.WithError(err).Error("Metrics server error")
+		})
+		if err != nil {
+			return fmt.Errorf("failed to start metrics server: %w", err)
+		}
+		logger.WithField("metrics_addr", metricsServer.Addr()).Info("Serving metrics at /metrics")
+	}
+
+	var healthServer *mcp.HealthServer
+	if addr := cmd.GetHealthAddr(); addr != "" {
+		healthServer = mcp.NewHealthServer(sshManager, addr)
+		err := healthServer.Start(func(err error) {
+			logger.WithError(err).Error("Health server error")
+		})
+		if err != nil {
+			return fmt.Errorf("failed to start health server: %w", err)
+		}
+		logger.WithField("health_addr", healthServer.Addr()).Info("Serving health probes at /healthz and /readyz")
+	}
+
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
 
@@ -150,18 +1100,83 @@ func runServer() error {
 			"signal": sig.String(),
 		}).Info("Received shutdown signal")
 
-		// Close all SSH connections
+		// No longer ready for new clients, though still alive until the
+		// connections are closed
+		if healthServer != nil {
+			healthServer.SetReady(false)
+		}
+
+		// Let running commands finish, then close all SSH connections
+		grace := cmd.GetShutdownGrace()
+		if grace > 0 {
+			logger.WithFields(logrus.Fields{
+				"grace":   grace.String(),
+				"running": sshManager.ExecStats().Running,
+			}).Info("Waiting for running commands to finish")
+		}
 		logger.Info("Closing all SSH connections")
-		sshManager.CloseAll()
+		if !sshManager.Shutdown(grace) {
+			logger.Warn("Shutdown grace period expired, aborting running commands")
+		}
+
+		// HTTP clients are disconnected only now, so that the results of
+		// commands finishing within the grace period still reach them
+		if httpServer != nil {
+			logger.Info("Closing the HTTP listener")
+			shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), httpShutdownTimeout)
+			if err := httpServer.Shutdown(shutdownCtx); err != nil {
+				logger.WithError(err).Warn("Failed to close HTTP connections cleanly")
+			}
+			shutdownCancel()
+		}
+
+		if metricsServer != nil {
+			shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), httpShutdownTimeout)
+			if err := metricsServer.Shutdown(shutdownCtx); err != nil {
+				logger.WithError(err).Warn("Failed to close the metrics server cleanly")
+			}
+			shutdownCancel()
+		}
+
+		if healthServer != nil {
+			shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), httpShutdownTimeout)
+			if err := healthServer.Shutdown(shutdownCtx); err != nil {
+				logger.WithError(err).Warn("Failed to close the health server cleanly")
+			}
+			shutdownCancel()
+		}
 
 		cancel()
 	}()
 
-	// Start MCP server with stdio transport
-	logger.Info("Starting MCP server on stdio transport")
-	if err := server.ServeStdio(mcpServer); err != nil {
-		logger.WithError(err).Error("Server error")
-		return err
+	if httpServer != nil {
+		logger.WithFields(logrus.Fields{
+			"listen_addr": httpOptions.Addr,
+			"tls":         httpOptions.TLS != nil,
+			"auth_token":  httpOptions.AuthToken != "",
+		}).Info("Starting MCP server on sse transport")
+		listener, err := httpServer.Listen()
+		if err != nil {
+			logger.WithError(err).Error("Server error")
+			return err
+		}
+		if healthServer != nil {
+			healthServer.SetReady(true)
+		}
+		if err := httpServer.Serve(listener); err != nil {
+			logger.WithError(err).Error("Server error")
+			return err
+		}
+	} else {
+		// Start MCP server with stdio transport
+		logger.Info("Starting MCP server on stdio transport")
+		if healthServer != nil {
+			healthServer.SetReady(true)
+		}
+		if err := server.ServeStdio(mcpServer); err != nil {
+			logger.WithError(err).Error("Server error")
+			return err
+		}
 	}
 
 	<-ctx.Done()
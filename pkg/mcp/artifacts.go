@@ -0,0 +1,282 @@
+package mcp
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// artifactTTL is how long captured output is kept
+	artifactTTL = 30 * time.Minute
+
+	// maxArtifactStoreBytes bounds the memory held by all artifacts; the
+	// oldest artifacts are evicted first when it is exceeded
+	maxArtifactStoreBytes = 100 * 1024 * 1024 // 100MB
+
+	// Page sizes for ssh_artifact_get
+	defaultArtifactPageBytes = 64 * 1024   // 64KB
+	maxArtifactPageBytes     = 1024 * 1024 // 1MB
+)
+
+// artifact is the stored output of a captured command
+type artifact struct {
+	id           string
+	connectionID string
+	stdout       string
+	stderr       string
+	exitCode     int
+	created      time.Time
+	expires      time.Time
+}
+
+// size returns the number of bytes held by the artifact
+func (a *artifact) size() int {
+	return len(a.stdout) + len(a.stderr)
+}
+
+// artifactStore keeps captured command output in memory until it expires
+type artifactStore struct {
+	artifacts map[string]*artifact
+	order     []string
+	bytes     int
+	mu        sync.Mutex
+}
+
+// newArtifactStore creates an empty artifact store
+func newArtifactStore() *artifactStore {
+	return &artifactStore{
+		artifacts: make(map[string]*artifact),
+	}
+}
+
+// add stores an artifact, assigning it an id and expiry
+func (s *artifactStore) add(a *artifact) (*artifact, error) {
+	id, err := newArtifactID()
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.expireLocked(time.Now())
+
+	a.id = id
+	a.created = time.Now()
+	a.expires = a.created.Add(artifactTTL)
+
+	// Evict the oldest artifacts until the new one fits
+	for len(s.order) > 0 && s.bytes+a.size() > maxArtifactStoreBytes {
+		s.removeLocked(s.order[0])
+	}
+
+	s.artifacts[id] = a
+	s.order = append(s.order, id)
+	s.bytes += a.size()
+
+	return a, nil
+}
+
+// get returns an artifact that has not expired
+func (s *artifactStore) get(id string) (*artifact, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.expireLocked(time.Now())
+
+	a, exists := s.artifacts[id]
+	return a, exists
+}
+
+// expireLocked drops all expired artifacts. The caller must hold s.mu.
+func (s *artifactStore) expireLocked(now time.Time) {
+	for len(s.order) > 0 {
+		a := s.artifacts[s.order[0]]
+		if now.Before(a.expires) {
+			return
+		}
+		s.removeLocked(a.id)
+	}
+}
+
+// removeLocked deletes an artifact. The caller must hold s.mu.
+func (s *artifactStore) removeLocked(id string) {
+	a, exists := s.artifacts[id]
+	if !exists {
+		return
+	}
+	delete(s.artifacts, id)
+	s.bytes -= a.size()
+	for i, other := range s.order {
+		if other == id {
+			s.order = append(s.order[:i], s.order[i+1:]...)
+			break
+		}
+	}
+}
+
+// newArtifactID returns a random artifact identifier
+func newArtifactID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate artifact id: %w", err)
+	}
+	return "art_" + hex.EncodeToString(b), nil
+}
+
+// artifactPage returns the part of data starting at offset, at most length
+// bytes long, without splitting a UTF-8 sequence at the end of the page
+func artifactPage(data string, offset, length int) string {
+	if offset >= len(data) {
+		return ""
+	}
+	end := offset + length
+	if end >= len(data) {
+		return data[offset:]
+	}
+	for end > offset && !utf8.RuneStart(data[end]) {
+		end--
+	}
+	if end == offset {
+		// A single sequence longer than the page; return it whole
+		end = offset + length
+	}
+	return data[offset:end]
+}
+
+// HandleCapture handles the ssh_capture tool
+func (h *Handlers) HandleCapture(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	connectionID, err := req.RequireString("connection_id")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	if err := validateConnectionID(connectionID); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	logger := h.connLogger(connectionID)
+
+	command, err := req.RequireString("command")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	if err := validateCommand(command); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	if err := h.checkCommandPolicy(logger, connectionID, command); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	logger.WithFields(logrus.Fields{
+		"connection_id": connectionID,
+		"command":       command,
+	}).Debug("Capturing SSH command output")
+
+	result, err := h.manager.Execute(connectionID, command)
+	if err != nil {
+		h.auditCommand(connectionID, command, 0, 0, 0, err)
+		logger.WithError(err).Error("Failed to execute SSH command")
+		return h.toolError("Failed to execute command", err)
+	}
+	h.auditCommand(connectionID, command, result.ExitCode, int64(len(result.Stdout)), int64(len(result.Stderr)), nil)
+
+	stored, err := h.artifacts.add(&artifact{
+		connectionID: connectionID,
+		stdout:       result.Stdout,
+		stderr:       result.Stderr,
+		exitCode:     result.ExitCode,
+	})
+	if err != nil {
+		logger.WithError(err).Error("Failed to store artifact")
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to store artifact: %v", err)), nil
+	}
+
+	logger.WithFields(logrus.Fields{
+		"artifact_id": stored.id,
+		"bytes":       stored.size(),
+	}).Debug("Command output captured")
+
+	response := CaptureResponse{
+		Success:     true,
+		ArtifactID:  stored.id,
+		ExitCode:    stored.exitCode,
+		StdoutBytes: len(stored.stdout),
+		StdoutLines: countLines(stored.stdout),
+		StderrBytes: len(stored.stderr),
+		StderrLines: countLines(stored.stderr),
+		ExpiresAt:   stored.expires.UTC().Format(time.RFC3339),
+	}
+
+	return h.toolResult(response)
+}
+
+// HandleArtifactGet handles the ssh_artifact_get tool
+func (h *Handlers) HandleArtifactGet(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	artifactID, err := req.RequireString("artifact_id")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	offset := int(req.GetFloat("offset", 0))
+	if offset < 0 {
+		return mcp.NewToolResultError("offset cannot be negative"), nil
+	}
+
+	length := int(req.GetFloat("length", defaultArtifactPageBytes))
+	if length < 1 || length > maxArtifactPageBytes {
+		return mcp.NewToolResultError(fmt.Sprintf("length must be between 1 and %d, got %d", maxArtifactPageBytes, length)), nil
+	}
+
+	stream := req.GetString("stream", "stdout")
+
+	stored, exists := h.artifacts.get(artifactID)
+	if !exists {
+		return mcp.NewToolResultError(fmt.Sprintf("artifact '%s' not found or expired", artifactID)), nil
+	}
+
+	var data string
+	switch stream {
+	case "stdout":
+		data = stored.stdout
+	case "stderr":
+		data = stored.stderr
+	default:
+		return mcp.NewToolResultError(fmt.Sprintf("stream must be 'stdout' or 'stderr', got '%s'", stream)), nil
+	}
+
+	page := artifactPage(data, offset, length)
+	nextOffset := offset + len(page)
+
+	response := ArtifactGetResponse{
+		Success:      true,
+		ArtifactID:   stored.id,
+		ConnectionID: stored.connectionID,
+		Stream:       stream,
+		Data:         page,
+		Offset:       offset,
+		NextOffset:   nextOffset,
+		TotalBytes:   len(data),
+		HasMore:      nextOffset < len(data),
+	}
+
+	return h.toolResult(response)
+}
+
+// countLines returns the number of lines in s
+func countLines(s string) int {
+	if s == "" {
+		return 0
+	}
+	return strings.Count(s, "\n") + 1
+}
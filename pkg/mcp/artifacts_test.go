@@ -0,0 +1,75 @@
+package mcp
+
+import (
+	"testing"
+	"time"
+)
+
+func TestArtifactPage(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		offset   int
+		length   int
+		expected string
+	}{
+		{
+			name:     "first page",
+			data:     "hello world",
+			offset:   0,
+			length:   5,
+			expected: "hello",
+		},
+		{
+			name:     "last page",
+			data:     "hello world",
+			offset:   6,
+			length:   100,
+			expected: "world",
+		},
+		{
+			name:     "offset past end",
+			data:     "hello",
+			offset:   10,
+			length:   5,
+			expected: "",
+		},
+		{
+			name:     "does not split multi-byte rune",
+			data:     "aé",
+			offset:   0,
+			length:   2,
+			expected: "a",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := artifactPage(tt.data, tt.offset, tt.length); got != tt.expected {
+				t.Errorf("artifactPage() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestArtifactStore_Expiry(t *testing.T) {
+	store := newArtifactStore()
+
+	stored, err := store.add(&artifact{stdout: "output"})
+	if err != nil {
+		t.Fatalf("failed to add artifact: %v", err)
+	}
+
+	if _, exists := store.get(stored.id); !exists {
+		t.Fatalf("expected artifact to exist")
+	}
+
+	stored.expires = time.Now().Add(-time.Second)
+
+	if _, exists := store.get(stored.id); exists {
+		t.Errorf("expected expired artifact to be removed")
+	}
+	if store.bytes != 0 {
+		t.Errorf("expected store to be empty, got %d bytes", store.bytes)
+	}
+}
@@ -0,0 +1,109 @@
+package mcp
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// AuditLog records every command an agent runs, by any tool, as a JSON line,
+// apart from the server log and regardless of its level. Output is not
+// recorded, only its size. It is safe for concurrent use.
+type AuditLog struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// auditEntry is a line of the audit log
+type auditEntry struct {
+	Time         time.Time `json:"time"`
+	ConnectionID string    `json:"connection_id"`
+	Host         string    `json:"host,omitempty"`
+	Username     string    `json:"username,omitempty"`
+	Command      string    `json:"command"`
+	ExitCode     *int      `json:"exit_code,omitempty"`
+	StdoutBytes  int64     `json:"stdout_bytes"`
+	StderrBytes  int64     `json:"stderr_bytes"`
+	Error        string    `json:"error,omitempty"`
+}
+
+// NewAuditLog creates an audit log writing to w
+func NewAuditLog(w io.Writer) *AuditLog {
+	return &AuditLog{w: w}
+}
+
+// OpenAuditLog opens the audit log file at path, created if needed, for
+// appending
+func OpenAuditLog(path string) (*AuditLog, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	return NewAuditLog(file), nil
+}
+
+// Close closes the file of an audit log opened with OpenAuditLog
+func (a *AuditLog) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if closer, ok := a.w.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// record writes an entry as a single line. Concurrent entries are written one
+// after the other so that lines never interleave.
+func (a *AuditLog) record(entry auditEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	_, err = a.w.Write(line)
+	return err
+}
+
+// WithAuditLog records the commands run by the tools subject to the command
+// policy in log
+func WithAuditLog(log *AuditLog) HandlerOption {
+	return func(h *Handlers) {
+		h.audit = log
+	}
+}
+
+// auditCommand records a command run on a connection, with its exit code and
+// output sizes if it completed or the error it failed with. Failing to write
+// the audit log is logged but does not fail the command, which already ran.
+func (h *Handlers) auditCommand(connectionID, command string, exitCode int, stdoutBytes, stderrBytes int64, err error) {
+	if h.audit == nil {
+		return
+	}
+
+	entry := auditEntry{
+		Time:         time.Now().UTC(),
+		ConnectionID: connectionID,
+		Command:      command,
+	}
+	if info, infoErr := h.manager.Info(connectionID); infoErr == nil {
+		entry.Host = info.Host
+		entry.Username = info.Username
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	} else {
+		entry.ExitCode = &exitCode
+		entry.StdoutBytes = stdoutBytes
+		entry.StderrBytes = stderrBytes
+	}
+
+	if err := h.audit.record(entry); err != nil {
+		h.connLogger(connectionID).WithError(err).Error("Failed to write audit log")
+	}
+}
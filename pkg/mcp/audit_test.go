@@ -0,0 +1,149 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestAuditLog_Execute(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	auditLog, err := OpenAuditLog(path)
+	if err != nil {
+		t.Fatalf("failed to open audit log: %v", err)
+	}
+	handlers := newTestHandlers(t, WithAuditLog(auditLog))
+
+	commands := []string{"uptime", "ls -la", "df -h"}
+	for _, command := range commands {
+		req := mcp.CallToolRequest{}
+		req.Params.Arguments = map[string]interface{}{"connection_id": "default", "command": command}
+		if _, err := handlers.HandleExecute(context.Background(), req); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	// Commands rejected before they run are not recorded
+	req := mcp.CallToolRequest{}
+	req.Params.Arguments = map[string]interface{}{"connection_id": "default", "command": ""}
+	if _, err := handlers.HandleExecute(context.Background(), req); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := auditLog.Close(); err != nil {
+		t.Fatalf("failed to close audit log: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read audit log: %v", err)
+	}
+
+	var entries []auditEntry
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		var entry auditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("invalid audit line %q: %v", scanner.Text(), err)
+		}
+		entries = append(entries, entry)
+	}
+	if len(entries) != len(commands) {
+		t.Fatalf("expected %d audit lines, got %d", len(commands), len(entries))
+	}
+	for i, entry := range entries {
+		if entry.Command != commands[i] || entry.ConnectionID != "default" || entry.Time.IsZero() {
+			t.Errorf("unexpected audit entry: %+v", entry)
+		}
+		// There is no such connection
+		if entry.ExitCode != nil || !strings.Contains(entry.Error, "not found") {
+			t.Errorf("expected the failure to be recorded, got %+v", entry)
+		}
+	}
+}
+
+func TestAuditLog_Tools(t *testing.T) {
+	var buf bytes.Buffer
+	handlers := newTestHandlers(t, WithAuditLog(NewAuditLog(&buf)))
+
+	tools := map[string]func(context.Context, mcp.CallToolRequest) (*mcp.CallToolResult, error){
+		"ssh_sudo":             handlers.HandleSudo,
+		"ssh_execute_stream":   handlers.HandleExecuteStream,
+		"ssh_capture":          handlers.HandleCapture,
+		"ssh_execute_table":    handlers.HandleExecuteTable,
+		"ssh_execute_to_local": handlers.HandleExecuteToLocal,
+	}
+	for name, handle := range tools {
+		req := mcp.CallToolRequest{}
+		req.Params.Arguments = map[string]interface{}{"connection_id": "default", "command": name, "local_path": "out.log"}
+		if _, err := handle(context.Background(), req); err != nil {
+			t.Fatalf("%s: unexpected error: %v", name, err)
+		}
+	}
+
+	audited := map[string]bool{}
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var entry auditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("invalid audit line %q: %v", scanner.Text(), err)
+		}
+		audited[entry.Command] = true
+	}
+	for name := range tools {
+		if !audited[name] {
+			t.Errorf("expected the command run by %s to be audited", name)
+		}
+	}
+}
+
+func TestAuditLog_Command(t *testing.T) {
+	var buf bytes.Buffer
+	handlers := newTestHandlers(t, WithAuditLog(NewAuditLog(&buf)))
+
+	handlers.auditCommand("default", "cat /etc/hostname", 1, 12, 3, nil)
+
+	var entry auditEntry
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("invalid audit line %q: %v", buf.String(), err)
+	}
+	if entry.ExitCode == nil || *entry.ExitCode != 1 || entry.StdoutBytes != 12 || entry.StderrBytes != 3 || entry.Error != "" {
+		t.Errorf("unexpected audit entry: %+v", entry)
+	}
+}
+
+func TestAuditLog_Concurrent(t *testing.T) {
+	var buf bytes.Buffer
+	auditLog := NewAuditLog(&buf)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			command := fmt.Sprintf("echo %d %s", i, strings.Repeat("x", 4096))
+			if err := auditLog.record(auditEntry{ConnectionID: "default", Command: command}); err != nil {
+				t.Errorf("failed to record: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 50 {
+		t.Fatalf("expected 50 lines, got %d", len(lines))
+	}
+	for _, line := range lines {
+		var entry auditEntry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Errorf("interleaved audit line: %v", err)
+		}
+	}
+}
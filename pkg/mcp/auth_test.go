@@ -0,0 +1,76 @@
+package mcp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequireBearerToken(t *testing.T) {
+	handler := RequireBearerToken("s3cret", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name           string
+		authorization  string
+		expectedStatus int
+	}{
+		{
+			name:           "valid token",
+			authorization:  "Bearer s3cret",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "wrong token",
+			authorization:  "Bearer guess",
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "missing header",
+			authorization:  "",
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "wrong scheme",
+			authorization:  "Basic s3cret",
+			expectedStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/sse", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			rec := httptest.NewRecorder()
+
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, rec.Code)
+			}
+		})
+	}
+}
+
+func TestRequireBearerToken_EmptyToken(t *testing.T) {
+	handler := RequireBearerToken("", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	for _, authorization := range []string{"Bearer ", "Bearer", ""} {
+		req := httptest.NewRequest(http.MethodGet, "/sse", nil)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		rec := httptest.NewRecorder()
+
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("expected %q to be rejected with an empty token, got status %d", authorization, rec.Code)
+		}
+	}
+}
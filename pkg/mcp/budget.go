@@ -0,0 +1,60 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/sirupsen/logrus"
+)
+
+// HandleTimeBudget handles the ssh_time_budget tool
+func (h *Handlers) HandleTimeBudget(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	connectionID, err := req.RequireString("connection_id")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	if err := validateConnectionID(connectionID); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	logger := h.connLogger(connectionID)
+
+	// A negative limit keeps the current budget
+	limit := time.Duration(-1)
+	if _, ok := req.GetArguments()["budget_seconds"]; ok {
+		seconds := req.GetFloat("budget_seconds", 0)
+		if seconds < 0 {
+			return mcp.NewToolResultError("budget_seconds cannot be negative"), nil
+		}
+		limit = time.Duration(seconds * float64(time.Second))
+	}
+	reset := req.GetBool("reset", false)
+
+	logger.WithFields(logrus.Fields{
+		"connection_id": connectionID,
+		"budget":        limit,
+		"reset":         reset,
+	}).Debug("Updating command time budget")
+
+	budget, err := h.manager.SetTimeBudget(connectionID, limit, reset)
+	if err != nil {
+		logger.WithError(err).Error("Failed to update command time budget")
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to update time budget: %v", err)), nil
+	}
+
+	response := TimeBudgetResponse{
+		Success:             true,
+		ConnectionID:        connectionID,
+		BudgetSeconds:       budget.Limit.Seconds(),
+		UsedSeconds:         budget.Used.Seconds(),
+		RemainingSeconds:    budget.Remaining().Seconds(),
+		ServerBudgetSeconds: budget.ServerLimit.Seconds(),
+		Exhausted:           budget.Exhausted(),
+		BudgetWarning:       budget.Warning(),
+	}
+
+	return h.toolResult(response)
+}
@@ -0,0 +1,115 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/sirupsen/logrus"
+)
+
+// HandleCancel handles the ssh_cancel tool
+func (h *Handlers) HandleCancel(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	requestID, err := req.RequireString("request_id")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	if err := validateRequestID(requestID); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	h.logger.WithFields(logrus.Fields{
+		"request_id": requestID,
+	}).Info("Cancelling SSH command")
+
+	result, err := h.manager.Cancel(requestID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to cancel SSH command")
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to cancel command: %v", err)), nil
+	}
+
+	var message string
+	switch {
+	case !result.Found:
+		message = "No command is in flight with this request_id; it may have already finished"
+	case !result.Started:
+		message = "The command had not started yet and will not run"
+	case len(result.Interrupted) > 0:
+		message = "Interrupted the command's processes; its ssh_execute call returns once the shell is ready"
+	default:
+		message = "The command is running but has no processes to interrupt; it runs only shell builtins"
+	}
+
+	h.logger.WithFields(logrus.Fields{
+		"found":       result.Found,
+		"started":     result.Started,
+		"interrupted": len(result.Interrupted),
+	}).Info(message)
+
+	pids := result.Interrupted
+	if pids == nil {
+		pids = []int{}
+	}
+
+	response := CancelResponse{
+		Success:         true,
+		RequestID:       requestID,
+		Found:           result.Found,
+		Cancelled:       result.Cancelled(),
+		InterruptedPIDs: pids,
+		Message:         message,
+	}
+
+	return h.toolResult(response)
+}
+
+// HandleInterrupt handles the ssh_interrupt tool
+func (h *Handlers) HandleInterrupt(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	connectionID, err := req.RequireString("connection_id")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	if err := validateConnectionID(connectionID); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	logger := h.connLogger(connectionID)
+
+	logger.WithFields(logrus.Fields{
+		"connection_id": connectionID,
+	}).Info("Interrupting SSH command")
+
+	result, err := h.manager.Interrupt(connectionID)
+	if err != nil {
+		logger.WithError(err).Error("Failed to interrupt SSH command")
+		return h.toolError("Failed to interrupt command", err)
+	}
+
+	message := "Interrupted the command's processes; its call returns once the shell is ready"
+	if len(result.Interrupted) == 0 {
+		message = "The command is running but has no processes to interrupt; it runs only shell builtins"
+	}
+
+	logger.WithFields(logrus.Fields{
+		"command":     result.Command,
+		"interrupted": len(result.Interrupted),
+	}).Info(message)
+
+	pids := result.Interrupted
+	if pids == nil {
+		pids = []int{}
+	}
+
+	response := InterruptResponse{
+		Success:         true,
+		ConnectionID:    connectionID,
+		Command:         result.Command,
+		RequestID:       result.RequestID,
+		InterruptedPIDs: pids,
+		Message:         message,
+	}
+
+	return h.toolResult(response)
+}
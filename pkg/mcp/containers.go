@@ -0,0 +1,61 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/sirupsen/logrus"
+)
+
+// HandleContainerInfo handles the ssh_container_info tool
+func (h *Handlers) HandleContainerInfo(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	connectionID, err := req.RequireString("connection_id")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	if err := validateConnectionID(connectionID); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	logger := h.connLogger(connectionID)
+
+	logger.WithFields(logrus.Fields{
+		"connection_id": connectionID,
+	}).Debug("Detecting container runtimes")
+
+	runtimes, err := h.manager.ContainerRuntimes(connectionID)
+	if err != nil {
+		logger.WithError(err).Error("Failed to detect container runtimes")
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to detect container runtimes: %v", err)), nil
+	}
+
+	response := ContainerInfoResponse{
+		Success:  true,
+		Runtimes: make([]ContainerRuntimeResponse, len(runtimes)),
+	}
+	for i, runtime := range runtimes {
+		entry := ContainerRuntimeResponse{
+			Name:          runtime.Name,
+			Path:          runtime.Path,
+			Version:       runtime.Version,
+			ServerVersion: runtime.ServerVersion,
+			Running:       runtime.Running,
+			Error:         runtime.Error,
+		}
+		if runtime.Running {
+			containers := runtime.Containers
+			entry.RunningContainers = &containers
+			if response.Active == "" {
+				response.Active = runtime.Name
+			}
+		}
+		if response.Found == "" {
+			response.Found = runtime.Name
+		}
+		response.Runtimes[i] = entry
+	}
+
+	return h.toolResult(response)
+}
@@ -0,0 +1,388 @@
+package mcp
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/denysvitali/mcp-ssh/pkg/ssh"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/sirupsen/logrus"
+)
+
+// maxReadFiles bounds the number of paths in a single ssh_read_files call
+const maxReadFiles = 50
+
+// readFilesArgs are the arguments of the ssh_read_files tool
+type readFilesArgs struct {
+	ConnectionID string   `json:"connection_id"`
+	Paths        []string `json:"paths"`
+	MaxBytes     int64    `json:"max_bytes"`
+}
+
+// HandleReadFiles handles the ssh_read_files tool
+func (h *Handlers) HandleReadFiles(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args readFilesArgs
+	if err := req.BindArguments(&args); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("invalid arguments: %v", err)), nil
+	}
+
+	if err := validateConnectionID(args.ConnectionID); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	logger := h.connLogger(args.ConnectionID)
+
+	if len(args.Paths) == 0 {
+		return mcp.NewToolResultError("paths cannot be empty"), nil
+	}
+	if len(args.Paths) > maxReadFiles {
+		return mcp.NewToolResultError(fmt.Sprintf("too many paths (max %d)", maxReadFiles)), nil
+	}
+
+	// Deduplicate while keeping the given order
+	seen := make(map[string]bool, len(args.Paths))
+	paths := make([]string, 0, len(args.Paths))
+	for _, path := range args.Paths {
+		if path == "" || strings.ContainsRune(path, 0) {
+			return mcp.NewToolResultError(fmt.Sprintf("invalid path %q", path)), nil
+		}
+		if !seen[path] {
+			seen[path] = true
+			paths = append(paths, path)
+		}
+	}
+
+	maxBytes := args.MaxBytes
+	if maxBytes <= 0 {
+		maxBytes = ssh.DefaultReadFileSize
+	}
+	if maxBytes > ssh.MaxReadFileSize {
+		return mcp.NewToolResultError(fmt.Sprintf("max_bytes must not exceed %d", ssh.MaxReadFileSize)), nil
+	}
+
+	logger.WithFields(logrus.Fields{
+		"connection_id": args.ConnectionID,
+		"paths":         len(paths),
+		"max_bytes":     maxBytes,
+	}).Debug("Reading remote files")
+
+	results, err := h.manager.ReadFiles(args.ConnectionID, paths, maxBytes)
+	if err != nil {
+		logger.WithError(err).Error("Failed to read remote files")
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to read files: %v", err)), nil
+	}
+
+	failed := 0
+	files := make(map[string]ReadFileResponse, len(results))
+	for _, result := range results {
+		if result.Error != "" {
+			failed++
+			files[result.Path] = ReadFileResponse{
+				Error: result.Error,
+			}
+			continue
+		}
+
+		file := ReadFileResponse{
+			Size:      &result.Size,
+			Truncated: &result.Truncated,
+		}
+		content := string(result.Content)
+		if !utf8.Valid(result.Content) {
+			content = base64.StdEncoding.EncodeToString(result.Content)
+			file.Encoding = "base64"
+		}
+		file.Content = &content
+		files[result.Path] = file
+	}
+
+	logger.WithFields(logrus.Fields{
+		"connection_id": args.ConnectionID,
+		"read":          len(results) - failed,
+		"failed":        failed,
+	}).Debug("Remote files read")
+
+	response := ReadFilesResponse{
+		Success: failed == 0,
+		Files:   files,
+		Read:    len(results) - failed,
+		Failed:  failed,
+	}
+
+	return h.toolResult(response)
+}
+
+// HandleListDir handles the ssh_ls tool
+func (h *Handlers) HandleListDir(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	connectionID, err := req.RequireString("connection_id")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	if err := validateConnectionID(connectionID); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	logger := h.connLogger(connectionID)
+
+	path := req.GetString("path", "")
+	if strings.ContainsRune(path, 0) {
+		return mcp.NewToolResultError(fmt.Sprintf("invalid path %q", path)), nil
+	}
+
+	logger.WithFields(logrus.Fields{
+		"connection_id": connectionID,
+		"path":          path,
+	}).Debug("Listing remote directory")
+
+	listing, err := h.manager.ListDir(connectionID, path)
+	if err != nil {
+		logger.WithError(err).Error("Failed to list remote directory")
+		return h.toolError("Failed to list directory", err)
+	}
+
+	entries := make([]DirEntryResponse, 0, len(listing.Entries))
+	for _, entry := range listing.Entries {
+		entries = append(entries, DirEntryResponse{
+			Name:    entry.Name,
+			Size:    entry.Size,
+			Mode:    fmt.Sprintf("%04o", uint32(entry.Mode.Perm())),
+			ModTime: entry.ModTime.UTC().Format(time.RFC3339),
+			IsDir:   entry.IsDir,
+			Type:    entry.Type,
+		})
+	}
+
+	return h.toolResult(ListDirResponse{
+		Success:   true,
+		Path:      listing.Path,
+		Entries:   entries,
+		Total:     listing.Total,
+		Truncated: listing.Truncated,
+	})
+}
+
+// HandleWatch handles the ssh_watch tool
+func (h *Handlers) HandleWatch(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	connectionID, err := req.RequireString("connection_id")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	if err := validateConnectionID(connectionID); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	logger := h.connLogger(connectionID)
+
+	path, err := req.RequireString("path")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	if strings.ContainsRune(path, 0) {
+		return mcp.NewToolResultError(fmt.Sprintf("invalid path %q", path)), nil
+	}
+
+	timeout := time.Duration(req.GetFloat("timeout", ssh.DefaultWatchTimeout.Seconds()) * float64(time.Second))
+	if timeout <= 0 || timeout > ssh.MaxWatchTimeout {
+		return mcp.NewToolResultError(fmt.Sprintf("timeout must be between 0 and %.0f seconds", ssh.MaxWatchTimeout.Seconds())), nil
+	}
+
+	interval := time.Duration(req.GetFloat("interval", ssh.DefaultWatchInterval.Seconds()) * float64(time.Second))
+	if interval < ssh.MinWatchInterval {
+		return mcp.NewToolResultError(fmt.Sprintf("interval must be at least %.1f seconds", ssh.MinWatchInterval.Seconds())), nil
+	}
+
+	logger.WithFields(logrus.Fields{
+		"connection_id": connectionID,
+		"path":          path,
+		"timeout":       timeout,
+		"interval":      interval,
+	}).Debug("Watching remote file")
+
+	change, err := h.manager.WatchFile(ctx, connectionID, path, timeout, interval)
+	if err != nil {
+		logger.WithError(err).Error("Failed to watch remote file")
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to watch file: %v", err)), nil
+	}
+
+	logger.WithFields(logrus.Fields{
+		"changed": change.Changed,
+		"change":  change.Change,
+	}).Debug("Finished watching remote file")
+
+	response := WatchResponse{
+		Success:       true,
+		Path:          path,
+		Changed:       change.Changed,
+		WaitedSeconds: change.Waited.Seconds(),
+		Before:        fileStateResponse(change.Before),
+		After:         fileStateResponse(change.After),
+	}
+	if change.Changed {
+		response.Change = change.Change
+	}
+
+	return h.toolResult(response)
+}
+
+// fileStateResponse converts a watched file state into its JSON representation
+func fileStateResponse(state ssh.FileState) FileStateResponse {
+	if !state.Exists {
+		return FileStateResponse{}
+	}
+	return FileStateResponse{
+		Exists:   true,
+		Size:     &state.Size,
+		Modified: state.ModTime.UTC().Format(time.RFC3339),
+	}
+}
+
+// HandleLoadEnv handles the ssh_load_env tool
+func (h *Handlers) HandleLoadEnv(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	connectionID, err := req.RequireString("connection_id")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	if err := validateConnectionID(connectionID); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	logger := h.connLogger(connectionID)
+
+	path, err := req.RequireString("path")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	if strings.ContainsRune(path, 0) {
+		return mcp.NewToolResultError(fmt.Sprintf("invalid path %q", path)), nil
+	}
+
+	logger.WithFields(logrus.Fields{
+		"connection_id": connectionID,
+		"path":          path,
+	}).Debug("Loading remote env file")
+
+	result, err := h.manager.LoadEnvFile(connectionID, path)
+	if err != nil {
+		logger.WithError(err).Error("Failed to load remote env file")
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to load env file: %v", err)), nil
+	}
+
+	// Only variable names are reported; values may be secrets
+	skipped := make([]EnvLineResponse, len(result.Skipped))
+	for i, line := range result.Skipped {
+		skipped[i] = EnvLineResponse{
+			Line:   line.Line,
+			Reason: line.Reason,
+		}
+	}
+
+	names := result.Names
+	if names == nil {
+		names = []string{}
+	}
+
+	response := LoadEnvResponse{
+		Success:   true,
+		Path:      path,
+		Loaded:    len(result.Names),
+		Variables: names,
+		Skipped:   skipped,
+	}
+
+	return h.toolResult(response)
+}
+
+// HandleSetEnv handles the ssh_setenv tool
+func (h *Handlers) HandleSetEnv(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	connectionID, err := req.RequireString("connection_id")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	if err := validateConnectionID(connectionID); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	logger := h.connLogger(connectionID)
+
+	name, err := req.RequireString("name")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	value, err := req.RequireString("value")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	// The value may be a secret and is not logged
+	logger.WithFields(logrus.Fields{
+		"connection_id": connectionID,
+		"name":          name,
+	}).Debug("Setting environment variable")
+
+	if err := h.manager.SetEnv(connectionID, name, value); err != nil {
+		logger.WithError(err).Error("Failed to set environment variable")
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to set environment variable: %v", err)), nil
+	}
+
+	response := SetEnvResponse{
+		Success:      true,
+		ConnectionID: connectionID,
+		Name:         name,
+		Message:      fmt.Sprintf("%s is now exported in the shell of '%s'", name, connectionID),
+	}
+
+	return h.toolResult(response)
+}
+
+// HandleGetEnv handles the ssh_getenv tool
+func (h *Handlers) HandleGetEnv(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	connectionID, err := req.RequireString("connection_id")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	if err := validateConnectionID(connectionID); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	logger := h.connLogger(connectionID)
+	name := req.GetString("name", "")
+
+	logger.WithFields(logrus.Fields{
+		"connection_id": connectionID,
+		"name":          name,
+	}).Debug("Reading environment")
+
+	env, err := h.manager.GetEnv(connectionID)
+	if err != nil {
+		logger.WithError(err).Error("Failed to read environment")
+		return h.toolError("Failed to read environment", err)
+	}
+
+	response := GetEnvResponse{
+		Success:      true,
+		ConnectionID: connectionID,
+	}
+	if name == "" {
+		count := len(env)
+		response.Variables = env
+		response.Count = &count
+		return h.toolResult(response)
+	}
+
+	value, set := env[name]
+	response.Name = name
+	response.Set = &set
+	if set {
+		response.Value = &value
+	}
+	return h.toolResult(response)
+}
@@ -0,0 +1,111 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/denysvitali/mcp-ssh/pkg/ssh"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/sirupsen/logrus"
+)
+
+// maxGitArgs bounds the number of extra arguments passed to git
+const maxGitArgs = 50
+
+// gitArgs are the arguments of the ssh_git tool
+type gitArgs struct {
+	ConnectionID string   `json:"connection_id"`
+	RepoPath     string   `json:"repo_path"`
+	Operation    string   `json:"operation"`
+	Args         []string `json:"args"`
+}
+
+// HandleGit handles the ssh_git tool
+func (h *Handlers) HandleGit(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args gitArgs
+	if err := req.BindArguments(&args); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("invalid arguments: %v", err)), nil
+	}
+
+	if err := validateConnectionID(args.ConnectionID); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	logger := h.connLogger(args.ConnectionID)
+	if args.RepoPath == "" {
+		return mcp.NewToolResultError("repo_path cannot be empty"), nil
+	}
+	if args.Operation == "" {
+		return mcp.NewToolResultError("operation cannot be empty"), nil
+	}
+	if len(args.Args) > maxGitArgs {
+		return mcp.NewToolResultError(fmt.Sprintf("too many args (max %d)", maxGitArgs)), nil
+	}
+
+	logger.WithFields(logrus.Fields{
+		"connection_id": args.ConnectionID,
+		"repo_path":     args.RepoPath,
+		"operation":     args.Operation,
+		"args":          args.Args,
+	}).Debug("Running git operation")
+
+	result, err := h.manager.Git(args.ConnectionID, args.RepoPath, args.Operation, args.Args)
+	if err != nil {
+		logger.WithError(err).Error("Failed to run git operation")
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to run git %s: %v", args.Operation, err)), nil
+	}
+
+	response := GitResponse{
+		Success:   true,
+		Operation: args.Operation,
+		ExitCode:  result.ExitCode,
+		Stderr:    result.Stderr,
+	}
+	if args.Operation != ssh.GitStatus && args.Operation != ssh.GitLog {
+		response.Stdout = result.Stdout
+	}
+	if result.Status != nil {
+		response.Status = gitStatusResponse(result.Status)
+	}
+	if args.Operation == ssh.GitLog && result.ExitCode == 0 {
+		response.Commits = make([]GitCommitResponse, len(result.Commits))
+		for i, commit := range result.Commits {
+			response.Commits[i] = GitCommitResponse{
+				Hash:        commit.Hash,
+				Author:      commit.Author,
+				AuthorEmail: commit.AuthorEmail,
+				Date:        commit.Date,
+				Subject:     commit.Subject,
+			}
+		}
+	}
+
+	return h.toolResult(response)
+}
+
+// gitStatusResponse converts a working tree status to its JSON form
+func gitStatusResponse(status *ssh.GitStatusInfo) *GitStatusResponse {
+	files := make([]GitFileResponse, len(status.Files))
+	for i, file := range status.Files {
+		files[i] = GitFileResponse{
+			Path:     file.Path,
+			Index:    file.Index,
+			Worktree: file.Worktree,
+			OrigPath: file.OrigPath,
+		}
+	}
+
+	response := &GitStatusResponse{
+		Branch:   status.Branch,
+		Commit:   status.Commit,
+		Detached: status.Detached,
+		Dirty:    status.Dirty(),
+		Files:    files,
+	}
+	if status.Upstream != "" {
+		response.Upstream = status.Upstream
+		response.Ahead = &status.Ahead
+		response.Behind = &status.Behind
+	}
+	return response
+}
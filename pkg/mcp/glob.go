@@ -0,0 +1,69 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/sirupsen/logrus"
+)
+
+// HandleExecuteGlob handles the ssh_execute_glob tool
+func (h *Handlers) HandleExecuteGlob(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	hostGlob, err := req.RequireString("host_glob")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	command, err := req.RequireString("command")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	if err := validateCommand(command); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	if err := h.checkCommandPolicy(h.logger, "", command); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	parallelism := req.GetInt("parallelism", 0)
+
+	h.logger.WithFields(logrus.Fields{
+		"host_glob":   hostGlob,
+		"command":     command,
+		"parallelism": parallelism,
+	}).Debug("Executing SSH command on matching hosts")
+
+	glob, err := h.manager.ExecuteGlob(hostGlob, command, parallelism)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to execute SSH command on matching hosts")
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to execute command: %v", err)), nil
+	}
+
+	response := ExecuteGlobResponse{
+		Success: true,
+		Matched: len(glob.Results) + len(glob.Failed),
+		Results: make(map[string]GlobHostResponse, len(glob.Results)),
+	}
+	for _, host := range glob.Results {
+		h.auditCommand(host.ConnectionID, command, host.Result.ExitCode, int64(len(host.Result.Stdout)), int64(len(host.Result.Stderr)), nil)
+		response.Results[host.ConnectionID] = GlobHostResponse{
+			Host:          host.Host,
+			Stdout:        host.Result.Stdout,
+			Stderr:        host.Result.Stderr,
+			ExitCode:      host.Result.ExitCode,
+			ReconnectInfo: reconnectInfo(host.Result),
+		}
+	}
+	if len(glob.Failed) > 0 {
+		response.Failed = make(map[string]GlobHostFailure, len(glob.Failed))
+		for _, host := range glob.Failed {
+			h.auditCommand(host.ConnectionID, command, 0, 0, 0, errors.New(host.Error))
+			response.Failed[host.ConnectionID] = GlobHostFailure{Host: host.Host, Error: host.Error}
+		}
+	}
+
+	return h.toolResult(response)
+}
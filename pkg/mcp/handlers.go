@@ -5,30 +5,94 @@ import (
 	"encoding/json"
 	"fmt"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/denysvitali/mcp-ssh/pkg/ssh"
+	"github.com/denysvitali/mcp-ssh/pkg/sshconfig"
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/sirupsen/logrus"
 )
 
+// ServerInfo describes the server-level settings reported by ssh_server_config
+type ServerInfo struct {
+	Version   string
+	Transport string
+	LogLevel  string
+	Tools     []string
+}
+
 // Handlers manages MCP tool handlers for SSH operations
 type Handlers struct {
-	manager *ssh.Manager
-	logger  *logrus.Logger
+	manager   *ssh.Manager
+	logger    *logrus.Logger
+	info      ServerInfo
+	artifacts *artifactStore
+
+	// policy restricts the commands that may be run, nil to allow all
+	policy *CommandPolicy
+
+	// audit records the commands run by ssh_execute, nil to record none
+	audit *AuditLog
+
+	// sshConfig resolves host aliases given to ssh_connect, nil for none
+	sshConfig *sshconfig.Config
+
+	// loggers are the loggers for connections overriding the log level, by
+	// level
+	loggers   map[logrus.Level]*logrus.Logger
+	loggersMu sync.Mutex
 }
 
 // NewHandlers creates a new handlers instance
-func NewHandlers(manager *ssh.Manager, logger *logrus.Logger) *Handlers {
+func NewHandlers(manager *ssh.Manager, logger *logrus.Logger, options ...HandlerOption) *Handlers {
 	if manager == nil {
 		panic("ssh.Manager cannot be nil")
 	}
 	if logger == nil {
 		panic("logger cannot be nil")
 	}
-	return &Handlers{
-		manager: manager,
-		logger:  logger,
+	h := &Handlers{
+		manager:   manager,
+		logger:    logger,
+		artifacts: newArtifactStore(),
+	}
+	for _, option := range options {
+		option(h)
+	}
+	return h
+}
+
+// SetServerInfo sets the server-level settings reported by ssh_server_config.
+// It must be called before the server starts serving requests.
+func (h *Handlers) SetServerInfo(info ServerInfo) {
+	h.info = info
+}
+
+// toolResult returns a tool response as structured content, along with its
+// JSON text for clients that do not read structured content
+func (h *Handlers) toolResult(response interface{}) (*mcp.CallToolResult, error) {
+	jsonResponse, err := json.Marshal(response)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to marshal response")
+		return mcp.NewToolResultError(fmt.Sprintf("Internal error: failed to marshal response: %v", err)), nil
+	}
+	return mcp.NewToolResultStructured(response, string(jsonResponse)), nil
+}
+
+// toolError returns the error result of a failed operation. An error with a
+// code (see ssh.ErrorCodeOf) is returned as an ErrorResponse carrying the code
+// as error_code, so that agents can tell failures apart.
+func (h *Handlers) toolError(message string, err error) (*mcp.CallToolResult, error) {
+	text := fmt.Sprintf("%s: %v", message, err)
+	code := ssh.ErrorCodeOf(err)
+	if code == "" {
+		return mcp.NewToolResultError(text), nil
 	}
+
+	result, err := h.toolResult(ErrorResponse{Error: text, ErrorCode: string(code)})
+	result.IsError = true
+	return result, err
 }
 
 // validateConnectionID validates the connection ID format
@@ -49,6 +113,15 @@ func validateConnectionID(id string) error {
 	return nil
 }
 
+// validateRequestID validates a caller-chosen request ID, which follows the
+// same rules as connection IDs
+func validateRequestID(id string) error {
+	if err := validateConnectionID(id); err != nil {
+		return fmt.Errorf("invalid request_id: %s", strings.TrimPrefix(err.Error(), "connection_id "))
+	}
+	return nil
+}
+
 // validatePort validates the port number
 func validatePort(port int) error {
 	if port < 1 || port > 65535 {
@@ -62,16 +135,19 @@ func validateCommand(cmd string) error {
 	if cmd == "" {
 		return fmt.Errorf("command cannot be empty")
 	}
-	if len(cmd) > 1048576 { // 1MB limit
+	if len(cmd) > ssh.MaxCommandSize {
 		return fmt.Errorf("command too long (max 1MB)")
 	}
 	return nil
 }
 
 // validateAuthMethod validates authentication method is provided
-func validateAuthMethod(password, privateKeyPath string) error {
-	if password == "" && privateKeyPath == "" {
-		return fmt.Errorf("either 'password' or 'private_key_path' must be provided")
+func validateAuthMethod(password, privateKeyPath, privateKey string, useAgent bool) error {
+	if password == "" && privateKeyPath == "" && privateKey == "" && !useAgent {
+		return fmt.Errorf("one of 'password', 'private_key_path', 'private_key' or 'use_agent' must be provided")
+	}
+	if privateKeyPath != "" && privateKey != "" {
+		return fmt.Errorf("'private_key' and 'private_key_path' cannot both be provided")
 	}
 	return nil
 }
@@ -89,71 +165,370 @@ func (h *Handlers) HandleConnect(ctx context.Context, req mcp.CallToolRequest) (
 		return mcp.NewToolResultError(err.Error()), nil
 	}
 
-	host, err := req.RequireString("host")
+	var params ssh.ConnectParams
+	if presetName := req.GetString("preset", ""); presetName != "" {
+		params, err = h.presetParams(connectionID, presetName, req)
+	} else {
+		params, err = h.connectParams(connectionID, req)
+	}
 	if err != nil {
 		return mcp.NewToolResultError(err.Error()), nil
 	}
 
+	logger := h.levelLogger(params.LogLevel)
+
+	logger.WithFields(logrus.Fields{
+		"connection_id": connectionID,
+		"host":          params.Host,
+		"port":          params.Port,
+		"username":      params.Username,
+		"via_proxy":     params.ProxyCommand != "",
+		"preset":        req.GetString("preset", ""),
+	}).Info("Attempting SSH connection")
+
+	// Establish connection
+	result, err := h.manager.Connect(params)
+	if err != nil {
+		logger.WithError(err).Error("Failed to establish SSH connection")
+		return h.toolError("Failed to connect", err)
+	}
+
+	message := "SSH connection established successfully"
+	switch {
+	case result.Reused:
+		message = "Reusing existing SSH connection"
+	case result.Replaced:
+		message = "SSH connection replaced successfully"
+	}
+
+	logger.Info(message)
+	for _, warning := range result.Warnings {
+		logger.Warn(warning)
+	}
+
+	if result.LostOnRestart {
+		message += ". Note: this connection id was in use before the server restarted; the previous connection and its shell state (working directory, variables, background jobs) are gone"
+	}
+
+	// Return success response
+	response := ConnectResponse{
+		Success:       true,
+		ConnectionID:  connectionID,
+		Host:          params.Host,
+		Port:          params.Port,
+		Username:      params.Username,
+		Reused:        result.Reused,
+		Replaced:      result.Replaced,
+		AutoReconnect: result.Info.AutoReconnect,
+		Message:       message,
+
+		ServerRestarted:    result.LostOnRestart,
+		HostKeyFingerprint: result.Info.HostKeyFingerprint,
+		Warnings:           result.Warnings,
+	}
+
+	return h.toolResult(response)
+}
+
+// connectParams reads the connection parameters of an ssh_connect call. With
+// an SSH config, the host may be an alias, whose settings fill in the
+// username, port and private key when they are not given.
+func (h *Handlers) connectParams(connectionID string, req mcp.CallToolRequest) (ssh.ConnectParams, error) {
+	host, err := req.RequireString("host")
+	if err != nil {
+		return ssh.ConnectParams{}, err
+	}
+
 	// Validate host is not empty after trim
 	if strings.TrimSpace(host) == "" {
-		return mcp.NewToolResultError("host cannot be empty"), nil
+		return ssh.ConnectParams{}, fmt.Errorf("host cannot be empty")
 	}
 
-	username, err := req.RequireString("username")
+	alias, err := h.sshConfigHost(host)
 	if err != nil {
-		return mcp.NewToolResultError(err.Error()), nil
+		return ssh.ConnectParams{}, err
+	}
+	if alias.HostName != "" {
+		host = alias.HostName
+	}
+
+	username := req.GetString("username", alias.User)
+	if _, set := req.GetArguments()["username"]; !set && alias.User == "" {
+		return ssh.ConnectParams{}, fmt.Errorf("required argument \"username\" not found")
 	}
 
 	// Validate username is not empty after trim
 	if strings.TrimSpace(username) == "" {
-		return mcp.NewToolResultError("username cannot be empty"), nil
+		return ssh.ConnectParams{}, fmt.Errorf("username cannot be empty")
 	}
 
 	// Optional parameters
 	port := int(req.GetFloat("port", 22))
+	if _, set := req.GetArguments()["port"]; !set && alias.Port != 0 {
+		port = alias.Port
+	}
 	if err := validatePort(port); err != nil {
-		return mcp.NewToolResultError(err.Error()), nil
+		return ssh.ConnectParams{}, err
 	}
 
 	password := req.GetString("password", "")
-	privateKeyPath := req.GetString("private_key_path", "")
+	keyPaths, err := parseKeyPaths(req)
+	if err != nil {
+		return ssh.ConnectParams{}, err
+	}
+	privateKey := req.GetString("private_key", "")
+	useAgent := req.GetBool("use_agent", false)
+
+	// The SSH config's key is only used when no credentials are given
+	if password == "" && len(keyPaths) == 0 && privateKey == "" && !useAgent {
+		if path := identityFile(alias); path != "" {
+			keyPaths = []string{path}
+		}
+	}
+	var privateKeyPath string
+	var morePaths []string
+	if len(keyPaths) > 0 {
+		privateKeyPath, morePaths = keyPaths[0], keyPaths[1:]
+	}
 
 	// Validate authentication method
-	if err := validateAuthMethod(password, privateKeyPath); err != nil {
-		return mcp.NewToolResultError(err.Error()), nil
+	if err := validateAuthMethod(password, privateKeyPath, privateKey, useAgent); err != nil {
+		return ssh.ConnectParams{}, err
 	}
 
-	h.logger.WithFields(logrus.Fields{
-		"connection_id": connectionID,
-		"host":          host,
-		"port":          port,
-		"username":      username,
-	}).Info("Attempting SSH connection")
+	passphrase := req.GetString("passphrase", "")
+	if passphrase != "" && privateKeyPath == "" && privateKey == "" {
+		return ssh.ConnectParams{}, fmt.Errorf("'passphrase' requires 'private_key_path' or 'private_key'")
+	}
 
-	// Establish connection
-	if err := h.manager.Connect(connectionID, host, port, username, password, privateKeyPath); err != nil {
-		h.logger.WithError(err).Error("Failed to establish SSH connection")
-		return mcp.NewToolResultError(fmt.Sprintf("Failed to connect: %v", err)), nil
+	jumpHost := strings.TrimSpace(req.GetString("jump_host", ""))
+	jumpPort := int(req.GetFloat("jump_port", 22))
+	if jumpHost == "" {
+		for _, name := range []string{"jump_port", "jump_username", "jump_private_key_path"} {
+			if _, set := req.GetArguments()[name]; set {
+				return ssh.ConnectParams{}, fmt.Errorf("'%s' requires 'jump_host'", name)
+			}
+		}
+	} else if err := validatePort(jumpPort); err != nil {
+		return ssh.ConnectParams{}, fmt.Errorf("jump host: %w", err)
 	}
 
-	h.logger.Info("SSH connection established successfully")
+	logLevel, err := parseLogLevel(req.GetString("log_level", ""))
+	if err != nil {
+		return ssh.ConnectParams{}, err
+	}
 
-	// Return success response
-	response := map[string]interface{}{
-		"success":       true,
-		"connection_id": connectionID,
-		"host":          host,
-		"port":          port,
-		"username":      username,
-		"message":       "SSH connection established successfully",
+	tags, err := parseTags(req, "tags")
+	if err != nil {
+		return ssh.ConnectParams{}, err
 	}
 
-	jsonResponse, err := json.Marshal(response)
+	maxRetries, retryBackoff, err := parseRetry(req)
 	if err != nil {
-		h.logger.WithError(err).Error("Failed to marshal response")
-		return mcp.NewToolResultError(fmt.Sprintf("Internal error: failed to marshal response: %v", err)), nil
+		return ssh.ConnectParams{}, err
+	}
+
+	ciphers, macs, keyExchanges, err := parseAlgorithms(req)
+	if err != nil {
+		return ssh.ConnectParams{}, err
+	}
+
+	return ssh.ConnectParams{
+		ID:              connectionID,
+		Host:            host,
+		Port:            port,
+		Username:        username,
+		Password:        password,
+		PrivateKeyPath:  privateKeyPath,
+		PrivateKeyPaths: morePaths,
+		PrivateKey:      privateKey,
+		Passphrase:      passphrase,
+		UseAgent:        useAgent,
+		ProxyCommand:    req.GetString("proxy_command", ""),
+		Ciphers:         ciphers,
+		MACs:            macs,
+		KeyExchanges:    keyExchanges,
+
+		JumpHost:           jumpHost,
+		JumpPort:           jumpPort,
+		JumpUsername:       req.GetString("jump_username", ""),
+		JumpPrivateKeyPath: req.GetString("jump_private_key_path", ""),
+
+		OnConflict:     req.GetString("on_conflict", ssh.ConflictError),
+		DisableHistory: req.GetBool("disable_history", false),
+		AutoReconnect:  req.GetBool("auto_reconnect", false),
+
+		SubshellPerCommand: req.GetBool("subshell_per_command", false),
+		RequestPTY:         req.GetBool("request_pty", false),
+		Shell:              req.GetString("shell", ""),
+		SkipShellInit:      req.GetBool("skip_shell_init", false),
+
+		HostKeyFingerprint: req.GetString("host_key_fingerprint", ""),
+
+		TimeBudget: time.Duration(req.GetFloat("time_budget_seconds", 0) * float64(time.Second)),
+
+		LogLevel: logLevel,
+		Tags:     tags,
+
+		MaxRetries:   maxRetries,
+		RetryBackoff: retryBackoff,
+	}, nil
+}
+
+// maxRetryBackoff bounds retry_backoff_ms
+const maxRetryBackoff = time.Minute
+
+// parseRetry reads how often and after how long a failed connection attempt
+// is retried
+func parseRetry(req mcp.CallToolRequest) (int, time.Duration, error) {
+	maxRetries := req.GetInt("max_retries", 0)
+	if maxRetries < 0 || maxRetries > ssh.MaxConnectRetries {
+		return 0, 0, fmt.Errorf("invalid max_retries %d: must be between 0 and %d", maxRetries, ssh.MaxConnectRetries)
 	}
-	return mcp.NewToolResultText(string(jsonResponse)), nil
+	backoff := time.Duration(req.GetFloat("retry_backoff_ms", 0) * float64(time.Millisecond))
+	if backoff < 0 || backoff > maxRetryBackoff {
+		return 0, 0, fmt.Errorf("invalid retry_backoff_ms: must be between 0 and %d", maxRetryBackoff.Milliseconds())
+	}
+	return maxRetries, backoff, nil
+}
+
+// maxKeyPaths is the number of private key files a connection may try
+const maxKeyPaths = 16
+
+// parseKeyPaths reads the private key files to try, in order: those of the
+// comma-separated private_key_path, then those of private_key_paths
+func parseKeyPaths(req mcp.CallToolRequest) ([]string, error) {
+	var paths []string
+	for _, path := range strings.Split(req.GetString("private_key_path", ""), ",") {
+		if path = strings.TrimSpace(path); path != "" {
+			paths = append(paths, path)
+		}
+	}
+
+	more, err := stringArray(req, "private_key_paths")
+	if err != nil {
+		return nil, err
+	}
+	paths = append(paths, more...)
+
+	if len(paths) > maxKeyPaths {
+		return nil, fmt.Errorf("too many private key paths (max %d)", maxKeyPaths)
+	}
+	return paths, nil
+}
+
+// stringArray reads an array parameter of non-empty strings, trimmed, nil if
+// it is not given
+func stringArray(req mcp.CallToolRequest, name string) ([]string, error) {
+	value, set := req.GetArguments()[name]
+	if !set {
+		return nil, nil
+	}
+	items, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("'%s' must be an array of strings", name)
+	}
+	values := make([]string, 0, len(items))
+	for _, item := range items {
+		text, ok := item.(string)
+		if !ok || strings.TrimSpace(text) == "" {
+			return nil, fmt.Errorf("'%s' must only hold non-empty strings", name)
+		}
+		values = append(values, strings.TrimSpace(text))
+	}
+	return values, nil
+}
+
+// parseAlgorithms reads the ciphers, MACs and key exchange algorithms to
+// offer instead of the defaults; the names are checked on connecting
+func parseAlgorithms(req mcp.CallToolRequest) ([]string, []string, []string, error) {
+	ciphers, err := stringArray(req, "ciphers")
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	macs, err := stringArray(req, "macs")
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	keyExchanges, err := stringArray(req, "kex_algorithms")
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return ciphers, macs, keyExchanges, nil
+}
+
+// maxTags is the number of tags a connection may have
+const maxTags = 32
+
+// parseTags reads an object parameter mapping tag names to string values
+func parseTags(req mcp.CallToolRequest, name string) (map[string]string, error) {
+	value, set := req.GetArguments()[name]
+	if !set || value == nil {
+		return nil, nil
+	}
+	object, ok := value.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("'%s' must be an object mapping tag names to values", name)
+	}
+	if len(object) > maxTags {
+		return nil, fmt.Errorf("'%s' has %d tags, at most %d are allowed", name, len(object), maxTags)
+	}
+
+	tags := make(map[string]string, len(object))
+	for key, value := range object {
+		if key == "" {
+			return nil, fmt.Errorf("'%s' has an empty tag name", name)
+		}
+		text, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("tag '%s' in '%s' must be a string", key, name)
+		}
+		tags[key] = text
+	}
+	return tags, nil
+}
+
+// presetParams reads the parameters of an ssh_connect call using a
+// connection preset. The target and credentials come from the preset; the
+// other options default to the preset's values.
+func (h *Handlers) presetParams(connectionID, presetName string, req mcp.CallToolRequest) (ssh.ConnectParams, error) {
+	for _, name := range []string{"host", "port", "username", "password", "private_key_path", "private_key_paths", "private_key", "passphrase", "use_agent", "proxy_command", "jump_host", "jump_port", "jump_username", "jump_private_key_path"} {
+		if _, set := req.GetArguments()[name]; set {
+			return ssh.ConnectParams{}, fmt.Errorf("'%s' cannot be combined with 'preset'", name)
+		}
+	}
+
+	preset, err := h.manager.Preset(presetName)
+	if err != nil {
+		return ssh.ConnectParams{}, err
+	}
+	params, err := preset.ConnectParams(connectionID)
+	if err != nil {
+		return ssh.ConnectParams{}, err
+	}
+
+	params.OnConflict = req.GetString("on_conflict", ssh.ConflictError)
+	params.DisableHistory = req.GetBool("disable_history", params.DisableHistory)
+	params.AutoReconnect = req.GetBool("auto_reconnect", params.AutoReconnect)
+	params.SubshellPerCommand = req.GetBool("subshell_per_command", params.SubshellPerCommand)
+	params.RequestPTY = req.GetBool("request_pty", false)
+	params.Shell = req.GetString("shell", "")
+	params.SkipShellInit = req.GetBool("skip_shell_init", false)
+	params.HostKeyFingerprint = req.GetString("host_key_fingerprint", params.HostKeyFingerprint)
+	params.TimeBudget = time.Duration(req.GetFloat("time_budget_seconds", 0) * float64(time.Second))
+	if params.LogLevel, err = parseLogLevel(req.GetString("log_level", "")); err != nil {
+		return ssh.ConnectParams{}, err
+	}
+	if params.Tags, err = parseTags(req, "tags"); err != nil {
+		return ssh.ConnectParams{}, err
+	}
+	if params.MaxRetries, params.RetryBackoff, err = parseRetry(req); err != nil {
+		return ssh.ConnectParams{}, err
+	}
+	if params.Ciphers, params.MACs, params.KeyExchanges, err = parseAlgorithms(req); err != nil {
+		return ssh.ConnectParams{}, err
+	}
+	return params, nil
 }
 
 // HandleExecute handles the ssh_execute tool
@@ -169,6 +544,8 @@ func (h *Handlers) HandleExecute(ctx context.Context, req mcp.CallToolRequest) (
 		return mcp.NewToolResultError(err.Error()), nil
 	}
 
+	logger := h.connLogger(connectionID)
+
 	command, err := req.RequireString("command")
 	if err != nil {
 		return mcp.NewToolResultError(err.Error()), nil
@@ -178,37 +555,299 @@ func (h *Handlers) HandleExecute(ctx context.Context, req mcp.CallToolRequest) (
 	if err := validateCommand(command); err != nil {
 		return mcp.NewToolResultError(err.Error()), nil
 	}
+	if err := h.checkCommandPolicy(logger, connectionID, command); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
 
-	h.logger.WithFields(logrus.Fields{
+	outputTo := req.GetString("output_to", "")
+	teeTo := req.GetString("tee_to", "")
+	if outputTo != "" && teeTo != "" {
+		return mcp.NewToolResultError("'output_to' and 'tee_to' are mutually exclusive"), nil
+	}
+
+	requestID := req.GetString("request_id", "")
+	if requestID != "" {
+		if err := validateRequestID(requestID); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		if outputTo != "" || teeTo != "" {
+			return mcp.NewToolResultError("'request_id' cannot be combined with 'output_to' or 'tee_to'"), nil
+		}
+	}
+
+	hashOutput := req.GetBool("hash_output", false)
+	if hashOutput && (outputTo != "" || teeTo != "") {
+		return mcp.NewToolResultError("'hash_output' cannot be combined with 'output_to' or 'tee_to'"), nil
+	}
+
+	idleComplete := time.Duration(req.GetFloat("idle_complete_seconds", 0) * float64(time.Second))
+	if idleComplete < 0 || idleComplete > ssh.MaxIdleComplete {
+		return mcp.NewToolResultError(fmt.Sprintf("idle_complete_seconds must be between 0 and %.0f", ssh.MaxIdleComplete.Seconds())), nil
+	}
+	if idleComplete > 0 && (outputTo != "" || teeTo != "" || requestID != "" || hashOutput || req.GetBool("interleaved", false)) {
+		return mcp.NewToolResultError("'idle_complete_seconds' cannot be combined with 'output_to', 'tee_to', 'request_id', 'hash_output' or 'interleaved'"), nil
+	}
+
+	var stdin *string
+	if value, ok := req.GetArguments()["stdin"].(string); ok {
+		if outputTo != "" || teeTo != "" || idleComplete > 0 {
+			return mcp.NewToolResultError("'stdin' cannot be combined with 'output_to', 'tee_to' or 'idle_complete_seconds'"), nil
+		}
+		stdin = &value
+	}
+
+	combineOutput := req.GetBool("combine_output", false)
+	if combineOutput && (outputTo != "" || teeTo != "" || idleComplete > 0) {
+		return mcp.NewToolResultError("'combine_output' cannot be combined with 'output_to', 'tee_to' or 'idle_complete_seconds'"), nil
+	}
+
+	cwd := req.GetString("cwd", "")
+	if cwd != "" && (outputTo != "" || teeTo != "" || idleComplete > 0) {
+		return mcp.NewToolResultError("'cwd' cannot be combined with 'output_to', 'tee_to' or 'idle_complete_seconds'"), nil
+	}
+
+	// Zero keeps the configured command timeout
+	var timeout time.Duration
+	if _, ok := req.GetArguments()["timeout_seconds"]; ok {
+		seconds := req.GetFloat("timeout_seconds", 0)
+		if seconds < 1 || seconds > ssh.MaxCommandTimeout.Seconds() {
+			return mcp.NewToolResultError(fmt.Sprintf("timeout_seconds must be between 1 and %.0f", ssh.MaxCommandTimeout.Seconds())), nil
+		}
+		if outputTo != "" || teeTo != "" || idleComplete > 0 {
+			return mcp.NewToolResultError("'timeout_seconds' cannot be combined with 'output_to', 'tee_to' or 'idle_complete_seconds'"), nil
+		}
+		timeout = time.Duration(seconds * float64(time.Second))
+	}
+
+	logger.WithFields(logrus.Fields{
 		"connection_id": connectionID,
 		"command":       command,
+		"output_to":     outputTo,
+		"tee_to":        teeTo,
+		"request_id":    requestID,
+		"timeout":       timeout,
+		"stdin":         stdin != nil,
+		"cwd":           cwd,
 	}).Debug("Executing SSH command")
 
-	// Execute command
-	result, err := h.manager.Execute(connectionID, command)
+	if outputTo != "" {
+		return h.executeToFile(ctx, connectionID, command, outputTo)
+	}
+	if teeTo != "" {
+		previewBytes := int(req.GetFloat("preview_bytes", ssh.DefaultTeePreviewBytes))
+		return h.executeTee(ctx, connectionID, command, teeTo, previewBytes)
+	}
+	if idleComplete > 0 {
+		return h.executeUntilIdle(ctx, connectionID, command, idleComplete)
+	}
+
+	// Execute command, interrupting it should the client cancel the request
+	opts := ssh.ExecuteOptions{
+		Context:       ctx,
+		CaptureChunks: req.GetBool("interleaved", false),
+		CombineOutput: combineOutput,
+		RequestID:     requestID,
+		HashStdout:    hashOutput,
+		Timeout:       timeout,
+		Stdin:         stdin,
+		Cwd:           cwd,
+	}
+	result, err := h.manager.ExecuteWithOptions(connectionID, command, opts)
 	if err != nil {
-		h.logger.WithError(err).Error("Failed to execute SSH command")
-		return mcp.NewToolResultError(fmt.Sprintf("Failed to execute command: %v", err)), nil
+		h.auditCommand(connectionID, command, 0, 0, 0, err)
+		logger.WithError(err).Error("Failed to execute SSH command")
+		return h.toolError("Failed to execute command", err)
 	}
+	h.auditCommand(connectionID, command, result.ExitCode, int64(len(result.Stdout)), int64(len(result.Stderr)), nil)
 
-	h.logger.WithFields(logrus.Fields{
+	logger.WithFields(logrus.Fields{
 		"exit_code": result.ExitCode,
+		"truncated": result.Truncated,
 	}).Debug("Command executed successfully")
 
 	// Return result
-	response := map[string]interface{}{
-		"success":   true,
-		"stdout":    result.Stdout,
-		"stderr":    result.Stderr,
-		"exit_code": result.ExitCode,
+	response := ExecuteResponse{
+		Success:       true,
+		Stdout:        result.Stdout,
+		Stderr:        result.Stderr,
+		ExitCode:      result.ExitCode,
+		RequestID:     requestID,
+		Cancelled:     result.Cancelled,
+		StartedAt:     timestamp(result.StartedAt),
+		FinishedAt:    timestamp(result.FinishedAt),
+		ReconnectInfo: reconnectInfo(result),
+	}
+	if opts.CaptureChunks {
+		response.Output = chunksResponse(result.Chunks)
+	}
+	if opts.HashStdout {
+		response.StdoutSHA256 = result.StdoutSHA256
+		response.StdoutBytes = &result.StdoutBytes
+	}
+	if result.Truncated {
+		response.Truncated = &result.Truncated
+	}
+	if req.GetBool("timing", false) {
+		response.Timing = &TimingResponse{
+			ConnectWaitMS: result.Timing.LockWait.Milliseconds(),
+			ExecMS:        result.Timing.Exec.Milliseconds(),
+			ReadMS:        result.Timing.Read.Milliseconds(),
+		}
 	}
 
-	jsonResponse, err := json.Marshal(response)
+	response.BudgetWarning = h.budgetWarning(connectionID)
+
+	return h.toolResult(response)
+}
+
+// budgetWarning returns the warning of a connection whose time budget is
+// running out, empty otherwise
+func (h *Handlers) budgetWarning(connectionID string) string {
+	budget, err := h.manager.TimeBudget(connectionID)
 	if err != nil {
-		h.logger.WithError(err).Error("Failed to marshal response")
-		return mcp.NewToolResultError(fmt.Sprintf("Internal error: failed to marshal response: %v", err)), nil
+		return ""
 	}
-	return mcp.NewToolResultText(string(jsonResponse)), nil
+	return budget.Warning()
+}
+
+// timestampFormat is RFC 3339 with millisecond precision
+const timestampFormat = "2006-01-02T15:04:05.000Z07:00"
+
+// timestamp formats t in UTC, empty for the zero time
+func timestamp(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(timestampFormat)
+}
+
+// reconnectInfo flags a response whose command ran on a re-established
+// connection, since the shell state the agent may rely on is gone
+func reconnectInfo(result *ssh.CommandResult) ReconnectInfo {
+	if !result.Reconnected {
+		return ReconnectInfo{}
+	}
+	return ReconnectInfo{
+		Reconnected: true,
+		ReconnectWarning: "The connection dropped and was re-established before the command ran; " +
+			"the shell was restarted, so the working directory and exported variables were reset",
+	}
+}
+
+// chunksResponse converts output chunks into their JSON representation
+func chunksResponse(chunks []ssh.OutputChunk) []OutputChunkResponse {
+	output := make([]OutputChunkResponse, 0, len(chunks))
+	for _, chunk := range chunks {
+		output = append(output, OutputChunkResponse{
+			Stream:   chunk.Stream,
+			Data:     chunk.Data,
+			OffsetMS: chunk.Offset.Milliseconds(),
+		})
+	}
+	return output
+}
+
+// executeToFile runs a command with its stdout redirected to a remote file
+func (h *Handlers) executeToFile(ctx context.Context, connectionID, command, outputTo string) (*mcp.CallToolResult, error) {
+	logger := h.connLogger(connectionID)
+
+	result, size, err := h.manager.ExecuteToFile(ctx, connectionID, command, outputTo)
+	if err != nil {
+		h.auditCommand(connectionID, command, 0, 0, 0, err)
+		logger.WithError(err).Error("Failed to execute SSH command")
+		return h.toolError("Failed to execute command", err)
+	}
+	h.auditCommand(connectionID, command, result.ExitCode, size, int64(len(result.Stderr)), nil)
+
+	logger.WithFields(logrus.Fields{
+		"exit_code":     result.ExitCode,
+		"bytes_written": size,
+	}).Debug("Command executed successfully")
+
+	response := ExecuteResponse{
+		Success:       true,
+		Stderr:        result.Stderr,
+		ExitCode:      result.ExitCode,
+		OutputTo:      outputTo,
+		BytesWritten:  &size,
+		StartedAt:     timestamp(result.StartedAt),
+		FinishedAt:    timestamp(result.FinishedAt),
+		ReconnectInfo: reconnectInfo(result),
+	}
+
+	response.BudgetWarning = h.budgetWarning(connectionID)
+
+	return h.toolResult(response)
+}
+
+// executeUntilIdle runs a command until it exits or goes quiet for idle
+func (h *Handlers) executeUntilIdle(ctx context.Context, connectionID, command string, idle time.Duration) (*mcp.CallToolResult, error) {
+	logger := h.connLogger(connectionID)
+
+	result, err := h.manager.ExecuteUntilIdle(ctx, connectionID, command, idle)
+	if err != nil {
+		h.auditCommand(connectionID, command, 0, 0, 0, err)
+		logger.WithError(err).Error("Failed to execute SSH command")
+		return h.toolError("Failed to execute command", err)
+	}
+	h.auditCommand(connectionID, command, result.ExitCode, int64(len(result.Stdout)), int64(len(result.Stderr)), nil)
+
+	logger.WithFields(logrus.Fields{
+		"exit_code":         result.ExitCode,
+		"completed_by_idle": result.CompletedByIdle,
+	}).Debug("Command executed successfully")
+
+	response := ExecuteResponse{
+		Success:         true,
+		Stdout:          result.Stdout,
+		Stderr:          result.Stderr,
+		ExitCode:        result.ExitCode,
+		CompletedByIdle: result.CompletedByIdle,
+		StartedAt:       timestamp(result.StartedAt),
+		FinishedAt:      timestamp(result.FinishedAt),
+		ReconnectInfo:   reconnectInfo(&ssh.CommandResult{Reconnected: result.Reconnected}),
+	}
+
+	response.BudgetWarning = h.budgetWarning(connectionID)
+
+	return h.toolResult(response)
+}
+
+// executeTee runs a command whose full stdout is saved to a remote file while
+// a preview is returned
+func (h *Handlers) executeTee(ctx context.Context, connectionID, command, teeTo string, previewBytes int) (*mcp.CallToolResult, error) {
+	logger := h.connLogger(connectionID)
+
+	result, size, err := h.manager.ExecuteTee(ctx, connectionID, command, teeTo, previewBytes)
+	if err != nil {
+		h.auditCommand(connectionID, command, 0, 0, 0, err)
+		logger.WithError(err).Error("Failed to execute SSH command")
+		return h.toolError("Failed to execute command", err)
+	}
+	h.auditCommand(connectionID, command, result.ExitCode, size, int64(len(result.Stderr)), nil)
+
+	logger.WithFields(logrus.Fields{
+		"exit_code":     result.ExitCode,
+		"bytes_written": size,
+	}).Debug("Command executed successfully")
+
+	truncated := size > int64(previewBytes)
+	response := ExecuteResponse{
+		Success:       true,
+		Stdout:        result.Stdout,
+		Stderr:        result.Stderr,
+		ExitCode:      result.ExitCode,
+		TeeTo:         teeTo,
+		BytesWritten:  &size,
+		Truncated:     &truncated,
+		StartedAt:     timestamp(result.StartedAt),
+		FinishedAt:    timestamp(result.FinishedAt),
+		ReconnectInfo: reconnectInfo(result),
+	}
+
+	response.BudgetWarning = h.budgetWarning(connectionID)
+
+	return h.toolResult(response)
 }
 
 // HandleClose handles the ssh_close tool
@@ -224,37 +863,112 @@ func (h *Handlers) HandleClose(ctx context.Context, req mcp.CallToolRequest) (*m
 		return mcp.NewToolResultError(err.Error()), nil
 	}
 
-	h.logger.WithFields(logrus.Fields{
+	logger := h.connLogger(connectionID)
+
+	logger.WithFields(logrus.Fields{
 		"connection_id": connectionID,
 	}).Info("Closing SSH connection")
 
 	// Close connection
 	if err := h.manager.Close(connectionID); err != nil {
-		h.logger.WithError(err).Error("Failed to close SSH connection")
+		logger.WithError(err).Error("Failed to close SSH connection")
 		return mcp.NewToolResultError(fmt.Sprintf("Failed to close connection: %v", err)), nil
 	}
 
-	h.logger.Info("SSH connection closed successfully")
+	logger.Info("SSH connection closed successfully")
 
 	// Return success response
-	response := map[string]interface{}{
-		"success":       true,
+	response := CloseResponse{
+		Success:      true,
+		ConnectionID: connectionID,
+		Message:      "SSH connection closed successfully",
+	}
+
+	return h.toolResult(response)
+}
+
+// HandleReconnect handles the ssh_reconnect tool
+func (h *Handlers) HandleReconnect(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	connectionID, err := req.RequireString("connection_id")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	if err := validateConnectionID(connectionID); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	logger := h.connLogger(connectionID)
+
+	logger.WithFields(logrus.Fields{
 		"connection_id": connectionID,
-		"message":       "SSH connection closed successfully",
+	}).Info("Re-establishing SSH connection")
+
+	info, err := h.manager.Reconnect(connectionID)
+	if err != nil {
+		logger.WithError(err).Error("Failed to re-establish SSH connection")
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to reconnect: %v", err)), nil
 	}
 
-	jsonResponse, err := json.Marshal(response)
+	logger.Info("SSH connection re-established successfully")
+
+	response := ReconnectResponse{
+		Success:      true,
+		ConnectionID: connectionID,
+		Reconnects:   info.Reconnects,
+		Message:      "SSH connection re-established with a fresh shell; the working directory and exported variables were reset",
+	}
+
+	return h.toolResult(response)
+}
+
+// HandleForgetCredentials handles the ssh_forget_credentials tool
+func (h *Handlers) HandleForgetCredentials(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	connectionID, err := req.RequireString("connection_id")
 	if err != nil {
-		h.logger.WithError(err).Error("Failed to marshal response")
-		return mcp.NewToolResultError(fmt.Sprintf("Internal error: failed to marshal response: %v", err)), nil
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	if err := validateConnectionID(connectionID); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	logger := h.connLogger(connectionID)
+
+	logger.WithFields(logrus.Fields{
+		"connection_id": connectionID,
+	}).Info("Forgetting SSH connection credentials")
+
+	forgotten, err := h.manager.ForgetCredentials(connectionID)
+	if err != nil {
+		logger.WithError(err).Error("Failed to forget SSH connection credentials")
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to forget credentials: %v", err)), nil
 	}
-	return mcp.NewToolResultText(string(jsonResponse)), nil
+
+	message := "No credentials were retained for this connection"
+	if forgotten {
+		message = "Credentials wiped from memory; the connection stays open but re-authenticating requires new credentials"
+	}
+
+	response := ForgetCredentialsResponse{
+		Success:      true,
+		ConnectionID: connectionID,
+		Forgotten:    forgotten,
+		Message:      message,
+	}
+
+	return h.toolResult(response)
 }
 
 // HandleList handles the ssh_list tool
 func (h *Handlers) HandleList(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	h.logger.Debug("Listing active SSH connections")
 
+	tagFilter, err := parseTags(req, "tag_filter")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
 	// Get list of connections
 	connections := h.manager.List()
 
@@ -262,28 +976,271 @@ func (h *Handlers) HandleList(ctx context.Context, req mcp.CallToolRequest) (*mc
 		"count": len(connections),
 	}).Debug("Retrieved connection list")
 
-	// Convert to response format
-	connList := make([]map[string]interface{}, len(connections))
-	for i, conn := range connections {
-		connList[i] = map[string]interface{}{
-			"connection_id": conn.ID,
-			"host":          conn.Host,
-			"port":          conn.Port,
-			"username":      conn.Username,
-			"created":       conn.Created.Format("2006-01-02 15:04:05"),
+	// Convert to response format, keeping those with the tags filtered on
+	now := time.Now()
+	connList := make([]ConnectionResponse, 0, len(connections))
+	for _, conn := range connections {
+		if conn.HasTags(tagFilter) {
+			connList = append(connList, h.connectionResponse(conn, now))
 		}
 	}
 
-	response := map[string]interface{}{
-		"success":     true,
-		"connections": connList,
-		"count":       len(connections),
+	execs := h.manager.ExecStats()
+	response := ListResponse{
+		Success:      true,
+		Connections:  connList,
+		Count:        len(connList),
+		RunningExecs: execs.Running,
+		QueuedExecs:  execs.Queued,
+
+		LostOnRestart: h.manager.LostOnRestart(),
 	}
 
-	jsonResponse, err := json.Marshal(response)
+	return h.toolResult(response)
+}
+
+// connectionResponse converts the information about a connection to its
+// response format
+func (h *Handlers) connectionResponse(conn ssh.ConnectionInfo, now time.Time) ConnectionResponse {
+	response := ConnectionResponse{
+		ConnectionID:       conn.ID,
+		Host:               conn.Host,
+		Port:               conn.Port,
+		Username:           conn.Username,
+		Created:            conn.Created.Format("2006-01-02 15:04:05"),
+		AutoReconnect:      conn.AutoReconnect,
+		Reconnects:         conn.Reconnects,
+		LastUsed:           timestamp(conn.LastUsed),
+		IdleSeconds:        now.Sub(conn.LastUsed).Seconds(),
+		UptimeSeconds:      now.Sub(conn.Created).Seconds(),
+		ReconnectState:     conn.Reconnect.State,
+		ReconnectFailures:  conn.Reconnect.Failures,
+		LastReconnectError: conn.Reconnect.LastError,
+		CommandPrefix:      conn.CommandPrefix,
+		TimeUsedSeconds:    conn.TimeBudget.Used.Seconds(),
+		LogLevel:           h.effectiveLogLevel(conn.LogLevel),
+		HostKeyFingerprint: conn.HostKeyFingerprint,
+		Tags:               conn.Tags,
+	}
+	if conn.TimeBudget.Limit > 0 {
+		remaining := conn.TimeBudget.Remaining().Seconds()
+		response.TimeBudgetSeconds = conn.TimeBudget.Limit.Seconds()
+		response.TimeRemainingSeconds = &remaining
+		response.BudgetWarning = conn.TimeBudget.Warning()
+	}
+	if !conn.Reconnect.RetryAt.IsZero() {
+		response.ReconnectRetryAt = conn.Reconnect.RetryAt.UTC().Format(time.RFC3339)
+	}
+	return response
+}
+
+// HandleInfo handles the ssh_info tool
+func (h *Handlers) HandleInfo(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	connectionID, err := req.RequireString("connection_id")
 	if err != nil {
-		h.logger.WithError(err).Error("Failed to marshal response")
-		return mcp.NewToolResultError(fmt.Sprintf("Internal error: failed to marshal response: %v", err)), nil
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	if err := validateConnectionID(connectionID); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	logger := h.connLogger(connectionID)
+
+	logger.WithField("connection_id", connectionID).Debug("Probing SSH connection")
+
+	details, err := h.manager.GetInfo(connectionID)
+	if err != nil {
+		logger.WithError(err).Error("Failed to probe SSH connection")
+		return h.toolError("Failed to get connection info", err)
+	}
+
+	logger.WithFields(logrus.Fields{
+		"alive": details.Alive,
+		"busy":  details.Running != nil,
+	}).Debug("Probed SSH connection")
+
+	response := InfoResponse{
+		Success:          true,
+		Connection:       h.connectionResponse(details.ConnectionInfo, time.Now()),
+		Alive:            details.Alive,
+		AliveError:       details.AliveError,
+		WorkingDirectory: details.WorkingDir,
+		OS:               details.OS,
+	}
+	if details.Running != nil {
+		response.RunningCommand = details.Running.Command
+		response.RunningSince = timestamp(details.Running.Started)
 	}
-	return mcp.NewToolResultText(string(jsonResponse)), nil
+
+	return h.toolResult(response)
+}
+
+// HandleServerConfig handles the ssh_server_config tool
+func (h *Handlers) HandleServerConfig(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	h.logger.Debug("Reporting server configuration")
+
+	config := h.manager.Config()
+
+	tools := h.info.Tools
+	if tools == nil {
+		tools = []string{}
+	}
+
+	response := ServerConfigResponse{
+		Success:                    true,
+		Version:                    h.info.Version,
+		Transport:                  h.info.Transport,
+		LogLevel:                   h.info.LogLevel,
+		Tools:                      tools,
+		HostKeyMode:                config.HostKeyMode(),
+		AllowedHostPatterns:        h.manager.AllowedHostPatterns(),
+		SFTPAllowedPathPatterns:    config.PathPolicy.AllowedCount(),
+		SFTPDeniedPathPatterns:     config.PathPolicy.DeniedCount(),
+		MaxConnections:             config.MaxConnections,
+		MaxConcurrentExecs:         config.MaxConcurrentExecs,
+		ExecQueueSize:              config.ExecQueueSize,
+		ActiveConnections:          h.manager.Count(),
+		DialTimeoutSeconds:         config.DialTimeout.Seconds(),
+		CommandTimeoutSeconds:      config.CommandTimeout.Seconds(),
+		IdleOutputThresholdSeconds: config.IdleOutputThreshold.Seconds(),
+		MaxCommandBytes:            ssh.MaxCommandSize,
+		MaxOutputBytes:             ssh.MaxOutputSize,
+		Presets:                    h.manager.PresetNames(),
+		TimeBudgetSeconds:          config.TimeBudget.Seconds(),
+	}
+
+	return h.toolResult(response)
+}
+
+// HandleListKeys handles the ssh_list_keys tool
+func (h *Handlers) HandleListKeys(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	h.logger.Debug("Listing available SSH keys")
+
+	response := ListKeysResponse{
+		Success: true,
+		Keys:    make([]KeyResponse, 0),
+	}
+
+	agentKeys, err := ssh.ListAgentKeys()
+	if err != nil {
+		response.AgentError = err.Error()
+	}
+
+	var fileKeys []ssh.KeyInfo
+	keyDir, err := ssh.DefaultKeyDir()
+	if err == nil {
+		fileKeys, err = ssh.ListKeyFiles(keyDir)
+	}
+	if err != nil {
+		response.FilesError = err.Error()
+	}
+
+	for _, key := range append(agentKeys, fileKeys...) {
+		response.Keys = append(response.Keys, KeyResponse{
+			Source:         key.Source,
+			Type:           key.Type,
+			Fingerprint:    key.Fingerprint,
+			Comment:        key.Comment,
+			PrivateKeyPath: key.Path,
+		})
+	}
+	response.Count = len(response.Keys)
+
+	h.logger.WithFields(logrus.Fields{
+		"count": response.Count,
+	}).Debug("Retrieved key list")
+
+	return h.toolResult(response)
+}
+
+// HandleShellSettings handles the ssh_shell_settings tool
+func (h *Handlers) HandleShellSettings(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	connectionID, err := req.RequireString("connection_id")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	if err := validateConnectionID(connectionID); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	logger := h.connLogger(connectionID)
+
+	disableHistory := req.GetBool("disable_history", false)
+
+	logger.WithFields(logrus.Fields{
+		"connection_id":   connectionID,
+		"disable_history": disableHistory,
+	}).Debug("Querying shell settings")
+
+	settings, err := h.manager.ShellSettings(connectionID, disableHistory)
+	if err != nil {
+		logger.WithError(err).Error("Failed to query shell settings")
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to query shell settings: %v", err)), nil
+	}
+
+	response := ShellSettingsResponse{
+		Success:                    true,
+		ConnectionID:               connectionID,
+		HistoryDisabled:            settings.HistoryDisabled,
+		SubshellPerCommand:         settings.SubshellPerCommand,
+		PTY:                        settings.PTY,
+		CommandPrefix:              settings.CommandPrefix,
+		HistFile:                   settings.HistFile,
+		HistSize:                   settings.HistSize,
+		CommandTimeoutSeconds:      settings.CommandTimeout.Seconds(),
+		IdleOutputThresholdSeconds: settings.IdleOutputThreshold.Seconds(),
+	}
+
+	return h.toolResult(response)
+}
+
+// HandleSetPrefix handles the ssh_set_prefix tool
+func (h *Handlers) HandleSetPrefix(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	connectionID, err := req.RequireString("connection_id")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	if err := validateConnectionID(connectionID); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	logger := h.connLogger(connectionID)
+
+	prefix := req.GetString("prefix", "")
+
+	// The prefix runs as the command, with the agent's commands as its
+	// arguments, so the policy applies to it as well
+	if words := strings.Join(strings.Fields(prefix), " "); words != "" {
+		if err := h.checkCommandPolicy(logger, "", words); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+	}
+
+	logger.WithFields(logrus.Fields{
+		"connection_id": connectionID,
+		"prefix":        prefix,
+	}).Debug("Setting command prefix")
+
+	prefix, err = h.manager.SetPrefix(connectionID, prefix)
+	if err != nil {
+		logger.WithError(err).Error("Failed to set command prefix")
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to set command prefix: %v", err)), nil
+	}
+
+	message := fmt.Sprintf("Commands on '%s' are now run as: %s <command>", connectionID, prefix)
+	if prefix == "" {
+		message = fmt.Sprintf("Command prefix of '%s' cleared", connectionID)
+	}
+
+	response := SetPrefixResponse{
+		Success:      true,
+		ConnectionID: connectionID,
+		Prefix:       prefix,
+		Message:      message,
+	}
+
+	return h.toolResult(response)
 }
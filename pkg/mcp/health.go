@@ -0,0 +1,59 @@
+package mcp
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/denysvitali/mcp-ssh/pkg/ssh"
+)
+
+// HealthResponse is the body of the health server's probes
+type HealthResponse struct {
+	Status string `json:"status"`
+
+	// ActiveConnections is the number of open SSH connections, reported
+	// by /readyz
+	ActiveConnections *int `json:"active_connections,omitempty"`
+}
+
+// HealthServer serves liveness and readiness probes over HTTP, e.g. for
+// container orchestration: /healthz answers 200 as long as the process is
+// up, and /readyz once the MCP server is serving (see SetReady), 503 before
+// and while shutting down
+type HealthServer struct {
+	backgroundServer
+	ready atomic.Bool
+}
+
+// NewHealthServer returns a health server for manager listening on addr. It
+// does not listen until Start is called, and is not ready until SetReady.
+func NewHealthServer(manager *ssh.Manager, addr string) *HealthServer {
+	s := &HealthServer{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		writeHealth(w, http.StatusOK, HealthResponse{Status: "ok"})
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		if !s.ready.Load() {
+			writeHealth(w, http.StatusServiceUnavailable, HealthResponse{Status: "not ready"})
+			return
+		}
+		active := manager.Count()
+		writeHealth(w, http.StatusOK, HealthResponse{Status: "ready", ActiveConnections: &active})
+	})
+	s.backgroundServer = newBackgroundServer(addr, mux)
+	return s
+}
+
+// SetReady sets whether /readyz reports the server as ready
+func (s *HealthServer) SetReady(ready bool) {
+	s.ready.Store(ready)
+}
+
+// writeHealth writes a probe response as JSON
+func writeHealth(w http.ResponseWriter, status int, response HealthResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(response)
+}
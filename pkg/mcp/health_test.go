@@ -0,0 +1,67 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/denysvitali/mcp-ssh/pkg/ssh"
+)
+
+func TestHealthServer(t *testing.T) {
+	validator, err := ssh.NewHostValidator("localhost")
+	if err != nil {
+		t.Fatalf("failed to create validator: %v", err)
+	}
+	healthServer := NewHealthServer(ssh.NewManager(validator), "127.0.0.1:0")
+	if err := healthServer.Start(func(err error) { t.Errorf("serve error: %v", err) }); err != nil {
+		t.Fatalf("failed to start: %v", err)
+	}
+
+	probe := func(path string) (int, HealthResponse) {
+		t.Helper()
+		resp, err := http.Get("http://" + healthServer.Addr() + path)
+		if err != nil {
+			t.Fatalf("failed to get %s: %v", path, err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		var body HealthResponse
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			t.Fatalf("failed to decode %s: %v", path, err)
+		}
+		return resp.StatusCode, body
+	}
+
+	if status, body := probe("/healthz"); status != http.StatusOK || body.Status != "ok" {
+		t.Errorf("expected /healthz to be ok, got %d %+v", status, body)
+	}
+	if status, body := probe("/readyz"); status != http.StatusServiceUnavailable || body.ActiveConnections != nil {
+		t.Errorf("expected /readyz to be unavailable before serving, got %d %+v", status, body)
+	}
+
+	healthServer.SetReady(true)
+	status, body := probe("/readyz")
+	if status != http.StatusOK || body.Status != "ready" {
+		t.Errorf("expected /readyz to be ready, got %d %+v", status, body)
+	}
+	if body.ActiveConnections == nil || *body.ActiveConnections != 0 {
+		t.Errorf("expected 0 active connections, got %v", body.ActiveConnections)
+	}
+
+	// Shutting down makes the server unready first
+	healthServer.SetReady(false)
+	if status, _ := probe("/readyz"); status != http.StatusServiceUnavailable {
+		t.Errorf("expected /readyz to be unavailable once shutting down, got %d", status)
+	}
+	if status, _ := probe("/healthz"); status != http.StatusOK {
+		t.Errorf("expected /healthz to stay ok, got %d", status)
+	}
+
+	if err := healthServer.Shutdown(context.Background()); err != nil {
+		t.Errorf("failed to shut down: %v", err)
+	}
+	if _, err := http.Get("http://" + healthServer.Addr() + "/healthz"); err == nil {
+		t.Errorf("expected the server to be closed")
+	}
+}
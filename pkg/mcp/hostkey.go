@@ -0,0 +1,104 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/denysvitali/mcp-ssh/pkg/ssh"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/sirupsen/logrus"
+)
+
+// HandleHostKey handles the ssh_hostkey tool
+func (h *Handlers) HandleHostKey(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	host, err := req.RequireString("host")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	if strings.TrimSpace(host) == "" {
+		return mcp.NewToolResultError("host cannot be empty"), nil
+	}
+
+	port := int(req.GetFloat("port", 22))
+	if err := validatePort(port); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	h.logger.WithFields(logrus.Fields{
+		"host": host,
+		"port": port,
+	}).Debug("Retrieving SSH host key")
+
+	info, err := h.manager.FetchHostKey(host, port)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to retrieve SSH host key")
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to retrieve host key: %v", err)), nil
+	}
+
+	response := HostKeyResponse{
+		Success:     true,
+		Host:        host,
+		Port:        port,
+		KeyType:     info.Type,
+		Fingerprint: info.Fingerprint,
+		Message:     "Verify the fingerprint out of band before pinning it with host_key_fingerprint on ssh_connect",
+	}
+
+	return h.toolResult(response)
+}
+
+// HandleTCPCheck handles the ssh_tcp_check tool
+func (h *Handlers) HandleTCPCheck(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	host, err := req.RequireString("host")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	if strings.TrimSpace(host) == "" {
+		return mcp.NewToolResultError("host cannot be empty"), nil
+	}
+
+	port := int(req.GetFloat("port", 22))
+	if err := validatePort(port); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	timeout := time.Duration(req.GetFloat("timeout", 0) * float64(time.Second))
+	if timeout < 0 || timeout > ssh.MaxTCPCheckTimeout {
+		return mcp.NewToolResultError(fmt.Sprintf("timeout must be between 0 and %.0f seconds", ssh.MaxTCPCheckTimeout.Seconds())), nil
+	}
+
+	h.logger.WithFields(logrus.Fields{
+		"host":    host,
+		"port":    port,
+		"timeout": timeout,
+	}).Debug("Checking TCP reachability")
+
+	result, err := h.manager.TCPCheck(ctx, host, port, timeout)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to check TCP reachability")
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to check reachability: %v", err)), nil
+	}
+
+	message := "The port accepts TCP connections; if ssh_connect fails, the problem is in the SSH handshake or authentication"
+	if !result.Reachable {
+		message = "The port cannot be reached over TCP; this is a network, DNS or firewall problem, or nothing listens on the port"
+	}
+
+	response := TCPCheckResponse{
+		Success:    true,
+		Host:       host,
+		Port:       port,
+		Reachable:  result.Reachable,
+		RemoteAddr: result.RemoteAddr,
+		LatencyMS:  result.Latency.Milliseconds(),
+		Reason:     result.Reason,
+		Error:      result.Error,
+		Message:    message,
+	}
+
+	return h.toolResult(response)
+}
@@ -0,0 +1,206 @@
+package mcp
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	"github.com/denysvitali/mcp-ssh/pkg/ssh"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/sirupsen/logrus"
+)
+
+// HandleExecuteToLocal handles the ssh_execute_to_local tool
+func (h *Handlers) HandleExecuteToLocal(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	connectionID, err := req.RequireString("connection_id")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	if err := validateConnectionID(connectionID); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	logger := h.connLogger(connectionID)
+
+	command, err := req.RequireString("command")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	if err := validateCommand(command); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	if err := h.checkCommandPolicy(logger, connectionID, command); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	localPath, err := req.RequireString("local_path")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	overwrite := req.GetBool("overwrite", false)
+
+	timeout := time.Duration(req.GetFloat("timeout", 0) * float64(time.Second))
+	if timeout < 0 || timeout > ssh.MaxLocalOutputTimeout {
+		return mcp.NewToolResultError(fmt.Sprintf("timeout must be between 0 and %.0f seconds", ssh.MaxLocalOutputTimeout.Seconds())), nil
+	}
+
+	logger.WithFields(logrus.Fields{
+		"connection_id": connectionID,
+		"command":       command,
+		"local_path":    localPath,
+		"overwrite":     overwrite,
+	}).Debug("Executing SSH command to local file")
+
+	result, err := h.manager.ExecuteToLocal(connectionID, command, localPath, overwrite, timeout)
+	if err != nil {
+		h.auditCommand(connectionID, command, 0, 0, 0, err)
+		logger.WithError(err).Error("Failed to execute SSH command to local file")
+		return h.toolError("Failed to execute command", err)
+	}
+	h.auditCommand(connectionID, command, result.ExitCode, result.BytesWritten, int64(len(result.Stderr)), nil)
+
+	logger.WithFields(logrus.Fields{
+		"local_path":    result.Path,
+		"exit_code":     result.ExitCode,
+		"bytes_written": result.BytesWritten,
+		"truncated":     result.Truncated,
+	}).Info("Command output written to local file")
+
+	response := ExecuteToLocalResponse{
+		Success:       true,
+		LocalPath:     result.Path,
+		BytesWritten:  result.BytesWritten,
+		ExitCode:      result.ExitCode,
+		Truncated:     result.Truncated,
+		Stderr:        result.Stderr,
+		DurationMS:    result.Duration.Milliseconds(),
+		ReconnectInfo: reconnectInfo(&ssh.CommandResult{Reconnected: result.Reconnected}),
+	}
+
+	return h.toolResult(response)
+}
+
+// HandleUpload handles the ssh_upload tool
+func (h *Handlers) HandleUpload(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	connectionID, err := req.RequireString("connection_id")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	if err := validateConnectionID(connectionID); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	logger := h.connLogger(connectionID)
+
+	localPath, err := req.RequireString("local_path")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	remotePath, err := req.RequireString("remote_path")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	overwrite := req.GetBool("overwrite", false)
+
+	logger.WithFields(logrus.Fields{
+		"connection_id": connectionID,
+		"local_path":    localPath,
+		"remote_path":   remotePath,
+		"overwrite":     overwrite,
+	}).Debug("Uploading file")
+
+	result, err := h.manager.Upload(connectionID, localPath, remotePath, overwrite)
+	if err != nil {
+		logger.WithError(err).Error("Failed to upload file")
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to upload file: %v", err)), nil
+	}
+
+	logger.WithFields(logrus.Fields{
+		"local_path":  result.LocalPath,
+		"remote_path": result.RemotePath,
+		"bytes":       result.Bytes,
+	}).Info("File uploaded")
+
+	response := TransferResponse{
+		Success:    true,
+		LocalPath:  result.LocalPath,
+		RemotePath: result.RemotePath,
+		Bytes:      result.Bytes,
+		Mode:       fmt.Sprintf("%04o", uint32(result.Mode)),
+	}
+
+	return h.toolResult(response)
+}
+
+// HandleDownload handles the ssh_download tool
+func (h *Handlers) HandleDownload(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	connectionID, err := req.RequireString("connection_id")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	if err := validateConnectionID(connectionID); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	logger := h.connLogger(connectionID)
+
+	remotePath, err := req.RequireString("remote_path")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	localPath := req.GetString("local_path", "")
+	inline := req.GetBool("inline", false)
+	overwrite := req.GetBool("overwrite", false)
+	switch {
+	case inline && localPath != "":
+		return mcp.NewToolResultError("'inline' cannot be combined with 'local_path'"), nil
+	case !inline && localPath == "":
+		return mcp.NewToolResultError("either 'local_path' or 'inline' must be provided"), nil
+	}
+
+	logger.WithFields(logrus.Fields{
+		"connection_id": connectionID,
+		"remote_path":   remotePath,
+		"local_path":    localPath,
+		"inline":        inline,
+	}).Debug("Downloading file")
+
+	var result *ssh.TransferResult
+	if inline {
+		result, err = h.manager.DownloadInline(connectionID, remotePath)
+	} else {
+		result, err = h.manager.Download(connectionID, remotePath, localPath, overwrite)
+	}
+	if err != nil {
+		logger.WithError(err).Error("Failed to download file")
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to download file: %v", err)), nil
+	}
+
+	logger.WithFields(logrus.Fields{
+		"remote_path": result.RemotePath,
+		"local_path":  result.LocalPath,
+		"bytes":       result.Bytes,
+	}).Info("File downloaded")
+
+	response := TransferResponse{
+		Success:    true,
+		LocalPath:  result.LocalPath,
+		RemotePath: result.RemotePath,
+		Bytes:      result.Bytes,
+		Mode:       fmt.Sprintf("%04o", uint32(result.Mode)),
+	}
+	if inline {
+		response.ContentBase64 = base64.StdEncoding.EncodeToString(result.Content)
+	}
+
+	return h.toolResult(response)
+}
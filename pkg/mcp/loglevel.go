@@ -0,0 +1,64 @@
+package mcp
+
+import (
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+)
+
+// parseLogLevel validates a connection's log level, returning its canonical
+// name; empty means the server's level
+func parseLogLevel(name string) (string, error) {
+	if name == "" {
+		return "", nil
+	}
+	level, err := logrus.ParseLevel(name)
+	if err != nil {
+		return "", fmt.Errorf("invalid log_level '%s' (expected trace, debug, info, warn or error)", name)
+	}
+	return level.String(), nil
+}
+
+// connLogger returns the logger for operations on a connection, at the
+// connection's own log level if it has one
+func (h *Handlers) connLogger(connectionID string) *logrus.Logger {
+	return h.levelLogger(h.manager.LogLevel(connectionID))
+}
+
+// levelLogger returns a logger writing like the server's, but at the named
+// level; the server's logger itself for an empty or matching level
+func (h *Handlers) levelLogger(name string) *logrus.Logger {
+	level, err := logrus.ParseLevel(name)
+	if name == "" || err != nil || level == h.logger.GetLevel() {
+		return h.logger
+	}
+
+	h.loggersMu.Lock()
+	defer h.loggersMu.Unlock()
+
+	if logger, ok := h.loggers[level]; ok {
+		return logger
+	}
+	logger := &logrus.Logger{
+		Out:          h.logger.Out,
+		Hooks:        h.logger.Hooks,
+		Formatter:    h.logger.Formatter,
+		ReportCaller: h.logger.ReportCaller,
+		Level:        level,
+		ExitFunc:     h.logger.ExitFunc,
+	}
+	if h.loggers == nil {
+		h.loggers = make(map[logrus.Level]*logrus.Logger)
+	}
+	h.loggers[level] = logger
+	return logger
+}
+
+// effectiveLogLevel returns the level operations on a connection with the
+// given override are logged at
+func (h *Handlers) effectiveLogLevel(override string) string {
+	if override != "" {
+		return override
+	}
+	return h.logger.GetLevel().String()
+}
@@ -0,0 +1,52 @@
+package mcp
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestParseLogLevel(t *testing.T) {
+	if level, err := parseLogLevel(""); err != nil || level != "" {
+		t.Errorf("expected no override for an empty level, got %q, %v", level, err)
+	}
+	if level, err := parseLogLevel("DEBUG"); err != nil || level != "debug" {
+		t.Errorf("expected debug, got %q, %v", level, err)
+	}
+	if _, err := parseLogLevel("verbose"); err == nil {
+		t.Error("expected an error for an unknown level")
+	}
+}
+
+func TestLevelLogger(t *testing.T) {
+	h := newTestHandlers(t)
+	var out bytes.Buffer
+	h.logger.SetOutput(&out)
+	h.logger.SetLevel(logrus.InfoLevel)
+
+	if h.levelLogger("") != h.logger || h.levelLogger("info") != h.logger {
+		t.Error("expected the server's logger without an override")
+	}
+
+	debug := h.levelLogger("debug")
+	if debug != h.levelLogger("debug") {
+		t.Error("expected the logger for a level to be reused")
+	}
+	debug.Debug("connection detail")
+	h.logger.Debug("server detail")
+	h.levelLogger("error").Info("quiet connection")
+
+	logged := out.String()
+	if !strings.Contains(logged, "connection detail") {
+		t.Errorf("expected the debug entry of the overriding connection, got %q", logged)
+	}
+	if strings.Contains(logged, "server detail") || strings.Contains(logged, "quiet connection") {
+		t.Errorf("expected entries below the effective level to be dropped, got %q", logged)
+	}
+
+	if level := h.effectiveLogLevel(""); level != "info" {
+		t.Errorf("expected the server's level, got %q", level)
+	}
+}
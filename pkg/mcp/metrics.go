@@ -0,0 +1,57 @@
+package mcp
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/denysvitali/mcp-ssh/pkg/ssh"
+)
+
+// MetricsHandler serves the manager's metrics in the Prometheus text
+// exposition format
+func MetricsHandler(manager *ssh.Manager) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		writeMetrics(w, manager.Metrics())
+	})
+}
+
+// writeMetrics writes metrics in the Prometheus text exposition format
+func writeMetrics(w io.Writer, metrics ssh.Metrics) {
+	metric := func(name, kind, help string, value string) {
+		_, _ = fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %s\n", name, help, name, kind, name, value)
+	}
+	metric("mcp_ssh_active_connections", "gauge", "Number of open SSH connections.",
+		strconv.Itoa(metrics.ActiveConnections))
+	metric("mcp_ssh_commands_executed_total", "counter", "Number of commands run.",
+		strconv.FormatUint(metrics.CommandsExecuted, 10))
+	metric("mcp_ssh_commands_failed_total", "counter", "Number of commands that failed with an error rather than an exit code.",
+		strconv.FormatUint(metrics.CommandsFailed, 10))
+	metric("mcp_ssh_commands_timed_out_total", "counter", "Number of commands that timed out.",
+		strconv.FormatUint(metrics.CommandsTimedOut, 10))
+
+	const histogram = "mcp_ssh_command_duration_seconds"
+	_, _ = fmt.Fprintf(w, "# HELP %s Time commands took to run.\n# TYPE %s histogram\n", histogram, histogram)
+	for i, bound := range ssh.CommandDurationBuckets {
+		_, _ = fmt.Fprintf(w, "%s_bucket{le=\"%s\"} %d\n", histogram, strconv.FormatFloat(bound, 'g', -1, 64), metrics.DurationCounts[i])
+	}
+	_, _ = fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n", histogram, metrics.CommandsExecuted)
+	_, _ = fmt.Fprintf(w, "%s_sum %s\n", histogram, strconv.FormatFloat(metrics.DurationSum.Seconds(), 'g', -1, 64))
+	_, _ = fmt.Fprintf(w, "%s_count %d\n", histogram, metrics.CommandsExecuted)
+}
+
+// MetricsServer serves the metrics of a manager over HTTP at /metrics, for
+// Prometheus to scrape
+type MetricsServer struct {
+	backgroundServer
+}
+
+// NewMetricsServer returns a metrics server for manager listening on addr.
+// It does not listen until Start is called.
+func NewMetricsServer(manager *ssh.Manager, addr string) *MetricsServer {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", MetricsHandler(manager))
+	return &MetricsServer{newBackgroundServer(addr, mux)}
+}
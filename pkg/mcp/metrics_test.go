@@ -0,0 +1,86 @@
+package mcp
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/denysvitali/mcp-ssh/pkg/ssh"
+)
+
+func TestMetricsServer(t *testing.T) {
+	validator, err := ssh.NewHostValidator("localhost")
+	if err != nil {
+		t.Fatalf("failed to create validator: %v", err)
+	}
+	metricsServer := NewMetricsServer(ssh.NewManager(validator), "127.0.0.1:0")
+	if err := metricsServer.Start(func(err error) { t.Errorf("serve error: %v", err) }); err != nil {
+		t.Fatalf("failed to start: %v", err)
+	}
+
+	resp, err := http.Get("http://" + metricsServer.Addr() + "/metrics")
+	if err != nil {
+		t.Fatalf("failed to get metrics: %v", err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		t.Fatalf("failed to read metrics: %v", err)
+	}
+
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("unexpected content type %q", ct)
+	}
+	for _, line := range []string{
+		"# TYPE mcp_ssh_active_connections gauge",
+		"mcp_ssh_active_connections 0",
+		"# TYPE mcp_ssh_commands_executed_total counter",
+		"mcp_ssh_commands_executed_total 0",
+		"mcp_ssh_commands_failed_total 0",
+		"mcp_ssh_commands_timed_out_total 0",
+		"# TYPE mcp_ssh_command_duration_seconds histogram",
+		`mcp_ssh_command_duration_seconds_bucket{le="0.1"} 0`,
+		`mcp_ssh_command_duration_seconds_bucket{le="+Inf"} 0`,
+		"mcp_ssh_command_duration_seconds_count 0",
+	} {
+		if !strings.Contains(string(body), line+"\n") {
+			t.Errorf("expected line %q in:\n%s", line, body)
+		}
+	}
+
+	if err := metricsServer.Shutdown(context.Background()); err != nil {
+		t.Errorf("failed to shut down: %v", err)
+	}
+	if _, err := http.Get("http://" + metricsServer.Addr() + "/metrics"); err == nil {
+		t.Errorf("expected the server to be closed")
+	}
+}
+
+func TestWriteMetrics(t *testing.T) {
+	var out strings.Builder
+	writeMetrics(&out, ssh.Metrics{
+		ActiveConnections: 2,
+		CommandsExecuted:  5,
+		CommandsFailed:    1,
+		DurationCounts:    []uint64{3, 4, 4, 5, 5, 5, 5, 5},
+		DurationSum:       2500 * time.Millisecond,
+	})
+
+	for _, line := range []string{
+		"mcp_ssh_active_connections 2",
+		"mcp_ssh_commands_executed_total 5",
+		"mcp_ssh_commands_failed_total 1",
+		`mcp_ssh_command_duration_seconds_bucket{le="0.5"} 4`,
+		`mcp_ssh_command_duration_seconds_bucket{le="300"} 5`,
+		`mcp_ssh_command_duration_seconds_bucket{le="+Inf"} 5`,
+		"mcp_ssh_command_duration_seconds_sum 2.5",
+		"mcp_ssh_command_duration_seconds_count 5",
+	} {
+		if !strings.Contains(out.String(), line+"\n") {
+			t.Errorf("expected line %q in:\n%s", line, out.String())
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package mcp
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/sirupsen/logrus"
+)
+
+// CommandPolicy restricts the commands agents may run. A command matching a
+// deny pattern is rejected, and so is, when there are allow patterns, one
+// matching none of them. Patterns are regular expressions searched for in the
+// whole command as given, so they must be anchored (^...$) to match it
+// entirely. The check does not parse the command: a policy is a guard rail
+// for well-behaved agents, not a sandbox.
+type CommandPolicy struct {
+	allow []*regexp.Regexp
+	deny  []*regexp.Regexp
+}
+
+// NewCommandPolicy compiles the allow and deny patterns of a command policy
+func NewCommandPolicy(allow, deny []string) (*CommandPolicy, error) {
+	allowed, err := compilePatterns(allow)
+	if err != nil {
+		return nil, fmt.Errorf("invalid allowed command pattern: %w", err)
+	}
+	denied, err := compilePatterns(deny)
+	if err != nil {
+		return nil, fmt.Errorf("invalid denied command pattern: %w", err)
+	}
+	return &CommandPolicy{allow: allowed, deny: denied}, nil
+}
+
+// compilePatterns compiles regular expressions, skipping empty ones
+func compilePatterns(patterns []string) ([]*regexp.Regexp, error) {
+	var compiled []*regexp.Regexp
+	for _, pattern := range patterns {
+		if pattern == "" {
+			continue
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
+
+// Check returns an error if the policy forbids command. A nil policy allows
+// every command.
+func (p *CommandPolicy) Check(command string) error {
+	if p == nil {
+		return nil
+	}
+	for _, re := range p.deny {
+		if re.MatchString(command) {
+			return fmt.Errorf("command rejected by policy: it matches the denied pattern '%s'", re)
+		}
+	}
+	if len(p.allow) == 0 {
+		return nil
+	}
+	for _, re := range p.allow {
+		if re.MatchString(command) {
+			return nil
+		}
+	}
+	return fmt.Errorf("command rejected by policy: it matches none of the allowed patterns")
+}
+
+// CheckPrefixed checks command and, with a command prefix, the command line
+// actually run: the prefix followed by the command
+func (p *CommandPolicy) CheckPrefixed(prefix, command string) error {
+	if err := p.Check(command); err != nil || prefix == "" {
+		return err
+	}
+	return p.Check(prefix + " " + command)
+}
+
+// HandlerOption configures Handlers
+type HandlerOption func(*Handlers)
+
+// WithCommandPolicy rejects the commands policy forbids in every tool that
+// runs a command given by the agent, and command prefixes it forbids
+func WithCommandPolicy(policy *CommandPolicy) HandlerOption {
+	return func(h *Handlers) {
+		h.policy = policy
+	}
+}
+
+// checkCommandPolicy checks command, as run on the connection with its
+// command prefix, against the command policy, logging a rejection
+func (h *Handlers) checkCommandPolicy(logger *logrus.Logger, connectionID, command string) error {
+	var prefix string
+	if h.policy != nil && connectionID != "" {
+		if info, err := h.manager.Info(connectionID); err == nil {
+			prefix = info.CommandPrefix
+		}
+	}
+	err := h.policy.CheckPrefixed(prefix, command)
+	if err != nil {
+		logger.WithFields(logrus.Fields{
+			"connection_id": connectionID,
+			"command":       command,
+			"prefix":        prefix,
+		}).WithError(err).Warn("Command rejected by policy")
+	}
+	return err
+}
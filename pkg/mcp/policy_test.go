@@ -0,0 +1,105 @@
+package mcp
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestCommandPolicy(t *testing.T) {
+	tests := []struct {
+		name     string
+		allow    []string
+		deny     []string
+		command  string
+		expected string
+	}{
+		{name: "no patterns", command: "rm -rf /tmp/x"},
+		{name: "denied", deny: []string{`\brm\b`}, command: "rm -rf /tmp/x", expected: "denied pattern"},
+		{name: "not denied", deny: []string{`\brm\b`}, command: "ls /tmp"},
+		{name: "allowed", allow: []string{`^ls\b`, `^cat\b`}, command: "cat /etc/hostname"},
+		{name: "not allowed", allow: []string{`^ls\b`}, command: "cat /etc/hostname", expected: "none of the allowed patterns"},
+		{name: "deny takes precedence", allow: []string{`^ls\b`}, deny: []string{`;`}, command: "ls; reboot", expected: "denied pattern"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy, err := NewCommandPolicy(tt.allow, tt.deny)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			err = policy.Check(tt.command)
+			if tt.expected == "" {
+				if err != nil {
+					t.Errorf("expected the command to be allowed, got %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.expected) {
+				t.Errorf("expected an error containing %q, got %v", tt.expected, err)
+			}
+		})
+	}
+
+	// The prefix is checked along with the command it is placed before
+	policy, err := NewCommandPolicy(nil, []string{`^sudo reboot\b`})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := policy.CheckPrefixed("", "reboot"); err != nil {
+		t.Errorf("expected the command to be allowed without a prefix, got %v", err)
+	}
+	if err := policy.CheckPrefixed("sudo", "reboot"); err == nil || !strings.Contains(err.Error(), "denied pattern") {
+		t.Errorf("expected the prefixed command to be denied, got %v", err)
+	}
+
+	policy = nil
+	if err := policy.Check("reboot"); err != nil {
+		t.Errorf("expected a nil policy to allow everything, got %v", err)
+	}
+
+	if _, err := NewCommandPolicy([]string{"("}, nil); err == nil {
+		t.Errorf("expected an invalid pattern to be rejected")
+	}
+}
+
+func TestCommandPolicy_Handlers(t *testing.T) {
+	policy, err := NewCommandPolicy(nil, []string{`\breboot\b`})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	handlers := newTestHandlers(t, WithCommandPolicy(policy))
+
+	req := mcp.CallToolRequest{}
+	req.Params.Arguments = map[string]interface{}{"connection_id": "default", "command": "sudo reboot"}
+	result, err := handlers.HandleExecute(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// The policy is checked before the connection is looked up
+	text := result.Content[0].(mcp.TextContent).Text
+	if !result.IsError || !strings.Contains(text, "rejected by policy") {
+		t.Errorf("expected the command to be rejected by the policy, got %q", text)
+	}
+}
+
+func TestCommandPolicy_SetPrefix(t *testing.T) {
+	policy, err := NewCommandPolicy(nil, []string{`\brm\b`})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	handlers := newTestHandlers(t, WithCommandPolicy(policy))
+
+	req := mcp.CallToolRequest{}
+	req.Params.Arguments = map[string]interface{}{"connection_id": "default", "prefix": "rm  -rf /srv"}
+	result, err := handlers.HandleSetPrefix(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	text := result.Content[0].(mcp.TextContent).Text
+	if !result.IsError || !strings.Contains(text, "rejected by policy") {
+		t.Errorf("expected the prefix to be rejected by the policy, got %q", text)
+	}
+}
@@ -0,0 +1,60 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/sirupsen/logrus"
+)
+
+// HandleListeningPorts handles the ssh_listening_ports tool
+func (h *Handlers) HandleListeningPorts(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	connectionID, err := req.RequireString("connection_id")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	if err := validateConnectionID(connectionID); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	logger := h.connLogger(connectionID)
+
+	logger.WithFields(logrus.Fields{
+		"connection_id": connectionID,
+	}).Debug("Listing listening ports")
+
+	ports, source, err := h.manager.ListeningPorts(connectionID)
+	if err != nil {
+		logger.WithError(err).Error("Failed to list listening ports")
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to list listening ports: %v", err)), nil
+	}
+
+	processesVisible := false
+	portList := make([]ListeningPortResponse, len(ports))
+	for i, port := range ports {
+		portList[i] = ListeningPortResponse{
+			Proto:        port.Proto,
+			LocalAddress: port.LocalAddress,
+			Port:         port.Port,
+			PID:          port.PID,
+			Program:      port.Program,
+		}
+		if port.PID != 0 {
+			processesVisible = true
+		}
+	}
+
+	response := ListeningPortsResponse{
+		Success: true,
+		Source:  source,
+		Ports:   portList,
+		Count:   len(ports),
+	}
+	if len(ports) > 0 && !processesVisible {
+		response.Note = "Owning processes are not visible to this user; root is usually required to see pid and program"
+	}
+
+	return h.toolResult(response)
+}
@@ -0,0 +1,714 @@
+package mcp
+
+// Tool responses. Each tool marshals one of these types, and main.go attaches
+// it as the tool's output schema. Fields that only apply to some calls are
+// omitted when unset; pointers are used where the zero value is meaningful.
+
+// ReconnectInfo flags a result whose command ran on a re-established
+// connection
+type ReconnectInfo struct {
+	Reconnected      bool   `json:"reconnected,omitempty"`
+	ReconnectWarning string `json:"reconnect_warning,omitempty"`
+}
+
+// ErrorResponse is the result of a tool call that failed with a classified
+// error. ErrorCode is one of the ssh.ErrorCode values, e.g. host_not_allowed
+// or auth_failed.
+type ErrorResponse struct {
+	Success   bool   `json:"success"`
+	Error     string `json:"error"`
+	ErrorCode string `json:"error_code"`
+}
+
+// ConnectResponse is the result of ssh_connect
+type ConnectResponse struct {
+	Success       bool   `json:"success"`
+	ConnectionID  string `json:"connection_id"`
+	Host          string `json:"host"`
+	Port          int    `json:"port"`
+	Username      string `json:"username"`
+	Reused        bool   `json:"reused"`
+	Replaced      bool   `json:"replaced"`
+	AutoReconnect bool   `json:"auto_reconnect"`
+	Message       string `json:"message"`
+
+	// ServerRestarted is set when the connection ID was in use before the
+	// server restarted, so state the caller expects from it is gone
+	ServerRestarted bool `json:"server_restarted,omitempty"`
+
+	// HostKeyFingerprint is the SHA256 fingerprint of the server's host key
+	HostKeyFingerprint string `json:"host_key_fingerprint"`
+
+	// Warnings are problems that did not prevent connecting, such as a
+	// private key file others may read
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// ExecuteResponse is the result of ssh_execute. Stdout is empty when it was
+// redirected with output_to.
+type ExecuteResponse struct {
+	Success  bool   `json:"success"`
+	Stdout   string `json:"stdout"`
+	Stderr   string `json:"stderr"`
+	ExitCode int    `json:"exit_code"`
+
+	// Output is set with interleaved
+	Output []OutputChunkResponse `json:"output,omitempty"`
+
+	// Timing is set with timing
+	Timing *TimingResponse `json:"timing,omitempty"`
+
+	// StartedAt and FinishedAt are UTC RFC 3339 timestamps with millisecond
+	// precision; FinishedAt is omitted for a command completed by idle
+	StartedAt  string `json:"started_at,omitempty"`
+	FinishedAt string `json:"finished_at,omitempty"`
+
+	// StdoutSHA256 and StdoutBytes are set with hash_output and cover stdout
+	// as written by the command, before filtering and trimming
+	StdoutSHA256 string `json:"stdout_sha256,omitempty"`
+	StdoutBytes  *int64 `json:"stdout_bytes,omitempty"`
+
+	// OutputTo and TeeTo echo the file stdout was written to, along with
+	// its size; Truncated reports whether the tee preview was cut short, or
+	// otherwise is set when the output exceeded the size limit
+	OutputTo     string `json:"output_to,omitempty"`
+	TeeTo        string `json:"tee_to,omitempty"`
+	BytesWritten *int64 `json:"bytes_written,omitempty"`
+	Truncated    *bool  `json:"truncated,omitempty"`
+
+	// RequestID echoes request_id; Cancelled is set when ssh_cancel
+	// interrupted the command
+	RequestID string `json:"request_id,omitempty"`
+	Cancelled bool   `json:"cancelled,omitempty"`
+
+	// CompletedByIdle is set with idle_complete_seconds when the command went
+	// quiet and was left running; exit_code is then -1
+	CompletedByIdle bool `json:"completed_by_idle,omitempty"`
+
+	// BudgetWarning is set once the connection's time budget is running out
+	BudgetWarning string `json:"budget_warning,omitempty"`
+
+	ReconnectInfo
+}
+
+// ExecuteTableResponse is the result of ssh_execute_table. Rows is set
+// without header; Columns and Records are set with it. They are omitted when
+// the output has no rows.
+type ExecuteTableResponse struct {
+	Success  bool   `json:"success"`
+	ExitCode int    `json:"exit_code"`
+	Stderr   string `json:"stderr"`
+	Count    int    `json:"count"`
+
+	Rows    [][]string          `json:"rows,omitempty"`
+	Columns []string            `json:"columns,omitempty"`
+	Records []map[string]string `json:"records,omitempty"`
+
+	ReconnectInfo
+}
+
+// TransferResponse is the result of ssh_upload and ssh_download. LocalPath is
+// omitted for an inline download, which sets ContentBase64 instead.
+type TransferResponse struct {
+	Success    bool   `json:"success"`
+	LocalPath  string `json:"local_path,omitempty"`
+	RemotePath string `json:"remote_path"`
+	Bytes      int64  `json:"bytes"`
+
+	// Mode holds the permission bits in octal, e.g. "0644"
+	Mode string `json:"mode"`
+
+	ContentBase64 string `json:"content_base64,omitempty"`
+}
+
+// ExecuteGlobResponse is the result of ssh_execute_glob. Results and Failed
+// are keyed by connection ID.
+type ExecuteGlobResponse struct {
+	Success bool                        `json:"success"`
+	Matched int                         `json:"matched"`
+	Results map[string]GlobHostResponse `json:"results"`
+	Failed  map[string]GlobHostFailure  `json:"failed,omitempty"`
+}
+
+// GlobHostResponse is the outcome of the command on one matched connection
+type GlobHostResponse struct {
+	Host     string `json:"host"`
+	Stdout   string `json:"stdout"`
+	Stderr   string `json:"stderr"`
+	ExitCode int    `json:"exit_code"`
+
+	ReconnectInfo
+}
+
+// GlobHostFailure is a matched connection the command could not be run on
+type GlobHostFailure struct {
+	Host  string `json:"host"`
+	Error string `json:"error"`
+}
+
+// OutputChunkResponse is a piece of output in arrival order
+type OutputChunkResponse struct {
+	Stream   string `json:"stream"`
+	Data     string `json:"data"`
+	OffsetMS int64  `json:"offset_ms"`
+}
+
+// TimingResponse breaks down where the time of a command was spent
+type TimingResponse struct {
+	ConnectWaitMS int64 `json:"connect_wait_ms"`
+	ExecMS        int64 `json:"exec_ms"`
+	ReadMS        int64 `json:"read_ms"`
+}
+
+// CloseResponse is the result of ssh_close
+type CloseResponse struct {
+	Success      bool   `json:"success"`
+	ConnectionID string `json:"connection_id"`
+	Message      string `json:"message"`
+}
+
+// ReconnectResponse is the result of ssh_reconnect
+type ReconnectResponse struct {
+	Success      bool   `json:"success"`
+	ConnectionID string `json:"connection_id"`
+	Reconnects   int    `json:"reconnects"`
+	Message      string `json:"message"`
+}
+
+// ForgetCredentialsResponse is the result of ssh_forget_credentials
+type ForgetCredentialsResponse struct {
+	Success      bool   `json:"success"`
+	ConnectionID string `json:"connection_id"`
+	Forgotten    bool   `json:"forgotten"`
+	Message      string `json:"message"`
+}
+
+// CancelResponse is the result of ssh_cancel
+type CancelResponse struct {
+	Success   bool   `json:"success"`
+	RequestID string `json:"request_id"`
+	Found     bool   `json:"found"`
+	Cancelled bool   `json:"cancelled"`
+
+	// InterruptedPIDs are the processes of the command sent SIGINT
+	InterruptedPIDs []int  `json:"interrupted_pids"`
+	Message         string `json:"message"`
+}
+
+// InterruptResponse is the result of ssh_interrupt
+type InterruptResponse struct {
+	Success      bool   `json:"success"`
+	ConnectionID string `json:"connection_id"`
+
+	// Command is the command that was running, and RequestID its request ID
+	// if it was given one
+	Command   string `json:"command"`
+	RequestID string `json:"request_id,omitempty"`
+
+	// InterruptedPIDs are the processes of the command sent SIGINT
+	InterruptedPIDs []int  `json:"interrupted_pids"`
+	Message         string `json:"message"`
+}
+
+// ListResponse is the result of ssh_list
+type ListResponse struct {
+	Success      bool                 `json:"success"`
+	Connections  []ConnectionResponse `json:"connections"`
+	Count        int                  `json:"count"`
+	RunningExecs int                  `json:"running_execs"`
+	QueuedExecs  int                  `json:"queued_execs"`
+
+	// LostOnRestart lists the connection IDs that were open before the
+	// server restarted and have not been connected again
+	LostOnRestart []string `json:"lost_on_restart,omitempty"`
+}
+
+// InfoResponse is the result of ssh_info
+type InfoResponse struct {
+	Success    bool               `json:"success"`
+	Connection ConnectionResponse `json:"connection"`
+
+	// Alive is set when the server answered a keepalive; AliveError says
+	// why it did not otherwise
+	Alive      bool   `json:"alive"`
+	AliveError string `json:"alive_error,omitempty"`
+
+	// WorkingDirectory and OS (uname -s) are queried from an idle shell
+	WorkingDirectory string `json:"working_directory,omitempty"`
+	OS               string `json:"os,omitempty"`
+
+	// RunningCommand is the command the shell is busy with, which keeps the
+	// working directory and OS from being queried, and RunningSince when it
+	// started
+	RunningCommand string `json:"running_command,omitempty"`
+	RunningSince   string `json:"running_since,omitempty"`
+}
+
+// ConnectionResponse describes an open connection
+type ConnectionResponse struct {
+	ConnectionID  string `json:"connection_id"`
+	Host          string `json:"host"`
+	Port          int    `json:"port"`
+	Username      string `json:"username"`
+	Created       string `json:"created"`
+	AutoReconnect bool   `json:"auto_reconnect"`
+	Reconnects    int    `json:"reconnects"`
+
+	// LastUsed is when the last command finished (the creation time if none
+	// has), as a UTC RFC 3339 timestamp with millisecond precision;
+	// IdleSeconds is the time since then and UptimeSeconds the time since
+	// the connection was created
+	LastUsed      string  `json:"last_used"`
+	IdleSeconds   float64 `json:"idle_seconds"`
+	UptimeSeconds float64 `json:"uptime_seconds"`
+
+	// ReconnectState is "ok", "backoff" or "failed"; the other reconnect
+	// fields describe the failed attempts behind it
+	ReconnectState     string `json:"reconnect_state"`
+	ReconnectFailures  int    `json:"reconnect_failures,omitempty"`
+	ReconnectRetryAt   string `json:"reconnect_retry_at,omitempty"`
+	LastReconnectError string `json:"last_reconnect_error,omitempty"`
+
+	// CommandPrefix is set with ssh_set_prefix
+	CommandPrefix string `json:"command_prefix,omitempty"`
+
+	// TimeUsedSeconds is the command time used; the other time fields are
+	// set when the connection has a time budget
+	TimeUsedSeconds      float64  `json:"time_used_seconds"`
+	TimeBudgetSeconds    float64  `json:"time_budget_seconds,omitempty"`
+	TimeRemainingSeconds *float64 `json:"time_remaining_seconds,omitempty"`
+	BudgetWarning        string   `json:"budget_warning,omitempty"`
+
+	// LogLevel is the level operations on the connection are logged at, the
+	// server's unless overridden with log_level on ssh_connect
+	LogLevel string `json:"log_level"`
+
+	// HostKeyFingerprint is the SHA256 fingerprint of the server's host key
+	HostKeyFingerprint string `json:"host_key_fingerprint"`
+
+	// Tags are the labels given with tags on ssh_connect
+	Tags map[string]string `json:"tags,omitempty"`
+}
+
+// ServerConfigResponse is the result of ssh_server_config
+type ServerConfigResponse struct {
+	Success                    bool     `json:"success"`
+	Version                    string   `json:"version"`
+	Transport                  string   `json:"transport"`
+	LogLevel                   string   `json:"log_level"`
+	Tools                      []string `json:"tools"`
+	HostKeyMode                string   `json:"host_key_mode"`
+	AllowedHostPatterns        int      `json:"allowed_host_patterns"`
+	SFTPAllowedPathPatterns    int      `json:"sftp_allowed_path_patterns"`
+	SFTPDeniedPathPatterns     int      `json:"sftp_denied_path_patterns"`
+	MaxConnections             int      `json:"max_connections"`
+	MaxConcurrentExecs         int      `json:"max_concurrent_execs"`
+	ExecQueueSize              int      `json:"exec_queue_size"`
+	ActiveConnections          int      `json:"active_connections"`
+	DialTimeoutSeconds         float64  `json:"dial_timeout_seconds"`
+	CommandTimeoutSeconds      float64  `json:"command_timeout_seconds"`
+	IdleOutputThresholdSeconds float64  `json:"idle_output_threshold_seconds"`
+	MaxCommandBytes            int      `json:"max_command_bytes"`
+	MaxOutputBytes             int      `json:"max_output_bytes"`
+	Presets                    []string `json:"presets"`
+	TimeBudgetSeconds          float64  `json:"time_budget_seconds"`
+}
+
+// ListKeysResponse is the result of ssh_list_keys
+type ListKeysResponse struct {
+	Success    bool          `json:"success"`
+	Keys       []KeyResponse `json:"keys"`
+	Count      int           `json:"count"`
+	AgentError string        `json:"agent_error,omitempty"`
+	FilesError string        `json:"files_error,omitempty"`
+}
+
+// KeyResponse describes a key from the SSH agent or ~/.ssh
+type KeyResponse struct {
+	Source         string `json:"source"`
+	Type           string `json:"type"`
+	Fingerprint    string `json:"fingerprint"`
+	Comment        string `json:"comment"`
+	PrivateKeyPath string `json:"private_key_path,omitempty"`
+}
+
+// ShellSettingsResponse is the result of ssh_shell_settings
+type ShellSettingsResponse struct {
+	Success                    bool    `json:"success"`
+	ConnectionID               string  `json:"connection_id"`
+	HistoryDisabled            bool    `json:"history_disabled"`
+	SubshellPerCommand         bool    `json:"subshell_per_command"`
+	PTY                        bool    `json:"pty"`
+	CommandPrefix              string  `json:"command_prefix"`
+	HistFile                   string  `json:"histfile"`
+	HistSize                   string  `json:"histsize"`
+	CommandTimeoutSeconds      float64 `json:"command_timeout_seconds"`
+	IdleOutputThresholdSeconds float64 `json:"idle_output_threshold_seconds"`
+}
+
+// SetPrefixResponse is the result of ssh_set_prefix. Prefix is empty once
+// cleared.
+type SetPrefixResponse struct {
+	Success      bool   `json:"success"`
+	ConnectionID string `json:"connection_id"`
+	Prefix       string `json:"prefix"`
+	Message      string `json:"message"`
+}
+
+// TimeBudgetResponse is the result of ssh_time_budget. The budget fields
+// are 0 when unlimited.
+type TimeBudgetResponse struct {
+	Success             bool    `json:"success"`
+	ConnectionID        string  `json:"connection_id"`
+	BudgetSeconds       float64 `json:"budget_seconds"`
+	UsedSeconds         float64 `json:"used_seconds"`
+	RemainingSeconds    float64 `json:"remaining_seconds"`
+	ServerBudgetSeconds float64 `json:"server_budget_seconds"`
+	Exhausted           bool    `json:"exhausted"`
+	BudgetWarning       string  `json:"budget_warning,omitempty"`
+}
+
+// SudoResponse is the result of ssh_sudo
+type SudoResponse struct {
+	Success    bool   `json:"success"`
+	Stdout     string `json:"stdout"`
+	Stderr     string `json:"stderr"`
+	ExitCode   int    `json:"exit_code"`
+	AuthFailed bool   `json:"auth_failed"`
+	AuthError  string `json:"auth_error,omitempty"`
+	Message    string `json:"message,omitempty"`
+
+	ReconnectInfo
+}
+
+// ScriptResponse is the result of ssh_exec_script
+type ScriptResponse struct {
+	Success bool `json:"success"`
+
+	// Output is the script's stdout and stderr, interleaved as written
+	Output      string `json:"output"`
+	ExitCode    int    `json:"exit_code"`
+	Interpreter string `json:"interpreter"`
+
+	ReconnectInfo
+}
+
+// WorkflowResponse is the result of ssh_run_workflow
+type WorkflowResponse struct {
+	Success   bool                   `json:"success"`
+	Steps     []WorkflowStepResponse `json:"steps"`
+	Succeeded int                    `json:"succeeded"`
+	Total     int                    `json:"total"`
+	ElapsedMS int64                  `json:"elapsed_ms"`
+
+	// BudgetExhausted is set when a deadline was given
+	BudgetExhausted *bool `json:"budget_exhausted,omitempty"`
+}
+
+// WorkflowStepResponse is the outcome of one workflow step. The command
+// output is only present for steps that ran.
+type WorkflowStepResponse struct {
+	ID           string `json:"id"`
+	ConnectionID string `json:"connection_id"`
+	Status       string `json:"status"`
+	DurationMS   int64  `json:"duration_ms"`
+	Stdout       string `json:"stdout,omitempty"`
+	Stderr       string `json:"stderr,omitempty"`
+	ExitCode     *int   `json:"exit_code,omitempty"`
+	Error        string `json:"error,omitempty"`
+}
+
+// GitResponse is the result of ssh_git
+type GitResponse struct {
+	Success   bool   `json:"success"`
+	Operation string `json:"operation"`
+	ExitCode  int    `json:"exit_code"`
+	Stderr    string `json:"stderr"`
+
+	// Stdout is the raw output of pull and checkout
+	Stdout string `json:"stdout,omitempty"`
+
+	Status  *GitStatusResponse  `json:"status,omitempty"`
+	Commits []GitCommitResponse `json:"commits,omitempty"`
+}
+
+// GitStatusResponse describes a working tree. Ahead and Behind are set when
+// the branch has an upstream.
+type GitStatusResponse struct {
+	Branch   string            `json:"branch"`
+	Commit   string            `json:"commit"`
+	Detached bool              `json:"detached"`
+	Dirty    bool              `json:"dirty"`
+	Files    []GitFileResponse `json:"files"`
+	Upstream string            `json:"upstream,omitempty"`
+	Ahead    *int              `json:"ahead,omitempty"`
+	Behind   *int              `json:"behind,omitempty"`
+}
+
+// GitFileResponse is a changed file in a working tree
+type GitFileResponse struct {
+	Path     string `json:"path"`
+	Index    string `json:"index"`
+	Worktree string `json:"worktree"`
+	OrigPath string `json:"orig_path,omitempty"`
+}
+
+// GitCommitResponse is a commit listed by the log operation
+type GitCommitResponse struct {
+	Hash        string `json:"hash"`
+	Author      string `json:"author"`
+	AuthorEmail string `json:"author_email"`
+	Date        string `json:"date"`
+	Subject     string `json:"subject"`
+}
+
+// CommandStatsResponse is the result of ssh_command_stats. Only Running is
+// meaningful when the shell is idle.
+type CommandStatsResponse struct {
+	Success    bool              `json:"success"`
+	Running    bool              `json:"running"`
+	Command    string            `json:"command"`
+	ElapsedMS  int64             `json:"elapsed_ms"`
+	ShellPID   int               `json:"shell_pid"`
+	Processes  []ProcessResponse `json:"processes"`
+	CPUPercent float64           `json:"cpu_percent"`
+	CPUTimeMS  int64             `json:"cpu_time_ms"`
+	RSSKB      int64             `json:"rss_kb"`
+	Note       string            `json:"note,omitempty"`
+}
+
+// JobsResponse is the result of ssh_jobs
+type JobsResponse struct {
+	Success      bool          `json:"success"`
+	ConnectionID string        `json:"connection_id"`
+	Jobs         []JobResponse `json:"jobs"`
+	Count        int           `json:"count"`
+}
+
+// JobResponse describes a background job of the persistent shell
+type JobResponse struct {
+	Number  int    `json:"number"`
+	Current bool   `json:"current"`
+	PID     int    `json:"pid"`
+	PIDs    []int  `json:"pids"`
+	State   string `json:"state"`
+	Command string `json:"command,omitempty"`
+}
+
+// ProcessResponse describes a process started by the running command
+type ProcessResponse struct {
+	PID         int     `json:"pid"`
+	PPID        int     `json:"ppid"`
+	PGID        int     `json:"pgid"`
+	State       string  `json:"state"`
+	CPUPercent  float64 `json:"cpu_percent"`
+	CPUTimeMS   int64   `json:"cpu_time_ms"`
+	RSSKB       int64   `json:"rss_kb"`
+	ElapsedMS   int64   `json:"elapsed_ms"`
+	CommandLine string  `json:"command_line"`
+}
+
+// ContainerInfoResponse is the result of ssh_container_info. Found and Active
+// are omitted when no runtime is installed or usable.
+type ContainerInfoResponse struct {
+	Success  bool                       `json:"success"`
+	Found    string                     `json:"found,omitempty"`
+	Active   string                     `json:"active,omitempty"`
+	Runtimes []ContainerRuntimeResponse `json:"runtimes"`
+}
+
+// ContainerRuntimeResponse describes an installed container runtime.
+// RunningContainers is set when the runtime is usable.
+type ContainerRuntimeResponse struct {
+	Name              string `json:"name"`
+	Path              string `json:"path"`
+	Version           string `json:"version"`
+	ServerVersion     string `json:"server_version,omitempty"`
+	Running           bool   `json:"running"`
+	RunningContainers *int   `json:"running_containers,omitempty"`
+	Error             string `json:"error,omitempty"`
+}
+
+// ListeningPortsResponse is the result of ssh_listening_ports
+type ListeningPortsResponse struct {
+	Success bool                    `json:"success"`
+	Source  string                  `json:"source"`
+	Ports   []ListeningPortResponse `json:"ports"`
+	Count   int                     `json:"count"`
+	Note    string                  `json:"note,omitempty"`
+}
+
+// ListeningPortResponse is a listening socket. PID and Program are only
+// visible for processes the remote user may inspect.
+type ListeningPortResponse struct {
+	Proto        string `json:"proto"`
+	LocalAddress string `json:"local_address"`
+	Port         int    `json:"port"`
+	PID          int    `json:"pid,omitempty"`
+	Program      string `json:"program,omitempty"`
+}
+
+// HostKeyResponse is the result of ssh_hostkey
+type HostKeyResponse struct {
+	Success     bool   `json:"success"`
+	Host        string `json:"host"`
+	Port        int    `json:"port"`
+	KeyType     string `json:"key_type"`
+	Fingerprint string `json:"fingerprint"`
+	Message     string `json:"message"`
+}
+
+// TCPCheckResponse is the result of ssh_tcp_check
+type TCPCheckResponse struct {
+	Success    bool   `json:"success"`
+	Host       string `json:"host"`
+	Port       int    `json:"port"`
+	Reachable  bool   `json:"reachable"`
+	RemoteAddr string `json:"remote_addr,omitempty"`
+	LatencyMS  int64  `json:"latency_ms"`
+	Reason     string `json:"reason,omitempty"`
+	Error      string `json:"error,omitempty"`
+	Message    string `json:"message"`
+}
+
+// ReadFilesResponse is the result of ssh_read_files, keyed by path
+type ReadFilesResponse struct {
+	Success bool                        `json:"success"`
+	Files   map[string]ReadFileResponse `json:"files"`
+	Read    int                         `json:"read"`
+	Failed  int                         `json:"failed"`
+}
+
+// ReadFileResponse is the content of one file, or the error reading it
+type ReadFileResponse struct {
+	Size      *int64  `json:"size,omitempty"`
+	Truncated *bool   `json:"truncated,omitempty"`
+	Content   *string `json:"content,omitempty"`
+
+	// Encoding is "base64" for content that is not valid UTF-8
+	Encoding string `json:"encoding,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// ListDirResponse is the result of ssh_ls
+type ListDirResponse struct {
+	Success bool               `json:"success"`
+	Path    string             `json:"path"`
+	Entries []DirEntryResponse `json:"entries"`
+
+	// Total counts every entry of the directory, more than returned when
+	// Truncated is set
+	Total     int  `json:"total"`
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// DirEntryResponse is one entry of a directory listing
+type DirEntryResponse struct {
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+
+	// Mode holds the permission bits in octal, e.g. "0644"
+	Mode    string `json:"mode"`
+	ModTime string `json:"modtime"`
+	IsDir   bool   `json:"is_dir"`
+
+	// Type is "file", "dir", "symlink" or "other"
+	Type string `json:"type"`
+}
+
+// WatchResponse is the result of ssh_watch
+type WatchResponse struct {
+	Success       bool              `json:"success"`
+	Path          string            `json:"path"`
+	Changed       bool              `json:"changed"`
+	Change        string            `json:"change,omitempty"`
+	WaitedSeconds float64           `json:"waited_seconds"`
+	Before        FileStateResponse `json:"before"`
+	After         FileStateResponse `json:"after"`
+}
+
+// FileStateResponse is the state of a watched file; Size and Modified are
+// set when it exists
+type FileStateResponse struct {
+	Exists   bool   `json:"exists"`
+	Size     *int64 `json:"size,omitempty"`
+	Modified string `json:"modified,omitempty"`
+}
+
+// LoadEnvResponse is the result of ssh_load_env. Only variable names are
+// reported; values may be secrets.
+type LoadEnvResponse struct {
+	Success   bool              `json:"success"`
+	Path      string            `json:"path"`
+	Loaded    int               `json:"loaded"`
+	Variables []string          `json:"variables"`
+	Skipped   []EnvLineResponse `json:"skipped"`
+}
+
+// SetEnvResponse is the result of ssh_setenv. The value is not echoed, as it
+// may be a secret.
+type SetEnvResponse struct {
+	Success      bool   `json:"success"`
+	ConnectionID string `json:"connection_id"`
+	Name         string `json:"name"`
+	Message      string `json:"message"`
+}
+
+// GetEnvResponse is the result of ssh_getenv: the variable given by name,
+// or every variable without one
+type GetEnvResponse struct {
+	Success      bool   `json:"success"`
+	ConnectionID string `json:"connection_id"`
+	Name         string `json:"name,omitempty"`
+
+	// Set tells whether the variable is in the environment, which an empty
+	// Value alone does not
+	Set   *bool   `json:"set,omitempty"`
+	Value *string `json:"value,omitempty"`
+
+	Variables map[string]string `json:"variables,omitempty"`
+	Count     *int              `json:"count,omitempty"`
+}
+
+// EnvLineResponse is a line of an env file that was not loaded
+type EnvLineResponse struct {
+	Line   int    `json:"line"`
+	Reason string `json:"reason"`
+}
+
+// CaptureResponse is the result of ssh_capture
+type CaptureResponse struct {
+	Success     bool   `json:"success"`
+	ArtifactID  string `json:"artifact_id"`
+	ExitCode    int    `json:"exit_code"`
+	StdoutBytes int    `json:"stdout_bytes"`
+	StdoutLines int    `json:"stdout_lines"`
+	StderrBytes int    `json:"stderr_bytes"`
+	StderrLines int    `json:"stderr_lines"`
+	ExpiresAt   string `json:"expires_at"`
+}
+
+// ExecuteToLocalResponse is the result of ssh_execute_to_local
+type ExecuteToLocalResponse struct {
+	Success      bool   `json:"success"`
+	LocalPath    string `json:"local_path"`
+	BytesWritten int64  `json:"bytes_written"`
+	ExitCode     int    `json:"exit_code"`
+	Truncated    bool   `json:"truncated"`
+	Stderr       string `json:"stderr,omitempty"`
+	DurationMS   int64  `json:"duration_ms"`
+	ReconnectInfo
+}
+
+// ArtifactGetResponse is the result of ssh_artifact_get
+type ArtifactGetResponse struct {
+	Success      bool   `json:"success"`
+	ArtifactID   string `json:"artifact_id"`
+	ConnectionID string `json:"connection_id"`
+	Stream       string `json:"stream"`
+	Data         string `json:"data"`
+	Offset       int    `json:"offset"`
+	NextOffset   int    `json:"next_offset"`
+	TotalBytes   int    `json:"total_bytes"`
+	HasMore      bool   `json:"has_more"`
+}
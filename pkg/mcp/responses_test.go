@@ -0,0 +1,170 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"testing"
+
+	"github.com/denysvitali/mcp-ssh/pkg/ssh"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/sirupsen/logrus"
+)
+
+// newTestHandlers returns handlers backed by a manager without connections
+func newTestHandlers(t *testing.T, options ...HandlerOption) *Handlers {
+	t.Helper()
+
+	validator, err := ssh.NewHostValidator("localhost")
+	if err != nil {
+		t.Fatalf("failed to create validator: %v", err)
+	}
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return NewHandlers(ssh.NewManager(validator), logger, options...)
+}
+
+// checkOutputSchema verifies that a tool result carries structured content
+// matching its text, and that every field the schema requires is present
+func checkOutputSchema(t *testing.T, tool mcp.Tool, result *mcp.CallToolResult) {
+	t.Helper()
+
+	if result.IsError {
+		t.Fatalf("unexpected error result: %+v", result.Content)
+	}
+	if result.StructuredContent == nil {
+		t.Fatalf("expected structured content")
+	}
+
+	text, ok := result.Content[0].(mcp.TextContent)
+	if !ok {
+		t.Fatalf("expected text content, got %T", result.Content[0])
+	}
+	structured, err := json.Marshal(result.StructuredContent)
+	if err != nil {
+		t.Fatalf("failed to marshal structured content: %v", err)
+	}
+	if string(structured) != text.Text {
+		t.Errorf("text content %s does not match structured content %s", text.Text, structured)
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal(structured, &fields); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	if len(tool.OutputSchema.Required) == 0 {
+		t.Fatalf("expected the schema to require fields")
+	}
+	for _, name := range tool.OutputSchema.Required {
+		value, present := fields[name]
+		if !present || value == nil {
+			t.Errorf("required field %q is missing from %s", name, structured)
+		}
+		if _, declared := tool.OutputSchema.Properties[name]; !declared {
+			t.Errorf("required field %q is not declared in the schema", name)
+		}
+	}
+	for name := range fields {
+		if _, declared := tool.OutputSchema.Properties[name]; !declared {
+			t.Errorf("field %q is not declared in the schema", name)
+		}
+	}
+}
+
+func TestOutputSchemas(t *testing.T) {
+	handlers := newTestHandlers(t)
+	ctx := context.Background()
+
+	t.Run("ssh_list", func(t *testing.T) {
+		tool := mcp.NewTool("ssh_list", mcp.WithOutputSchema[ListResponse]())
+		result, err := handlers.HandleList(ctx, mcp.CallToolRequest{})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		checkOutputSchema(t, tool, result)
+	})
+
+	t.Run("ssh_server_config", func(t *testing.T) {
+		tool := mcp.NewTool("ssh_server_config", mcp.WithOutputSchema[ServerConfigResponse]())
+		result, err := handlers.HandleServerConfig(ctx, mcp.CallToolRequest{})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		checkOutputSchema(t, tool, result)
+	})
+
+	t.Run("ssh_artifact_get", func(t *testing.T) {
+		stored, err := handlers.artifacts.add(&artifact{connectionID: "default", stdout: "hello"})
+		if err != nil {
+			t.Fatalf("failed to add artifact: %v", err)
+		}
+
+		tool := mcp.NewTool("ssh_artifact_get", mcp.WithOutputSchema[ArtifactGetResponse]())
+		req := mcp.CallToolRequest{}
+		req.Params.Arguments = map[string]interface{}{"artifact_id": stored.id}
+		result, err := handlers.HandleArtifactGet(ctx, req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		checkOutputSchema(t, tool, result)
+	})
+}
+
+func TestToolError(t *testing.T) {
+	handlers := newTestHandlers(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		call     func() (*mcp.CallToolResult, error)
+		expected string
+	}{
+		{
+			name: "ssh_connect host not allowed",
+			call: func() (*mcp.CallToolResult, error) {
+				req := mcp.CallToolRequest{}
+				req.Params.Arguments = map[string]interface{}{
+					"connection_id": "web",
+					"host":          "example.com",
+					"username":      "root",
+					"password":      "secret",
+				}
+				return handlers.HandleConnect(ctx, req)
+			},
+			expected: string(ssh.CodeHostNotAllowed),
+		},
+		{
+			name: "ssh_execute not found",
+			call: func() (*mcp.CallToolResult, error) {
+				req := mcp.CallToolRequest{}
+				req.Params.Arguments = map[string]interface{}{"connection_id": "missing", "command": "true"}
+				return handlers.HandleExecute(ctx, req)
+			},
+			expected: string(ssh.CodeNotFound),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := tt.call()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !result.IsError {
+				t.Fatalf("expected an error result")
+			}
+
+			text, ok := result.Content[0].(mcp.TextContent)
+			if !ok {
+				t.Fatalf("expected text content, got %T", result.Content[0])
+			}
+			var response ErrorResponse
+			if err := json.Unmarshal([]byte(text.Text), &response); err != nil {
+				t.Fatalf("expected a JSON error response, got %q", text.Text)
+			}
+			if response.Success || response.ErrorCode != tt.expected || response.Error == "" {
+				t.Errorf("expected error code %q, got %+v", tt.expected, response)
+			}
+		})
+	}
+}
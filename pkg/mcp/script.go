@@ -0,0 +1,81 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/denysvitali/mcp-ssh/pkg/ssh"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/sirupsen/logrus"
+)
+
+// HandleExecScript handles the ssh_exec_script tool
+func (h *Handlers) HandleExecScript(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	connectionID, err := req.RequireString("connection_id")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	if err := validateConnectionID(connectionID); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	logger := h.connLogger(connectionID)
+
+	script, err := req.RequireString("script")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	if err := h.checkCommandPolicy(logger, connectionID, script); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	interpreter := req.GetString("interpreter", ssh.DefaultScriptInterpreter)
+
+	var timeout time.Duration
+	if _, ok := req.GetArguments()["timeout_seconds"]; ok {
+		seconds := req.GetFloat("timeout_seconds", 0)
+		if seconds < 1 || seconds > ssh.MaxCommandTimeout.Seconds() {
+			return mcp.NewToolResultError(fmt.Sprintf("timeout_seconds must be between 1 and %.0f", ssh.MaxCommandTimeout.Seconds())), nil
+		}
+		timeout = time.Duration(seconds * float64(time.Second))
+	}
+
+	logger.WithFields(logrus.Fields{
+		"connection_id": connectionID,
+		"interpreter":   interpreter,
+		"script_bytes":  len(script),
+		"timeout":       timeout,
+	}).Debug("Executing script")
+
+	result, err := h.manager.ExecuteScript(connectionID, script, interpreter, timeout)
+	if err != nil {
+		h.auditCommand(connectionID, script, 0, 0, 0, err)
+		logger.WithError(err).Error("Failed to execute script")
+		return h.toolError("Failed to execute script", err)
+	}
+	h.auditCommand(connectionID, script, result.ExitCode, int64(len(result.Stdout)), int64(len(result.Stderr)), nil)
+
+	logger.WithFields(logrus.Fields{
+		"exit_code": result.ExitCode,
+	}).Debug("Script finished")
+
+	// Errors of the wrapper itself, e.g. failing to create the file, are on
+	// stderr; the script's own are merged into stdout
+	output := result.Stdout
+	if result.Stderr != "" {
+		if output != "" {
+			output += "\n"
+		}
+		output += result.Stderr
+	}
+
+	return h.toolResult(ScriptResponse{
+		Success:       true,
+		Output:        output,
+		ExitCode:      result.ExitCode,
+		Interpreter:   interpreter,
+		ReconnectInfo: reconnectInfo(result),
+	})
+}
@@ -0,0 +1,37 @@
+package mcp
+
+import (
+	"os"
+
+	"github.com/denysvitali/mcp-ssh/pkg/sshconfig"
+)
+
+// WithSSHConfig resolves the host given to ssh_connect as an alias of an SSH
+// client configuration, which supplies the host name, user, port and key
+// not given explicitly
+func WithSSHConfig(config *sshconfig.Config) HandlerOption {
+	return func(h *Handlers) {
+		h.sshConfig = config
+	}
+}
+
+// sshConfigHost returns the SSH config settings for a host alias, none
+// without an SSH config
+func (h *Handlers) sshConfigHost(alias string) (sshconfig.Host, error) {
+	if h.sshConfig == nil {
+		return sshconfig.Host{}, nil
+	}
+	return h.sshConfig.Resolve(alias)
+}
+
+// identityFile returns the first of a host's identity files that exists,
+// empty if none does. Like ssh, the default ones listed for "Host *" are
+// skipped when missing.
+func identityFile(host sshconfig.Host) string {
+	for _, path := range host.IdentityFiles {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
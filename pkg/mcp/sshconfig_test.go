@@ -0,0 +1,57 @@
+package mcp
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/denysvitali/mcp-ssh/pkg/sshconfig"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestConnectParams_SSHConfig(t *testing.T) {
+	dir := t.TempDir()
+	key := filepath.Join(dir, "prod_key")
+	if err := os.WriteFile(key, []byte("key"), 0600); err != nil {
+		t.Fatalf("failed to write key: %v", err)
+	}
+	config, err := sshconfig.Parse(strings.NewReader(`
+Host prod
+    HostName prod-01.example.com
+    User deploy
+    Port 2222
+    IdentityFile ` + filepath.Join(dir, "missing_key") + `
+    IdentityFile ` + key + `
+`))
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+	handlers := newTestHandlers(t, WithSSHConfig(config))
+
+	req := mcp.CallToolRequest{}
+	req.Params.Arguments = map[string]interface{}{"connection_id": "prod", "host": "prod"}
+	params, err := handlers.connectParams("prod", req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if params.Host != "prod-01.example.com" || params.Username != "deploy" || params.Port != 2222 || params.PrivateKeyPath != key {
+		t.Errorf("expected the alias to be resolved, got %+v", params)
+	}
+
+	// Explicit parameters take precedence, and credentials replace the key
+	req.Params.Arguments = map[string]interface{}{"connection_id": "prod", "host": "prod", "username": "root", "port": 22, "password": "secret"}
+	params, err = handlers.connectParams("prod", req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if params.Host != "prod-01.example.com" || params.Username != "root" || params.Port != 22 || params.PrivateKeyPath != "" {
+		t.Errorf("expected the explicit parameters to be used, got %+v", params)
+	}
+
+	// Hosts the config does not know still need a username
+	req.Params.Arguments = map[string]interface{}{"connection_id": "other", "host": "other.example.com", "password": "secret"}
+	if _, err := handlers.connectParams("other", req); err == nil || !strings.Contains(err.Error(), "username") {
+		t.Errorf("expected a missing username error, got %v", err)
+	}
+}
@@ -0,0 +1,108 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/sirupsen/logrus"
+)
+
+// HandleCommandStats handles the ssh_command_stats tool
+func (h *Handlers) HandleCommandStats(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	connectionID, err := req.RequireString("connection_id")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	if err := validateConnectionID(connectionID); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	logger := h.connLogger(connectionID)
+
+	logger.WithFields(logrus.Fields{
+		"connection_id": connectionID,
+	}).Debug("Reading running command stats")
+
+	stats, err := h.manager.CommandStats(connectionID)
+	if err != nil {
+		logger.WithError(err).Error("Failed to read command stats")
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to read command stats: %v", err)), nil
+	}
+
+	processes := make([]ProcessResponse, len(stats.Processes))
+	for i, process := range stats.Processes {
+		processes[i] = ProcessResponse{
+			PID:         process.PID,
+			PPID:        process.PPID,
+			PGID:        process.PGID,
+			State:       process.State,
+			CPUPercent:  process.CPUPercent,
+			CPUTimeMS:   process.CPUTime.Milliseconds(),
+			RSSKB:       process.RSSKB,
+			ElapsedMS:   process.Elapsed.Milliseconds(),
+			CommandLine: process.Command,
+		}
+	}
+
+	response := CommandStatsResponse{
+		Success:    true,
+		Running:    stats.Running,
+		Command:    stats.Command,
+		ElapsedMS:  stats.Elapsed.Milliseconds(),
+		ShellPID:   stats.ShellPID,
+		Processes:  processes,
+		CPUPercent: stats.CPUPercent,
+		CPUTimeMS:  stats.CPUTime.Milliseconds(),
+		RSSKB:      stats.RSSKB,
+	}
+	if stats.Running && len(stats.Processes) == 0 {
+		response.Note = "The command has no child processes; it is running shell builtins only or has just finished"
+	}
+
+	return h.toolResult(response)
+}
+
+// HandleJobs handles the ssh_jobs tool
+func (h *Handlers) HandleJobs(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	connectionID, err := req.RequireString("connection_id")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	if err := validateConnectionID(connectionID); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	logger := h.connLogger(connectionID)
+
+	logger.WithFields(logrus.Fields{
+		"connection_id": connectionID,
+	}).Debug("Listing background jobs")
+
+	jobs, err := h.manager.Jobs(connectionID)
+	if err != nil {
+		logger.WithError(err).Error("Failed to list background jobs")
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to list jobs: %v", err)), nil
+	}
+
+	response := JobsResponse{
+		Success:      true,
+		ConnectionID: connectionID,
+		Jobs:         make([]JobResponse, len(jobs)),
+		Count:        len(jobs),
+	}
+	for i, job := range jobs {
+		response.Jobs[i] = JobResponse{
+			Number:  job.Number,
+			Current: job.Current,
+			PID:     job.PIDs[0],
+			PIDs:    job.PIDs,
+			State:   job.State,
+			Command: job.Command,
+		}
+	}
+
+	return h.toolResult(response)
+}
@@ -0,0 +1,125 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/denysvitali/mcp-ssh/pkg/ssh"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/sirupsen/logrus"
+)
+
+// HandleExecuteStream handles the ssh_execute_stream tool. Each line of
+// stdout is sent as a progress notification as soon as it is complete, if
+// the client asked for progress with a progress token.
+func (h *Handlers) HandleExecuteStream(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	connectionID, err := req.RequireString("connection_id")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	if err := validateConnectionID(connectionID); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	logger := h.connLogger(connectionID)
+
+	command, err := req.RequireString("command")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	if err := validateCommand(command); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	if err := h.checkCommandPolicy(logger, connectionID, command); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	requestID := req.GetString("request_id", "")
+	if requestID != "" {
+		if err := validateRequestID(requestID); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+	}
+
+	// Zero keeps the configured command timeout
+	var timeout time.Duration
+	if _, ok := req.GetArguments()["timeout_seconds"]; ok {
+		seconds := req.GetFloat("timeout_seconds", 0)
+		if seconds < 1 || seconds > ssh.MaxCommandTimeout.Seconds() {
+			return mcp.NewToolResultError(fmt.Sprintf("timeout_seconds must be between 1 and %.0f", ssh.MaxCommandTimeout.Seconds())), nil
+		}
+		timeout = time.Duration(seconds * float64(time.Second))
+	}
+
+	var progressToken mcp.ProgressToken
+	if req.Params.Meta != nil {
+		progressToken = req.Params.Meta.ProgressToken
+	}
+
+	logger.WithFields(logrus.Fields{
+		"connection_id": connectionID,
+		"command":       command,
+		"request_id":    requestID,
+		"timeout":       timeout,
+		"progress":      progressToken != nil,
+	}).Debug("Executing SSH command with streamed output")
+
+	lines := 0
+	opts := ssh.ExecuteOptions{
+		RequestID: requestID,
+		Timeout:   timeout,
+		OnStdoutLine: func(line string) {
+			lines++
+			if progressToken == nil {
+				return
+			}
+			mcpServer := server.ServerFromContext(ctx)
+			if mcpServer == nil {
+				return
+			}
+			err := mcpServer.SendNotificationToClient(ctx, "notifications/progress", map[string]any{
+				"progressToken": progressToken,
+				"progress":      lines,
+				"message":       line,
+			})
+			if err != nil {
+				logger.WithError(err).Debug("Failed to send output line")
+			}
+		},
+	}
+	result, err := h.manager.ExecuteWithOptions(connectionID, command, opts)
+	if err != nil {
+		h.auditCommand(connectionID, command, 0, 0, 0, err)
+		logger.WithError(err).Error("Failed to execute SSH command")
+		return h.toolError("Failed to execute command", err)
+	}
+	h.auditCommand(connectionID, command, result.ExitCode, int64(len(result.Stdout)), int64(len(result.Stderr)), nil)
+
+	logger.WithFields(logrus.Fields{
+		"exit_code": result.ExitCode,
+		"lines":     lines,
+	}).Debug("Command executed successfully")
+
+	response := ExecuteResponse{
+		Success:       true,
+		Stdout:        result.Stdout,
+		Stderr:        result.Stderr,
+		ExitCode:      result.ExitCode,
+		RequestID:     requestID,
+		Cancelled:     result.Cancelled,
+		StartedAt:     timestamp(result.StartedAt),
+		FinishedAt:    timestamp(result.FinishedAt),
+		ReconnectInfo: reconnectInfo(result),
+	}
+	if result.Truncated {
+		response.Truncated = &result.Truncated
+	}
+
+	response.BudgetWarning = h.budgetWarning(connectionID)
+
+	return h.toolResult(response)
+}
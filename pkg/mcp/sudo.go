@@ -0,0 +1,75 @@
+package mcp
+
+import (
+	"context"
+
+	"github.com/denysvitali/mcp-ssh/pkg/ssh"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/sirupsen/logrus"
+)
+
+// HandleSudo handles the ssh_sudo tool
+func (h *Handlers) HandleSudo(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	connectionID, err := req.RequireString("connection_id")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	if err := validateConnectionID(connectionID); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	logger := h.connLogger(connectionID)
+
+	command, err := req.RequireString("command")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	if err := validateCommand(command); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	if err := h.checkCommandPolicy(logger, connectionID, command); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	opts := ssh.SudoOptions{
+		Password:   req.GetString("password", ""),
+		StripNoise: req.GetBool("strip_lecture", true),
+	}
+
+	// Never log the password
+	logger.WithFields(logrus.Fields{
+		"connection_id": connectionID,
+		"command":       command,
+		"with_password": opts.Password != "",
+	}).Debug("Executing SSH command through sudo")
+
+	result, err := h.manager.ExecuteSudo(connectionID, command, opts)
+	if err != nil {
+		h.auditCommand(connectionID, command, 0, 0, 0, err)
+		logger.WithError(err).Error("Failed to execute SSH command through sudo")
+		return h.toolError("Failed to execute command", err)
+	}
+	h.auditCommand(connectionID, command, result.ExitCode, int64(len(result.Stdout)), int64(len(result.Stderr)), nil)
+
+	logger.WithFields(logrus.Fields{
+		"exit_code":   result.ExitCode,
+		"auth_failed": result.AuthFailed,
+	}).Debug("Sudo command finished")
+
+	response := SudoResponse{
+		Success:       true,
+		Stdout:        result.Stdout,
+		Stderr:        result.Stderr,
+		ExitCode:      result.ExitCode,
+		AuthFailed:    result.AuthFailed,
+		ReconnectInfo: reconnectInfo(result.CommandResult),
+	}
+	if result.AuthFailed {
+		response.AuthError = result.AuthError
+		response.Message = "sudo authentication failed; the command was not run"
+	}
+
+	return h.toolResult(response)
+}
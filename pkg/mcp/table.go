@@ -0,0 +1,71 @@
+package mcp
+
+import (
+	"context"
+
+	"github.com/denysvitali/mcp-ssh/pkg/ssh"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/sirupsen/logrus"
+)
+
+// HandleExecuteTable handles the ssh_execute_table tool
+func (h *Handlers) HandleExecuteTable(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	connectionID, err := req.RequireString("connection_id")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	if err := validateConnectionID(connectionID); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	logger := h.connLogger(connectionID)
+
+	command, err := req.RequireString("command")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	if err := validateCommand(command); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	if err := h.checkCommandPolicy(logger, connectionID, command); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	delimiter := req.GetString("delimiter", "")
+	header := req.GetBool("header", false)
+
+	logger.WithFields(logrus.Fields{
+		"connection_id": connectionID,
+		"command":       command,
+		"delimiter":     delimiter,
+		"header":        header,
+	}).Debug("Executing SSH command as a table")
+
+	result, err := h.manager.Execute(connectionID, command)
+	if err != nil {
+		h.auditCommand(connectionID, command, 0, 0, 0, err)
+		logger.WithError(err).Error("Failed to execute SSH command")
+		return h.toolError("Failed to execute command", err)
+	}
+	h.auditCommand(connectionID, command, result.ExitCode, int64(len(result.Stdout)), int64(len(result.Stderr)), nil)
+
+	table := ssh.ParseTable(result.Stdout, delimiter, header)
+
+	response := ExecuteTableResponse{
+		Success:       true,
+		ExitCode:      result.ExitCode,
+		Stderr:        result.Stderr,
+		Count:         len(table.Rows),
+		ReconnectInfo: reconnectInfo(result),
+	}
+	if header {
+		response.Columns = table.Columns
+		response.Records = table.Records()
+	} else {
+		response.Rows = table.Rows
+	}
+
+	return h.toolResult(response)
+}
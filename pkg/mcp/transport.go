@@ -0,0 +1,157 @@
+package mcp
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// Transports the server can be reached over
+const (
+	// TransportStdio serves a single client on stdin/stdout
+	TransportStdio = "stdio"
+
+	// TransportSSE serves any number of clients over HTTP (see HTTPServer)
+	TransportSSE = "sse"
+)
+
+// readHeaderTimeout bounds how long a client may take to send its request
+// headers. Event streams stay open indefinitely, so there is no write timeout.
+const readHeaderTimeout = 10 * time.Second
+
+// HTTPOptions configures the HTTP transport
+type HTTPOptions struct {
+	// Addr is the address to listen on, e.g. "127.0.0.1:8080"
+	Addr string
+
+	// TLS, when set, serves HTTPS (see NewTLSConfig)
+	TLS *tls.Config
+
+	// AuthToken, when set, is the bearer token clients must present (see
+	// RequireBearerToken)
+	AuthToken string
+}
+
+// HTTPServer serves an MCP server to any number of clients over HTTP with
+// server-sent events: a client opens an event stream at /sse, which tells it
+// where to post its requests (/message with its session ID), and receives
+// the responses on the stream. All clients share the same tools and thus the
+// same SSH connections.
+type HTTPServer struct {
+	sse    *server.SSEServer
+	server *http.Server
+}
+
+// NewHTTPServer returns an HTTP transport for mcpServer. It does not listen
+// until Serve or ListenAndServe is called.
+func NewHTTPServer(mcpServer *server.MCPServer, opts HTTPOptions) *HTTPServer {
+	httpServer := &http.Server{
+		Addr:              opts.Addr,
+		TLSConfig:         opts.TLS,
+		ReadHeaderTimeout: readHeaderTimeout,
+	}
+	sse := server.NewSSEServer(mcpServer,
+		server.WithHTTPServer(httpServer),
+		server.WithKeepAlive(true),
+	)
+
+	var handler http.Handler = sse
+	if opts.AuthToken != "" {
+		handler = RequireBearerToken(opts.AuthToken, handler)
+	}
+	httpServer.Handler = handler
+
+	return &HTTPServer{sse: sse, server: httpServer}
+}
+
+// ListenAndServe listens on the configured address and serves clients until
+// Shutdown is called
+func (s *HTTPServer) ListenAndServe() error {
+	listener, err := s.Listen()
+	if err != nil {
+		return err
+	}
+	return s.Serve(listener)
+}
+
+// Listen listens on the configured address, for Serve to serve clients on,
+// so that the caller knows when clients can connect
+func (s *HTTPServer) Listen() (net.Listener, error) {
+	listener, err := net.Listen("tcp", s.server.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", s.server.Addr, err)
+	}
+	return listener, nil
+}
+
+// Serve serves clients on listener until Shutdown is called, after which it
+// returns nil
+func (s *HTTPServer) Serve(listener net.Listener) error {
+	var err error
+	if s.server.TLSConfig != nil {
+		// The certificate is part of the TLS config
+		err = s.server.ServeTLS(listener, "", "")
+	} else {
+		err = s.server.Serve(listener)
+	}
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// Shutdown closes the listener and the event streams of all clients, then
+// waits for requests in progress until ctx is done
+func (s *HTTPServer) Shutdown(ctx context.Context) error {
+	return s.sse.Shutdown(ctx)
+}
+
+// backgroundServer is an HTTP server run in the background next to the MCP
+// transport, such as the metrics or health server
+type backgroundServer struct {
+	server *http.Server
+}
+
+// newBackgroundServer returns a server of handler listening on addr
+func newBackgroundServer(addr string, handler http.Handler) backgroundServer {
+	return backgroundServer{
+		server: &http.Server{
+			Addr:              addr,
+			Handler:           handler,
+			ReadHeaderTimeout: readHeaderTimeout,
+		},
+	}
+}
+
+// Start listens on the configured address and serves in the background
+// until Shutdown is called. Errors serving are passed to onError.
+func (s *backgroundServer) Start(onError func(error)) error {
+	listener, err := net.Listen("tcp", s.server.Addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", s.server.Addr, err)
+	}
+	s.server.Addr = listener.Addr().String()
+	go func() {
+		if err := s.server.Serve(listener); !errors.Is(err, http.ErrServerClosed) {
+			onError(err)
+		}
+	}()
+	return nil
+}
+
+// Addr returns the address the server listens on, once started
+func (s *backgroundServer) Addr() string {
+	return s.server.Addr
+}
+
+// Shutdown closes the listener and waits for requests in progress until ctx
+// is done
+func (s *backgroundServer) Shutdown(ctx context.Context) error {
+	return s.server.Shutdown(ctx)
+}
@@ -0,0 +1,103 @@
+package mcp
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/client/transport"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// startHTTPServer serves an MCP server with the ssh_list tool over HTTP and
+// returns its base URL. It is shut down when the test ends.
+func startHTTPServer(t *testing.T, opts HTTPOptions) string {
+	t.Helper()
+
+	h := newTestHandlers(t)
+	mcpServer := server.NewMCPServer("mcp-ssh", "test", server.WithToolCapabilities(true))
+	mcpServer.AddTool(mcp.NewTool("ssh_list", mcp.WithOutputSchema[ListResponse]()), h.HandleList)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	httpServer := NewHTTPServer(mcpServer, opts)
+	served := make(chan error, 1)
+	go func() { served <- httpServer.Serve(listener) }()
+
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := httpServer.Shutdown(ctx); err != nil {
+			t.Errorf("failed to shut down: %v", err)
+		}
+		if err := <-served; err != nil {
+			t.Errorf("expected Serve to return nil after Shutdown, got %v", err)
+		}
+	})
+	return "http://" + listener.Addr().String()
+}
+
+// connectSSEClient connects and initializes an MCP client over SSE
+func connectSSEClient(t *testing.T, ctx context.Context, url string, options ...transport.ClientOption) *client.Client {
+	t.Helper()
+
+	c, err := client.NewSSEMCPClient(url+"/sse", options...)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	t.Cleanup(func() { _ = c.Close() })
+	if err := c.Start(ctx); err != nil {
+		t.Fatalf("failed to start client: %v", err)
+	}
+	init := mcp.InitializeRequest{}
+	init.Params.ProtocolVersion = mcp.LATEST_PROTOCOL_VERSION
+	init.Params.ClientInfo = mcp.Implementation{Name: "test", Version: "1.0"}
+	if _, err := c.Initialize(ctx, init); err != nil {
+		t.Fatalf("failed to initialize: %v", err)
+	}
+	return c
+}
+
+func TestHTTPServer_SSE(t *testing.T) {
+	url := startHTTPServer(t, HTTPOptions{AuthToken: "s3cret"})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Several clients are served at once
+	auth := transport.WithHeaders(map[string]string{"Authorization": "Bearer s3cret"})
+	for _, c := range []*client.Client{connectSSEClient(t, ctx, url, auth), connectSSEClient(t, ctx, url, auth)} {
+		tools, err := c.ListTools(ctx, mcp.ListToolsRequest{})
+		if err != nil {
+			t.Fatalf("failed to list tools: %v", err)
+		}
+		if len(tools.Tools) != 1 || tools.Tools[0].Name != "ssh_list" {
+			t.Errorf("expected the ssh_list tool, got %+v", tools.Tools)
+		}
+
+		req := mcp.CallToolRequest{}
+		req.Params.Name = "ssh_list"
+		result, err := c.CallTool(ctx, req)
+		if err != nil {
+			t.Fatalf("failed to call tool: %v", err)
+		}
+		if result.IsError {
+			t.Errorf("unexpected error result: %+v", result.Content)
+		}
+	}
+
+	// The event stream requires the token
+	resp, err := http.Get(url + "/sse")
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected status %d without a token, got %d", http.StatusUnauthorized, resp.StatusCode)
+	}
+}
@@ -0,0 +1,142 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/denysvitali/mcp-ssh/pkg/ssh"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// maxWorkflowSteps bounds the number of steps in a single workflow
+	maxWorkflowSteps = 100
+
+	// maxWorkflowDeadline bounds the time budget of a single workflow
+	maxWorkflowDeadline = time.Hour
+)
+
+// workflowArgs are the arguments of the ssh_run_workflow tool
+type workflowArgs struct {
+	Steps []struct {
+		ID           string   `json:"id"`
+		ConnectionID string   `json:"connection_id"`
+		Command      string   `json:"command"`
+		DependsOn    []string `json:"depends_on"`
+	} `json:"steps"`
+	OnFailure string  `json:"on_failure"`
+	Deadline  float64 `json:"deadline"`
+}
+
+// HandleRunWorkflow handles the ssh_run_workflow tool
+func (h *Handlers) HandleRunWorkflow(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args workflowArgs
+	if err := req.BindArguments(&args); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("invalid arguments: %v", err)), nil
+	}
+
+	if len(args.Steps) == 0 {
+		return mcp.NewToolResultError("steps cannot be empty"), nil
+	}
+	if len(args.Steps) > maxWorkflowSteps {
+		return mcp.NewToolResultError(fmt.Sprintf("too many steps (max %d)", maxWorkflowSteps)), nil
+	}
+
+	var stopOnFailure bool
+	switch args.OnFailure {
+	case "", "stop":
+		stopOnFailure = true
+	case "continue":
+		stopOnFailure = false
+	default:
+		return mcp.NewToolResultError(fmt.Sprintf("on_failure must be 'stop' or 'continue', got '%s'", args.OnFailure)), nil
+	}
+
+	budget := time.Duration(args.Deadline * float64(time.Second))
+	if budget < 0 || budget > maxWorkflowDeadline {
+		return mcp.NewToolResultError(fmt.Sprintf("deadline must be between 0 and %.0f seconds", maxWorkflowDeadline.Seconds())), nil
+	}
+
+	steps := make([]ssh.WorkflowStep, len(args.Steps))
+	for i, step := range args.Steps {
+		if err := validateConnectionID(step.ConnectionID); err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("step %d: %v", i+1, err)), nil
+		}
+		if err := validateCommand(step.Command); err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("step %d: %v", i+1, err)), nil
+		}
+		if err := h.checkCommandPolicy(h.connLogger(step.ConnectionID), step.ConnectionID, step.Command); err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("step %d: %v", i+1, err)), nil
+		}
+		steps[i] = ssh.WorkflowStep{
+			ID:           step.ID,
+			ConnectionID: step.ConnectionID,
+			Command:      step.Command,
+			DependsOn:    step.DependsOn,
+		}
+	}
+
+	h.logger.WithFields(logrus.Fields{
+		"steps":      len(steps),
+		"on_failure": args.OnFailure,
+		"deadline":   budget,
+	}).Info("Running SSH workflow")
+
+	workflow, err := h.manager.RunWorkflowWithOptions(steps, ssh.WorkflowOptions{
+		StopOnFailure: stopOnFailure,
+		Budget:        budget,
+	})
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to run SSH workflow")
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to run workflow: %v", err)), nil
+	}
+
+	results := workflow.Steps
+	succeeded := 0
+	stepList := make([]WorkflowStepResponse, len(results))
+	for i, result := range results {
+		step := WorkflowStepResponse{
+			ID:           result.ID,
+			ConnectionID: result.ConnectionID,
+			Status:       result.Status,
+			DurationMS:   result.Duration.Milliseconds(),
+			Error:        result.Error,
+		}
+		switch {
+		case result.Result != nil:
+			h.auditCommand(result.ConnectionID, steps[i].Command, result.Result.ExitCode, int64(len(result.Result.Stdout)), int64(len(result.Result.Stderr)), nil)
+		case result.Status != ssh.StepSkipped:
+			h.auditCommand(result.ConnectionID, steps[i].Command, 0, 0, 0, errors.New(result.Error))
+		}
+		if result.Result != nil {
+			step.Stdout = result.Result.Stdout
+			step.Stderr = result.Result.Stderr
+			step.ExitCode = &result.Result.ExitCode
+		}
+		if result.Status == ssh.StepSucceeded {
+			succeeded++
+		}
+		stepList[i] = step
+	}
+
+	h.logger.WithFields(logrus.Fields{
+		"steps":     len(results),
+		"succeeded": succeeded,
+	}).Info("SSH workflow finished")
+
+	response := WorkflowResponse{
+		Success:   succeeded == len(results),
+		Steps:     stepList,
+		Succeeded: succeeded,
+		Total:     len(results),
+		ElapsedMS: workflow.Elapsed.Milliseconds(),
+	}
+	if budget > 0 {
+		response.BudgetExhausted = &workflow.BudgetExhausted
+	}
+
+	return h.toolResult(response)
+}
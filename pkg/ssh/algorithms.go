@@ -0,0 +1,33 @@
+package ssh
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// checkAlgorithms checks that the ciphers, MACs and key exchanges a
+// connection asks for are implemented. Insecure ones are accepted, as they
+// are what legacy servers need.
+func checkAlgorithms(params ConnectParams) error {
+	supported, insecure := ssh.SupportedAlgorithms(), ssh.InsecureAlgorithms()
+	kinds := []struct {
+		kind      string
+		requested []string
+		known     []string
+	}{
+		{kind: "cipher", requested: params.Ciphers, known: slices.Concat(supported.Ciphers, insecure.Ciphers)},
+		{kind: "MAC", requested: params.MACs, known: slices.Concat(supported.MACs, insecure.MACs)},
+		{kind: "key exchange algorithm", requested: params.KeyExchanges, known: slices.Concat(supported.KeyExchanges, insecure.KeyExchanges)},
+	}
+	for _, k := range kinds {
+		for _, name := range k.requested {
+			if !slices.Contains(k.known, name) {
+				return fmt.Errorf("unsupported %s '%s' (supported: %s)", k.kind, name, strings.Join(k.known, ", "))
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package ssh
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestConnect_Algorithms(t *testing.T) {
+	server := newTestServer(t)
+
+	// A legacy cipher left out of the defaults is only used when asked for
+	server.config.Ciphers = []string{"aes128-cbc"}
+
+	manager := newTestManager(t)
+	if _, err := manager.Connect(server.params("default")); err == nil {
+		t.Fatal("expected the default ciphers not to match the server's")
+	}
+
+	params := server.params("default")
+	params.Ciphers = []string{"aes128-cbc"}
+	if _, err := manager.Connect(params); err != nil {
+		t.Fatalf("expected the requested cipher to be used, got %v", err)
+	}
+	result, err := manager.Execute("default", "echo ok")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Stdout != "ok" {
+		t.Errorf("expected stdout %q, got %q", "ok", result.Stdout)
+	}
+	manager.CloseAll()
+
+	tests := []struct {
+		name    string
+		modify  func(p *ConnectParams)
+		wantErr string
+	}{
+		{name: "cipher", modify: func(p *ConnectParams) { p.Ciphers = []string{"aes128-cbc", "rot13"} }, wantErr: "unsupported cipher 'rot13'"},
+		{name: "MAC", modify: func(p *ConnectParams) { p.MACs = []string{"hmac-md5"} }, wantErr: "unsupported MAC 'hmac-md5'"},
+		{name: "key exchange", modify: func(p *ConnectParams) { p.KeyExchanges = []string{"dh-none"} }, wantErr: "unsupported key exchange algorithm 'dh-none'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := server.params("invalid")
+			tt.modify(&params)
+			_, err := manager.Connect(params)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("expected an error containing %q, got %v", tt.wantErr, err)
+			}
+			if !strings.Contains(err.Error(), "supported: ") {
+				t.Errorf("expected the supported algorithms to be listed, got %v", err)
+			}
+		})
+	}
+}
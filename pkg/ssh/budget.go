@@ -0,0 +1,173 @@
+package ssh
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// BudgetWarningRatio is the share of a connection's time budget after which
+// its status carries a warning that the budget is running out
+const BudgetWarningRatio = 0.8
+
+// TimeBudget reports the cumulative command time budget of a connection
+type TimeBudget struct {
+	// Limit is the command time the connection may use, 0 if unlimited
+	Limit time.Duration
+
+	// Used is the command time spent so far
+	Used time.Duration
+
+	// ServerLimit is the budget enforced by the server configuration, 0 if
+	// none. Limit cannot exceed it, and Used cannot be reset under it.
+	ServerLimit time.Duration
+}
+
+// Remaining returns the command time left, 0 once exhausted or if unlimited
+func (b TimeBudget) Remaining() time.Duration {
+	if b.Limit <= 0 || b.Used >= b.Limit {
+		return 0
+	}
+	return b.Limit - b.Used
+}
+
+// Exhausted reports whether further commands are rejected
+func (b TimeBudget) Exhausted() bool {
+	return b.Limit > 0 && b.Used >= b.Limit
+}
+
+// Warning describes a budget that is exhausted or running out, empty
+// otherwise
+func (b TimeBudget) Warning() string {
+	switch {
+	case b.Limit <= 0:
+		return ""
+	case b.Exhausted():
+		return fmt.Sprintf("The command time budget of %s is exhausted; further commands are rejected", b.Limit)
+	case float64(b.Used) >= BudgetWarningRatio*float64(b.Limit):
+		return fmt.Sprintf("%s of the command time budget of %s is left", b.Remaining().Round(time.Second), b.Limit)
+	}
+	return ""
+}
+
+// timeBudget tracks the command time of a connection. It is shared by the
+// connections replacing a dropped one, so that reconnecting does not reset
+// it.
+type timeBudget struct {
+	mu    sync.Mutex
+	limit time.Duration
+	used  time.Duration
+}
+
+// check fails once the budget is exhausted
+func (b *timeBudget) check(id string, serverLimit time.Duration) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.limit <= 0 || b.used < b.limit {
+		return nil
+	}
+	hint := "raise or reset it with ssh_time_budget"
+	if serverLimit > 0 {
+		hint = "it is enforced by the server and cannot be reset"
+	}
+	return fmt.Errorf("time budget exhausted: connection '%s' has used %s of its %s command time budget; %s",
+		id, b.used.Round(100*time.Millisecond), b.limit, hint)
+}
+
+// charge adds d to the time used
+func (b *timeBudget) charge(d time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.used += d
+}
+
+// status returns the current budget
+func (b *timeBudget) status(serverLimit time.Duration) TimeBudget {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return TimeBudget{Limit: b.limit, Used: b.used, ServerLimit: serverLimit}
+}
+
+// WithTimeBudget limits the command time every connection may use to budget
+// (0: unlimited). Connections may set a lower budget of their own, but not a
+// higher one, and cannot reset the time used.
+func WithTimeBudget(budget time.Duration) ManagerOption {
+	return func(c *ManagerConfig) {
+		c.TimeBudget = budget
+	}
+}
+
+// newTimeBudget returns the budget of a new connection asking for limit,
+// 0 for the server's default
+func (m *Manager) newTimeBudget(limit time.Duration) (*timeBudget, error) {
+	if err := m.validateTimeBudget(limit); err != nil {
+		return nil, err
+	}
+	if limit == 0 {
+		limit = m.config.TimeBudget
+	}
+	return &timeBudget{limit: limit}, nil
+}
+
+// validateTimeBudget checks a budget asked for by a connection, where 0
+// means the server's default
+func (m *Manager) validateTimeBudget(limit time.Duration) error {
+	if limit < 0 {
+		return fmt.Errorf("time budget cannot be negative")
+	}
+	if server := m.config.TimeBudget; server > 0 && limit > server {
+		return fmt.Errorf("time budget %s exceeds the server's limit of %s", limit, server)
+	}
+	return nil
+}
+
+// TimeBudget returns the command time budget of a connection
+func (m *Manager) TimeBudget(id string) (TimeBudget, error) {
+	m.mu.RLock()
+	conn, exists := m.connections[id]
+	m.mu.RUnlock()
+
+	if !exists {
+		return TimeBudget{}, m.connectionNotFound(id)
+	}
+	return conn.budget.status(m.config.TimeBudget), nil
+}
+
+// SetTimeBudget changes the command time budget of a connection to limit,
+// where 0 removes it (or restores the server's limit, if any) and a negative
+// limit keeps it, and with reset clears the time used. Resetting is refused
+// when the server enforces a budget.
+func (m *Manager) SetTimeBudget(id string, limit time.Duration, reset bool) (TimeBudget, error) {
+	m.mu.RLock()
+	conn, exists := m.connections[id]
+	m.mu.RUnlock()
+
+	if !exists {
+		return TimeBudget{}, m.connectionNotFound(id)
+	}
+
+	server := m.config.TimeBudget
+	if reset && server > 0 {
+		return TimeBudget{}, fmt.Errorf("the time budget is enforced by the server and cannot be reset")
+	}
+	if limit >= 0 {
+		if err := m.validateTimeBudget(limit); err != nil {
+			return TimeBudget{}, err
+		}
+		if limit == 0 {
+			limit = server
+		}
+	}
+
+	conn.budget.mu.Lock()
+	if limit >= 0 {
+		conn.budget.limit = limit
+	}
+	if reset {
+		conn.budget.used = 0
+	}
+	conn.budget.mu.Unlock()
+
+	return conn.budget.status(server), nil
+}
@@ -0,0 +1,121 @@
+package ssh
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTimeBudget_Status(t *testing.T) {
+	tests := []struct {
+		name      string
+		budget    TimeBudget
+		remaining time.Duration
+		exhausted bool
+		warning   string
+	}{
+		{name: "unlimited", budget: TimeBudget{Used: time.Hour}},
+		{name: "plenty", budget: TimeBudget{Limit: 10 * time.Minute, Used: time.Minute}, remaining: 9 * time.Minute},
+		{name: "running out", budget: TimeBudget{Limit: 10 * time.Minute, Used: 9 * time.Minute},
+			remaining: time.Minute, warning: "1m0s of the command time budget of 10m0s is left"},
+		{name: "exhausted", budget: TimeBudget{Limit: 10 * time.Minute, Used: 11 * time.Minute},
+			exhausted: true, warning: "budget of 10m0s is exhausted"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.budget.Remaining(); got != tt.remaining {
+				t.Errorf("Remaining() = %v, want %v", got, tt.remaining)
+			}
+			if got := tt.budget.Exhausted(); got != tt.exhausted {
+				t.Errorf("Exhausted() = %v, want %v", got, tt.exhausted)
+			}
+			warning := tt.budget.Warning()
+			if (tt.warning == "") != (warning == "") || !strings.Contains(warning, tt.warning) {
+				t.Errorf("Warning() = %q, want %q", warning, tt.warning)
+			}
+		})
+	}
+}
+
+func TestTimeBudget_Enforced(t *testing.T) {
+	server := newTestServer(t)
+	manager := newTestManager(t)
+
+	params := server.params("default")
+	params.AutoReconnect = true
+	params.TimeBudget = time.Second
+	if _, err := manager.Connect(params); err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	t.Cleanup(manager.CloseAll)
+
+	// Each command is charged at least its runtime, so two of them use up
+	// the budget
+	if _, err := manager.Execute("default", "sleep 0.55"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The time used is kept when the connection is re-established
+	server.DropConnections()
+	waitConnectionLost(t, manager, "default")
+	if _, err := manager.Execute("default", "sleep 0.55"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	_, err := manager.Execute("default", "true")
+	if err == nil || !strings.Contains(err.Error(), "time budget exhausted") {
+		t.Fatalf("expected the budget to be exhausted, got %v", err)
+	}
+	if infos := manager.List(); len(infos) != 1 || !infos[0].TimeBudget.Exhausted() {
+		t.Errorf("expected an exhausted budget to be listed, got %+v", infos)
+	}
+
+	budget, err := manager.SetTimeBudget("default", -1, true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if budget.Used != 0 || budget.Limit != time.Second {
+		t.Errorf("expected the time used to be reset, got %+v", budget)
+	}
+	if _, err := manager.Execute("default", "true"); err != nil {
+		t.Errorf("expected commands to run after a reset, got %v", err)
+	}
+
+	if budget, err := manager.SetTimeBudget("default", 0, false); err != nil || budget.Limit != 0 {
+		t.Errorf("expected the budget to be removed, got %+v, %v", budget, err)
+	}
+}
+
+func TestTimeBudget_ServerLimit(t *testing.T) {
+	server := newTestServer(t)
+	manager := newTestManager(t, WithTimeBudget(time.Minute))
+
+	params := server.params("default")
+	params.TimeBudget = 2 * time.Minute
+	if _, err := manager.Connect(params); err == nil || !strings.Contains(err.Error(), "exceeds the server's limit") {
+		t.Fatalf("expected a budget above the server's to be rejected, got %v", err)
+	}
+
+	connectTestServer(t, manager, server, "default")
+	budget, err := manager.TimeBudget("default")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if budget.Limit != time.Minute || budget.ServerLimit != time.Minute {
+		t.Errorf("expected the server's budget by default, got %+v", budget)
+	}
+
+	if _, err := manager.SetTimeBudget("default", -1, true); err == nil {
+		t.Error("expected resetting an enforced budget to fail")
+	}
+	if _, err := manager.SetTimeBudget("default", 2*time.Minute, false); err == nil {
+		t.Error("expected raising an enforced budget to fail")
+	}
+	if budget, err := manager.SetTimeBudget("default", 30*time.Second, false); err != nil || budget.Limit != 30*time.Second {
+		t.Errorf("expected the budget to be lowered, got %+v, %v", budget, err)
+	}
+	if budget, err := manager.SetTimeBudget("default", 0, false); err != nil || budget.Limit != time.Minute {
+		t.Errorf("expected removing the budget to restore the server's, got %+v, %v", budget, err)
+	}
+}
@@ -0,0 +1,224 @@
+package ssh
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// ErrCancelled is returned for a command cancelled before it was sent
+var ErrCancelled = errors.New("command cancelled before it started")
+
+const (
+	// cancelWaitTimeout bounds how long Cancel waits for a command that has
+	// just been sent to start its processes
+	cancelWaitTimeout = time.Second
+
+	// cancelPollInterval is how often Cancel looks for those processes
+	cancelPollInterval = 50 * time.Millisecond
+)
+
+// activeRequest is a command registered under a caller-chosen request ID
+type activeRequest struct {
+	connectionID string
+	cancelled    atomic.Bool
+}
+
+// requestRegistry tracks the commands executing under a request ID
+type requestRegistry struct {
+	mu       sync.Mutex
+	requests map[string]*activeRequest
+}
+
+// newRequestRegistry returns an empty registry
+func newRequestRegistry() *requestRegistry {
+	return &requestRegistry{
+		requests: make(map[string]*activeRequest),
+	}
+}
+
+// add registers a command under requestID. IDs must be unique among the
+// commands in flight.
+func (r *requestRegistry) add(requestID, connectionID string) (*activeRequest, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.requests[requestID]; exists {
+		return nil, fmt.Errorf("request '%s' is already in flight", requestID)
+	}
+	request := &activeRequest{connectionID: connectionID}
+	r.requests[requestID] = request
+	return request, nil
+}
+
+// get returns the command registered under requestID, nil if none
+func (r *requestRegistry) get(requestID string) *activeRequest {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.requests[requestID]
+}
+
+// remove unregisters requestID
+func (r *requestRegistry) remove(requestID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.requests, requestID)
+}
+
+// CancelResult describes the outcome of Manager.Cancel
+type CancelResult struct {
+	// Found is set when a command with the request ID was in flight
+	Found bool
+
+	// Started is set when the command had already been sent to the shell.
+	// Otherwise it was still waiting and will not run.
+	Started bool
+
+	// Interrupted lists the processes of the command that were sent SIGINT
+	Interrupted []int
+}
+
+// Cancelled reports whether the command was stopped
+func (r *CancelResult) Cancelled() bool {
+	return r.Found && (!r.Started || len(r.Interrupted) > 0)
+}
+
+// Cancel interrupts the command in flight under requestID. A command still
+// waiting for its shell is dropped. A running command has SIGINT sent to the
+// processes it started, like Ctrl-C; the shell itself survives, so a command
+// list separated by ';' continues with its next command. Commands made only of
+// shell builtins have no process to interrupt.
+func (m *Manager) Cancel(requestID string) (*CancelResult, error) {
+	request := m.requests.get(requestID)
+	if request == nil {
+		return &CancelResult{}, nil
+	}
+
+	// Set before looking at the shell, so that a command not running yet
+	// sees it before it is sent (see ExecuteOptions.Cancelled)
+	request.cancelled.Store(true)
+	result := &CancelResult{Found: true}
+
+	m.mu.RLock()
+	conn, exists := m.connections[request.connectionID]
+	m.mu.RUnlock()
+
+	if !exists {
+		return result, nil
+	}
+
+	started, pids, err := conn.interruptRunning(func(running *RunningCommand) bool {
+		return running.RequestID == requestID
+	})
+	if err != nil {
+		return nil, err
+	}
+	result.Started = started
+	result.Interrupted = pids
+	return result, nil
+}
+
+// InterruptResult describes the outcome of Manager.Interrupt
+type InterruptResult struct {
+	// Command is the command that was running
+	Command   string
+	RequestID string
+
+	// Interrupted lists the processes of the command that were sent SIGINT,
+	// empty if it had none, e.g. when made only of shell builtins
+	Interrupted []int
+}
+
+// Interrupt interrupts the command running on a connection, whether or not it
+// was given a request ID, as Cancel does: its processes are sent SIGINT and
+// the shell survives for the next command. It fails if no command is running.
+func (m *Manager) Interrupt(id string) (*InterruptResult, error) {
+	m.mu.RLock()
+	conn, exists := m.connections[id]
+	m.mu.RUnlock()
+
+	if !exists {
+		return nil, m.connectionNotFound(id)
+	}
+
+	running := conn.executor.Running()
+	if running == nil {
+		return nil, fmt.Errorf("no command is running on connection '%s'", id)
+	}
+
+	started, pids, err := conn.interruptRunning(func(current *RunningCommand) bool {
+		return current == running
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !started {
+		return nil, fmt.Errorf("no command is running on connection '%s'", id)
+	}
+	return &InterruptResult{Command: running.Command, RequestID: running.RequestID, Interrupted: pids}, nil
+}
+
+// interruptRunning sends SIGINT to the processes of the command running on
+// the connection if isTarget accepts it, waiting for a command that has just
+// been sent to start them. It reports whether the command was running and
+// the processes interrupted.
+func (c *Connection) interruptRunning(isTarget func(*RunningCommand) bool) (bool, []int, error) {
+	started := false
+	deadline := time.Now().Add(cancelWaitTimeout)
+	for {
+		running := c.executor.Running()
+		if running == nil || !isTarget(running) {
+			return started, nil, nil
+		}
+		started = true
+
+		processes, err := c.shellProcesses()
+		if err != nil {
+			return started, nil, err
+		}
+		if len(processes) > 0 {
+			pids := make([]int, len(processes))
+			for i, process := range processes {
+				pids[i] = process.PID
+			}
+			if err := c.interrupt(pids); err != nil {
+				return started, nil, err
+			}
+			return started, pids, nil
+		}
+
+		// The command may have been sent without its processes being
+		// started yet
+		if time.Now().After(deadline) {
+			return started, nil, nil
+		}
+		time.Sleep(cancelPollInterval)
+	}
+}
+
+// interrupt sends SIGINT to processes on the remote host. Processes that
+// exited in the meantime are ignored.
+func (c *Connection) interrupt(pids []int) error {
+	return interruptProcesses(c.client, pids)
+}
+
+// interruptProcesses sends SIGINT to processes on the host of client
+func interruptProcesses(client *ssh.Client, pids []int) error {
+	args := make([]string, len(pids))
+	for i, pid := range pids {
+		args[i] = strconv.Itoa(pid)
+	}
+
+	_, err := runSession(client, "kill -INT "+strings.Join(args, " ")+" 2>/dev/null")
+	var exitErr *ssh.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		return fmt.Errorf("failed to interrupt processes: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,194 @@
+package ssh
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// waitRunning waits until the connection's shell is running the command
+// registered under requestID
+func waitRunning(t *testing.T, manager *Manager, id, requestID string) {
+	t.Helper()
+
+	manager.mu.RLock()
+	executor := manager.connections[id].executor
+	manager.mu.RUnlock()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if running := executor.Running(); running != nil && running.RequestID == requestID {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("request %s did not start", requestID)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestCancel_RunningCommand(t *testing.T) {
+	server := newTestServer(t)
+	manager := newTestManager(t)
+	connectTestServer(t, manager, server, "default")
+
+	if _, err := manager.Execute("default", "export KEPT=yes"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	type outcome struct {
+		result *CommandResult
+		err    error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		result, err := manager.ExecuteWithOptions("default", "sleep 10 && echo finished", ExecuteOptions{RequestID: "build-1"})
+		done <- outcome{result, err}
+	}()
+	waitRunning(t, manager, "default", "build-1")
+
+	if _, err := manager.ExecuteWithOptions("default", "true", ExecuteOptions{RequestID: "build-1"}); err == nil ||
+		!strings.Contains(err.Error(), "already in flight") {
+		t.Errorf("expected a duplicate request ID to be rejected, got %v", err)
+	}
+
+	cancelled, err := manager.Cancel("build-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !cancelled.Found || !cancelled.Started || len(cancelled.Interrupted) == 0 || !cancelled.Cancelled() {
+		t.Errorf("expected the running command to be interrupted, got %+v", cancelled)
+	}
+
+	var got outcome
+	select {
+	case got = <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("the command was not interrupted")
+	}
+	if got.err != nil {
+		t.Fatalf("unexpected error: %v", got.err)
+	}
+	if !got.result.Cancelled || got.result.ExitCode == 0 || strings.Contains(got.result.Stdout, "finished") {
+		t.Errorf("expected an interrupted result, got %+v", got.result)
+	}
+
+	// The shell survived with its state
+	result, err := manager.Execute("default", `echo "$KEPT"`)
+	if err != nil || result.Stdout != "yes" {
+		t.Errorf("expected the shell to survive the cancellation, got %+v, %v", result, err)
+	}
+
+	// The request ID is released once the command returns
+	if cancelled, err := manager.Cancel("build-1"); err != nil || cancelled.Found {
+		t.Errorf("expected no command in flight, got %+v, %v", cancelled, err)
+	}
+}
+
+func TestCancel_QueuedCommand(t *testing.T) {
+	server := newTestServer(t)
+	manager := newTestManager(t)
+	connectTestServer(t, manager, server, "default")
+
+	// Keep the shell busy so the next command waits for it
+	busy := make(chan error, 1)
+	go func() {
+		_, err := manager.ExecuteWithOptions("default", "sleep 0.5", ExecuteOptions{RequestID: "busy"})
+		busy <- err
+	}()
+	waitRunning(t, manager, "default", "busy")
+
+	queued := make(chan error, 1)
+	go func() {
+		_, err := manager.ExecuteWithOptions("default", "echo never", ExecuteOptions{RequestID: "queued"})
+		queued <- err
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for manager.requests.get("queued") == nil {
+		if time.Now().After(deadline) {
+			t.Fatalf("the queued command was not registered")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	cancelled, err := manager.Cancel("queued")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !cancelled.Found || cancelled.Started || !cancelled.Cancelled() {
+		t.Errorf("expected the queued command to be dropped, got %+v", cancelled)
+	}
+
+	if err := <-queued; !errors.Is(err, ErrCancelled) {
+		t.Errorf("expected the queued command to be cancelled, got %v", err)
+	}
+	if err := <-busy; err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestCancel_UnknownRequest(t *testing.T) {
+	manager := newTestManager(t)
+
+	result, err := manager.Cancel("missing")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Found || result.Cancelled() {
+		t.Errorf("expected no command to be found, got %+v", result)
+	}
+}
+
+func TestInterrupt(t *testing.T) {
+	server := newTestServer(t)
+	manager := newTestManager(t)
+	connectTestServer(t, manager, server, "default")
+
+	if _, err := manager.Interrupt("default"); err == nil || !strings.Contains(err.Error(), "no command is running") {
+		t.Errorf("expected an error with no command running, got %v", err)
+	}
+	if _, err := manager.Interrupt("missing"); ErrorCodeOf(err) != CodeNotFound {
+		t.Errorf("expected a not found error, got %v", err)
+	}
+
+	type outcome struct {
+		result *CommandResult
+		err    error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		// Started without a request ID
+		result, err := manager.Execute("default", "sleep 30")
+		done <- outcome{result, err}
+	}()
+	waitRunning(t, manager, "default", "")
+
+	interrupted, err := manager.Interrupt("default")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if interrupted.Command != "sleep 30" || len(interrupted.Interrupted) == 0 {
+		t.Errorf("expected the running command to be interrupted, got %+v", interrupted)
+	}
+
+	var got outcome
+	select {
+	case got = <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("the command was not interrupted")
+	}
+	if got.err != nil {
+		t.Fatalf("unexpected error: %v", got.err)
+	}
+	if got.result.ExitCode == 0 {
+		t.Errorf("expected an interrupted command to fail, got %+v", got.result)
+	}
+
+	// The shell recovered for the next command
+	result, err := manager.Execute("default", "echo ready")
+	if err != nil || result.Stdout != "ready" {
+		t.Errorf("expected the shell to survive the interruption, got %+v, %v", result, err)
+	}
+}
@@ -0,0 +1,99 @@
+package ssh
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// containerRuntimes are the container CLIs probed, in order of preference
+var containerRuntimes = []string{"docker", "podman", "nerdctl"}
+
+// containerInfoCommand probes each container runtime CLI and prints a block
+// of key=value lines for every one that is installed. "ps -q" both checks
+// that the runtime is usable (daemon up, socket accessible) and counts the
+// running containers.
+var containerInfoCommand = `for rt in ` + strings.Join(containerRuntimes, " ") + `; do
+command -v "$rt" >/dev/null 2>&1 || continue
+echo "runtime=$rt"
+echo "path=$(command -v "$rt")"
+v=$("$rt" version --format '{{.Client.Version}}' 2>/dev/null)
+[ -n "$v" ] || v=$("$rt" --version 2>/dev/null | head -n 1)
+echo "version=$v"
+echo "server_version=$("$rt" version --format '{{.Server.Version}}' 2>/dev/null)"
+if out=$("$rt" ps -q 2>&1); then
+echo "running=true"
+echo "containers=$(printf '%s\n' "$out" | grep -c .)"
+else
+echo "running=false"
+echo "error=$(printf '%s\n' "$out" | head -n 1)"
+fi
+done`
+
+// ContainerRuntime describes a container runtime CLI found on the remote host
+type ContainerRuntime struct {
+	Name    string
+	Path    string
+	Version string
+
+	// ServerVersion is the daemon version, empty for daemonless runtimes or
+	// when the daemon is unreachable
+	ServerVersion string
+
+	// Running is set when the runtime could list containers, i.e. its daemon
+	// is up and accessible to the remote user
+	Running    bool
+	Containers int
+
+	// Error is the runtime's message when it could not list containers,
+	// e.g. a permission error on the Docker socket
+	Error string
+}
+
+// ContainerRuntimes detects the container runtimes installed on the remote
+// host. They are returned in order of preference: docker, podman, nerdctl.
+func (m *Manager) ContainerRuntimes(id string) ([]ContainerRuntime, error) {
+	result, err := m.Execute(id, containerInfoCommand)
+	if err != nil {
+		return nil, err
+	}
+	if result.ExitCode != 0 {
+		return nil, fmt.Errorf("container runtime detection exited with code %d: %s", result.ExitCode, result.Stderr)
+	}
+	return parseContainerRuntimes(result.Stdout), nil
+}
+
+// parseContainerRuntimes parses the output of containerInfoCommand
+func parseContainerRuntimes(output string) []ContainerRuntime {
+	var runtimes []ContainerRuntime
+	for _, line := range strings.Split(output, "\n") {
+		key, value, found := strings.Cut(strings.TrimSpace(line), "=")
+		if !found {
+			continue
+		}
+		if key == "runtime" {
+			runtimes = append(runtimes, ContainerRuntime{Name: value})
+			continue
+		}
+		if len(runtimes) == 0 {
+			continue
+		}
+
+		runtime := &runtimes[len(runtimes)-1]
+		switch key {
+		case "path":
+			runtime.Path = value
+		case "version":
+			runtime.Version = value
+		case "server_version":
+			runtime.ServerVersion = value
+		case "running":
+			runtime.Running = value == "true"
+		case "containers":
+			runtime.Containers, _ = strconv.Atoi(value)
+		case "error":
+			runtime.Error = value
+		}
+	}
+	return runtimes
+}
@@ -0,0 +1,95 @@
+package ssh
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// fakeDocker reports two running containers
+const fakeDocker = `#!/bin/sh
+case "$1 $3" in
+"version {{.Client.Version}}") echo 24.0.5 ;;
+"version {{.Server.Version}}") echo 24.0.7 ;;
+"ps ") printf 'a1b2c3\nd4e5f6\n' ;;
+esac
+`
+
+// fakeNerdctl has no version subcommand and cannot reach containerd
+const fakeNerdctl = `#!/bin/sh
+case "$1" in
+--version) echo "nerdctl version 1.7.0" ;;
+ps) echo "cannot access containerd socket: permission denied" >&2; exit 1 ;;
+*) exit 1 ;;
+esac
+`
+
+func TestParseContainerRuntimes(t *testing.T) {
+	output := "runtime=docker\npath=/usr/bin/docker\nversion=24.0.5\nserver_version=\nrunning=false\n" +
+		"error=permission denied while trying to connect to the Docker daemon socket\n" +
+		"runtime=podman\npath=/usr/bin/podman\nversion=4.9.3\nserver_version=4.9.3\nrunning=true\ncontainers=3"
+
+	expected := []ContainerRuntime{
+		{
+			Name:    "docker",
+			Path:    "/usr/bin/docker",
+			Version: "24.0.5",
+			Error:   "permission denied while trying to connect to the Docker daemon socket",
+		},
+		{
+			Name:          "podman",
+			Path:          "/usr/bin/podman",
+			Version:       "4.9.3",
+			ServerVersion: "4.9.3",
+			Running:       true,
+			Containers:    3,
+		},
+	}
+
+	if got := parseContainerRuntimes(output); !reflect.DeepEqual(got, expected) {
+		t.Errorf("parseContainerRuntimes() = %+v, want %+v", got, expected)
+	}
+	if got := parseContainerRuntimes(""); got != nil {
+		t.Errorf("expected no runtimes, got %+v", got)
+	}
+}
+
+func TestContainerRuntimes(t *testing.T) {
+	dir := t.TempDir()
+	for name, script := range map[string]string{"docker": fakeDocker, "nerdctl": fakeNerdctl} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(script), 0o700); err != nil {
+			t.Fatalf("failed to write fake %s: %v", name, err)
+		}
+	}
+
+	server := newTestServer(t)
+	manager := newTestManager(t)
+	connectTestServer(t, manager, server, "default")
+	if _, err := manager.Execute("default", "export PATH="+shellQuote(dir)+":$PATH"); err != nil {
+		t.Fatalf("failed to set PATH: %v", err)
+	}
+
+	runtimes, err := manager.ContainerRuntimes("default")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	found := make(map[string]ContainerRuntime)
+	for _, runtime := range runtimes {
+		found[runtime.Name] = runtime
+	}
+	if len(runtimes) == 0 || runtimes[0].Name != "docker" {
+		t.Fatalf("expected docker to be reported first, got %+v", runtimes)
+	}
+
+	docker := found["docker"]
+	if docker.Version != "24.0.5" || docker.ServerVersion != "24.0.7" || !docker.Running || docker.Containers != 2 {
+		t.Errorf("unexpected docker runtime %+v", docker)
+	}
+
+	nerdctl := found["nerdctl"]
+	if nerdctl.Version != "nerdctl version 1.7.0" || nerdctl.Running || nerdctl.Error == "" {
+		t.Errorf("unexpected nerdctl runtime %+v", nerdctl)
+	}
+}
@@ -0,0 +1,100 @@
+package ssh
+
+import (
+	"slices"
+	"sync"
+)
+
+// credentials are authentication secrets retained for a live connection,
+// e.g. so that it can be re-established. Secrets are kept as byte slices so
+// that they can be overwritten instead of waiting for garbage collection.
+type credentials struct {
+	mu              sync.Mutex
+	password        []byte
+	privateKeyPath  string
+	privateKeyPaths []string
+	privateKey      []byte
+	passphrase      []byte
+	jumpKeyPath     string
+}
+
+// retainCredentials copies the secrets of params
+func retainCredentials(params ConnectParams) *credentials {
+	return &credentials{
+		password:        []byte(params.Password),
+		privateKeyPath:  params.PrivateKeyPath,
+		privateKeyPaths: slices.Clone(params.PrivateKeyPaths),
+		privateKey:      []byte(params.PrivateKey),
+		passphrase:      []byte(params.Passphrase),
+		jumpKeyPath:     params.JumpPrivateKeyPath,
+	}
+}
+
+// stripCredentials returns params without its secrets
+func stripCredentials(params ConnectParams) ConnectParams {
+	params.Password = ""
+	params.PrivateKeyPath = ""
+	params.PrivateKeyPaths = nil
+	params.PrivateKey = ""
+	params.Passphrase = ""
+	params.JumpPrivateKeyPath = ""
+	return params
+}
+
+// wipe zeroes and drops the retained secrets
+func (c *credentials) wipe() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	zero(c.password)
+	c.password = nil
+	c.privateKeyPath = ""
+	c.privateKeyPaths = nil
+	zero(c.privateKey)
+	c.privateKey = nil
+	zero(c.passphrase)
+	c.passphrase = nil
+	c.jumpKeyPath = ""
+}
+
+// restore puts the retained secrets back into params
+func (c *credentials) restore(params *ConnectParams) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	params.Password = string(c.password)
+	params.PrivateKeyPath = c.privateKeyPath
+	params.PrivateKeyPaths = slices.Clone(c.privateKeyPaths)
+	params.PrivateKey = string(c.privateKey)
+	params.Passphrase = string(c.passphrase)
+	params.JumpPrivateKeyPath = c.jumpKeyPath
+}
+
+// zero overwrites b with zero bytes
+func zero(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
+
+// ForgetCredentials wipes the authentication secrets retained for a
+// connection. The live connection keeps working, but anything that needs to
+// authenticate again has to be given the credentials anew. It reports whether
+// any credentials were retained.
+func (m *Manager) ForgetCredentials(id string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	conn, exists := m.connections[id]
+	if !exists {
+		return false, m.connectionNotFound(id)
+	}
+
+	if conn.credentials == nil {
+		return false, nil
+	}
+
+	conn.credentials.wipe()
+	conn.credentials = nil
+	return true, nil
+}
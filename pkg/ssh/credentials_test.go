@@ -0,0 +1,47 @@
+package ssh
+
+import (
+	"testing"
+)
+
+func TestForgetCredentials(t *testing.T) {
+	server := newTestServer(t)
+	manager := newTestManager(t)
+	connectTestServer(t, manager, server, "default")
+
+	forgotten, err := manager.ForgetCredentials("default")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if forgotten {
+		t.Errorf("expected no credentials to be retained by default")
+	}
+
+	password := []byte(testPassword)
+	manager.mu.Lock()
+	manager.connections["default"].credentials = &credentials{password: password, privateKeyPath: "/tmp/key"}
+	manager.mu.Unlock()
+
+	forgotten, err = manager.ForgetCredentials("default")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !forgotten {
+		t.Errorf("expected the retained credentials to be forgotten")
+	}
+	for i, b := range password {
+		if b != 0 {
+			t.Fatalf("expected password byte %d to be zeroed, got %q", i, b)
+		}
+	}
+
+	// The live connection is unaffected
+	result, err := manager.Execute("default", "echo still here")
+	if err != nil || result.Stdout != "still here" {
+		t.Errorf("expected the connection to keep working, got %+v, %v", result, err)
+	}
+
+	if _, err := manager.ForgetCredentials("unknown"); err == nil {
+		t.Errorf("expected an error for an unknown connection")
+	}
+}
@@ -0,0 +1,264 @@
+package ssh
+
+import (
+	"fmt"
+	"strings"
+)
+
+// EnvLoadResult describes the variables exported from a dotenv file
+type EnvLoadResult struct {
+	// Names are the exported variables in the order they first appear
+	Names []string
+
+	// Skipped lists the lines that could not be parsed
+	Skipped []EnvLineError
+}
+
+// EnvLineError explains why a dotenv line was skipped
+type EnvLineError struct {
+	Line   int
+	Reason string
+}
+
+// envVar is a variable assignment read from a dotenv file
+type envVar struct {
+	name  string
+	value string
+}
+
+// LoadEnvFile reads a dotenv file over SFTP and exports its variables into
+// the connection's persistent shell. Values are exported literally, without
+// variable or command expansion. Lines that cannot be parsed are skipped and
+// reported; the values themselves are never returned.
+func (m *Manager) LoadEnvFile(id, remotePath string) (*EnvLoadResult, error) {
+	client, err := m.sftpClient(id)
+	if err != nil {
+		return nil, err
+	}
+
+	resolved, err := m.resolveRemotePath(client, remotePath)
+	if err != nil {
+		return nil, err
+	}
+
+	file := readRemoteFile(client, resolved, MaxReadFileSize)
+	if file.Error != "" {
+		return nil, fmt.Errorf("failed to read '%s': %s", remotePath, file.Error)
+	}
+	if file.Truncated {
+		return nil, fmt.Errorf("'%s' is larger than %d bytes", remotePath, MaxReadFileSize)
+	}
+
+	vars, skipped := parseDotenv(string(file.Content))
+	result := &EnvLoadResult{Skipped: skipped}
+	if len(vars) == 0 {
+		return result, nil
+	}
+
+	seen := make(map[string]bool, len(vars))
+	var script strings.Builder
+	for _, v := range vars {
+		fmt.Fprintf(&script, "export %s=%s\n", v.name, shellQuote(v.value))
+		if !seen[v.name] {
+			seen[v.name] = true
+			result.Names = append(result.Names, v.name)
+		}
+	}
+
+	exported, err := m.Execute(id, strings.TrimSuffix(script.String(), "\n"))
+	if err != nil {
+		return nil, err
+	}
+	if exported.ExitCode != 0 {
+		return nil, fmt.Errorf("failed to export variables: %s", exported.Stderr)
+	}
+	return result, nil
+}
+
+// SetEnv exports a variable into the persistent shell of a connection. The
+// value is set literally, without variable or command expansion.
+func (m *Manager) SetEnv(id, name, value string) error {
+	_, err := m.runWithReconnect(id, func(executor *ShellExecutor) error {
+		return executor.SetEnv(name, value)
+	})
+	return err
+}
+
+// SetEnv exports a variable into the persistent shell. The value is single
+// quoted, so it is set literally, without variable or command expansion.
+func (e *ShellExecutor) SetEnv(name, value string) error {
+	if !isEnvName(name) {
+		return fmt.Errorf("invalid variable name %q: it must start with a letter or underscore and contain only letters, digits and underscores", name)
+	}
+	if strings.ContainsRune(value, 0) {
+		return fmt.Errorf("the value of %s must not contain NUL bytes", name)
+	}
+	if strings.Contains(value, "__MCP_SSH_END_") {
+		return fmt.Errorf("the value of %s contains forbidden delimiter pattern '__MCP_SSH_END_'", name)
+	}
+
+	result, err := e.ExecuteWithOptions(fmt.Sprintf("export %s=%s", name, shellQuote(value)), ExecuteOptions{inShell: true})
+	if err != nil {
+		return err
+	}
+	if result.ExitCode != 0 {
+		// e.g. a readonly variable
+		return fmt.Errorf("failed to export %s: %s", name, result.Stderr)
+	}
+	return nil
+}
+
+// envEndMarker is printed after the environment, so that trailing
+// whitespace of the last value survives the trimming of the output
+const envEndMarker = "."
+
+// GetEnv returns the environment of the persistent shell of a connection
+func (m *Manager) GetEnv(id string) (map[string]string, error) {
+	var env map[string]string
+	_, err := m.runWithReconnect(id, func(executor *ShellExecutor) error {
+		var err error
+		env, err = executor.GetEnv()
+		return err
+	})
+	return env, err
+}
+
+// GetEnv returns the variables exported by the persistent shell, as seen by
+// the commands it runs, by running env in it
+func (e *ShellExecutor) GetEnv() (map[string]string, error) {
+	result, err := e.ExecuteWithOptions("command env; echo "+envEndMarker, ExecuteOptions{inShell: true})
+	if err != nil {
+		return nil, err
+	}
+	if result.Truncated {
+		return nil, fmt.Errorf("the environment is larger than %d bytes", MaxOutputSize)
+	}
+	if result.ExitCode != 0 || !strings.HasSuffix(result.Stdout, envEndMarker) {
+		return nil, fmt.Errorf("failed to list the environment: %s", result.Stderr)
+	}
+	output := strings.TrimSuffix(result.Stdout, envEndMarker)
+	return parseEnv(strings.TrimSuffix(output, "\n")), nil
+}
+
+// parseEnv parses the output of env: NAME=value lines, split at the first
+// '=' so that values may contain more. A line that does not start with a
+// name and '=' continues the value of the previous one, which held a
+// newline. A continuation line that looks like an assignment cannot be told
+// apart from one and is taken as a new variable.
+func parseEnv(output string) map[string]string {
+	env := make(map[string]string)
+	var last string
+	for _, line := range strings.Split(output, "\n") {
+		name, value, found := strings.Cut(line, "=")
+		if found && name != "" && !strings.ContainsAny(name, " \t") {
+			env[name] = value
+			last = name
+			continue
+		}
+		if last != "" {
+			env[last] += "\n" + line
+		}
+	}
+	return env
+}
+
+// parseDotenv parses dotenv content: KEY=value lines with an optional
+// "export " prefix, '#' comments and blank lines. Single-quoted values are
+// literal, double-quoted values support \n, \t, \", \\ and \$ escapes, and
+// both may span several lines. Unquoted values end at an inline " #" comment.
+func parseDotenv(content string) ([]envVar, []EnvLineError) {
+	var vars []envVar
+	var skipped []EnvLineError
+
+	lines := strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		lineNumber := i + 1
+		line := strings.TrimSpace(lines[i])
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimSpace(strings.TrimPrefix(line, "export "))
+
+		name, value, found := strings.Cut(line, "=")
+		if !found {
+			skipped = append(skipped, EnvLineError{Line: lineNumber, Reason: "missing '='"})
+			continue
+		}
+		name = strings.TrimSpace(name)
+		if !isEnvName(name) {
+			skipped = append(skipped, EnvLineError{Line: lineNumber, Reason: fmt.Sprintf("invalid variable name %q", name)})
+			continue
+		}
+
+		value = strings.TrimLeft(value, " \t")
+		if value == "" || (value[0] != '\'' && value[0] != '"') {
+			if comment := strings.Index(value, " #"); comment >= 0 {
+				value = value[:comment]
+			}
+			vars = append(vars, envVar{name: name, value: strings.TrimSpace(value)})
+			continue
+		}
+
+		// Quoted values may continue on the following lines
+		quote := value[0]
+		raw := value[1:]
+		end := closingQuote(raw, quote)
+		for end < 0 && i+1 < len(lines) {
+			i++
+			raw += "\n" + lines[i]
+			end = closingQuote(raw, quote)
+		}
+		if end < 0 {
+			skipped = append(skipped, EnvLineError{Line: lineNumber, Reason: "unterminated quoted value"})
+			continue
+		}
+
+		if quote == '"' {
+			vars = append(vars, envVar{name: name, value: unescapeDotenv(raw[:end])})
+		} else {
+			vars = append(vars, envVar{name: name, value: raw[:end]})
+		}
+	}
+	return vars, skipped
+}
+
+// closingQuote returns the index of the quote ending s, skipping quotes
+// escaped with a backslash inside double quotes, or -1 if there is none
+func closingQuote(s string, quote byte) int {
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && quote == '"':
+			i++
+		case s[i] == quote:
+			return i
+		}
+	}
+	return -1
+}
+
+// unescapeDotenv resolves the escapes of a double-quoted dotenv value.
+// Unknown escapes are kept as they are.
+func unescapeDotenv(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case '"', '\\', '$':
+			b.WriteByte(s[i])
+		default:
+			b.WriteByte('\\')
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,172 @@
+package ssh
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseDotenv(t *testing.T) {
+	content := "# database settings\n" +
+		"DB_HOST=db.internal\n" +
+		"export DB_PORT = 5432\n" +
+		"\n" +
+		"GREETING=hello world # inline comment\n" +
+		"SINGLE='$HOME \\n stays'\n" +
+		"DOUBLE=\"line one\\nsay \\\"hi\\\" \\$HOME\"\n" +
+		"MULTI=\"first\r\n" +
+		"second\"\n" +
+		"EMPTY=\n" +
+		"URL=https://example.com/#anchor\n" +
+		"MY-VAR=1\n" +
+		"not an assignment\n" +
+		"BROKEN='never closed\n"
+
+	vars, skipped := parseDotenv(content)
+
+	expectedVars := []envVar{
+		{"DB_HOST", "db.internal"},
+		{"DB_PORT", "5432"},
+		{"GREETING", "hello world"},
+		{"SINGLE", `$HOME \n stays`},
+		{"DOUBLE", "line one\nsay \"hi\" $HOME"},
+		{"MULTI", "first\nsecond"},
+		{"EMPTY", ""},
+		{"URL", "https://example.com/#anchor"},
+	}
+	if !reflect.DeepEqual(vars, expectedVars) {
+		t.Errorf("parseDotenv() vars = %q, want %q", vars, expectedVars)
+	}
+
+	expectedSkipped := []EnvLineError{
+		{Line: 12, Reason: `invalid variable name "MY-VAR"`},
+		{Line: 13, Reason: "missing '='"},
+		{Line: 14, Reason: "unterminated quoted value"},
+	}
+	if !reflect.DeepEqual(skipped, expectedSkipped) {
+		t.Errorf("parseDotenv() skipped = %+v, want %+v", skipped, expectedSkipped)
+	}
+}
+
+func TestLoadEnvFile(t *testing.T) {
+	envFile := filepath.Join(t.TempDir(), ".env")
+	content := "APP_NAME='my app'\nAPP_SECRET=\"s3cr3t; echo pwned\"\nAPP_NAME=final\n1BAD=x\n"
+	if err := os.WriteFile(envFile, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	server := newTestServer(t)
+	manager := newTestManager(t)
+	connectTestServer(t, manager, server, "default")
+
+	result, err := manager.LoadEnvFile("default", envFile)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(result.Names, []string{"APP_NAME", "APP_SECRET"}) {
+		t.Errorf("unexpected names %q", result.Names)
+	}
+	if len(result.Skipped) != 1 || result.Skipped[0].Line != 4 {
+		t.Errorf("expected line 4 to be skipped, got %+v", result.Skipped)
+	}
+
+	check, err := manager.Execute("default", `printf '%s|%s' "$APP_NAME" "$APP_SECRET"`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if check.Stdout != "final|s3cr3t; echo pwned" {
+		t.Errorf("expected the variables to persist in the shell, got %q", check.Stdout)
+	}
+
+	if _, err := manager.LoadEnvFile("default", filepath.Join(t.TempDir(), "missing.env")); err == nil {
+		t.Errorf("expected an error for a missing file")
+	}
+}
+
+func TestSetEnv(t *testing.T) {
+	server := newTestServer(t)
+	manager := newTestManager(t)
+	connectTestServer(t, manager, server, "default")
+
+	value := `it's "quoted" $HOME $(id) ` + "`id`\nsecond line"
+	if err := manager.SetEnv("default", "FOO", value); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	result, err := manager.Execute("default", `echo "$FOO"`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Stdout != value {
+		t.Errorf("expected %q, got %q", value, result.Stdout)
+	}
+
+	// The variable is exported to child processes
+	result, err = manager.Execute("default", `sh -c 'printf %s "$FOO"'`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Stdout != value {
+		t.Errorf("expected the variable to be exported, got %q", result.Stdout)
+	}
+
+	for _, name := range []string{"", "1FOO", "FOO-BAR", "FOO;id", "FOO BAR"} {
+		if err := manager.SetEnv("default", name, "x"); err == nil {
+			t.Errorf("expected name %q to be rejected", name)
+		}
+	}
+	if err := manager.SetEnv("default", "FOO", "__MCP_SSH_END_1__:0"); err == nil {
+		t.Error("expected a value holding the delimiter pattern to be rejected")
+	}
+	if _, err := manager.Execute("default", "readonly LOCKED=1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := manager.SetEnv("default", "LOCKED", "2"); err == nil {
+		t.Error("expected setting a readonly variable to fail")
+	}
+}
+
+func TestGetEnv(t *testing.T) {
+	server := newTestServer(t)
+	manager := newTestManager(t)
+	connectTestServer(t, manager, server, "default")
+
+	value := "a=b==c\nsecond line  "
+	if err := manager.SetEnv("default", "FOO", value); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := manager.SetEnv("default", "EMPTY", ""); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	env, err := manager.GetEnv("default")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if env["FOO"] != value {
+		t.Errorf("expected FOO=%q, got %q", value, env["FOO"])
+	}
+	if got, set := env["EMPTY"]; !set || got != "" {
+		t.Errorf("expected EMPTY to be set and empty, got %q, %v", got, set)
+	}
+	if _, set := env["MCP_SSH_UNSET"]; set {
+		t.Error("expected MCP_SSH_UNSET not to be set")
+	}
+	if env["HOME"] == "" {
+		t.Error("expected HOME in the environment")
+	}
+}
+
+func TestParseEnv(t *testing.T) {
+	env := parseEnv("A=1\nB=x=y\nC=first\nsecond\n\nD=\nBASH_FUNC_f%%=() {  echo hi\n}")
+	want := map[string]string{
+		"A":             "1",
+		"B":             "x=y",
+		"C":             "first\nsecond\n",
+		"D":             "",
+		"BASH_FUNC_f%%": "() {  echo hi\n}",
+	}
+	if !reflect.DeepEqual(env, want) {
+		t.Errorf("parseEnv() = %q, want %q", env, want)
+	}
+}
@@ -0,0 +1,73 @@
+package ssh
+
+import (
+	"errors"
+	"net"
+	"strings"
+)
+
+// ErrorCode classifies a failure so that callers can react to it without
+// parsing the error message
+type ErrorCode string
+
+// Error codes carried by ConnectionError
+const (
+	// CodeHostNotAllowed: the host does not match the allowed hosts
+	CodeHostNotAllowed ErrorCode = "host_not_allowed"
+
+	// CodeAuthFailed: the server rejected every authentication method
+	CodeAuthFailed ErrorCode = "auth_failed"
+
+	// CodeNotFound: there is no connection with the given ID
+	CodeNotFound ErrorCode = "not_found"
+
+	// CodeLimitReached: the maximum number of connections is open
+	CodeLimitReached ErrorCode = "limit_reached"
+
+	// CodeTimeout: connecting or running a command took too long
+	CodeTimeout ErrorCode = "timeout"
+
+	// CodeRateLimited: the connection ran too many commands in the last
+	// minute
+	CodeRateLimited ErrorCode = "rate_limited"
+)
+
+// ConnectionError is an error with a code telling what kind of failure it
+// is. The underlying error, which may be of a more specific type such as
+// ConnectionNotFoundError, is available through errors.As.
+type ConnectionError struct {
+	Code ErrorCode
+	Err  error
+}
+
+func (e *ConnectionError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ConnectionError) Unwrap() error {
+	return e.Err
+}
+
+// ErrorCodeOf returns the code of the first ConnectionError in err's chain,
+// empty if there is none
+func ErrorCodeOf(err error) ErrorCode {
+	var connErr *ConnectionError
+	if errors.As(err, &connErr) {
+		return connErr.Code
+	}
+	return ""
+}
+
+// classifyDialError adds a code to the error of an SSH dial or handshake that
+// failed for a known reason: authentication or a timeout
+func classifyDialError(err error) error {
+	var netErr net.Error
+	switch {
+	case strings.Contains(err.Error(), "unable to authenticate"):
+		// The ssh package has no error type for this
+		return &ConnectionError{Code: CodeAuthFailed, Err: err}
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return &ConnectionError{Code: CodeTimeout, Err: err}
+	}
+	return err
+}
@@ -0,0 +1,135 @@
+package ssh
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestErrorCodes(t *testing.T) {
+	server := newTestServer(t)
+	manager := newTestManager(t)
+	connectTestServer(t, manager, server, "default")
+
+	tests := []struct {
+		name     string
+		run      func() error
+		expected ErrorCode
+	}{
+		{
+			name: "host not allowed",
+			run: func() error {
+				params := server.params("other")
+				params.Host = "example.com"
+				_, err := manager.Connect(params)
+				return err
+			},
+			expected: CodeHostNotAllowed,
+		},
+		{
+			name: "jump host not allowed",
+			run: func() error {
+				params := server.params("other")
+				params.JumpHost = "example.com"
+				_, err := manager.Connect(params)
+				return err
+			},
+			expected: CodeHostNotAllowed,
+		},
+		{
+			name: "auth failed",
+			run: func() error {
+				params := server.params("other")
+				params.Password = "wrong"
+				_, err := manager.Connect(params)
+				return err
+			},
+			expected: CodeAuthFailed,
+		},
+		{
+			name: "not found",
+			run: func() error {
+				_, err := manager.Execute("missing", "true")
+				return err
+			},
+			expected: CodeNotFound,
+		},
+		{
+			name: "limit reached",
+			run: func() error {
+				manager.mu.Lock()
+				manager.config.MaxConnections = 1
+				manager.mu.Unlock()
+				_, err := manager.Connect(server.params("other"))
+				return err
+			},
+			expected: CodeLimitReached,
+		},
+		{
+			name: "command timeout",
+			run: func() error {
+				_, err := manager.ExecuteWithOptions("default", "sleep 1", ExecuteOptions{Timeout: 200 * time.Millisecond})
+				return err
+			},
+			expected: CodeTimeout,
+		},
+		{
+			name: "other failure",
+			run: func() error {
+				_, err := manager.Execute("default", "exit")
+				return err
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.run()
+			if err == nil {
+				t.Fatalf("expected an error")
+			}
+			if code := ErrorCodeOf(err); code != tt.expected {
+				t.Errorf("expected code %q, got %q (%v)", tt.expected, code, err)
+			}
+		})
+	}
+
+	// The specific error type is still available
+	_, err := manager.Execute("missing", "true")
+	var notFound *ConnectionNotFoundError
+	if !errors.As(err, &notFound) || notFound.ID != "missing" {
+		t.Errorf("expected a ConnectionNotFoundError, got %v", err)
+	}
+}
+
+// fakeTimeoutError is a net.Error reporting a timeout
+type fakeTimeoutError struct{}
+
+func (fakeTimeoutError) Error() string   { return "i/o timeout" }
+func (fakeTimeoutError) Timeout() bool   { return true }
+func (fakeTimeoutError) Temporary() bool { return true }
+
+func TestClassifyDialError(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected ErrorCode
+	}{
+		{name: "auth", err: fmt.Errorf("failed to connect: %w", errors.New("ssh: handshake failed: ssh: unable to authenticate, attempted methods [none password], no supported methods remain")), expected: CodeAuthFailed},
+		{name: "timeout", err: fmt.Errorf("failed to connect: %w", fakeTimeoutError{}), expected: CodeTimeout},
+		{name: "refused", err: errors.New("failed to connect: connection refused")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := classifyDialError(tt.err)
+			if code := ErrorCodeOf(err); code != tt.expected {
+				t.Errorf("expected code %q, got %q", tt.expected, code)
+			}
+			if err.Error() != tt.err.Error() {
+				t.Errorf("expected the message to be kept, got %q", err.Error())
+			}
+		})
+	}
+}
@@ -1,11 +1,18 @@
 package ssh
 
 import (
-	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"hash"
 	"io"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"golang.org/x/crypto/ssh"
@@ -13,39 +20,344 @@ import (
 
 const (
 	// Shell initialization timeouts
-	shellInitialDrainDelay = 200 * time.Millisecond
-	shellInitCommandDelay  = 100 * time.Millisecond
+	shellInitTimeout  = 10 * time.Second
+	shellReadyTimeout = 10 * time.Second
 
-	// Command execution timeout
-	defaultCommandTimeout = 30 * time.Second
+	// shellInitToken starts the sentinel echoed after the init commands,
+	// which ends the output of the shell's startup
+	shellInitToken = "__MCP_INIT_"
 
-	// Read timeouts
-	stderrReadTimeout = 100 * time.Millisecond
-	pollInterval      = 10 * time.Millisecond
+	// shellReadyToken is echoed once the shell is initialized, to check that
+	// commands' output comes back clean
+	shellReadyToken = "__MCP_READY__"
+
+	// DefaultCommandTimeout is the command execution timeout
+	DefaultCommandTimeout = 30 * time.Second
+
+	// MaxCommandTimeout is the longest per-command timeout ExecuteWithTimeout
+	// is meant to be given
+	MaxCommandTimeout = time.Hour
+
+	// DefaultIdleOutputThreshold is how long a command may go without producing
+	// output before a timeout is reported as a possible hang
+	DefaultIdleOutputThreshold = 10 * time.Second
+
+	// streamEndTimeout is how long the delimiter ending a command's stderr
+	// is waited for once the one on stdout has been seen. It only runs out
+	// when the command redirected the stderr of the shell itself.
+	streamEndTimeout = time.Second
+
+	// DefaultHealthCheckTimeout is how long the server is given to answer
+	// the keepalive sent before a command to a quiet connection
+	DefaultHealthCheckTimeout = 5 * time.Second
+
+	// healthCheckIdle is how long a connection may go without receiving
+	// anything before it is checked for being alive ahead of a command
+	healthCheckIdle = time.Second
+
+	// resyncTimeout is how long a command that timed out is given to end
+	// once interrupted before the shell is reported busy
+	resyncTimeout = 2 * time.Second
+
+	// Terminal requested for a shell in PTY mode
+	ptyTerm    = "xterm-256color"
+	ptyRows    = 24
+	ptyColumns = 80
+
+	// readBufferSize is the size of the buffer used to read shell output
+	readBufferSize = 32 * 1024
+
+	// outputQueueSize is the number of output chunks buffered between the
+	// stream readers and Execute
+	outputQueueSize = 64
+
+	// DefaultTeePreviewBytes is how much of a tee'd command's output is returned
+	DefaultTeePreviewBytes = 64 * 1024 // 64KB
 
 	// Output size limits
-	maxCommandSize = 1 * 1024 * 1024 // 1MB
-	maxOutputSize  = 10 * 1024 * 1024 // 10MB
+	MaxCommandSize = 1 * 1024 * 1024  // 1MB
+	MaxOutputSize  = 10 * 1024 * 1024 // 10MB
 )
 
+// ErrConnectionDead matches, with errors.Is, every ConnectionLostError: the
+// connection stopped answering or its shell's streams were closed
+var ErrConnectionDead = errors.New("the connection is dead")
+
+// ErrShellBusy is returned for a command sent to a shell that is still
+// running an earlier command that timed out
+var ErrShellBusy = errors.New("the shell is still running a command that timed out")
+
+// Output stream names
+const (
+	StreamStdout = "stdout"
+	StreamStderr = "stderr"
+)
+
+// OutputChunk is a piece of command output tagged with the stream it was
+// written to
+type OutputChunk struct {
+	Stream string
+	Data   string
+
+	// Offset is when the chunk arrived, relative to the command start
+	Offset time.Duration
+}
+
 // CommandResult represents the result of a command execution
 type CommandResult struct {
 	Stdout   string
 	Stderr   string
 	ExitCode int
+
+	// Chunks holds stdout and stderr in arrival order. It is only populated
+	// when requested with ExecuteOptions.CaptureChunks.
+	Chunks []OutputChunk
+
+	// Timing breaks down where the execution time was spent
+	Timing CommandTiming
+
+	// StartedAt is when the command was sent to the shell and FinishedAt when
+	// its end was seen, both in UTC
+	StartedAt  time.Time
+	FinishedAt time.Time
+
+	// Reconnected is set when the connection was lost and re-established
+	// before the command ran. The command ran in a fresh shell, so the
+	// working directory and variables of the previous shell are gone.
+	Reconnected bool
+
+	// Cancelled is set when the command was interrupted through its request
+	// ID
+	Cancelled bool
+
+	// StdoutSHA256 is the hex SHA-256 of stdout exactly as the command wrote
+	// it, before output filters and whitespace trimming, and StdoutBytes its
+	// length. They are only set when requested with ExecuteOptions.HashStdout.
+	StdoutSHA256 string
+	StdoutBytes  int64
+
+	// Truncated is set when stdout or stderr exceeded MaxOutputSize. The
+	// output is then cut at the limit and the command was interrupted.
+	Truncated bool
+}
+
+// ConnectionLostError reports that the shell's connection failed while
+// running a command
+type ConnectionLostError struct {
+	// Sent is set when the command had already been written to the shell,
+	// so it may have run
+	Sent bool
+
+	Err error
+}
+
+func (e *ConnectionLostError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ConnectionLostError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is ErrConnectionDead
+func (e *ConnectionLostError) Is(target error) bool {
+	return target == ErrConnectionDead
+}
+
+// CommandTiming is the time spent in each phase of a command execution
+type CommandTiming struct {
+	// LockWait is the time spent waiting for commands already running on the
+	// same shell to finish
+	LockWait time.Duration
+
+	// Exec is the time from sending the command until its end delimiter
+	// arrived
+	Exec time.Duration
+
+	// Read is the time spent collecting remaining output after the delimiter
+	Read time.Duration
+}
+
+// ExecuteOptions tunes a single command execution
+type ExecuteOptions struct {
+	// CaptureChunks records the output as chunks in arrival order, preserving
+	// the interleaving of stdout and stderr
+	CaptureChunks bool
+
+	// CombineOutput redirects the command's stderr to its stdout in the
+	// shell, so that Stdout holds both in the order they were written and
+	// Stderr stays empty
+	CombineOutput bool
+
+	// Timeout, when positive, replaces the shell's CommandTimeout for this
+	// command
+	Timeout time.Duration
+
+	// RequestID identifies the command while it runs (see Manager.Cancel)
+	RequestID string
+
+	// Cancelled, when set, is checked right before the command is sent. If it
+	// returns true the command is not run and ErrCancelled is returned.
+	Cancelled func() bool
+
+	// Context, when set, cancels the command when it is done: a command not
+	// sent yet is not run and ErrCancelled is returned, a running one is
+	// interrupted as on a timeout and an error wrapping the context's error
+	// is returned without waiting for it to end
+	Context context.Context
+
+	// HashStdout sets CommandResult.StdoutSHA256 and StdoutBytes
+	HashStdout bool
+
+	// Stdin, when set, is the command's standard input, which then ends
+	// after it. Otherwise the command reads from the shell's own input.
+	Stdin *string
+
+	// Cwd, when set, is the directory the command runs in. The command runs
+	// in a subshell, so the persistent shell's working directory and any
+	// other state the command changes are left as they were.
+	Cwd string
+
+	// OnStdoutLine, when set, is called with each line of stdout as soon as
+	// it is complete (see ExecuteStream)
+	OnStdoutLine func(line string)
+
+	// inShell runs the command in the persistent shell itself even with
+	// SubshellPerCommand, for commands that inspect or change its state
+	inShell bool
+}
+
+// outputChunk is raw output read from one of the shell's streams
+type outputChunk struct {
+	stream string
+	data   []byte
+	at     time.Time
+}
+
+// ShellOptions configures a persistent shell executor
+type ShellOptions struct {
+	// CommandTimeout bounds each command execution (default: DefaultCommandTimeout)
+	CommandTimeout time.Duration
+
+	// IdleOutputThreshold is the output silence after which a timed out
+	// command is reported as possibly waiting for input or hung
+	// (default: DefaultIdleOutputThreshold)
+	IdleOutputThreshold time.Duration
+
+	// DisableHistory stops the remote shell from recording commands in its
+	// history file
+	DisableHistory bool
+
+	// AllowSessionCommands disables the check that rejects commands which
+	// would replace or exit the persistent shell (see checkSessionCommand)
+	AllowSessionCommands bool
+
+	// OutputFilter, when set, transforms the output of every command
+	OutputFilter OutputFilter
+
+	// SubshellPerCommand runs each command in a child shell so that shell
+	// options, unexported variables and functions do not carry over to the
+	// next command. The working directory and exported variables are passed
+	// back to the persistent shell (see subshellCommand).
+	SubshellPerCommand bool
+
+	// HealthCheckTimeout bounds the wait for the keepalive that checks a
+	// quiet connection before a command is sent
+	// (default: DefaultHealthCheckTimeout)
+	HealthCheckTimeout time.Duration
+
+	// RequestPTY runs the shell on a pseudo-terminal, for commands that need
+	// one such as sudo without NOPASSWD or anything checking isatty. The
+	// terminal merges stderr into stdout, so CommandResult.Stderr stays empty.
+	RequestPTY bool
+
+	// Shell, when set, replaces the user's login shell with this POSIX shell
+	// (e.g. /bin/sh or bash) before anything else is sent, for hosts whose
+	// login shell is fish, csh or another non-POSIX shell
+	Shell string
+
+	// SkipInit leaves out the commands that silence the shell's echo and
+	// prompt, for shells that fail on them. They are only needed with a PTY.
+	SkipInit bool
 }
 
-// ShellExecutor manages a persistent shell session for executing commands
+// validateProgram checks the path of a program run on the remote, such as
+// the shell given in ShellOptions, named kind in errors. It must be a single
+// word, as it is placed in commands unquoted.
+func validateProgram(kind, path string) error {
+	if len(path) > 256 {
+		return fmt.Errorf("invalid %s: too long", kind)
+	}
+	for _, r := range path {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("/._+-", r)) {
+			return fmt.Errorf("invalid %s '%s': only a path made of letters, digits and '/._+-' is allowed", kind, path)
+		}
+	}
+	return nil
+}
+
+// disableHistoryCommand keeps commands out of the remote shell history
+const disableHistoryCommand = "unset HISTFILE; export HISTSIZE=0"
+
+// ShellExecutor manages a persistent shell session for executing commands.
+// Two readers forward the shell's stdout and stderr into a single channel so
+// that output is consumed in arrival order.
 type ShellExecutor struct {
+	client  *ssh.Client
 	session *ssh.Session
 	stdin   io.WriteCloser
-	stdout  *bufio.Reader
-	stderr  *bufio.Reader
+	output  chan outputChunk
+	done    chan struct{}
+	options ShellOptions
 	mu      sync.Mutex
+
+	// readErr is the error that stopped the stream readers
+	readErr   error
+	readErrMu sync.Mutex
+	closeOnce sync.Once
+
+	// lastOutput holds the UnixNano time at which the last byte was received
+	// on either stdout or stderr
+	lastOutput atomic.Int64
+
+	// historyDisabled records whether history was disabled at init or since
+	historyDisabled atomic.Bool
+
+	// prefix is prepended to every command (see SetPrefix), nil if unset
+	prefix atomic.Pointer[string]
+
+	// sent counts the commands written to the shell
+	sent atomic.Int64
+
+	// shellPID is the process ID of the remote shell (0 if unknown). The
+	// commands it runs are its child processes.
+	shellPID int
+
+	// running is the command currently executing, nil when idle
+	running atomic.Pointer[RunningCommand]
+
+	// staleStdout and staleStderr look for the end of the output of a
+	// command that timed out, nil once it has been seen. Both are guarded by
+	// mu.
+	staleStdout *streamEnd
+	staleStderr *streamEnd
+}
+
+// RunningCommand describes the command a shell is currently executing
+type RunningCommand struct {
+	Command   string
+	RequestID string
+	Started   time.Time
 }
 
 // NewShellExecutor creates a new persistent shell executor
-func NewShellExecutor(client *ssh.Client) (*ShellExecutor, error) {
+func NewShellExecutor(client *ssh.Client, options ShellOptions) (*ShellExecutor, error) {
+	if options.Shell != "" {
+		if err := validateProgram("shell", options.Shell); err != nil {
+			return nil, err
+		}
+	}
+
 	session, err := client.NewSession()
 	if err != nil {
 		return nil, fmt.Errorf("failed to create session: %w", err)
@@ -72,41 +384,180 @@ func NewShellExecutor(client *ssh.Client) (*ShellExecutor, error) {
 		return nil, fmt.Errorf("failed to get stderr pipe: %w", err)
 	}
 
+	if options.RequestPTY {
+		modes := ssh.TerminalModes{
+			ssh.ECHO:          0,
+			ssh.TTY_OP_ISPEED: 14400,
+			ssh.TTY_OP_OSPEED: 14400,
+		}
+		if err := session.RequestPty(ptyTerm, ptyRows, ptyColumns, modes); err != nil {
+			_ = session.Close() // Best effort cleanup
+			return nil, fmt.Errorf("failed to request a PTY: %w", err)
+		}
+	}
+
 	// Start shell
 	if err := session.Shell(); err != nil {
 		_ = session.Close() // Best effort cleanup
 		return nil, fmt.Errorf("failed to start shell: %w", err)
 	}
 
+	if options.CommandTimeout <= 0 {
+		options.CommandTimeout = DefaultCommandTimeout
+	}
+	if options.IdleOutputThreshold <= 0 {
+		options.IdleOutputThreshold = DefaultIdleOutputThreshold
+	}
+	if options.HealthCheckTimeout <= 0 {
+		options.HealthCheckTimeout = DefaultHealthCheckTimeout
+	}
+
 	executor := &ShellExecutor{
+		client:  client,
 		session: session,
 		stdin:   stdin,
-		stdout:  bufio.NewReader(stdoutPipe),
-		stderr:  bufio.NewReader(stderrPipe),
+		output:  make(chan outputChunk, outputQueueSize),
+		done:    make(chan struct{}),
+		options: options,
 	}
+	// The server just answered the session request, so the connection is
+	// known to be alive
+	executor.lastOutput.Store(time.Now().UnixNano())
 
-	// Wait for initial shell output
-	time.Sleep(shellInitialDrainDelay)
-	executor.drainOutput()
+	var readers sync.WaitGroup
+	readers.Add(2)
+	go executor.pump(StreamStdout, stdoutPipe, &readers)
+	go executor.pump(StreamStderr, stderrPipe, &readers)
+	go func() {
+		readers.Wait()
+		close(executor.output)
+	}()
+
+	// The login shell only has to understand exec; everything after it is
+	// read by the given shell
+	if options.Shell != "" {
+		if _, err := stdin.Write([]byte("exec " + options.Shell + "\n")); err != nil {
+			_ = session.Close() // Best effort cleanup
+			return nil, fmt.Errorf("failed to start shell '%s': %w", options.Shell, err)
+		}
+	}
 
 	// Disable echo and set empty prompt for clean output
-	initCommands := "stty -echo 2>/dev/null; export PS1=''\n"
-	if _, err := stdin.Write([]byte(initCommands)); err != nil {
+	var init []string
+	if !options.SkipInit {
+		init = append(init, "stty -echo 2>/dev/null; export PS1=''")
+		if options.RequestPTY {
+			// Servers may ignore the requested modes. The shell is
+			// interactive on a terminal, so continuation prompts are cleared
+			// too, and lines must end in a bare newline.
+			init[0] = "stty -echo -onlcr 2>/dev/null; export PS1='' PS2=''"
+		}
+	}
+	if options.DisableHistory {
+		init = append(init, disableHistoryCommand)
+	}
+
+	// The init commands are followed by a sentinel on stdout and stderr.
+	// Everything the shell writes before it, such as a banner, prompts or
+	// the echo of its input, is discarded, however long the link takes to
+	// deliver it. The sentinel is quoted so that its echo does not match.
+	sentinel := fmt.Sprintf("%s%d__", shellInitToken, time.Now().UnixNano())
+	quoted := shellInitToken + "''" + strings.TrimPrefix(sentinel, shellInitToken)
+	init = append(init, "echo "+quoted, "echo "+quoted+" >&2")
+	if _, err := stdin.Write([]byte(strings.Join(init, "; ") + "\n")); err != nil {
 		_ = session.Close() // Best effort cleanup
 		return nil, fmt.Errorf("failed to initialize shell: %w", err)
 	}
+	if err := executor.awaitInit(sentinel); err != nil {
+		_ = executor.Close() // Best effort cleanup
+		return nil, err
+	}
+	executor.historyDisabled.Store(options.DisableHistory)
 
-	// Wait for init commands to complete and drain
-	time.Sleep(shellInitCommandDelay)
-	executor.drainOutput()
+	// Check that the shell runs commands and that their output is free of
+	// echoed input and prompts, which would otherwise pollute every result
+	if err := executor.checkReady(); err != nil {
+		_ = executor.Close() // Best effort cleanup
+		return nil, err
+	}
+
+	// Record the shell's PID so that the processes of a running command can
+	// be found from another session. Shells that cannot report it simply
+	// leave command stats unavailable.
+	if result, err := executor.ExecuteWithOptions("echo $$", ExecuteOptions{inShell: true}); err == nil && result.ExitCode == 0 {
+		executor.shellPID, _ = strconv.Atoi(strings.TrimSpace(result.Stdout))
+	}
 
 	return executor, nil
 }
 
+// awaitInit discards the shell's output up to the sentinel echoed after the
+// init commands, waiting at most shellInitTimeout for it
+func (e *ShellExecutor) awaitInit(sentinel string) error {
+	e.staleStdout = &streamEnd{delimiter: sentinel}
+	e.staleStderr = &streamEnd{delimiter: sentinel, found: e.options.RequestPTY}
+	err := e.resync(shellInitTimeout)
+	switch {
+	case errors.Is(err, ErrShellBusy):
+		e.staleStdout, e.staleStderr = nil, nil
+		return fmt.Errorf("shell initialization failed, the shell did not run the init commands within %s; "+
+			"the shell may be restricted or not POSIX, try setting shell or skip_shell_init", shellInitTimeout)
+	case err != nil:
+		return fmt.Errorf("shell initialization failed: %w", err)
+	}
+	return nil
+}
+
+// checkReady runs a sentinel command in the freshly initialized shell and
+// fails unless its output is exactly the expected token
+func (e *ShellExecutor) checkReady() error {
+	result, err := e.ExecuteWithOptions("echo "+shellReadyToken, ExecuteOptions{Timeout: shellReadyTimeout, inShell: true})
+	if err != nil {
+		return fmt.Errorf("shell initialization failed, the shell did not run a test command: %w", err)
+	}
+	if result.ExitCode != 0 || strings.TrimSpace(result.Stdout) != shellReadyToken {
+		output := result.Stdout
+		if len(output) > 200 {
+			output = output[:200] + "..."
+		}
+		return fmt.Errorf("shell initialization failed, a test command printed %q (exit code %d) instead of %q; "+
+			"the shell may be restricted or not POSIX, try setting shell or skip_shell_init", output, result.ExitCode, shellReadyToken)
+	}
+	return nil
+}
+
 // Execute runs a command in the persistent shell and returns the result
 func (e *ShellExecutor) Execute(command string) (*CommandResult, error) {
+	return e.ExecuteWithOptions(command, ExecuteOptions{})
+}
+
+// ExecuteWithTimeout runs a command in the persistent shell like Execute, but
+// gives up after timeout instead of the shell's CommandTimeout (zero keeps
+// the latter). The shell's output readers outlive the command, so a timeout
+// leaves no goroutine behind.
+func (e *ShellExecutor) ExecuteWithTimeout(command string, timeout time.Duration) (*CommandResult, error) {
+	return e.ExecuteWithOptions(command, ExecuteOptions{Timeout: timeout})
+}
+
+// ExecuteWithContext runs a command in the persistent shell like Execute,
+// interrupting it when ctx is cancelled (see ExecuteOptions.Context)
+func (e *ShellExecutor) ExecuteWithContext(ctx context.Context, command string) (*CommandResult, error) {
+	return e.ExecuteWithOptions(command, ExecuteOptions{Context: ctx})
+}
+
+// ExecuteWithInput runs a command in the persistent shell like Execute, with
+// stdin as its standard input
+func (e *ShellExecutor) ExecuteWithInput(command, stdin string) (*CommandResult, error) {
+	return e.ExecuteWithOptions(command, ExecuteOptions{Stdin: &stdin})
+}
+
+// ExecuteWithOptions runs a command in the persistent shell with the given
+// per-call options and returns the result
+func (e *ShellExecutor) ExecuteWithOptions(command string, opts ExecuteOptions) (*CommandResult, error) {
+	called := time.Now()
 	e.mu.Lock()
 	defer e.mu.Unlock()
+	lockWait := time.Since(called)
 
 	// Generate unique delimiter
 	delimiter := fmt.Sprintf("__MCP_SSH_END_%d__", time.Now().UnixNano())
@@ -117,141 +568,747 @@ func (e *ShellExecutor) Execute(command string) (*CommandResult, error) {
 	if strings.Contains(command, "__MCP_SSH_END_") {
 		return nil, fmt.Errorf("command contains forbidden delimiter pattern '__MCP_SSH_END_'")
 	}
+	if opts.Stdin != nil {
+		if err := validateStdin(*opts.Stdin); err != nil {
+			return nil, err
+		}
+	}
+
+	// A command given a directory runs in a subshell, where exit and exec
+	// leave the persistent shell alone
+	shellCommand := command
+	if opts.Cwd != "" {
+		if err := validateRemotePath(opts.Cwd); err != nil {
+			return nil, fmt.Errorf("invalid cwd: %w", err)
+		}
+		shellCommand = cwdCommand(command, opts.Cwd)
+	}
+
+	if !e.options.AllowSessionCommands {
+		if err := checkSessionCommand(shellCommand); err != nil {
+			return nil, err
+		}
+	}
+
+	// A shell whose streams already failed cannot run the command
+	if err := e.streamError(); err != nil {
+		return nil, &ConnectionLostError{Err: fmt.Errorf("shell session closed: %w", err)}
+	}
+
+	// A connection that went quiet may have died without its streams
+	// noticing, e.g. when the server vanished from the network. The command
+	// would then only fail once it timed out.
+	if err := e.checkAlive(); err != nil {
+		return nil, err
+	}
+
+	// The rest of the output of a command that timed out must not be taken
+	// for this one's
+	if err := e.resync(streamEndTimeout); err != nil {
+		return nil, err
+	}
 
 	// Prepare command with delimiter and exit code capture
 	// We use a compound command that:
 	// 1. Executes the user's command
 	// 2. Captures the exit code
 	// 3. Prints the delimiter followed by the exit code
-	fullCommand := fmt.Sprintf(
-		"%s\necho \"%s:$?\"\n",
-		command,
-		delimiter,
-	)
+	// 4. Prints the delimiter on stderr too, so that the end of the
+	//    command's stderr is known regardless of when it arrives. A PTY has
+	//    no separate stderr.
+	if prefix := e.Prefix(); !opts.inShell && (e.options.SubshellPerCommand || prefix != "") {
+		shellCommand = subshellCommand(prefix, command)
+	}
+	if opts.CombineOutput {
+		shellCommand = combinedCommand(shellCommand)
+	}
+	if opts.Stdin != nil {
+		shellCommand = stdinCommand(shellCommand, *opts.Stdin)
+	}
+	fullCommand := fmt.Sprintf("%s\necho \"%s:$?\"", shellCommand, delimiter)
+	if !e.options.RequestPTY {
+		fullCommand += fmt.Sprintf("; echo \"%s:\" >&2", delimiter)
+	}
+	fullCommand += "\n"
 
 	// Send command
-	if _, err := e.stdin.Write([]byte(fullCommand)); err != nil {
-		return nil, fmt.Errorf("failed to write command: %w", err)
+	started := time.Now()
+	e.lastOutput.Store(started.UnixNano())
+	e.running.Store(&RunningCommand{Command: command, RequestID: opts.RequestID, Started: started})
+	defer e.running.Store(nil)
+
+	// Checked after the command is marked as running so that a cancellation
+	// either sees it running or stops it here
+	if opts.Cancelled != nil && opts.Cancelled() {
+		return nil, ErrCancelled
+	}
+	if opts.Context != nil && opts.Context.Err() != nil {
+		return nil, fmt.Errorf("%w: %w", ErrCancelled, opts.Context.Err())
+	}
+	n, err := e.stdin.Write([]byte(fullCommand))
+	if n > 0 {
+		e.sent.Add(1)
+	}
+	if err != nil {
+		// The shell cannot have seen the command unless part of it was written
+		return nil, &ConnectionLostError{Sent: n > 0, Err: fmt.Errorf("failed to write command: %w", err)}
+	}
+
+	result, err := e.collect(delimiter, started, opts)
+	if err != nil {
+		return nil, err
 	}
+	result.Timing.LockWait = lockWait
+	return result, nil
+}
 
-	// Read output until we see the delimiter
-	var stdoutBuilder strings.Builder
-	var stderrBuilder strings.Builder
-	var exitCode int
+// collect consumes shell output until the delimiter shows up on both stdout
+// and stderr. Should the command have redirected the shell's stderr, its
+// delimiter is given up on after streamEndTimeout. At most MaxOutputSize bytes
+// of each stream are kept; a command writing more is interrupted.
+func (e *ShellExecutor) collect(delimiter string, started time.Time, opts ExecuteOptions) (*CommandResult, error) {
+	stdout := limitedBuffer{limit: MaxOutputSize}
+	stderr := limitedBuffer{limit: MaxOutputSize}
+	var chunks []OutputChunk
 
-	// Create channels for async reading
-	stdoutChan := make(chan string, 1)
-	stderrChan := make(chan string, 1)
-	errChan := make(chan error, 2)
+	limit := e.options.CommandTimeout
+	if opts.Timeout > 0 {
+		limit = opts.Timeout
+	}
+	timeout := time.NewTimer(limit)
+	defer timeout.Stop()
 
-	// Read stdout
-	go func() {
-		output, code, err := e.readUntilDelimiter(e.stdout, delimiter)
-		if err != nil {
-			errChan <- err
-			return
-		}
-		stdoutChan <- output
-		exitCode = code
-	}()
+	var cancelled <-chan struct{}
+	if opts.Context != nil {
+		cancelled = opts.Context.Done()
+	}
 
-	// Read stderr
-	go func() {
-		output, err := e.readStderr(e.stderr, stderrReadTimeout)
-		if err != nil {
-			errChan <- err
-			return
-		}
-		stderrChan <- output
-	}()
+	var stderrWait <-chan time.Time
+	var delimiterAt time.Time
+
+	stdoutEnd := &streamEnd{delimiter: delimiter}
+	stderrEnd := &streamEnd{delimiter: delimiter, found: e.options.RequestPTY}
+	ends := map[string]*streamEnd{StreamStdout: stdoutEnd, StreamStderr: stderrEnd}
+	var stdoutHash hash.Hash
+	if opts.HashStdout {
+		stdoutHash = sha256.New()
+		stdoutEnd.consume = func(data []byte) { stdoutHash.Write(data) }
+	}
+
+	// Reads may end in the middle of a multi-byte character, which must not
+	// be split across two captured chunks
+	holdback := map[string]*utf8Holdback{StreamStdout: {}, StreamStderr: {}}
+	captured := map[string]int{}
 
-	// Wait for both readers with timeout
-	timeout := time.After(defaultCommandTimeout)
+	var lines *lineSplitter
+	if opts.OnStdoutLine != nil {
+		lines = &lineSplitter{delimiter: delimiter, emit: opts.OnStdoutLine, filter: e.options.OutputFilter}
+	}
 
-	var stdoutReceived, stderrReceived bool
-	for !stdoutReceived || !stderrReceived {
+	truncated := false
+	for !stdoutEnd.found || !stderrEnd.found {
 		select {
-		case stdout := <-stdoutChan:
-			stdoutBuilder.WriteString(stdout)
-			stdoutReceived = true
-		case stderr := <-stderrChan:
-			stderrBuilder.WriteString(stderr)
-			stderrReceived = true
-		case err := <-errChan:
-			return nil, fmt.Errorf("read error: %w", err)
-		case <-timeout:
-			return nil, fmt.Errorf("command execution timed out")
+		case chunk, ok := <-e.output:
+			if !ok {
+				return nil, &ConnectionLostError{Sent: true, Err: fmt.Errorf("shell session closed: %w", e.readError())}
+			}
+			end := ends[chunk.stream]
+			if end.found {
+				// Nothing of the command is left on a stream after its delimiter
+				continue
+			}
+
+			if chunk.stream == StreamStdout {
+				_, _ = stdout.Write(chunk.data)
+				if lines != nil {
+					lines.write(chunk.data)
+				}
+			} else {
+				_, _ = stderr.Write(chunk.data)
+			}
+			if opts.CaptureChunks && captured[chunk.stream] < MaxOutputSize {
+				// Only the captured copy is cut to the limit; the delimiter
+				// and the hash still need every byte the shell wrote
+				if data := holdback[chunk.stream].next(chunk.data); len(data) > 0 {
+					data = data[:min(len(data), MaxOutputSize-captured[chunk.stream])]
+					captured[chunk.stream] += len(data)
+					chunks = appendChunk(chunks, outputChunk{stream: chunk.stream, data: data, at: chunk.at}, started)
+				}
+			}
+
+			if end.write(chunk.data) && end == stdoutEnd {
+				delimiterAt = time.Now()
+				if !stderrEnd.found {
+					wait := time.NewTimer(streamEndTimeout)
+					defer wait.Stop()
+					stderrWait = wait.C
+				}
+			}
+
+			if !truncated && (stdoutEnd.consumed > MaxOutputSize || stderrEnd.consumed > MaxOutputSize) {
+				// Whatever the command writes from now on is discarded. It
+				// keeps running if it cannot be interrupted, until it ends
+				// or times out.
+				truncated = true
+				_ = e.interruptCommand()
+			}
+
+		case <-stderrWait:
+			// The shell's stderr no longer reaches us; keep what arrived
+			stderrEnd.found = true
+
+		case <-timeout.C:
+			return nil, e.abandon(e.timeoutError(started), stdoutEnd, stderrEnd)
+
+		case <-cancelled:
+			err := fmt.Errorf("command cancelled after %s: %w", time.Since(started).Round(time.Millisecond), opts.Context.Err())
+			return nil, e.abandon(err, stdoutEnd, stderrEnd)
 		}
 	}
 
+	for _, stream := range []string{StreamStdout, StreamStderr} {
+		if pending := holdback[stream].flush(); len(pending) > 0 && captured[stream] < MaxOutputSize {
+			chunks = appendChunk(chunks, outputChunk{stream: stream, data: pending, at: time.Now()}, started)
+		}
+	}
+	stdoutData, stderrData := stdout.buf.Bytes(), stderr.buf.Bytes()
+	stdoutData = stdoutData[:min(stdoutEnd.length(), int64(len(stdoutData)))]
+	stderrData = stderrData[:min(stderrEnd.length(), int64(len(stderrData)))]
+	chunks = trimChunks(chunks, int(stdoutEnd.length()), int(stderrEnd.length()))
+	var stdoutSHA256 string
+	var hashedBytes int64
+	if stdoutHash != nil {
+		stdoutSHA256, hashedBytes = hex.EncodeToString(stdoutHash.Sum(nil)), stdoutEnd.length()
+	}
+	if filter := e.options.OutputFilter; filter != nil {
+		stdoutData = filter(StreamStdout, stdoutData)
+		stderrData = filter(StreamStderr, stderrData)
+		chunks = filterChunks(chunks, filter)
+	}
 	return &CommandResult{
-		Stdout:   strings.TrimSpace(stdoutBuilder.String()),
-		Stderr:   strings.TrimSpace(stderrBuilder.String()),
-		ExitCode: exitCode,
+		Stdout:   strings.TrimSpace(string(stdoutData)),
+		Stderr:   strings.TrimSpace(string(stderrData)),
+		ExitCode: stdoutEnd.exitCode,
+		Chunks:   chunks,
+		Timing: CommandTiming{
+			Exec: delimiterAt.Sub(started),
+			Read: time.Since(delimiterAt),
+		},
+		StdoutSHA256: stdoutSHA256,
+		StdoutBytes:  hashedBytes,
+		StartedAt:    started.UTC(),
+		FinishedAt:   delimiterAt.UTC(),
+		Truncated:    stdoutEnd.length() > MaxOutputSize || stderrEnd.length() > MaxOutputSize,
 	}, nil
 }
 
-// readUntilDelimiter reads from the reader until it finds the delimiter
-func (e *ShellExecutor) readUntilDelimiter(reader *bufio.Reader, delimiter string) (string, int, error) {
-	var output strings.Builder
-	var exitCode int
+// streamEnd looks for the delimiter line ending a command's output on one of
+// the shell's streams. Only the end of the stream that may still hold the
+// delimiter is kept, the window; bytes leaving it are the command's output
+// and are passed to consume, if set.
+type streamEnd struct {
+	delimiter string
+	consume   func([]byte)
 
-	for {
-		line, err := reader.ReadString('\n')
-		if err != nil {
-			if err == io.EOF {
-				return output.String(), exitCode, nil
-			}
-			return "", 0, err
+	window   []byte
+	consumed int64
+
+	// found is set once the delimiter line has been seen, exitCode then
+	// holds the exit code that followed it
+	found    bool
+	exitCode int
+}
+
+// write scans the next data received on the stream and reports whether the
+// delimiter line is now complete
+func (s *streamEnd) write(data []byte) bool {
+	if s.found {
+		return true
+	}
+
+	s.window = append(s.window, data...)
+	if index, code, found := findDelimiter(s.window, s.delimiter); found {
+		s.pass(s.window[:index])
+		s.window, s.found, s.exitCode = nil, true, code
+		return true
+	}
+	if cut := len(s.window) - delimiterWindow(s.delimiter); cut > 0 {
+		s.pass(s.window[:cut])
+		s.window = append([]byte(nil), s.window[cut:]...)
+	}
+	return false
+}
+
+// pass hands bytes that left the window to consume
+func (s *streamEnd) pass(data []byte) {
+	s.consumed += int64(len(data))
+	if s.consume != nil {
+		s.consume(data)
+	}
+}
+
+// length returns how much of the stream is the command's output: everything
+// before the delimiter, or everything received if it was never seen
+func (s *streamEnd) length() int64 {
+	return s.consumed + int64(len(s.window))
+}
+
+// delimiterWindow is how much of the end of a stream may belong to a
+// delimiter line that has not fully arrived: the delimiter, the colon and
+// an exit code of up to three digits
+func delimiterWindow(delimiter string) int {
+	return len(delimiter) + 8
+}
+
+// abandon handles a command that timed out or was cancelled, failing with
+// err. Its processes are interrupted, as by Manager.Cancel, and the rest of
+// its output is discarded once it ends, so that the next command reads only
+// its own output. A command that does not end in time, such as one made of
+// shell builtins, leaves the shell busy until it does (see ErrShellBusy).
+func (e *ShellExecutor) abandon(err error, stdout, stderr *streamEnd) error {
+	stdout.consume = nil
+	e.staleStdout, e.staleStderr = stdout, stderr
+
+	if interruptErr := e.interruptCommand(); interruptErr != nil {
+		return fmt.Errorf("%w; it could not be interrupted (%v) and the shell stays busy until it ends", err, interruptErr)
+	}
+	if errors.Is(e.resync(resyncTimeout), ErrShellBusy) {
+		return fmt.Errorf("%w; it did not stop when interrupted and the shell stays busy until it ends", err)
+	}
+	return fmt.Errorf("%w; the command was interrupted", err)
+}
+
+// checkAlive sends a keepalive on a connection that received nothing for
+// healthCheckIdle. If it goes unanswered the connection is closed, so that
+// later commands fail right away, and a ConnectionLostError is returned.
+func (e *ShellExecutor) checkAlive() error {
+	if time.Since(time.Unix(0, e.lastOutput.Load())) < healthCheckIdle {
+		return nil
+	}
+	if err := sendKeepalive(e.client, e.options.HealthCheckTimeout); err != nil {
+		_ = e.client.Close() // Also unblocks the keepalive
+		return &ConnectionLostError{Err: fmt.Errorf("connection is not responding: %w", err)}
+	}
+	return nil
+}
+
+// interruptCommand sends SIGINT to the processes started by the shell,
+// looking them up over a separate session
+func (e *ShellExecutor) interruptCommand() error {
+	if e.shellPID == 0 {
+		return fmt.Errorf("the process ID of the shell is unknown")
+	}
+
+	output, err := runSession(e.client, processListCommand)
+	if err != nil {
+		return fmt.Errorf("failed to list processes: %w", err)
+	}
+	processes := descendantProcesses(parseProcessList(string(output)), e.shellPID)
+	if len(processes) == 0 {
+		return fmt.Errorf("the command has no process to interrupt")
+	}
+
+	pids := make([]int, len(processes))
+	for i, process := range processes {
+		pids[i] = process.PID
+	}
+	return interruptProcesses(e.client, pids)
+}
+
+// resync discards shell output up to the delimiters of a command that timed
+// out, or of the shell's initialization, waiting at most wait for them. It
+// returns ErrShellBusy if the command has not ended by then.
+func (e *ShellExecutor) resync(wait time.Duration) error {
+	if e.staleStdout == nil {
+		return nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	var stderrWait <-chan time.Time
+	for !e.staleStdout.found || !e.staleStderr.found {
+		if e.staleStdout.found && stderrWait == nil {
+			wait := time.NewTimer(streamEndTimeout)
+			defer wait.Stop()
+			stderrWait = wait.C
 		}
 
-		// Check if this line contains the delimiter
-		if strings.Contains(line, delimiter) {
-			// Extract exit code from delimiter line (format: __DELIMITER__:123)
-			parts := strings.Split(line, ":")
-			if len(parts) == 2 {
-				_, _ = fmt.Sscanf(strings.TrimSpace(parts[1]), "%d", &exitCode)
+		select {
+		case chunk, ok := <-e.output:
+			if !ok {
+				return &ConnectionLostError{Err: fmt.Errorf("shell session closed: %w", e.readError())}
+			}
+			if chunk.stream == StreamStdout {
+				e.staleStdout.write(chunk.data)
+			} else {
+				e.staleStderr.write(chunk.data)
 			}
-			return output.String(), exitCode, nil
+
+		case <-stderrWait:
+			// The command redirected the shell's stderr
+			e.staleStderr.found = true
+
+		case <-timer.C:
+			return fmt.Errorf("%w; wait for it to end, or close the connection and connect again", ErrShellBusy)
 		}
+	}
 
-		output.WriteString(line)
+	e.staleStdout, e.staleStderr = nil, nil
+	return nil
+}
+
+// subshellCommand wraps command to run in a child shell: bash when the
+// persistent shell is bash, sh otherwise, so that the child's export -p
+// output can be sourced by the parent. On exit, the child saves its working
+// directory and exported variables to a private directory created once per
+// shell, and the parent restores them. Everything else the command changes
+// stays in the child: shell options such as set -e, unexported variables
+// (IFS included), functions, aliases, traps, unset variables and background
+// jobs. SHLVL is kept as is rather than increasing with every command.
+//
+// A non-empty prefix, already validated, is placed before the child shell so
+// that it applies to the command as a whole.
+func subshellCommand(prefix, command string) string {
+	if prefix != "" {
+		prefix += " "
 	}
+	script := "trap 'pwd > \"$0/dir\" 2>/dev/null; export -p > \"$0/env\" 2>/dev/null' EXIT\n" + command
+	return `[ -d "${__mcp_state:-}" ] || __mcp_state=$(mktemp -d 2>/dev/null || echo /nonexistent)
+` + prefix + `"${BASH:-sh}" -c ` + shellQuote(script) + ` "$__mcp_state"
+__mcp_rc=$?
+[ -f "$__mcp_state/dir" ] && cd "$(cat "$__mcp_state/dir")"
+[ -f "$__mcp_state/env" ] && { __mcp_shlvl=$SHLVL; . "$__mcp_state/env" 2>/dev/null; SHLVL=$__mcp_shlvl; }
+rm -f "$__mcp_state/dir" "$__mcp_state/env"
+(exit $__mcp_rc)`
 }
 
-// readStderr reads stderr with a timeout (since stderr might not have data)
-func (e *ShellExecutor) readStderr(reader *bufio.Reader, timeout time.Duration) (string, error) {
-	var output strings.Builder
+// validateStdin checks input given to a command. Like the command, it is
+// sent through the shell, so it must fit in a command and cannot carry NUL
+// bytes or the delimiter pattern.
+func validateStdin(stdin string) error {
+	if len(stdin) > MaxCommandSize {
+		return fmt.Errorf("stdin exceeds maximum size of %d bytes", MaxCommandSize)
+	}
+	if strings.ContainsRune(stdin, 0) {
+		return fmt.Errorf("stdin must not contain NUL bytes")
+	}
+	if strings.Contains(stdin, "__MCP_SSH_END_") {
+		return fmt.Errorf("stdin contains forbidden delimiter pattern '__MCP_SSH_END_'")
+	}
+	return nil
+}
 
-	deadline := time.Now().Add(timeout)
-	for time.Now().Before(deadline) {
-		// Check if data is available
-		if reader.Buffered() > 0 {
-			line, err := reader.ReadString('\n')
-			if err != nil && err != io.EOF {
-				return "", err
-			}
-			output.WriteString(line)
-			if err == io.EOF {
-				break
-			}
-		} else {
-			time.Sleep(pollInterval)
+// stdinCommand wraps command to read stdin from a temporary file holding
+// exactly input. A pipe would run the command in a child shell; redirecting
+// a brace group keeps it in the persistent shell, so that its state changes
+// carry over as usual. If the file cannot be created or written, it is
+// removed, the redirection fails and the command does not run.
+func stdinCommand(command, input string) string {
+	return `__mcp_in=$(mktemp 2>/dev/null) || __mcp_in=/nonexistent
+[ "$__mcp_in" = /nonexistent ] || printf '%s' ` + shellQuote(input) + ` > "$__mcp_in" || { rm -f "$__mcp_in"; __mcp_in=/nonexistent; }
+{ ` + command + `
+} < "$__mcp_in"
+__mcp_rc=$?
+[ "$__mcp_in" = /nonexistent ] || rm -f "$__mcp_in"
+(exit $__mcp_rc)`
+}
+
+// combinedCommand wraps command to write its stderr to stdout. Redirecting a
+// brace group keeps the command in the persistent shell, and the delimiters
+// written after it still go to their own streams.
+func combinedCommand(command string) string {
+	return "{ " + command + "\n} 2>&1"
+}
+
+// cwdCommand wraps command to run in a subshell started in dir. A leading
+// "~" stands for the home directory; the rest of dir is taken literally. If
+// the directory cannot be entered, the command does not run.
+func cwdCommand(command, dir string) string {
+	quoted := shellQuote(dir)
+	if dir == "~" {
+		quoted = `"$HOME"`
+	} else if rest, ok := strings.CutPrefix(dir, "~/"); ok {
+		quoted = `"$HOME"/` + shellQuote(rest)
+	}
+	return "( cd -- " + quoted + " || exit\n" + command + "\n)"
+}
+
+// findDelimiter looks for a complete "<delimiter>:<exit code>" line in output
+// and returns the offset at which the delimiter starts along with the exit code
+func findDelimiter(output []byte, delimiter string) (int, int, bool) {
+	index := bytes.Index(output, []byte(delimiter))
+	if index < 0 {
+		return 0, 0, false
+	}
+
+	rest := output[index+len(delimiter):]
+	newline := bytes.IndexByte(rest, '\n')
+	if newline < 0 {
+		// The exit code has not fully arrived yet
+		return 0, 0, false
+	}
+
+	exitCode, _ := strconv.Atoi(strings.TrimPrefix(strings.TrimSpace(string(rest[:newline])), ":"))
+	return index, exitCode, true
+}
+
+// appendChunk records an output chunk, merging it into the previous one when
+// both come from the same stream
+func appendChunk(chunks []OutputChunk, chunk outputChunk, started time.Time) []OutputChunk {
+	if n := len(chunks); n > 0 && chunks[n-1].Stream == chunk.stream {
+		chunks[n-1].Data += string(chunk.data)
+		return chunks
+	}
+	return append(chunks, OutputChunk{
+		Stream: chunk.stream,
+		Data:   string(chunk.data),
+		Offset: chunk.at.Sub(started),
+	})
+}
+
+// trimChunks drops everything from stdoutEnd onwards on stdout and from
+// stderrEnd onwards on stderr, which is the delimiter line and anything after
+// it
+func trimChunks(chunks []OutputChunk, stdoutEnd, stderrEnd int) []OutputChunk {
+	if chunks == nil {
+		return nil
+	}
+
+	trimmed := make([]OutputChunk, 0, len(chunks))
+	ends := map[string]int{StreamStdout: stdoutEnd, StreamStderr: stderrEnd}
+	seen := map[string]int{}
+	for _, chunk := range chunks {
+		end := ends[chunk.Stream]
+		if seen[chunk.Stream] >= end {
+			continue
+		}
+		if seen[chunk.Stream]+len(chunk.Data) > end {
+			chunk.Data = chunk.Data[:end-seen[chunk.Stream]]
+		}
+		seen[chunk.Stream] += len(chunk.Data)
+		if chunk.Data == "" {
+			continue
 		}
+		trimmed = append(trimmed, chunk)
+	}
+	return trimmed
+}
+
+// filterChunks applies an output filter to every chunk, dropping chunks it
+// empties
+func filterChunks(chunks []OutputChunk, filter OutputFilter) []OutputChunk {
+	filtered := chunks[:0]
+	for _, chunk := range chunks {
+		chunk.Data = string(filter(chunk.Stream, []byte(chunk.Data)))
+		if chunk.Data != "" {
+			filtered = append(filtered, chunk)
+		}
+	}
+	return filtered
+}
+
+// HistoryDisabled reports whether the shell history has been disabled
+func (e *ShellExecutor) HistoryDisabled() bool {
+	return e.historyDisabled.Load()
+}
+
+// DisableHistory stops the shell from recording further commands in its
+// history file. History cannot be re-enabled on a live shell.
+func (e *ShellExecutor) DisableHistory() error {
+	if _, err := e.ExecuteWithOptions(disableHistoryCommand, ExecuteOptions{inShell: true}); err != nil {
+		return fmt.Errorf("failed to disable shell history: %w", err)
 	}
+	e.historyDisabled.Store(true)
+	return nil
+}
 
-	return output.String(), nil
+// ShellPID returns the process ID of the remote shell, 0 if unknown
+func (e *ShellExecutor) ShellPID() int {
+	return e.shellPID
 }
 
-// drainOutput drains any pending output from stdout and stderr
-func (e *ShellExecutor) drainOutput() {
-	// Non-blocking drain
-	for e.stdout.Buffered() > 0 {
-		_, _ = e.stdout.ReadString('\n')
+// Running returns the command currently executing, nil when the shell is idle
+func (e *ShellExecutor) Running() *RunningCommand {
+	return e.running.Load()
+}
+
+// Options returns the options the executor was created with
+func (e *ShellExecutor) Options() ShellOptions {
+	return e.options
+}
+
+// ExecuteToFile runs a command with its stdout redirected to a remote file
+// and returns the result together with the size of the written file. The
+// command is interrupted when ctx is cancelled.
+func (e *ShellExecutor) ExecuteToFile(ctx context.Context, command, remotePath string) (*CommandResult, int64, error) {
+	if err := validateRemotePath(remotePath); err != nil {
+		return nil, 0, err
 	}
-	for e.stderr.Buffered() > 0 {
-		_, _ = e.stderr.ReadString('\n')
+
+	quotedPath := shellQuote(remotePath)
+
+	// The braces keep the command in the current shell so state still persists
+	result, err := e.ExecuteWithContext(ctx, fmt.Sprintf("{\n%s\n} > %s", command, quotedPath))
+	if err != nil {
+		return nil, 0, err
 	}
+
+	size, err := e.fileSize(quotedPath)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return result, size, nil
+}
+
+// ExecuteTee runs a command whose stdout is written in full to a remote file
+// via tee while only the first previewBytes are returned in the result. The
+// returned size is that of the remote file. Since the command runs as part of
+// a pipeline, changes it makes to the shell state do not persist. The command
+// is interrupted when ctx is cancelled.
+func (e *ShellExecutor) ExecuteTee(ctx context.Context, command, remotePath string, previewBytes int) (*CommandResult, int64, error) {
+	if err := validateRemotePath(remotePath); err != nil {
+		return nil, 0, err
+	}
+	if previewBytes <= 0 || previewBytes > MaxOutputSize {
+		return nil, 0, fmt.Errorf("preview size must be between 1 and %d bytes, got %d", MaxOutputSize, previewBytes)
+	}
+
+	quotedPath := shellQuote(remotePath)
+
+	// The command's exit status is passed out of the pipeline on fd 3 while the
+	// preview is written to the original stdout kept on fd 4. The rest of the
+	// output is drained after head so tee never sees a broken pipe. The command
+	// runs in a subshell so an exit in it still reaches the status echo.
+	wrapped := fmt.Sprintf(
+		"{ __mcp_tee_rc=$( { { (\n%s\n) 3>&-; echo $? >&3; } | tee %s | { head -c %d; cat >/dev/null; } >&4; } 3>&1 ); } 4>&1; (exit \"$__mcp_tee_rc\")",
+		command,
+		quotedPath,
+		previewBytes,
+	)
+
+	result, err := e.ExecuteWithContext(ctx, wrapped)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	size, err := e.fileSize(quotedPath)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return result, size, nil
+}
+
+// fileSize returns the size of a remote file given its shell-quoted path
+func (e *ShellExecutor) fileSize(quotedPath string) (int64, error) {
+	// GNU stat first, BSD stat as a fallback
+	result, err := e.ExecuteWithOptions(fmt.Sprintf("stat -c %%s %s 2>/dev/null || stat -f %%z %s", quotedPath, quotedPath), ExecuteOptions{inShell: true})
+	if err != nil {
+		return 0, fmt.Errorf("failed to stat output file: %w", err)
+	}
+	if result.ExitCode != 0 {
+		return 0, fmt.Errorf("failed to stat output file: %s", result.Stderr)
+	}
+
+	size, err := strconv.ParseInt(result.Stdout, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse output file size %q: %w", result.Stdout, err)
+	}
+	return size, nil
+}
+
+// validateRemotePath checks that a remote path can be safely passed to the shell
+func validateRemotePath(path string) error {
+	if strings.TrimSpace(path) == "" {
+		return fmt.Errorf("remote path cannot be empty")
+	}
+	if strings.ContainsAny(path, "\x00\n\r") {
+		return fmt.Errorf("remote path contains invalid characters")
+	}
+	if strings.Contains(path, "__MCP_SSH_END_") {
+		return fmt.Errorf("remote path contains forbidden delimiter pattern '__MCP_SSH_END_'")
+	}
+	return nil
+}
+
+// shellQuote quotes a string for safe use as a single POSIX shell word
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// timeoutError builds the timeout error for a command started at the given
+// time, noting when the command has been silent long enough to look hung
+func (e *ShellExecutor) timeoutError(started time.Time) error {
+	elapsed := time.Since(started).Round(time.Second)
+	idle := time.Since(time.Unix(0, e.lastOutput.Load())).Round(time.Second)
+	if idle >= e.options.IdleOutputThreshold {
+		return &ConnectionError{Code: CodeTimeout, Err: fmt.Errorf("command execution timed out after %s: no output received for %s, command may be waiting for input or hung", elapsed, idle)}
+	}
+	return &ConnectionError{Code: CodeTimeout, Err: fmt.Errorf("command execution timed out after %s", elapsed)}
+}
+
+// pump forwards everything read from a stream to the output channel until
+// the stream fails or the executor is closed
+func (e *ShellExecutor) pump(stream string, reader io.Reader, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	buf := make([]byte, readBufferSize)
+	for {
+		n, err := reader.Read(buf)
+		if n > 0 {
+			now := time.Now()
+			e.lastOutput.Store(now.UnixNano())
+
+			data := make([]byte, n)
+			copy(data, buf[:n])
+
+			select {
+			case e.output <- outputChunk{stream: stream, data: data, at: now}:
+			case <-e.done:
+				return
+			}
+		}
+		if err != nil {
+			e.setReadError(err)
+			return
+		}
+	}
+}
+
+// setReadError records the first error that stopped a stream reader
+func (e *ShellExecutor) setReadError(err error) {
+	e.readErrMu.Lock()
+	defer e.readErrMu.Unlock()
+
+	if e.readErr == nil {
+		e.readErr = err
+	}
+}
+
+// readError returns the error that stopped the stream readers
+func (e *ShellExecutor) readError() error {
+	e.readErrMu.Lock()
+	defer e.readErrMu.Unlock()
+
+	if e.readErr == nil {
+		return io.EOF
+	}
+	return e.readErr
+}
+
+// streamError returns the error that stopped the stream readers, or nil
+// while they are running
+func (e *ShellExecutor) streamError() error {
+	e.readErrMu.Lock()
+	defer e.readErrMu.Unlock()
+
+	return e.readErr
+}
+
+// commandsSent returns the number of commands written to the shell so far
+func (e *ShellExecutor) commandsSent() int64 {
+	return e.sent.Load()
 }
 
 // Close closes the shell executor
@@ -259,6 +1316,10 @@ func (e *ShellExecutor) Close() error {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
+	e.closeOnce.Do(func() {
+		close(e.done)
+	})
+
 	if e.stdin != nil {
 		_ = e.stdin.Close() // Best effort cleanup
 	}
@@ -0,0 +1,1034 @@
+package ssh
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestShellQuote(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "plain",
+			input:    "/tmp/out.log",
+			expected: "'/tmp/out.log'",
+		},
+		{
+			name:     "spaces",
+			input:    "/tmp/my file",
+			expected: "'/tmp/my file'",
+		},
+		{
+			name:     "single quote",
+			input:    "it's",
+			expected: `'it'\''s'`,
+		},
+		{
+			name:     "shell metacharacters",
+			input:    "$(rm -rf /); `id`",
+			expected: "'$(rm -rf /); `id`'",
+		},
+		{
+			name:     "empty",
+			input:    "",
+			expected: "''",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := shellQuote(tt.input); got != tt.expected {
+				t.Errorf("shellQuote(%q) = %q, want %q", tt.input, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestValidateRemotePath(t *testing.T) {
+	tests := []struct {
+		name        string
+		path        string
+		expectError bool
+	}{
+		{
+			name:        "absolute path",
+			path:        "/var/log/build.log",
+			expectError: false,
+		},
+		{
+			name:        "relative path with spaces",
+			path:        "out dir/result.txt",
+			expectError: false,
+		},
+		{
+			name:        "empty",
+			path:        "",
+			expectError: true,
+		},
+		{
+			name:        "newline",
+			path:        "/tmp/a\nrm -rf /",
+			expectError: true,
+		},
+		{
+			name:        "delimiter pattern",
+			path:        "/tmp/__MCP_SSH_END_1__",
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateRemotePath(tt.path)
+			if tt.expectError && err == nil {
+				t.Errorf("expected error but got nil")
+			}
+			if !tt.expectError && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestExecute_StderrAndExitCode(t *testing.T) {
+	server := newTestServer(t)
+	manager := newTestManager(t)
+	connectTestServer(t, manager, server, "default")
+
+	result, err := manager.Execute("default", "echo out; echo err >&2; printf partial; exit_code() { return 3; }; exit_code")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Stdout != "out\npartial" {
+		t.Errorf("expected stdout %q, got %q", "out\npartial", result.Stdout)
+	}
+	if result.Stderr != "err" {
+		t.Errorf("expected stderr %q, got %q", "err", result.Stderr)
+	}
+	if result.ExitCode != 3 {
+		t.Errorf("expected exit code 3, got %d", result.ExitCode)
+	}
+	if result.Chunks != nil {
+		t.Errorf("expected no chunks unless requested, got %+v", result.Chunks)
+	}
+}
+
+func TestExecuteWithOptions_CombineOutput(t *testing.T) {
+	server := newTestServer(t)
+	manager := newTestManager(t)
+	connectTestServer(t, manager, server, "default")
+
+	command := "for i in 1 2 3; do echo out$i; echo err$i >&2; done; cd /tmp; (exit 5)"
+	result, err := manager.ExecuteWithOptions("default", command, ExecuteOptions{CombineOutput: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "out1\nerr1\nout2\nerr2\nout3\nerr3"; result.Stdout != want {
+		t.Errorf("stdout = %q, want %q", result.Stdout, want)
+	}
+	if result.Stderr != "" {
+		t.Errorf("expected empty stderr, got %q", result.Stderr)
+	}
+	if result.ExitCode != 5 {
+		t.Errorf("expected exit code 5, got %d", result.ExitCode)
+	}
+
+	// The command ran in the persistent shell, and later commands keep their
+	// streams apart
+	result, err = manager.Execute("default", "pwd; echo err >&2")
+	if err != nil || result.Stdout != "/tmp" || result.Stderr != "err" {
+		t.Errorf("expected separate streams in /tmp, got %+v, %v", result, err)
+	}
+}
+
+func TestExecuteWithOptions_CaptureChunks(t *testing.T) {
+	server := newTestServer(t)
+	manager := newTestManager(t)
+	connectTestServer(t, manager, server, "default")
+
+	command := "echo one; sleep 0.05; echo two >&2; sleep 0.05; echo three; sleep 0.05; echo four >&2"
+	result, err := manager.ExecuteWithOptions("default", command, ExecuteOptions{CaptureChunks: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []OutputChunk{
+		{Stream: StreamStdout, Data: "one\n"},
+		{Stream: StreamStderr, Data: "two\n"},
+		{Stream: StreamStdout, Data: "three\n"},
+		{Stream: StreamStderr, Data: "four\n"},
+	}
+	if len(result.Chunks) != len(expected) {
+		t.Fatalf("expected %d chunks, got %+v", len(expected), result.Chunks)
+	}
+	for i, chunk := range result.Chunks {
+		if chunk.Stream != expected[i].Stream || chunk.Data != expected[i].Data {
+			t.Errorf("chunk %d: expected %s %q, got %s %q", i, expected[i].Stream, expected[i].Data, chunk.Stream, chunk.Data)
+		}
+		if i > 0 && chunk.Offset < result.Chunks[i-1].Offset {
+			t.Errorf("chunk %d: offset %v is before previous offset %v", i, chunk.Offset, result.Chunks[i-1].Offset)
+		}
+		if strings.Contains(chunk.Data, "__MCP_SSH_END_") {
+			t.Errorf("chunk %d leaks the delimiter: %q", i, chunk.Data)
+		}
+	}
+}
+
+func TestExecute_PTY(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("the test server only provides PTYs on Linux")
+	}
+
+	server := newTestServer(t)
+	manager := newTestManager(t, WithAllowPTY(true))
+	t.Cleanup(manager.CloseAll)
+	connectTestServer(t, manager, server, "plain")
+	params := server.params("pty")
+	params.RequestPTY = true
+	if _, err := manager.Connect(params); err != nil {
+		t.Fatalf("failed to connect with a PTY: %v", err)
+	}
+
+	result, err := manager.Execute("pty", "tty")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.ExitCode != 0 || !strings.HasPrefix(result.Stdout, "/dev/") {
+		t.Errorf("expected a terminal device, got %q (exit code %d)", result.Stdout, result.ExitCode)
+	}
+
+	// Stderr goes to the terminal, and lines end in a bare newline
+	result, err = manager.Execute("pty", "echo out; echo err >&2; echo more")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Stdout != "out\nerr\nmore" || result.Stderr != "" {
+		t.Errorf("expected merged output, got stdout %q and stderr %q", result.Stdout, result.Stderr)
+	}
+
+	result, err = manager.Execute("plain", "tty")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.ExitCode == 0 || result.Stdout != "not a tty" {
+		t.Errorf("expected no terminal without a PTY, got %q (exit code %d)", result.Stdout, result.ExitCode)
+	}
+}
+
+func TestConnect_PTYDisabled(t *testing.T) {
+	server := newTestServer(t)
+	manager := newTestManager(t)
+
+	params := server.params("pty")
+	params.RequestPTY = true
+	_, err := manager.Connect(params)
+	if err == nil || !strings.Contains(err.Error(), "PTY mode is disabled") {
+		t.Fatalf("expected PTY mode to be refused, got %v", err)
+	}
+}
+
+func TestExecute_LateStderr(t *testing.T) {
+	server := newTestServer(t)
+	manager := newTestManager(t)
+	connectTestServer(t, manager, server, "default")
+
+	result, err := manager.Execute("default", "echo out; sleep 0.5; echo late error >&2; echo more >&2")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Stdout != "out" {
+		t.Errorf("expected stdout %q, got %q", "out", result.Stdout)
+	}
+	if result.Stderr != "late error\nmore" {
+		t.Errorf("expected stderr %q, got %q", "late error\nmore", result.Stderr)
+	}
+
+	// Nothing of it is left for the next command
+	result, err = manager.Execute("default", "echo next >&2")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Stderr != "next" {
+		t.Errorf("expected stderr %q, got %q", "next", result.Stderr)
+	}
+}
+
+func TestTrimChunks(t *testing.T) {
+	chunks := []OutputChunk{
+		{Stream: StreamStdout, Data: "abc"},
+		{Stream: StreamStderr, Data: "err"},
+		{Stream: StreamStdout, Data: "de__MCP_SSH_END_1__:0\n"},
+		{Stream: StreamStderr, Data: "or__MCP_SSH_END_1__:\n"},
+		{Stream: StreamStdout, Data: "late"},
+	}
+
+	trimmed := trimChunks(chunks, 5, 5)
+	expected := []OutputChunk{
+		{Stream: StreamStdout, Data: "abc"},
+		{Stream: StreamStderr, Data: "err"},
+		{Stream: StreamStdout, Data: "de"},
+		{Stream: StreamStderr, Data: "or"},
+	}
+	if len(trimmed) != len(expected) {
+		t.Fatalf("expected %d chunks, got %+v", len(expected), trimmed)
+	}
+	for i := range expected {
+		if trimmed[i] != expected[i] {
+			t.Errorf("chunk %d: expected %+v, got %+v", i, expected[i], trimmed[i])
+		}
+	}
+}
+
+func TestExecute_Timing(t *testing.T) {
+	server := newTestServer(t)
+	manager := newTestManager(t)
+	connectTestServer(t, manager, server, "default")
+
+	first := make(chan *CommandResult, 1)
+	go func() {
+		result, _ := manager.Execute("default", "sleep 0.3")
+		first <- result
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	second, err := manager.Execute("default", "true")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	result := <-first
+	if result == nil {
+		t.Fatalf("first command failed")
+	}
+
+	if result.Timing.Exec < 300*time.Millisecond {
+		t.Errorf("expected exec time of at least 300ms, got %v", result.Timing.Exec)
+	}
+	if second.Timing.LockWait < 100*time.Millisecond {
+		t.Errorf("expected the second command to wait for the first, got %v", second.Timing.LockWait)
+	}
+	if second.Timing.Read <= 0 {
+		t.Errorf("expected a read time, got %v", second.Timing.Read)
+	}
+}
+
+func TestExecute_Timestamps(t *testing.T) {
+	server := newTestServer(t)
+	manager := newTestManager(t)
+	connectTestServer(t, manager, server, "default")
+
+	before := time.Now()
+	result, err := manager.Execute("default", "sleep 0.2")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	after := time.Now()
+
+	if result.StartedAt.Location() != time.UTC || result.FinishedAt.Location() != time.UTC {
+		t.Errorf("expected UTC timestamps, got %v and %v", result.StartedAt, result.FinishedAt)
+	}
+	if result.StartedAt.Before(before) || result.FinishedAt.After(after) {
+		t.Errorf("expected timestamps between %v and %v, got %v and %v", before, after, result.StartedAt, result.FinishedAt)
+	}
+	if elapsed := result.FinishedAt.Sub(result.StartedAt); elapsed < 200*time.Millisecond {
+		t.Errorf("expected at least 200ms between start and finish, got %v", elapsed)
+	}
+}
+
+func TestExecuteWithOptions_HashStdout(t *testing.T) {
+	server := newTestServer(t)
+	manager := newTestManager(t)
+	connectTestServer(t, manager, server, "default")
+
+	tests := []struct {
+		command string
+		raw     string
+	}{
+		{command: "echo hello", raw: "hello\n"},
+		{command: "printf '  padded\\n\\n'", raw: "  padded\n\n"},
+		{command: "printf partial", raw: "partial"},
+		{command: "true", raw: ""},
+	}
+	for _, tt := range tests {
+		result, err := manager.ExecuteWithOptions("default", tt.command, ExecuteOptions{HashStdout: true})
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.command, err)
+		}
+		sum := sha256.Sum256([]byte(tt.raw))
+		if expected := hex.EncodeToString(sum[:]); result.StdoutSHA256 != expected {
+			t.Errorf("%s: expected hash of %q, got %s", tt.command, tt.raw, result.StdoutSHA256)
+		}
+		if result.StdoutBytes != int64(len(tt.raw)) {
+			t.Errorf("%s: expected %d bytes, got %d", tt.command, len(tt.raw), result.StdoutBytes)
+		}
+	}
+
+	result, err := manager.Execute("default", "echo hello")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.StdoutSHA256 != "" || result.StdoutBytes != 0 {
+		t.Errorf("expected no hash unless requested, got %q (%d bytes)", result.StdoutSHA256, result.StdoutBytes)
+	}
+}
+
+func TestSubshellPerCommand(t *testing.T) {
+	for _, shell := range []string{"sh", "bash"} {
+		t.Run(shell, func(t *testing.T) {
+			server := newTestServer(t)
+			server.shell = []string{shell}
+			manager := newTestManager(t)
+			params := server.params("default")
+			params.SubshellPerCommand = true
+			if _, err := manager.Connect(params); err != nil {
+				t.Fatalf("failed to connect: %v", err)
+			}
+			t.Cleanup(manager.CloseAll)
+
+			dir := t.TempDir()
+			steps := []struct {
+				command  string
+				stdout   string
+				exitCode int
+			}{
+				// The working directory and exported variables persist
+				{command: "cd " + shellQuote(dir) + " && export GREETING='hello world' && LOCAL=1", exitCode: 0},
+				{command: "pwd; echo \"$GREETING\"", stdout: dir + "\nhello world"},
+
+				// Shell options, unexported variables and functions do not
+				{command: "set -e; IFS=:; f() { echo f; }; false; echo unreachable", exitCode: 1},
+				{command: "echo \"${LOCAL:-unset}\"; case $- in *e*) echo errexit;; esac; command -v f || echo nofunc; printf '%s\\n' \"$IFS\" | od -c | head -n1 | tr -s ' '", stdout: "unset\nnofunc\n0000000 \\t \\n \\n"},
+
+				// Exit codes are kept, and SHLVL does not grow with each command
+				{command: "(exit 7)", exitCode: 7},
+				{command: "echo \"$SHLVL\" > shlvl; pwd", stdout: dir},
+				{command: "[ \"$SHLVL\" = \"$(cat shlvl)\" ]", exitCode: 0},
+			}
+			for _, step := range steps {
+				result, err := manager.Execute("default", step.command)
+				if err != nil {
+					t.Fatalf("%q: unexpected error: %v", step.command, err)
+				}
+				if result.ExitCode != step.exitCode {
+					t.Errorf("%q: exit code = %d, want %d (stderr %q)", step.command, result.ExitCode, step.exitCode, result.Stderr)
+				}
+				if step.stdout != "" && result.Stdout != strings.TrimSpace(step.stdout) {
+					t.Errorf("%q: stdout = %q, want %q", step.command, result.Stdout, step.stdout)
+				}
+			}
+		})
+	}
+}
+
+func TestExecuteWithTimeout(t *testing.T) {
+	server := newTestServer(t)
+	manager := newTestManager(t)
+	connectTestServer(t, manager, server, "default")
+	executor := manager.connections["default"].executor
+
+	result, err := executor.ExecuteWithTimeout("sleep 1; echo done", 5*time.Second)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Stdout != "done" {
+		t.Errorf("expected stdout %q, got %q", "done", result.Stdout)
+	}
+
+	goroutines := runtime.NumGoroutine()
+	for i := 0; i < 3; i++ {
+		started := time.Now()
+		_, err := executor.ExecuteWithTimeout("sleep 1", 200*time.Millisecond)
+		if err == nil || !strings.Contains(err.Error(), "timed out") {
+			t.Fatalf("expected a timeout error, got %v", err)
+		}
+		if elapsed := time.Since(started); elapsed > 2*time.Second {
+			t.Errorf("expected the timeout to apply, took %s", elapsed)
+		}
+	}
+	if after := runtime.NumGoroutine(); after > goroutines {
+		t.Errorf("expected no goroutines left behind by timeouts, had %d, now %d", goroutines, after)
+	}
+}
+
+func TestExecuteWithContext(t *testing.T) {
+	server := newTestServer(t)
+	manager := newTestManager(t)
+	connectTestServer(t, manager, server, "default")
+	executor := manager.connections["default"].executor
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(300*time.Millisecond, cancel)
+	started := time.Now()
+	_, err := executor.ExecuteWithContext(ctx, "sleep 30; echo done")
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected a cancellation error, got %v", err)
+	}
+	if elapsed := time.Since(started); elapsed > 5*time.Second {
+		t.Errorf("expected the command to be cancelled promptly, took %s", elapsed)
+	}
+
+	// The shell is left ready for the next command
+	result, err := executor.ExecuteWithContext(context.Background(), "echo next")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Stdout != "next" {
+		t.Errorf("expected stdout %q, got %q", "next", result.Stdout)
+	}
+
+	// A context cancelled before the command is sent does not run it
+	_, err = executor.ExecuteWithContext(ctx, "echo never")
+	if !errors.Is(err, ErrCancelled) || !errors.Is(err, context.Canceled) {
+		t.Errorf("expected ErrCancelled, got %v", err)
+	}
+}
+
+func TestExecuteTee(t *testing.T) {
+	server := newTestServer(t)
+	manager := newTestManager(t)
+	connectTestServer(t, manager, server, "default")
+	executor := manager.connections["default"].executor
+	out := filepath.Join(t.TempDir(), "tee.log")
+
+	// The exit code is the command's, not the pipeline's
+	result, size, err := executor.ExecuteTee(context.Background(), "sh -c 'echo x; exit 3'", out, 100)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.ExitCode != 3 {
+		t.Errorf("expected exit code 3, got %d (stderr %q)", result.ExitCode, result.Stderr)
+	}
+	if result.Stdout != "x" || size != 2 {
+		t.Errorf("expected stdout %q and size 2, got %q and %d", "x", result.Stdout, size)
+	}
+
+	// Only the preview is returned while the file gets the full output
+	result, size, err = executor.ExecuteTee(context.Background(), "seq 1 1000", out, 20)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.ExitCode != 0 {
+		t.Errorf("expected exit code 0, got %d (stderr %q)", result.ExitCode, result.Stderr)
+	}
+	var full strings.Builder
+	for i := 1; i <= 1000; i++ {
+		fmt.Fprintf(&full, "%d\n", i)
+	}
+	if expected := strings.TrimSpace(full.String()[:20]); result.Stdout != expected {
+		t.Errorf("expected preview %q, got %q", expected, result.Stdout)
+	}
+	if size != int64(full.Len()) {
+		t.Errorf("expected size %d, got %d", full.Len(), size)
+	}
+	written, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("failed to read output: %v", err)
+	}
+	if string(written) != full.String() {
+		t.Errorf("expected the file to hold the full output, got %d bytes", len(written))
+	}
+
+	// An exit in the command itself still reports its code
+	allowing := newTestManager(t, WithAllowSessionCommands(true))
+	connectTestServer(t, allowing, server, "allowing")
+	result, _, err = allowing.ExecuteTee(context.Background(), "allowing", "echo x; exit 4", out, 100)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.ExitCode != 4 || result.Stdout != "x" {
+		t.Errorf("expected exit code 4 and stdout %q, got %d and %q", "x", result.ExitCode, result.Stdout)
+	}
+}
+
+func TestExecuteWithInput(t *testing.T) {
+	server := newTestServer(t)
+	manager := newTestManager(t)
+	connectTestServer(t, manager, server, "default")
+	executor := manager.connections["default"].executor
+
+	// The input arrives byte for byte, and the command runs in the
+	// persistent shell
+	input := "first line\nit's \"quoted\" $HOME `x`\nno newline"
+	out := t.TempDir() + "/out"
+	result, err := executor.ExecuteWithInput("cat > "+shellQuote(out)+"; cd /tmp", input)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.ExitCode != 0 {
+		t.Fatalf("expected exit code 0, got %d (stderr %q)", result.ExitCode, result.Stderr)
+	}
+	written, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("failed to read output: %v", err)
+	}
+	if string(written) != input {
+		t.Errorf("expected %q, got %q", input, written)
+	}
+	result, err = executor.Execute("pwd")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Stdout != "/tmp" {
+		t.Errorf("expected the working directory to carry over, got %q", result.Stdout)
+	}
+
+	// Empty input ends right away, and the delimiter follows the command
+	result, err = executor.ExecuteWithInput("cat; echo done", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Stdout != "done" {
+		t.Errorf("expected %q, got %q", "done", result.Stdout)
+	}
+
+	if _, err := executor.ExecuteWithInput("cat", "__MCP_SSH_END_1__:0"); err == nil {
+		t.Error("expected stdin holding the delimiter pattern to be rejected")
+	}
+}
+
+func TestExecuteWithOptions_Cwd(t *testing.T) {
+	server := newTestServer(t)
+	manager := newTestManager(t)
+	connectTestServer(t, manager, server, "default")
+
+	dir := t.TempDir() + "/it's here"
+	if err := os.Mkdir(dir, 0o700); err != nil {
+		t.Fatalf("failed to create directory: %v", err)
+	}
+	if _, err := manager.Execute("default", "cd /tmp"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Every line of the command runs in the directory, and exit only ends
+	// the subshell
+	result, err := manager.ExecuteWithOptions("default", "pwd\ncd /; exit 4", ExecuteOptions{Cwd: dir})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Stdout != dir || result.ExitCode != 4 {
+		t.Errorf("expected %q with exit code 4, got %q with %d", dir, result.Stdout, result.ExitCode)
+	}
+
+	// The persistent shell's directory is unchanged
+	result, err = manager.Execute("default", "pwd")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Stdout != "/tmp" {
+		t.Errorf("expected the shell to stay in /tmp, got %q", result.Stdout)
+	}
+
+	// A directory that cannot be entered stops the command
+	result, err = manager.ExecuteWithOptions("default", "echo ran", ExecuteOptions{Cwd: dir + "/missing"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.ExitCode == 0 || result.Stdout != "" {
+		t.Errorf("expected the command not to run, got %+v", result)
+	}
+
+	for _, cwd := range []string{"/tmp\necho injected", "/tmp/__MCP_SSH_END_1__"} {
+		if _, err := manager.ExecuteWithOptions("default", "pwd", ExecuteOptions{Cwd: cwd}); err == nil {
+			t.Errorf("expected cwd %q to be rejected", cwd)
+		}
+	}
+}
+
+func TestCwdCommand(t *testing.T) {
+	tests := []struct {
+		dir      string
+		expected string
+	}{
+		{dir: "/var/log", expected: "( cd -- '/var/log' || exit\nls\n)"},
+		{dir: "~", expected: "( cd -- \"$HOME\" || exit\nls\n)"},
+		{dir: "~/src/$(id)", expected: "( cd -- \"$HOME\"/'src/$(id)' || exit\nls\n)"},
+		{dir: "~other", expected: "( cd -- '~other' || exit\nls\n)"},
+	}
+
+	for _, tt := range tests {
+		if got := cwdCommand("ls", tt.dir); got != tt.expected {
+			t.Errorf("cwdCommand(%q) = %q, want %q", tt.dir, got, tt.expected)
+		}
+	}
+}
+
+func TestExecute_TimeoutResyncsShell(t *testing.T) {
+	server := newTestServer(t)
+	manager := newTestManager(t)
+	connectTestServer(t, manager, server, "default")
+
+	started := time.Now()
+	_, err := manager.ExecuteWithOptions("default", "echo before; sleep 60; echo after", ExecuteOptions{Timeout: time.Second})
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Fatalf("expected a timeout error, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "interrupted") {
+		t.Errorf("expected the command to be interrupted, got %v", err)
+	}
+	if elapsed := time.Since(started); elapsed > 5*time.Second {
+		t.Errorf("expected the command to be stopped soon after the timeout, took %s", elapsed)
+	}
+
+	result, err := manager.Execute("default", "echo hello")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Stdout != "hello" || result.Stderr != "" || result.ExitCode != 0 {
+		t.Errorf("expected only the output of the following command, got %+v", result)
+	}
+}
+
+func TestExecute_TimeoutIdleDiagnostic(t *testing.T) {
+	server := newTestServer(t)
+	manager := newTestManager(t, WithIdleOutputThreshold(500*time.Millisecond))
+	connectTestServer(t, manager, server, "default")
+
+	// A command blocked on input is reported as possibly waiting for it
+	_, err := manager.ExecuteWithOptions("default", "read x", ExecuteOptions{Timeout: time.Second})
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Fatalf("expected a timeout error, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "no output received for") || !strings.Contains(err.Error(), "may be waiting for input or hung") {
+		t.Errorf("expected the idle output diagnostic, got %v", err)
+	}
+
+	// A command still producing output is not
+	connectTestServer(t, manager, server, "chatty")
+	_, err = manager.ExecuteWithOptions("chatty", "for i in 1 2 3 4 5 6 7 8 9 10 11 12 13 14 15; do echo tick; sleep 0.1; done", ExecuteOptions{Timeout: time.Second})
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Fatalf("expected a timeout error, got %v", err)
+	}
+	if strings.Contains(err.Error(), "waiting for input") {
+		t.Errorf("expected no idle output diagnostic, got %v", err)
+	}
+}
+
+func TestExecute_OutputLimit(t *testing.T) {
+	server := newTestServer(t)
+	manager := newTestManager(t)
+	connectTestServer(t, manager, server, "default")
+
+	var before runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+
+	// yes never ends on its own, so it must be stopped at the limit rather
+	// than at the command timeout
+	started := time.Now()
+	result, err := manager.ExecuteWithOptions("default", "yes 0123456789abcdef", ExecuteOptions{HashStdout: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if elapsed := time.Since(started); elapsed > 10*time.Second {
+		t.Errorf("expected the command to be stopped at the limit, took %s", elapsed)
+	}
+	if !result.Truncated {
+		t.Error("expected the output to be truncated")
+	}
+	if len(result.Stdout) > MaxOutputSize {
+		t.Errorf("expected at most %d bytes of stdout, got %d", MaxOutputSize, len(result.Stdout))
+	}
+	if !strings.HasPrefix(result.Stdout, "0123456789abcdef\n0123456789abcdef\n") {
+		t.Errorf("expected the start of the output to be kept, got %q", result.Stdout[:min(len(result.Stdout), 64)])
+	}
+	if result.StdoutBytes <= MaxOutputSize {
+		t.Errorf("expected stdout_bytes to count the full output, got %d", result.StdoutBytes)
+	}
+
+	var after runtime.MemStats
+	runtime.ReadMemStats(&after)
+	if grown := int64(after.HeapAlloc) - int64(before.HeapAlloc); grown > 8*MaxOutputSize {
+		t.Errorf("expected memory use bounded by the output limit, heap grew by %d bytes", grown)
+	}
+
+	result, err = manager.Execute("default", "echo hello")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Stdout != "hello" || result.Truncated {
+		t.Errorf("expected only the output of the following command, got %q (truncated: %v)", result.Stdout, result.Truncated)
+	}
+}
+
+func TestExecuteWithOptions_CaptureChunksNearLimit(t *testing.T) {
+	server := newTestServer(t)
+	manager := newTestManager(t)
+	connectTestServer(t, manager, server, "default")
+
+	// The delimiter line straddles the limit, past which nothing is captured,
+	// yet must still end the command
+	size := MaxOutputSize - 5
+	command := fmt.Sprintf("head -c %d /dev/zero | tr '\\0' a", size)
+	opts := ExecuteOptions{CaptureChunks: true, HashStdout: true, Timeout: 15 * time.Second}
+	result, err := manager.ExecuteWithOptions("default", command, opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.ExitCode != 0 || result.Truncated {
+		t.Errorf("expected exit code 0 without truncation, got %d (truncated: %v)", result.ExitCode, result.Truncated)
+	}
+	captured := 0
+	for _, chunk := range result.Chunks {
+		if strings.Contains(chunk.Data, "__MCP_SSH_END_") {
+			t.Fatal("expected the delimiter not to leak into the chunks")
+		}
+		captured += len(chunk.Data)
+	}
+	if captured != size {
+		t.Errorf("expected %d captured bytes, got %d", size, captured)
+	}
+	sum := sha256.Sum256([]byte(strings.Repeat("a", size)))
+	if result.StdoutSHA256 != hex.EncodeToString(sum[:]) || result.StdoutBytes != int64(size) {
+		t.Errorf("expected the hash of the full output, got %s over %d bytes", result.StdoutSHA256, result.StdoutBytes)
+	}
+
+	result, err = manager.Execute("default", "echo hello")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Stdout != "hello" {
+		t.Errorf("expected the shell to stay usable, got %q", result.Stdout)
+	}
+}
+
+// frozenProxy forwards TCP connections to a target until frozen, after
+// which it silently drops everything, like a host that vanished from the
+// network
+type frozenProxy struct {
+	listener net.Listener
+	frozen   atomic.Bool
+}
+
+// newFrozenProxy starts a proxy to target, closed when the test ends
+func newFrozenProxy(t *testing.T, target string) *frozenProxy {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	p := &frozenProxy{listener: listener}
+	var conns []net.Conn
+	var mu sync.Mutex
+	t.Cleanup(func() {
+		_ = listener.Close()
+		mu.Lock()
+		defer mu.Unlock()
+		for _, conn := range conns {
+			_ = conn.Close()
+		}
+	})
+
+	go func() {
+		for {
+			client, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			server, err := net.Dial("tcp", target)
+			if err != nil {
+				_ = client.Close()
+				continue
+			}
+			mu.Lock()
+			conns = append(conns, client, server)
+			mu.Unlock()
+			go p.forward(server, client)
+			go p.forward(client, server)
+		}
+	}()
+	return p
+}
+
+// forward copies src to dst, discarding the data once frozen
+func (p *frozenProxy) forward(dst, src net.Conn) {
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := src.Read(buf)
+		if n > 0 && !p.frozen.Load() {
+			if _, err := dst.Write(buf[:n]); err != nil {
+				return
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func TestExecute_DeadConnection(t *testing.T) {
+	server := newTestServer(t)
+	proxy := newFrozenProxy(t, server.listener.Addr().String())
+	manager := newTestManager(t)
+
+	params := server.params("default")
+	params.Port = proxy.listener.Addr().(*net.TCPAddr).Port
+	if _, err := manager.Connect(params); err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	t.Cleanup(func() { _ = manager.Close("default") })
+	executor := manager.connections["default"].executor
+	executor.options.HealthCheckTimeout = 300 * time.Millisecond
+
+	if _, err := manager.Execute("default", "true"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Once the connection has been quiet, the next command finds out it is
+	// dead instead of waiting for its timeout
+	proxy.frozen.Store(true)
+	time.Sleep(healthCheckIdle)
+	started := time.Now()
+	_, err := manager.Execute("default", "echo hello")
+	if !errors.Is(err, ErrConnectionDead) {
+		t.Fatalf("expected ErrConnectionDead, got %v", err)
+	}
+	var lost *ConnectionLostError
+	if !errors.As(err, &lost) || lost.Sent {
+		t.Errorf("expected the command not to have been sent, got %v", err)
+	}
+	if elapsed := time.Since(started); elapsed > 2*time.Second {
+		t.Errorf("expected the dead connection to be reported quickly, took %v", elapsed)
+	}
+
+	// The connection was closed, so later commands fail right away
+	started = time.Now()
+	if _, err := manager.Execute("default", "echo hello"); !errors.Is(err, ErrConnectionDead) {
+		t.Errorf("expected ErrConnectionDead, got %v", err)
+	}
+	if elapsed := time.Since(started); elapsed > 200*time.Millisecond {
+		t.Errorf("expected the closed connection to fail right away, took %v", elapsed)
+	}
+}
+
+func TestExecute_ClosedConnection(t *testing.T) {
+	server := newTestServer(t)
+	manager := newTestManager(t)
+	connectTestServer(t, manager, server, "default")
+
+	// Closed mid-command, the shell's streams end instead of the command
+	done := make(chan error, 1)
+	go func() {
+		_, err := manager.Execute("default", "sleep 3")
+		done <- err
+	}()
+	waitRunning(t, manager, "default", "")
+	server.DropConnections()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, ErrConnectionDead) {
+			t.Errorf("expected ErrConnectionDead, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the command to fail once the connection closed")
+	}
+}
+
+func TestConnect_Shell(t *testing.T) {
+	// A login shell that understands nothing but exec, failing on the
+	// commands that set up a POSIX shell
+	server := newTestServer(t)
+	server.shell = []string{"sh", "-c", `while read -r line; do case $line in "exec "*) eval "$line";; *) echo "unknown command: $line" >&2; exit 1;; esac; done`}
+	manager := newTestManager(t)
+	t.Cleanup(manager.CloseAll)
+
+	// Without a shell, the login shell quits on the first init command
+	if _, err := manager.Connect(server.params("login")); err == nil || !strings.Contains(err.Error(), "shell initialization failed") {
+		t.Errorf("expected the login shell to fail, got %v", err)
+	}
+
+	params := server.params("default")
+	params.Shell = "sh"
+	if _, err := manager.Connect(params); err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	result, err := manager.Execute("default", "echo ok; (exit 3)")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Stdout != "ok" || result.ExitCode != 3 {
+		t.Errorf("expected the command to run in sh, got %+v", result)
+	}
+
+	params = server.params("invalid")
+	params.Shell = "sh; rm -rf /"
+	if _, err := manager.Connect(params); err == nil || !strings.Contains(err.Error(), "invalid shell") {
+		t.Errorf("expected an invalid shell error, got %v", err)
+	}
+}
+
+func TestConnect_SkipShellInit(t *testing.T) {
+	input := filepath.Join(t.TempDir(), "input")
+	server := newTestServer(t)
+	server.shell = []string{"sh", "-c", "tee " + shellQuote(input) + " | sh"}
+	manager := newTestManager(t)
+	t.Cleanup(manager.CloseAll)
+
+	params := server.params("default")
+	params.SkipShellInit = true
+	if _, err := manager.Connect(params); err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	result, err := manager.Execute("default", "echo ok")
+	if err != nil || result.Stdout != "ok" {
+		t.Fatalf("expected the command to run, got %+v, %v", result, err)
+	}
+
+	sent, err := os.ReadFile(input)
+	if err != nil {
+		t.Fatalf("failed to read the shell's input: %v", err)
+	}
+	if strings.Contains(string(sent), "stty") || strings.Contains(string(sent), "PS1") {
+		t.Errorf("expected no shell init commands, got %q", sent)
+	}
+}
+
+func TestConnect_ShellInitFailure(t *testing.T) {
+	// A shell whose input is echoed back before it runs, as if stty -echo
+	// had no effect
+	server := newTestServer(t)
+	server.shell = []string{"sh", "-c", "exec sh -v 2>&1"}
+	manager := newTestManager(t)
+	t.Cleanup(manager.CloseAll)
+
+	_, err := manager.Connect(server.params("default"))
+	if err == nil || !strings.Contains(err.Error(), "shell initialization failed") || !strings.Contains(err.Error(), shellReadyToken) {
+		t.Fatalf("expected a shell initialization error, got %v", err)
+	}
+	if _, err := manager.Info("default"); err == nil {
+		t.Error("expected the connection not to be kept")
+	}
+}
+
+func TestConnect_HighLatency(t *testing.T) {
+	// A slow link and a login banner: the init commands are only answered
+	// well after any fixed delay, and the banner must not leak into output
+	server := newTestServer(t)
+	server.latency = 300 * time.Millisecond
+	server.shell = []string{"sh", "-c", "echo 'Welcome banner'; echo 'motd' >&2; exec sh"}
+	manager := newTestManager(t)
+	connectTestServer(t, manager, server, "default")
+
+	result, err := manager.Execute("default", "echo ok")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Stdout != "ok" || result.Stderr != "" {
+		t.Errorf("expected only the command's output, got %+v", result)
+	}
+}
@@ -0,0 +1,256 @@
+package ssh
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Git operations supported by Manager.Git
+const (
+	GitStatus   = "status"
+	GitPull     = "pull"
+	GitCheckout = "checkout"
+	GitLog      = "log"
+)
+
+// DefaultGitLogCount is the number of commits returned by GitLog unless the
+// arguments limit it themselves
+const DefaultGitLogCount = 20
+
+// gitEnv keeps git from prompting for credentials or opening an editor,
+// either of which would hang the persistent shell
+const gitEnv = "GIT_TERMINAL_PROMPT=0 GIT_MERGE_AUTOEDIT=no GIT_EDITOR=true"
+
+// gitLogFormat separates the fields of a commit with the unit separator
+const gitLogFormat = "--format=%H%x1f%an%x1f%ae%x1f%aI%x1f%s"
+
+// GitFileChange is a changed path in a working tree. Index and Worktree are
+// git's status codes for the staged and unstaged side, e.g. "M" (modified),
+// "A" (added), "D" (deleted), "R" (renamed), "U" (unmerged), "?" (untracked)
+// or "." (unchanged).
+type GitFileChange struct {
+	Path     string
+	OrigPath string
+	Index    string
+	Worktree string
+}
+
+// GitStatusInfo is the parsed state of a repository's working tree
+type GitStatusInfo struct {
+	Branch   string
+	Commit   string
+	Detached bool
+
+	// Upstream is empty when the branch does not track one; Ahead and Behind
+	// are only meaningful when it does
+	Upstream string
+	Ahead    int
+	Behind   int
+
+	Files []GitFileChange
+}
+
+// Dirty reports whether the working tree has changes, including untracked files
+func (s *GitStatusInfo) Dirty() bool {
+	return len(s.Files) > 0
+}
+
+// GitCommit is a commit listed by GitLog
+type GitCommit struct {
+	Hash        string
+	Author      string
+	AuthorEmail string
+	Date        string
+	Subject     string
+}
+
+// GitResult is the outcome of a git operation. Status is the working tree
+// state after the operation; it is nil for GitLog and when it could not be
+// read. Commits is only set for GitLog.
+type GitResult struct {
+	*CommandResult
+
+	Status  *GitStatusInfo
+	Commits []GitCommit
+}
+
+// Git runs a git operation in the repository at repoPath, using "git -C" so
+// that the shell's working directory is left alone. Extra args are passed to
+// git after the operation's own flags.
+func (m *Manager) Git(id, repoPath, operation string, args []string) (*GitResult, error) {
+	if err := validateRemotePath(repoPath); err != nil {
+		return nil, err
+	}
+
+	git := fmt.Sprintf("%s git -C %s", gitEnv, shellQuote(repoPath))
+
+	var command string
+	switch operation {
+	case GitStatus:
+		command = git + " status --porcelain=v2 --branch"
+	case GitPull:
+		command = git + " pull --no-edit"
+	case GitCheckout:
+		if len(args) == 0 {
+			return nil, fmt.Errorf("checkout requires a branch, commit or path argument")
+		}
+		command = git + " checkout"
+	case GitLog:
+		command = fmt.Sprintf("%s log %s", git, gitLogFormat)
+		if !hasGitLimit(args) {
+			command += fmt.Sprintf(" -n %d", DefaultGitLogCount)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported git operation %q (supported: status, pull, checkout, log)", operation)
+	}
+	for _, arg := range args {
+		command += " " + shellQuote(arg)
+	}
+
+	result, err := m.Execute(id, command)
+	if err != nil {
+		return nil, err
+	}
+
+	gitResult := &GitResult{CommandResult: result}
+	switch operation {
+	case GitStatus:
+		if result.ExitCode == 0 {
+			gitResult.Status = parseGitStatus(result.Stdout)
+		}
+	case GitLog:
+		if result.ExitCode == 0 {
+			gitResult.Commits = parseGitLog(result.Stdout)
+		}
+	default:
+		// Report where pull and checkout left the working tree, even if they
+		// failed part way
+		status, err := m.Execute(id, git+" status --porcelain=v2 --branch")
+		if err == nil && status.ExitCode == 0 {
+			gitResult.Status = parseGitStatus(status.Stdout)
+		}
+	}
+	return gitResult, nil
+}
+
+// hasGitLimit reports whether git log args already limit the commit count
+func hasGitLimit(args []string) bool {
+	for _, arg := range args {
+		if arg == "-n" || arg == "--max-count" || strings.HasPrefix(arg, "--max-count=") ||
+			strings.HasPrefix(arg, "-n") && len(arg) > 2 ||
+			len(arg) > 1 && arg[0] == '-' && isDigits(arg[1:]) {
+			return true
+		}
+	}
+	return false
+}
+
+// isDigits reports whether s is a non-empty string of ASCII digits
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// gitStatusFields is the number of space separated fields between the entry
+// type and the path of ordinary ("1"), renamed or copied ("2") and unmerged
+// ("u") entries in porcelain v2 status output
+var gitStatusFields = map[string]int{"1": 7, "2": 8, "u": 9}
+
+// parseGitStatus parses the output of "git status --porcelain=v2 --branch":
+//
+//	# branch.oid 4f2a...
+//	# branch.head main
+//	# branch.upstream origin/main
+//	# branch.ab +1 -0
+//	1 .M N... 100644 100644 100644 4f2a... 4f2a... README.md
+//	2 R. N... 100644 100644 100644 4f2a... 4f2a... R100 new.go	old.go
+//	? notes.txt
+func parseGitStatus(output string) *GitStatusInfo {
+	status := &GitStatusInfo{}
+	for _, line := range strings.Split(output, "\n") {
+		if header, ok := strings.CutPrefix(line, "# "); ok {
+			key, value, _ := strings.Cut(header, " ")
+			switch key {
+			case "branch.oid":
+				if value != "(initial)" {
+					status.Commit = value
+				}
+			case "branch.head":
+				if value == "(detached)" {
+					status.Detached = true
+				} else {
+					status.Branch = value
+				}
+			case "branch.upstream":
+				status.Upstream = value
+			case "branch.ab":
+				_, _ = fmt.Sscanf(value, "+%d -%d", &status.Ahead, &status.Behind)
+			}
+			continue
+		}
+
+		kind, rest, _ := strings.Cut(line, " ")
+		switch kind {
+		case "1", "2", "u":
+			fields := gitStatusFields[kind]
+			parts := strings.SplitN(rest, " ", fields+1)
+			if len(parts) != fields+1 || len(parts[0]) != 2 {
+				continue
+			}
+			change := GitFileChange{Index: parts[0][:1], Worktree: parts[0][1:]}
+			change.Path = parts[fields]
+			if kind == "2" {
+				change.Path, change.OrigPath, _ = strings.Cut(change.Path, "\t")
+				change.OrigPath = unquoteGitPath(change.OrigPath)
+			}
+			change.Path = unquoteGitPath(change.Path)
+			status.Files = append(status.Files, change)
+		case "?":
+			status.Files = append(status.Files, GitFileChange{
+				Path:     unquoteGitPath(rest),
+				Index:    "?",
+				Worktree: "?",
+			})
+		}
+	}
+	return status
+}
+
+// unquoteGitPath decodes a path git quoted because it contains special
+// characters, e.g. "caf\303\251.txt"
+func unquoteGitPath(path string) string {
+	if len(path) < 2 || path[0] != '"' {
+		return path
+	}
+	if unquoted, err := strconv.Unquote(path); err == nil {
+		return unquoted
+	}
+	return path
+}
+
+// parseGitLog parses git log output produced with gitLogFormat
+func parseGitLog(output string) []GitCommit {
+	var commits []GitCommit
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.SplitN(line, "\x1f", 5)
+		if len(fields) != 5 {
+			continue
+		}
+		commits = append(commits, GitCommit{
+			Hash:        fields[0],
+			Author:      fields[1],
+			AuthorEmail: fields[2],
+			Date:        fields[3],
+			Subject:     fields[4],
+		})
+	}
+	return commits
+}
@@ -0,0 +1,130 @@
+package ssh
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseGitStatus(t *testing.T) {
+	output := "# branch.oid 4f2a9c1d\n" +
+		"# branch.head main\n" +
+		"# branch.upstream origin/main\n" +
+		"# branch.ab +2 -1\n" +
+		"1 .M N... 100644 100644 100644 4f2a9c1d 4f2a9c1d README.md\n" +
+		"1 A. N... 000000 100644 100644 00000000 4f2a9c1d dir/new file.go\n" +
+		"2 R. N... 100644 100644 100644 4f2a9c1d 4f2a9c1d R100 renamed.go\toriginal.go\n" +
+		"u UU N... 100644 100644 100644 100644 4f2a9c1d 4f2a9c1d 4f2a9c1d conflict.txt\n" +
+		"? \"caf\\303\\251.txt\""
+
+	expected := &GitStatusInfo{
+		Branch:   "main",
+		Commit:   "4f2a9c1d",
+		Upstream: "origin/main",
+		Ahead:    2,
+		Behind:   1,
+		Files: []GitFileChange{
+			{Path: "README.md", Index: ".", Worktree: "M"},
+			{Path: "dir/new file.go", Index: "A", Worktree: "."},
+			{Path: "renamed.go", OrigPath: "original.go", Index: "R", Worktree: "."},
+			{Path: "conflict.txt", Index: "U", Worktree: "U"},
+			{Path: "café.txt", Index: "?", Worktree: "?"},
+		},
+	}
+
+	got := parseGitStatus(output)
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("parseGitStatus() = %+v, want %+v", got, expected)
+	}
+	if !got.Dirty() {
+		t.Errorf("expected a dirty working tree")
+	}
+}
+
+func TestParseGitStatus_DetachedClean(t *testing.T) {
+	got := parseGitStatus("# branch.oid 4f2a9c1d\n# branch.head (detached)")
+	if !got.Detached || got.Branch != "" || got.Commit != "4f2a9c1d" {
+		t.Errorf("unexpected status %+v", got)
+	}
+	if got.Dirty() {
+		t.Errorf("expected a clean working tree")
+	}
+}
+
+func TestHasGitLimit(t *testing.T) {
+	tests := []struct {
+		args     []string
+		expected bool
+	}{
+		{nil, false},
+		{[]string{"main"}, false},
+		{[]string{"-n", "5"}, true},
+		{[]string{"-n5"}, true},
+		{[]string{"-5"}, true},
+		{[]string{"--max-count=3"}, true},
+		{[]string{"--", "README.md"}, false},
+	}
+
+	for _, tt := range tests {
+		if got := hasGitLimit(tt.args); got != tt.expected {
+			t.Errorf("hasGitLimit(%q) = %v, want %v", tt.args, got, tt.expected)
+		}
+	}
+}
+
+func TestGit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	repo := t.TempDir()
+	for _, args := range [][]string{
+		{"init", "-q", "-b", "main"},
+		{"-c", "user.name=Test", "-c", "user.email=test@example.com", "commit", "-q", "--allow-empty", "-m", "first"},
+		{"-c", "user.name=Test", "-c", "user.email=test@example.com", "commit", "-q", "--allow-empty", "-m", "second"},
+	} {
+		if out, err := exec.Command("git", append([]string{"-C", repo}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v: %s", args, err, out)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(repo, "notes.txt"), []byte("draft"), 0o600); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	server := newTestServer(t)
+	manager := newTestManager(t)
+	connectTestServer(t, manager, server, "default")
+
+	status, err := manager.Git("default", repo, GitStatus, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if status.Status == nil || status.Status.Branch != "main" || !status.Status.Dirty() {
+		t.Fatalf("unexpected status %+v (stderr %q)", status.Status, status.Stderr)
+	}
+
+	log, err := manager.Git("default", repo, GitLog, []string{"-n", "1"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(log.Commits) != 1 || log.Commits[0].Subject != "second" || log.Commits[0].Author != "Test" {
+		t.Errorf("unexpected commits %+v", log.Commits)
+	}
+
+	checkout, err := manager.Git("default", repo, GitCheckout, []string{"-b", "feature"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if checkout.ExitCode != 0 || checkout.Status == nil || checkout.Status.Branch != "feature" {
+		t.Errorf("expected to be on the new branch, got %+v (stderr %q)", checkout.Status, checkout.Stderr)
+	}
+
+	if _, err := manager.Git("default", repo, "push", nil); err == nil {
+		t.Errorf("expected an error for an unsupported operation")
+	}
+	if _, err := manager.Git("default", repo, GitCheckout, nil); err == nil {
+		t.Errorf("expected an error for checkout without arguments")
+	}
+}
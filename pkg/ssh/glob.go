@@ -0,0 +1,91 @@
+package ssh
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// MaxGlobParallelism bounds how many connections ExecuteGlob runs a command
+// on at once
+const MaxGlobParallelism = 8
+
+// GlobHostResult is the outcome of a command on one connection matched by
+// ExecuteGlob. Error is set, and Result nil, when the command could not be
+// run there.
+type GlobHostResult struct {
+	ConnectionID string
+	Host         string
+	Result       *CommandResult
+	Error        string
+}
+
+// GlobResult holds the outcome of ExecuteGlob, each list ordered by
+// connection ID
+type GlobResult struct {
+	// Results are the connections the command ran on, whatever its exit code
+	Results []GlobHostResult
+
+	// Failed are the matched connections the command could not be run on
+	Failed []GlobHostResult
+}
+
+// ExecuteGlob runs a command concurrently on every open connection whose
+// host matches hostGlob, a path.Match pattern compared case-insensitively
+// (e.g. "web-*.example.com"). At most parallelism connections run it at once;
+// values out of range mean MaxGlobParallelism. It fails if the pattern is
+// malformed or matches no connection, but not when the command fails on some
+// of them.
+func (m *Manager) ExecuteGlob(hostGlob, command string, parallelism int) (*GlobResult, error) {
+	pattern := strings.ToLower(hostGlob)
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("invalid host glob %q: %w", hostGlob, err)
+	}
+	if parallelism <= 0 || parallelism > MaxGlobParallelism {
+		parallelism = MaxGlobParallelism
+	}
+
+	var matched []ConnectionInfo
+	for _, info := range m.List() {
+		if ok, _ := path.Match(pattern, strings.ToLower(info.Host)); ok {
+			matched = append(matched, info)
+		}
+	}
+	if len(matched) == 0 {
+		return nil, fmt.Errorf("no open connection has a host matching %q", hostGlob)
+	}
+	sort.Slice(matched, func(i, j int) bool { return matched[i].ID < matched[j].ID })
+
+	results := make([]GlobHostResult, len(matched))
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, parallelism)
+	for i, info := range matched {
+		wg.Add(1)
+		go func(i int, info ConnectionInfo) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			result := GlobHostResult{ConnectionID: info.ID, Host: info.Host}
+			if commandResult, err := m.Execute(info.ID, command); err != nil {
+				result.Error = err.Error()
+			} else {
+				result.Result = commandResult
+			}
+			results[i] = result
+		}(i, info)
+	}
+	wg.Wait()
+
+	glob := &GlobResult{}
+	for _, result := range results {
+		if result.Result == nil {
+			glob.Failed = append(glob.Failed, result)
+		} else {
+			glob.Results = append(glob.Results, result)
+		}
+	}
+	return glob, nil
+}
@@ -0,0 +1,46 @@
+package ssh
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExecuteGlob(t *testing.T) {
+	server := newTestServer(t)
+	manager := newTestManager(t)
+	connectTestServer(t, manager, server, "a")
+	connectTestServer(t, manager, server, "b")
+
+	// A connection whose budget is used up matches but cannot run the command
+	params := server.params("exhausted")
+	params.TimeBudget = time.Millisecond
+	if _, err := manager.Connect(params); err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	t.Cleanup(manager.CloseAll)
+	_, _ = manager.Execute("exhausted", "sleep 0.01")
+
+	glob, err := manager.ExecuteGlob("127.0.0.*", "echo $((40 + 2)); (exit 1)", 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(glob.Results) != 2 || glob.Results[0].ConnectionID != "a" || glob.Results[1].ConnectionID != "b" {
+		t.Fatalf("expected results for a and b, got %+v (failed %+v)", glob.Results, glob.Failed)
+	}
+	for _, host := range glob.Results {
+		if host.Host != "127.0.0.1" || host.Result.Stdout != "42" || host.Result.ExitCode != 1 {
+			t.Errorf("unexpected result %+v: %+v", host, host.Result)
+		}
+	}
+	if len(glob.Failed) != 1 || glob.Failed[0].ConnectionID != "exhausted" || !strings.Contains(glob.Failed[0].Error, "time budget exhausted") {
+		t.Errorf("expected the exhausted connection to fail, got %+v", glob.Failed)
+	}
+
+	if _, err := manager.ExecuteGlob("10.*", "true", 1); err == nil || !strings.Contains(err.Error(), "no open connection") {
+		t.Errorf("expected an error when nothing matches, got %v", err)
+	}
+	if _, err := manager.ExecuteGlob("127.0.0.[", "true", 1); err == nil || !strings.Contains(err.Error(), "invalid host glob") {
+		t.Errorf("expected an error for a malformed glob, got %v", err)
+	}
+}
@@ -0,0 +1,68 @@
+package ssh
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// HostKeyInfo describes a server's host key
+type HostKeyInfo struct {
+	Type string
+
+	// Fingerprint is the SHA256 fingerprint in OpenSSH format, e.g.
+	// SHA256:uNiVztksCsDhcc0u9e8BujQXVUpKZIDTMczCvj3tD2s
+	Fingerprint string
+}
+
+// errHostKeyCaptured aborts a handshake once the host key has been seen
+var errHostKeyCaptured = errors.New("host key captured")
+
+// FetchHostKey connects to a host just far enough to receive its host key
+// and returns the key's type and fingerprint. No authentication takes place.
+// The host must pass the host validator.
+func (m *Manager) FetchHostKey(host string, port int) (*HostKeyInfo, error) {
+	host = unbracketHost(host)
+	if err := m.validator.Validate(host, port); err != nil {
+		return nil, err
+	}
+
+	var info *HostKeyInfo
+	config := &ssh.ClientConfig{
+		User: "mcp-ssh",
+		HostKeyCallback: func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			info = &HostKeyInfo{
+				Type:        key.Type(),
+				Fingerprint: ssh.FingerprintSHA256(key),
+			}
+			return errHostKeyCaptured
+		},
+		Timeout: m.config.DialTimeout,
+	}
+
+	addr := net.JoinHostPort(host, fmt.Sprintf("%d", port))
+	client, err := ssh.Dial("tcp", addr, config)
+	if err == nil {
+		// Unreachable: the callback always rejects the key
+		_ = client.Close()
+	}
+	if info == nil {
+		return nil, fmt.Errorf("failed to retrieve host key from %s: %w", addr, err)
+	}
+	return info, nil
+}
+
+// pinnedHostKey returns a host key callback accepting only the key with the
+// given SHA256 fingerprint (with or without the "SHA256:" prefix)
+func pinnedHostKey(fingerprint string) ssh.HostKeyCallback {
+	expected := "SHA256:" + strings.TrimPrefix(strings.TrimSpace(fingerprint), "SHA256:")
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		if actual := ssh.FingerprintSHA256(key); actual != expected {
+			return fmt.Errorf("host key mismatch for %s: expected %s, got %s %s", hostname, expected, key.Type(), actual)
+		}
+		return nil
+	}
+}
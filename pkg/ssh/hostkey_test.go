@@ -0,0 +1,81 @@
+package ssh
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func TestFetchHostKey(t *testing.T) {
+	server := newTestServer(t)
+	manager := newTestManager(t)
+
+	info, err := manager.FetchHostKey("127.0.0.1", server.Port())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := ssh.FingerprintSHA256(server.hostKey.PublicKey())
+	if info.Fingerprint != expected || info.Type != ssh.KeyAlgoED25519 {
+		t.Errorf("expected %s %s, got %+v", ssh.KeyAlgoED25519, expected, info)
+	}
+
+	if _, err := manager.FetchHostKey("example.com", server.Port()); err == nil {
+		t.Errorf("expected an error for a host that is not allowed")
+	}
+}
+
+func TestConnect_PinnedHostKey(t *testing.T) {
+	server := newTestServer(t)
+	manager := newTestManager(t)
+	fingerprint := ssh.FingerprintSHA256(server.hostKey.PublicKey())
+
+	tests := []struct {
+		name        string
+		fingerprint string
+		wantError   bool
+	}{
+		{"matching", fingerprint, false},
+		{"without prefix", strings.TrimPrefix(fingerprint, "SHA256:"), false},
+		{"mismatch", "SHA256:uNiVztksCsDhcc0u9e8BujQXVUpKZIDTMczCvj3tD2s", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := server.params(tt.name)
+			params.HostKeyFingerprint = tt.fingerprint
+
+			_, err := manager.Connect(params)
+			if tt.wantError {
+				if err == nil || !strings.Contains(err.Error(), "host key mismatch") {
+					t.Errorf("expected a host key mismatch, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			_ = manager.Close(tt.name)
+		})
+	}
+}
+
+func TestConnect_RecordsHostKeyFingerprint(t *testing.T) {
+	server := newTestServer(t)
+	manager := newTestManager(t)
+
+	result, err := manager.Connect(server.params("default"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer manager.CloseAll()
+
+	expected := ssh.FingerprintSHA256(server.hostKey.PublicKey())
+	if result.Info.HostKeyFingerprint != expected {
+		t.Errorf("expected fingerprint %s, got %q", expected, result.Info.HostKeyFingerprint)
+	}
+	if infos := manager.List(); len(infos) != 1 || infos[0].HostKeyFingerprint != expected {
+		t.Errorf("expected ssh_list to report fingerprint %s, got %+v", expected, infos)
+	}
+}
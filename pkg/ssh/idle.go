@@ -0,0 +1,227 @@
+package ssh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// MaxIdleComplete is the longest output silence ExecuteUntilIdle accepts as
+// completion
+const MaxIdleComplete = 10 * time.Minute
+
+// IdleResult is the outcome of a command run until it exits or goes quiet
+type IdleResult struct {
+	Stdout string
+	Stderr string
+
+	// ExitCode is -1 when the command completed by idle
+	ExitCode int
+
+	// CompletedByIdle is set when the command produced no output for the idle
+	// period and was left running; it has no exit code yet
+	CompletedByIdle bool
+
+	// StartedAt is when the command was started and FinishedAt when it
+	// exited, both in UTC. FinishedAt is zero when it completed by idle.
+	StartedAt  time.Time
+	FinishedAt time.Time
+
+	Duration    time.Duration
+	Reconnected bool
+}
+
+// ExecuteUntilIdle runs a command that may never terminate, such as a server
+// starting up, and returns once it exits or once it has produced no output for
+// idle, whichever comes first. In the latter case the command keeps running
+// with its further output discarded, until it exits or the connection is
+// closed.
+//
+// The command runs in a separate session started in the working directory of
+// the connection's persistent shell, so that the shell stays usable. Other
+// shell state, such as variables, does not carry over. The command prefix
+// applies. The configured command timeout bounds the wait and stops the
+// command when reached, as does cancelling ctx.
+func (m *Manager) ExecuteUntilIdle(ctx context.Context, id, command string, idle time.Duration) (*IdleResult, error) {
+	timeout := m.config.CommandTimeout
+	if idle <= 0 || idle > MaxIdleComplete {
+		return nil, fmt.Errorf("idle period must be between 0 and %s", MaxIdleComplete)
+	}
+	if idle >= timeout {
+		return nil, fmt.Errorf("idle period must be shorter than the command timeout (%s)", timeout)
+	}
+
+	var result *IdleResult
+	reconnected, err := m.runWithReconnect(id, func(executor *ShellExecutor) error {
+		m.mu.RLock()
+		conn, exists := m.connections[id]
+		m.mu.RUnlock()
+
+		if !exists || conn.executor != executor {
+			return fmt.Errorf("connection '%s' was closed", id)
+		}
+
+		command := command
+		if prefix := executor.Prefix(); prefix != "" {
+			command = prefix + " sh -c " + shellQuote(command)
+		}
+		command, err := inShellDirectory(executor, command)
+		if err != nil {
+			return err
+		}
+
+		result, err = conn.runUntilIdle(ctx, command, idle, timeout)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result.Reconnected = reconnected
+	return result, nil
+}
+
+// runUntilIdle runs command in a new session of the connection until it
+// exits, goes quiet for idle or reaches timeout. Cancelling ctx ends the
+// session, which hangs up the command.
+func (c *Connection) runUntilIdle(ctx context.Context, command string, idle, timeout time.Duration) (*IdleResult, error) {
+	session, err := c.client.NewSession()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create session: %w", err)
+	}
+
+	output := &idleOutput{
+		stdout: limitedBuffer{limit: MaxOutputSize},
+		stderr: limitedBuffer{limit: MaxOutputSize},
+	}
+	session.Stdout = &idleStreamWriter{output: output, buf: &output.stdout}
+	session.Stderr = &idleStreamWriter{output: output, buf: &output.stderr}
+
+	started := time.Now()
+	output.last = started
+	if err := session.Start(command); err != nil {
+		_ = session.Close() // Best effort cleanup
+		return nil, fmt.Errorf("failed to start command: %w", err)
+	}
+
+	// The session is closed once the command exits, also when it is left
+	// running after going quiet
+	done := make(chan error, 1)
+	go func() {
+		err := session.Wait()
+		_ = session.Close() // Best effort cleanup
+		done <- err
+	}()
+
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	quiet := time.NewTimer(idle)
+	defer quiet.Stop()
+
+	for {
+		select {
+		case waitErr := <-done:
+			var exitErr *ssh.ExitError
+			exitCode := 0
+			switch {
+			case errors.As(waitErr, &exitErr):
+				exitCode = exitErr.ExitStatus()
+			case waitErr != nil:
+				return nil, &ConnectionLostError{Sent: true, Err: fmt.Errorf("command session failed: %w", waitErr)}
+			}
+			return c.idleResult(output.detach(), exitCode, false, started), nil
+
+		case <-quiet.C:
+			if silent := output.silentFor(); silent < idle {
+				quiet.Reset(idle - silent)
+				continue
+			}
+			return c.idleResult(output.detach(), -1, true, started), nil
+
+		case <-deadline.C:
+			_ = session.Close()
+			<-done
+			return nil, &ConnectionError{Code: CodeTimeout, Err: fmt.Errorf("command execution timed out after %s without going quiet for %s", timeout, idle)}
+
+		case <-ctx.Done():
+			_ = session.Close()
+			<-done
+			return nil, fmt.Errorf("command cancelled after %s: %w", time.Since(started).Round(time.Millisecond), ctx.Err())
+		}
+	}
+}
+
+// idleResult builds the result from the output collected so far
+func (c *Connection) idleResult(output *idleOutput, exitCode int, byIdle bool, started time.Time) *IdleResult {
+	stdout, stderr := output.stdout.buf.Bytes(), output.stderr.buf.Bytes()
+
+	// A command left running may have been cut in the middle of a character
+	stdout = stdout[:len(stdout)-incompleteUTF8Suffix(stdout)]
+	stderr = stderr[:len(stderr)-incompleteUTF8Suffix(stderr)]
+
+	if filter := c.executor.Options().OutputFilter; filter != nil {
+		stdout = filter(StreamStdout, stdout)
+		stderr = filter(StreamStderr, stderr)
+	}
+
+	result := &IdleResult{
+		Stdout:          strings.TrimSpace(string(stdout)),
+		Stderr:          strings.TrimSpace(string(stderr)),
+		ExitCode:        exitCode,
+		CompletedByIdle: byIdle,
+		StartedAt:       started.UTC(),
+		Duration:        time.Since(started),
+	}
+	if !byIdle {
+		result.FinishedAt = time.Now().UTC()
+	}
+	return result
+}
+
+// idleOutput collects the output of a command run until idle, along with
+// when it last produced any
+type idleOutput struct {
+	mu       sync.Mutex
+	stdout   limitedBuffer
+	stderr   limitedBuffer
+	last     time.Time
+	detached bool
+}
+
+// silentFor returns how long the command has produced no output
+func (o *idleOutput) silentFor() time.Duration {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return time.Since(o.last)
+}
+
+// detach stops collecting output, discarding whatever the command writes
+// from now on, and returns o for reading
+func (o *idleOutput) detach() *idleOutput {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.detached = true
+	return o
+}
+
+// idleStreamWriter writes one of a command's streams into its idleOutput
+type idleStreamWriter struct {
+	output *idleOutput
+	buf    *limitedBuffer
+}
+
+func (w *idleStreamWriter) Write(p []byte) (int, error) {
+	w.output.mu.Lock()
+	defer w.output.mu.Unlock()
+
+	if !w.output.detached {
+		w.output.last = time.Now()
+		_, _ = w.buf.Write(p)
+	}
+	return len(p), nil
+}
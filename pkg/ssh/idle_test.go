@@ -0,0 +1,103 @@
+package ssh
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExecuteUntilIdle(t *testing.T) {
+	server := newTestServer(t)
+	manager := newTestManager(t)
+	connectTestServer(t, manager, server, "default")
+
+	dir := t.TempDir()
+	if _, err := manager.Execute("default", "cd "+shellQuote(dir)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	t.Run("exits", func(t *testing.T) {
+		result, err := manager.ExecuteUntilIdle(context.Background(), "default", "pwd; echo err >&2; exit 3", time.Second)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if result.CompletedByIdle || result.ExitCode != 3 {
+			t.Errorf("expected exit code 3, got %+v", result)
+		}
+		if result.Stdout != dir || result.Stderr != "err" {
+			t.Errorf("expected the command to run in %s, got %+v", dir, result)
+		}
+	})
+
+	t.Run("goes quiet", func(t *testing.T) {
+		command := "for i in 1 2 3; do echo $i; sleep 0.1; done; echo ready >&2; sleep 2; echo late"
+		result, err := manager.ExecuteUntilIdle(context.Background(), "default", command, 400*time.Millisecond)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !result.CompletedByIdle || result.ExitCode != -1 {
+			t.Errorf("expected completion by idle, got %+v", result)
+		}
+		if result.Stdout != "1\n2\n3" || result.Stderr != "ready" {
+			t.Errorf("unexpected output %+v", result)
+		}
+		if result.Duration >= 2*time.Second {
+			t.Errorf("expected to return before the command exits, took %v", result.Duration)
+		}
+
+		// The persistent shell is not held up by the command left running
+		shell, err := manager.Execute("default", "echo free")
+		if err != nil || shell.Stdout != "free" {
+			t.Errorf("expected the shell to stay usable, got %+v, %v", shell, err)
+		}
+	})
+
+	t.Run("invalid idle", func(t *testing.T) {
+		if _, err := manager.ExecuteUntilIdle(context.Background(), "default", "true", DefaultCommandTimeout); err == nil || !strings.Contains(err.Error(), "shorter than the command timeout") {
+			t.Errorf("expected an error for an idle period reaching the timeout, got %v", err)
+		}
+		if _, err := manager.ExecuteUntilIdle(context.Background(), "default", "true", 0); err == nil {
+			t.Error("expected an error for a zero idle period")
+		}
+	})
+}
+
+func TestExecuteUntilIdle_Timeout(t *testing.T) {
+	server := newTestServer(t)
+	manager := newTestManager(t)
+	manager.config.CommandTimeout = time.Second
+	connectTestServer(t, manager, server, "default")
+
+	started := time.Now()
+	_, err := manager.ExecuteUntilIdle(context.Background(), "default", "while true; do echo tick; sleep 0.05; done", 500*time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Fatalf("expected a timeout for a command that never goes quiet, got %v", err)
+	}
+	if elapsed := time.Since(started); elapsed > 3*time.Second {
+		t.Errorf("expected the command to be stopped at the timeout, took %v", elapsed)
+	}
+}
+
+func TestExecuteUntilIdle_Cancelled(t *testing.T) {
+	server := newTestServer(t)
+	manager := newTestManager(t)
+	connectTestServer(t, manager, server, "default")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(300*time.Millisecond, cancel)
+	started := time.Now()
+	_, err := manager.ExecuteUntilIdle(ctx, "default", "echo started; sleep 3", 2*time.Second)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected a cancellation error, got %v", err)
+	}
+	if elapsed := time.Since(started); elapsed > time.Second {
+		t.Errorf("expected the command to be cancelled promptly, took %s", elapsed)
+	}
+
+	// The persistent shell is not affected
+	if result, err := manager.Execute("default", "echo next"); err != nil || result.Stdout != "next" {
+		t.Errorf("expected the shell to stay usable, got %+v, %v", result, err)
+	}
+}
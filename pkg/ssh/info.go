@@ -0,0 +1,68 @@
+package ssh
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ConnectionDetails is the information about a connection together with its
+// live state, as probed by GetInfo
+type ConnectionDetails struct {
+	ConnectionInfo
+
+	// Alive is set when the server answered a keepalive request; AliveError
+	// says why it did not otherwise
+	Alive      bool
+	AliveError string
+
+	// WorkingDir is the shell's working directory and OS the remote kernel
+	// name (uname -s). They are only queried when the connection is alive
+	// and the shell idle.
+	WorkingDir string
+	OS         string
+
+	// Running is the command the shell is busy with, nil when idle
+	Running *RunningCommand
+}
+
+// GetInfo returns information about a connection and probes its live state:
+// whether the server still answers, and, if the shell is not running a
+// command, its working directory and the remote OS. Unlike running a
+// command, the probe is neither charged to the time budget nor rate limited.
+func (m *Manager) GetInfo(id string) (*ConnectionDetails, error) {
+	info, err := m.Info(id)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	conn, exists := m.connections[id]
+	m.mu.RUnlock()
+
+	if !exists {
+		return nil, m.connectionNotFound(id)
+	}
+
+	details := &ConnectionDetails{ConnectionInfo: info}
+	if err := sendKeepalive(conn.client, conn.executor.Options().HealthCheckTimeout); err != nil {
+		details.AliveError = err.Error()
+		return details, nil
+	}
+	details.Alive = true
+
+	if details.Running = conn.executor.Running(); details.Running != nil {
+		return details, nil
+	}
+
+	result, err := conn.executor.ExecuteWithOptions(`pwd; uname -s`, ExecuteOptions{inShell: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query the shell: %w", err)
+	}
+	lines := strings.Split(result.Stdout, "\n")
+	details.WorkingDir = lines[0]
+	if len(lines) > 1 {
+		details.OS = lines[1]
+	}
+
+	return details, nil
+}
@@ -0,0 +1,80 @@
+package ssh
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGetInfo(t *testing.T) {
+	server := newTestServer(t)
+	manager := newTestManager(t, WithTimeBudget(time.Hour))
+	connectTestServer(t, manager, server, "default")
+
+	dir := t.TempDir()
+	if _, err := manager.Execute("default", "cd "+shellQuote(dir)); err != nil {
+		t.Fatalf("failed to change directory: %v", err)
+	}
+	budget, err := manager.TimeBudget("default")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	details, err := manager.GetInfo("default")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if details.ID != "default" || details.Host != "127.0.0.1" || details.Username == "" {
+		t.Errorf("unexpected connection info: %+v", details.ConnectionInfo)
+	}
+	if !details.Alive || details.AliveError != "" {
+		t.Errorf("expected the connection to be alive, got %+v", details)
+	}
+	if details.WorkingDir != dir {
+		t.Errorf("expected working directory %q, got %q", dir, details.WorkingDir)
+	}
+	if details.OS != "Linux" && details.OS != "Darwin" {
+		t.Errorf("unexpected OS %q", details.OS)
+	}
+	if details.Running != nil {
+		t.Errorf("expected no running command, got %+v", details.Running)
+	}
+
+	// The probe is not charged to the time budget
+	if after, _ := manager.TimeBudget("default"); after.Used != budget.Used {
+		t.Errorf("expected the probe not to be charged, used %s then %s", budget.Used, after.Used)
+	}
+
+	if _, err := manager.GetInfo("missing"); ErrorCodeOf(err) != CodeNotFound {
+		t.Errorf("expected a not found error, got %v", err)
+	}
+}
+
+func TestGetInfo_Busy(t *testing.T) {
+	server := newTestServer(t)
+	manager := newTestManager(t)
+	connectTestServer(t, manager, server, "default")
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := manager.ExecuteWithOptions("default", "sleep 1", ExecuteOptions{RequestID: "busy"})
+		done <- err
+	}()
+	waitRunning(t, manager, "default", "busy")
+
+	// The shell is not queried while it runs a command
+	started := time.Now()
+	details, err := manager.GetInfo("default")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if elapsed := time.Since(started); elapsed > 500*time.Millisecond {
+		t.Errorf("expected the probe not to wait for the command, took %v", elapsed)
+	}
+	if !details.Alive || details.Running == nil || details.Running.Command != "sleep 1" || details.WorkingDir != "" {
+		t.Errorf("unexpected details of a busy connection: %+v", details)
+	}
+
+	if err := <-done; err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,96 @@
+package ssh
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// jobsCommand lists the background jobs of the persistent shell
+const jobsCommand = "jobs -l"
+
+var (
+	// jobLine matches the first line of a job in jobs -l output: bash prints
+	// "[1]+  1234 Running    sleep 60 &", dash "[1] + 1234 Running"
+	jobLine = regexp.MustCompile(`^\[(\d+)\]\s*([+-]?)\s+(\d+)\s+(.*)$`)
+
+	// jobPipelineLine matches the further processes of a pipeline job, which
+	// bash prints on their own lines as "      1235   | sort"
+	jobPipelineLine = regexp.MustCompile(`^\s+(\d+)\s+\|\s*(.*)$`)
+
+	// jobState matches the state at the start of a job line, e.g. Running,
+	// Done, Done(1), Exit 1, Stopped (tty input), Killed or Terminated
+	jobState = regexp.MustCompile(`^(Running|Done(?:\(\d+\))?|Exit \d+|Stopped(?: \([^)]*\))?|[A-Z][A-Za-z]*(?: \(core dumped\))?)(?:\s+|$)`)
+)
+
+// Job is a background job of a connection's persistent shell
+type Job struct {
+	// Number is the job number, %<number> in bash's kill, fg or wait. Shells
+	// without job control, such as dash, may not accept it; use the PIDs.
+	Number int
+
+	// Current marks the job the shell uses by default ("+")
+	Current bool
+
+	// PIDs are the processes of the job, more than one for a pipeline
+	PIDs []int
+
+	// State is as reported by the shell, e.g. Running, Stopped, Done or
+	// Exit 1. Finished jobs are only reported once.
+	State string
+
+	// Command is empty when the shell does not keep it (dash)
+	Command string
+}
+
+// Jobs lists the background jobs started in a connection's persistent shell,
+// e.g. with '&'. They keep running between commands, and after the
+// connection is closed.
+func (m *Manager) Jobs(id string) ([]Job, error) {
+	result, err := m.ExecuteWithOptions(id, jobsCommand, ExecuteOptions{inShell: true})
+	if err != nil {
+		return nil, err
+	}
+	if result.ExitCode != 0 {
+		return nil, fmt.Errorf("jobs exited with code %d: %s", result.ExitCode, result.Stderr)
+	}
+	return parseJobs(result.Stdout), nil
+}
+
+// parseJobs parses the output of jobs -l, skipping lines it cannot make
+// sense of
+func parseJobs(output string) []Job {
+	var jobs []Job
+	for _, line := range strings.Split(output, "\n") {
+		if match := jobPipelineLine.FindStringSubmatch(line); match != nil && len(jobs) > 0 {
+			job := &jobs[len(jobs)-1]
+			pid, _ := strconv.Atoi(match[1])
+			job.PIDs = append(job.PIDs, pid)
+			job.Command = strings.TrimSpace(job.Command + " | " + strings.TrimSpace(match[2]))
+			continue
+		}
+
+		match := jobLine.FindStringSubmatch(strings.TrimRight(line, " \t\r"))
+		if match == nil {
+			continue
+		}
+		number, _ := strconv.Atoi(match[1])
+		pid, _ := strconv.Atoi(match[3])
+
+		job := Job{
+			Number:  number,
+			Current: match[2] == "+",
+			PIDs:    []int{pid},
+		}
+		rest := match[4]
+		if state := jobState.FindStringSubmatch(rest); state != nil {
+			job.State = state[1]
+			job.Command = strings.TrimSpace(rest[len(state[0]):])
+		} else {
+			job.Command = strings.TrimSpace(rest)
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs
+}
@@ -0,0 +1,103 @@
+package ssh
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseJobs(t *testing.T) {
+	tests := []struct {
+		name     string
+		output   string
+		expected []Job
+	}{
+		{
+			name:     "empty",
+			output:   "",
+			expected: nil,
+		},
+		{
+			name: "bash",
+			output: "[1]-   523 Running                 sleep 30 &\n" +
+				"[2]+   524 Running                 sleep 31\n" +
+				"       525                       | sleep 32 &\n" +
+				"[3]    530 Exit 3                  ( exit 3 )\n" +
+				"[4]    531 Stopped (tty input)     cat\n",
+			expected: []Job{
+				{Number: 1, PIDs: []int{523}, State: "Running", Command: "sleep 30 &"},
+				{Number: 2, Current: true, PIDs: []int{524, 525}, State: "Running", Command: "sleep 31 | sleep 32 &"},
+				{Number: 3, PIDs: []int{530}, State: "Exit 3", Command: "( exit 3 )"},
+				{Number: 4, PIDs: []int{531}, State: "Stopped (tty input)", Command: "cat"},
+			},
+		},
+		{
+			name: "dash",
+			output: "[2] + 530 Done(3)                \n" +
+				"[1] - 529 Running                \n" +
+				"garbage\n",
+			expected: []Job{
+				{Number: 2, Current: true, PIDs: []int{530}, State: "Done(3)"},
+				{Number: 1, PIDs: []int{529}, State: "Running"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseJobs(tt.output); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("parseJobs() = %+v, want %+v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestManager_Jobs(t *testing.T) {
+	server := newTestServer(t)
+	manager := newTestManager(t)
+	connectTestServer(t, manager, server, "default")
+
+	jobs, err := manager.Jobs("default")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(jobs) != 0 {
+		t.Errorf("expected no jobs, got %+v", jobs)
+	}
+
+	if _, err := manager.Execute("default", "sleep 30 &"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	jobs, err = manager.Jobs("default")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(jobs) != 1 || jobs[0].Number != 1 || jobs[0].State != "Running" || len(jobs[0].PIDs) != 1 || jobs[0].PIDs[0] <= 0 {
+		t.Fatalf("expected the background job, got %+v", jobs)
+	}
+
+	// Not "kill %1": without job control, dash signals the job's process
+	// group, which does not exist
+	if _, err := manager.Execute("default", "kill "+strconv.Itoa(jobs[0].PIDs[0])); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		jobs, err = manager.Jobs("default")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(jobs) == 0 || jobs[0].State != "Running" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the job to stop, got %+v", jobs)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if len(jobs) == 1 && !strings.HasPrefix(jobs[0].State, "Terminated") {
+		t.Errorf("expected the job to be reported terminated, got %+v", jobs)
+	}
+}
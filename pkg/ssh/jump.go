@@ -0,0 +1,55 @@
+package ssh
+
+import (
+	"fmt"
+	"net"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// dialJump connects to the jump host of a connection, authenticating with
+// the SSH agent's keys and then the jump host's private key. Its host key is
+// verified against the known_hosts file, if configured.
+func (m *Manager) dialJump(params ConnectParams, agentKeys []ssh.Signer) (*ssh.Client, error) {
+	signers := agentKeys
+	if params.JumpPrivateKeyPath != "" {
+		signer, err := readPrivateKey(params.JumpPrivateKeyPath, "")
+		if err != nil {
+			return nil, fmt.Errorf("jump host: %w", err)
+		}
+		signers = append(signers[:len(signers):len(signers)], signer)
+	}
+	if len(signers) == 0 {
+		return nil, fmt.Errorf("no authentication method provided for the jump host (jump private key or SSH agent required)")
+	}
+
+	username := params.JumpUsername
+	if username == "" {
+		username = params.Username
+	}
+	addr := net.JoinHostPort(params.JumpHost, fmt.Sprintf("%d", params.jumpPort()))
+	hostKeyCallback, hostKeyAlgorithms, err := m.hostKeyCallback(addr, "")
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := ssh.Dial("tcp", addr, &ssh.ClientConfig{
+		User:              username,
+		Auth:              []ssh.AuthMethod{ssh.PublicKeys(signers...)},
+		HostKeyCallback:   hostKeyCallback,
+		HostKeyAlgorithms: hostKeyAlgorithms,
+		Timeout:           m.config.DialTimeout,
+	})
+	if err != nil {
+		return nil, classifyDialError(fmt.Errorf("failed to connect to jump host %s: %w", addr, err))
+	}
+	return client, nil
+}
+
+// jumpPort returns the port of the jump host, 22 unless given
+func (p *ConnectParams) jumpPort() int {
+	if p.JumpPort == 0 {
+		return 22
+	}
+	return p.JumpPort
+}
@@ -0,0 +1,129 @@
+package ssh
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// jumpParams returns connect parameters for target tunneled through
+// bastion, which accepts a new key
+func jumpParams(t *testing.T, bastion, target *testServer) ConnectParams {
+	t.Helper()
+
+	key := writePrivateKey(t, "")
+	bastion.authorize(publicKeyOf(t, key))
+
+	params := target.params("default")
+	params.JumpHost = "127.0.0.1"
+	params.JumpPort = bastion.Port()
+	params.JumpPrivateKeyPath = key
+	return params
+}
+
+func TestConnect_JumpHost(t *testing.T) {
+	bastion := newTestServer(t)
+	target := newTestServer(t)
+	manager := newTestManager(t)
+
+	if _, err := manager.Connect(jumpParams(t, bastion, target)); err != nil {
+		t.Fatalf("failed to connect through the jump host: %v", err)
+	}
+	t.Cleanup(manager.CloseAll)
+
+	result, err := manager.Execute("default", "echo hello")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Stdout != "hello" {
+		t.Errorf("expected %q, got %q", "hello", result.Stdout)
+	}
+	if got := bastion.forwards.Load(); got != 1 {
+		t.Errorf("expected the connection to be tunneled through the jump host once, got %d", got)
+	}
+
+	// Closing the connection also closes the jump host client
+	jump := manager.connections["default"].jump
+	if err := manager.Close("default"); err != nil {
+		t.Fatalf("failed to close: %v", err)
+	}
+	closed := make(chan struct{})
+	go func() {
+		_ = jump.Wait()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Error("expected the jump host client to be closed with the connection")
+	}
+}
+
+func TestConnect_JumpHostReconnect(t *testing.T) {
+	bastion := newTestServer(t)
+	target := newTestServer(t)
+	manager := newTestManager(t)
+
+	params := jumpParams(t, bastion, target)
+	params.AutoReconnect = true
+	if _, err := manager.Connect(params); err != nil {
+		t.Fatalf("failed to connect through the jump host: %v", err)
+	}
+	t.Cleanup(manager.CloseAll)
+
+	// Losing the jump host takes the tunneled connection down with it; the
+	// retained jump key is used to go through it again
+	bastion.DropConnections()
+	waitConnectionLost(t, manager, "default")
+	if _, err := manager.Execute("default", "true"); err != nil {
+		t.Fatalf("expected the connection to be re-established, got %v", err)
+	}
+	if got := bastion.forwards.Load(); got != 2 {
+		t.Errorf("expected the new connection to be tunneled through the jump host, got %d forwards", got)
+	}
+}
+
+func TestConnect_JumpHostErrors(t *testing.T) {
+	bastion := newTestServer(t)
+	target := newTestServer(t)
+
+	tests := []struct {
+		name    string
+		modify  func(*ConnectParams)
+		wantErr string
+	}{
+		{
+			name:    "jump host not allowed",
+			modify:  func(p *ConnectParams) { p.JumpHost = "bastion.example.com" },
+			wantErr: "jump host: host 'bastion.example.com' is not in the allowed hosts list",
+		},
+		{
+			name:    "with proxy command",
+			modify:  func(p *ConnectParams) { p.ProxyCommand = "nc %h %p" },
+			wantErr: "cannot be combined with a proxy command",
+		},
+		{
+			name:    "no jump authentication",
+			modify:  func(p *ConnectParams) { p.JumpPrivateKeyPath = "" },
+			wantErr: "no authentication method provided for the jump host",
+		},
+		{
+			name:    "jump key not accepted",
+			modify:  func(p *ConnectParams) { p.JumpPrivateKeyPath = writePrivateKey(t, "") },
+			wantErr: "failed to connect to jump host",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manager := newTestManager(t, WithAllowProxyCommand(true))
+			params := jumpParams(t, bastion, target)
+			tt.modify(&params)
+
+			_, err := manager.Connect(params)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("expected an error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
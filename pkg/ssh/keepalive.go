@@ -0,0 +1,126 @@
+package ssh
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// keepaliveRequest is the global request sent to keep connections alive, as
+// OpenSSH's ServerAliveInterval does
+const keepaliveRequest = "keepalive@openssh.com"
+
+// WithKeepalive sends a keepalive request on every connection each interval
+// (0: never), so that firewalls do not drop idle connections and dead ones
+// are noticed. A connection whose keepalive fails or is not answered within
+// the interval is closed and removed, unless it has AutoReconnect set: then
+// only its transport is closed and the next command re-establishes it.
+// onFailure, if set, is called with each such connection and the error.
+func WithKeepalive(interval time.Duration, onFailure func(ConnectionInfo, error)) ManagerOption {
+	return func(c *ManagerConfig) {
+		c.KeepaliveInterval = interval
+		c.OnKeepaliveFailure = onFailure
+	}
+}
+
+// keepalive stops the keepalive goroutine of a connection
+type keepalive struct {
+	stopOnce sync.Once
+	stopCh   chan struct{}
+}
+
+// stop stops the keepalive goroutine without waiting for it, as it may be
+// waiting for the manager lock. It is safe to call on a nil keepalive and
+// more than once.
+func (k *keepalive) stop() {
+	if k == nil {
+		return
+	}
+	k.stopOnce.Do(func() { close(k.stopCh) })
+}
+
+// startKeepalive starts sending keepalive requests on conn if the manager is
+// configured to. It must be called once conn is stored in m.connections.
+func (m *Manager) startKeepalive(conn *Connection) {
+	if m.config.KeepaliveInterval <= 0 {
+		return
+	}
+
+	k := &keepalive{stopCh: make(chan struct{})}
+	conn.keepalive = k
+
+	go func() {
+		ticker := time.NewTicker(m.config.KeepaliveInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-k.stopCh:
+				return
+			case <-ticker.C:
+			}
+
+			if err := sendKeepalive(conn.client, m.config.KeepaliveInterval); err != nil {
+				select {
+				case <-k.stopCh:
+					// The connection was closed meanwhile
+				default:
+					m.keepaliveFailed(conn, err)
+				}
+				return
+			}
+		}
+	}()
+}
+
+// sendKeepalive sends a keepalive request and waits up to timeout for the
+// reply. The server refusing the request still shows the connection is alive.
+func sendKeepalive(client *ssh.Client, timeout time.Duration) error {
+	reply := make(chan error, 1)
+	go func() {
+		// Unblocked by closing the client if the reply never comes
+		_, _, err := client.SendRequest(keepaliveRequest, true, nil)
+		reply <- err
+	}()
+
+	select {
+	case err := <-reply:
+		return err
+	case <-time.After(timeout):
+		return fmt.Errorf("no reply to keepalive within %s", timeout)
+	}
+}
+
+// keepaliveFailed handles a connection whose keepalive failed with err
+func (m *Manager) keepaliveFailed(conn *Connection, err error) {
+	m.mu.Lock()
+	id := conn.Info.ID
+	if m.connections[id] != conn {
+		// Closed or replaced meanwhile
+		m.mu.Unlock()
+		return
+	}
+
+	reconnect := conn.params.AutoReconnect
+	if reconnect {
+		// Commands now fail fast as connection lost and reconnect
+		_ = conn.client.Close()
+	} else {
+		delete(m.connections, id)
+		m.updateIndex(id)
+	}
+	info := conn.Info
+	m.mu.Unlock()
+
+	// The transport has likely stalled: closing the shell first would wait
+	// for a command in flight on it until the command times out
+	if !reconnect {
+		conn.abort()
+	}
+
+	if m.config.OnKeepaliveFailure != nil {
+		m.config.OnKeepaliveFailure(info, err)
+	}
+}
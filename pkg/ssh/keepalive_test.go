@@ -0,0 +1,107 @@
+package ssh
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// waitKeepalives waits until server has received at least n keepalives
+func waitKeepalives(t *testing.T, server *testServer, n int64) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for server.keepalives.Load() < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected at least %d keepalives, got %d", n, server.keepalives.Load())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestKeepalive_Sent(t *testing.T) {
+	server := newTestServer(t)
+	manager := newTestManager(t, WithKeepalive(50*time.Millisecond, nil))
+	t.Cleanup(manager.CloseAll)
+	connectTestServer(t, manager, server, "default")
+
+	waitKeepalives(t, server, 3)
+
+	// Closing the connection stops its keepalives
+	if err := manager.Close("default"); err != nil {
+		t.Fatalf("failed to close: %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	sent := server.keepalives.Load()
+	time.Sleep(200 * time.Millisecond)
+	if got := server.keepalives.Load(); got != sent {
+		t.Errorf("expected no keepalives after close, got %d more", got-sent)
+	}
+}
+
+func TestKeepalive_NotSentByDefault(t *testing.T) {
+	server := newTestServer(t)
+	manager := newTestManager(t)
+	t.Cleanup(manager.CloseAll)
+	connectTestServer(t, manager, server, "default")
+
+	time.Sleep(200 * time.Millisecond)
+	if got := server.keepalives.Load(); got != 0 {
+		t.Errorf("expected no keepalives, got %d", got)
+	}
+}
+
+func TestKeepalive_FailureRemovesConnection(t *testing.T) {
+	server := newTestServer(t)
+	failed := make(chan ConnectionInfo, 1)
+	manager := newTestManager(t, WithKeepalive(50*time.Millisecond, func(info ConnectionInfo, err error) {
+		failed <- info
+	}))
+	t.Cleanup(manager.CloseAll)
+	connectTestServer(t, manager, server, "default")
+
+	server.DropConnections()
+	select {
+	case info := <-failed:
+		if info.ID != "default" {
+			t.Errorf("expected connection 'default' to fail, got %q", info.ID)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the dropped connection to fail its keepalive")
+	}
+
+	_, err := manager.Execute("default", "true")
+	var notFound *ConnectionNotFoundError
+	if !errors.As(err, &notFound) {
+		t.Fatalf("expected the dead connection to be removed, got %v", err)
+	}
+}
+
+func TestKeepalive_FailureWithAutoReconnect(t *testing.T) {
+	server := newTestServer(t)
+	failed := make(chan ConnectionInfo, 1)
+	manager := newTestManager(t, WithKeepalive(50*time.Millisecond, func(info ConnectionInfo, err error) {
+		failed <- info
+	}))
+	t.Cleanup(manager.CloseAll)
+	connectAutoReconnect(t, manager, server, "default")
+
+	server.DropConnections()
+	select {
+	case <-failed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the dropped connection to fail its keepalive")
+	}
+
+	// The connection is kept and re-established by the next command, which
+	// then gets keepalives of its own
+	result, err := manager.Execute("default", "echo hello")
+	if err != nil {
+		t.Fatalf("expected the connection to be re-established, got %v", err)
+	}
+	if !result.Reconnected {
+		t.Error("expected the command to run on a re-established connection")
+	}
+	sent := server.keepalives.Load()
+	waitKeepalives(t, server, sent+2)
+}
@@ -0,0 +1,244 @@
+package ssh
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// agentDialTimeout bounds connecting to the SSH agent socket
+const agentDialTimeout = 2 * time.Second
+
+// KeyInfo describes a public key available to the server. It never carries
+// private key material.
+type KeyInfo struct {
+	// Source is "agent" for keys loaded in the SSH agent or "file" for key
+	// files found on disk
+	Source      string
+	Type        string
+	Fingerprint string
+	Comment     string
+
+	// Path is the private key path to pass as private_key_path (file keys only)
+	Path string
+}
+
+// dialAgent connects to the SSH agent at $SSH_AUTH_SOCK
+func dialAgent() (net.Conn, error) {
+	socket := os.Getenv("SSH_AUTH_SOCK")
+	if socket == "" {
+		return nil, fmt.Errorf("SSH_AUTH_SOCK is not set")
+	}
+
+	conn, err := net.DialTimeout("unix", socket, agentDialTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to SSH agent: %w", err)
+	}
+	return conn, nil
+}
+
+// agentSigners returns signers for the keys loaded in the SSH agent at
+// $SSH_AUTH_SOCK. They sign through the returned agent connection, which
+// must stay open until authentication is done.
+func agentSigners() (net.Conn, []ssh.Signer, error) {
+	conn, err := dialAgent()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	signers, err := agent.NewClient(conn).Signers()
+	if err != nil {
+		_ = conn.Close() // Best effort cleanup
+		return nil, nil, fmt.Errorf("failed to list SSH agent keys: %w", err)
+	}
+	return conn, signers, nil
+}
+
+// ListAgentKeys returns the keys loaded in the SSH agent at $SSH_AUTH_SOCK
+func ListAgentKeys() ([]KeyInfo, error) {
+	conn, err := dialAgent()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = conn.Close() // Best effort cleanup
+	}()
+
+	keys, err := agent.NewClient(conn).List()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list SSH agent keys: %w", err)
+	}
+
+	infos := make([]KeyInfo, 0, len(keys))
+	for _, key := range keys {
+		infos = append(infos, KeyInfo{
+			Source:      "agent",
+			Type:        key.Type(),
+			Fingerprint: ssh.FingerprintSHA256(key),
+			Comment:     key.Comment,
+		})
+	}
+	return infos, nil
+}
+
+// ListKeyFiles returns the keys in dir that have a public key file (*.pub).
+// Only the public halves are read.
+func ListKeyFiles(dir string) ([]KeyInfo, error) {
+	matches, err := filepath.Glob(filepath.Join(dir, "*.pub"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list key files: %w", err)
+	}
+
+	infos := make([]KeyInfo, 0, len(matches))
+	for _, pubPath := range matches {
+		// #nosec G304 - Only public key files in the key directory are read
+		data, err := os.ReadFile(pubPath)
+		if err != nil {
+			continue
+		}
+
+		key, comment, _, _, err := ssh.ParseAuthorizedKey(data)
+		if err != nil {
+			continue
+		}
+
+		info := KeyInfo{
+			Source:      "file",
+			Type:        key.Type(),
+			Fingerprint: ssh.FingerprintSHA256(key),
+			Comment:     comment,
+		}
+
+		privatePath := strings.TrimSuffix(pubPath, ".pub")
+		if _, err := os.Stat(privatePath); err == nil {
+			info.Path = privatePath
+		}
+
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// DefaultKeyDir returns the current user's ~/.ssh directory
+func DefaultKeyDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to determine home directory: %w", err)
+	}
+	return filepath.Join(home, ".ssh"), nil
+}
+
+// ErrPassphraseRequired is returned when connecting with an encrypted private
+// key without a passphrase
+var ErrPassphraseRequired = errors.New("the private key is encrypted and requires a passphrase")
+
+// parsePrivateKey parses the private key read from path, decrypting it with
+// passphrase if it is encrypted. A passphrase given for an unencrypted key is
+// ignored.
+func parsePrivateKey(keyData []byte, path, passphrase string) (ssh.Signer, error) {
+	signer, err := ssh.ParsePrivateKey(keyData)
+	var missing *ssh.PassphraseMissingError
+	if !errors.As(err, &missing) {
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse private key: %w", err)
+		}
+		return signer, nil
+	}
+
+	if passphrase == "" {
+		return nil, fmt.Errorf("private key '%s': %w; provide it with 'passphrase'", path, ErrPassphraseRequired)
+	}
+	secret := []byte(passphrase)
+	signer, err = ssh.ParsePrivateKeyWithPassphrase(keyData, secret)
+	zero(secret)
+	switch {
+	case errors.Is(err, x509.IncorrectPasswordError):
+		return nil, fmt.Errorf("incorrect passphrase for private key '%s'", path)
+	case err != nil:
+		return nil, fmt.Errorf("failed to decrypt private key: %w", err)
+	}
+	return signer, nil
+}
+
+// keyPaths returns the private key files of a connection in the order they
+// are offered
+func (p *ConnectParams) keyPaths() []string {
+	var paths []string
+	if p.PrivateKeyPath != "" {
+		paths = append(paths, p.PrivateKeyPath)
+	}
+	return append(paths, p.PrivateKeyPaths...)
+}
+
+// loadKeyFiles reads and parses the private key files at paths. A single file
+// must be usable. Of several, those that are not are skipped with a warning,
+// unless none is left and fallback, telling whether there is another
+// authentication method, is false.
+func loadKeyFiles(paths []string, passphrase string, fallback bool) ([]ssh.Signer, []string, error) {
+	if len(paths) == 1 {
+		signer, err := readPrivateKey(paths[0], passphrase)
+		if err != nil {
+			return nil, nil, err
+		}
+		return []ssh.Signer{signer}, nil, nil
+	}
+
+	var signers []ssh.Signer
+	var warnings []string
+	var errs []error
+	for _, path := range paths {
+		signer, err := readPrivateKey(path, passphrase)
+		if err != nil {
+			errs = append(errs, err)
+			warnings = append(warnings, fmt.Sprintf("skipped private key file '%s': %v", path, err))
+			continue
+		}
+		signers = append(signers, signer)
+	}
+	if len(signers) == 0 && !fallback {
+		return nil, nil, fmt.Errorf("none of the %d private key files could be used: %w", len(paths), errors.Join(errs...))
+	}
+	return signers, warnings, nil
+}
+
+// checkKeyFiles checks the permissions of the private key files of a
+// connection, returning a warning for each file the group or others may
+// read, or an error with StrictKeyPermissions. OpenSSH refuses such keys.
+// File modes do not carry these permissions on Windows, where nothing is
+// checked.
+func (m *Manager) checkKeyFiles(params ConnectParams) ([]string, error) {
+	if runtime.GOOS == "windows" {
+		return nil, nil
+	}
+
+	var warnings []string
+	for _, path := range append(params.keyPaths(), params.JumpPrivateKeyPath) {
+		if path == "" {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			// Reported when the key is read
+			continue
+		}
+		mode := info.Mode().Perm()
+		if mode&0o077 == 0 {
+			continue
+		}
+		problem := fmt.Sprintf("private key file '%s' is accessible by others (mode %04o); restrict it with 'chmod 600'", path, mode)
+		if m.config.StrictKeyPermissions {
+			return nil, errors.New(problem)
+		}
+		warnings = append(warnings, problem)
+	}
+	return warnings, nil
+}
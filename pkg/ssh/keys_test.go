@@ -0,0 +1,445 @@
+package ssh
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+func TestListKeyFiles(t *testing.T) {
+	dir := t.TempDir()
+
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	sshPub, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatalf("failed to convert key: %v", err)
+	}
+
+	authorized := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(sshPub))) + " deploy@example\n"
+	if err := os.WriteFile(filepath.Join(dir, "id_ed25519.pub"), []byte(authorized), 0600); err != nil {
+		t.Fatalf("failed to write public key: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "id_ed25519"), []byte("private"), 0600); err != nil {
+		t.Fatalf("failed to write private key: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "broken.pub"), []byte("not a key"), 0600); err != nil {
+		t.Fatalf("failed to write broken key: %v", err)
+	}
+
+	keys, err := ListKeyFiles(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(keys) != 1 {
+		t.Fatalf("expected 1 key, got %d", len(keys))
+	}
+
+	key := keys[0]
+	if key.Type != ssh.KeyAlgoED25519 {
+		t.Errorf("expected type %s, got %s", ssh.KeyAlgoED25519, key.Type)
+	}
+	if key.Fingerprint != ssh.FingerprintSHA256(sshPub) {
+		t.Errorf("expected fingerprint %s, got %s", ssh.FingerprintSHA256(sshPub), key.Fingerprint)
+	}
+	if key.Comment != "deploy@example" {
+		t.Errorf("expected comment 'deploy@example', got %q", key.Comment)
+	}
+	if key.Path != filepath.Join(dir, "id_ed25519") {
+		t.Errorf("expected private key path, got %q", key.Path)
+	}
+}
+
+// writePrivateKey writes a new ed25519 private key, encrypted with
+// passphrase unless it is empty, and returns its path
+func writePrivateKey(t *testing.T, passphrase string) string {
+	t.Helper()
+
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	var block *pem.Block
+	if passphrase == "" {
+		block, err = ssh.MarshalPrivateKey(priv, "test")
+	} else {
+		block, err = ssh.MarshalPrivateKeyWithPassphrase(priv, "test", []byte(passphrase))
+	}
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "id_ed25519")
+	if err := os.WriteFile(path, pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatalf("failed to write private key: %v", err)
+	}
+	return path
+}
+
+func TestConnect_KeyPassphrase(t *testing.T) {
+	server := newTestServer(t)
+	encrypted := writePrivateKey(t, "s3cret")
+
+	tests := []struct {
+		name       string
+		keyPath    string
+		passphrase string
+		wantErr    string
+	}{
+		{name: "correct passphrase", keyPath: encrypted, passphrase: "s3cret"},
+		{name: "wrong passphrase", keyPath: encrypted, passphrase: "guess", wantErr: "incorrect passphrase"},
+		{name: "missing passphrase", keyPath: encrypted, wantErr: "requires a passphrase"},
+		{name: "unencrypted key", keyPath: writePrivateKey(t, ""), passphrase: "spurious"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manager := newTestManager(t)
+
+			// The test server only accepts the password, but the key must
+			// still be usable for the connection to proceed
+			params := server.params("default")
+			params.PrivateKeyPath = tt.keyPath
+			params.Passphrase = tt.passphrase
+			_, err := manager.Connect(params)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				manager.CloseAll()
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("expected an error containing %q, got %v", tt.wantErr, err)
+			}
+			if tt.passphrase == "" && !errors.Is(err, ErrPassphraseRequired) {
+				t.Errorf("expected ErrPassphraseRequired, got %v", err)
+			}
+		})
+	}
+}
+
+func TestConnect_KeyPassphraseReconnect(t *testing.T) {
+	server := newTestServer(t)
+	manager := newTestManager(t)
+
+	params := server.params("default")
+	params.PrivateKeyPath = writePrivateKey(t, "s3cret")
+	params.Passphrase = "s3cret"
+	params.AutoReconnect = true
+	if _, err := manager.Connect(params); err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	t.Cleanup(manager.CloseAll)
+
+	// The passphrase is retained to decrypt the key again
+	server.DropConnections()
+	waitConnectionLost(t, manager, "default")
+	if _, err := manager.Execute("default", "true"); err != nil {
+		t.Errorf("expected the connection to be re-established, got %v", err)
+	}
+}
+
+func TestConnect_InlineKey(t *testing.T) {
+	server := newTestServer(t)
+
+	readKey := func(path string) string {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("failed to read private key: %v", err)
+		}
+		return string(data)
+	}
+	plain := readKey(writePrivateKey(t, ""))
+	encrypted := readKey(writePrivateKey(t, "s3cret"))
+	encoded := base64.StdEncoding.EncodeToString([]byte(plain))
+
+	tests := []struct {
+		name       string
+		key        string
+		keyPath    string
+		passphrase string
+		wantErr    string
+	}{
+		{name: "PEM", key: plain},
+		{name: "base64 PEM", key: encoded},
+		{name: "wrapped base64 PEM", key: encoded[:40] + "\n" + encoded[40:80] + "\n" + encoded[80:] + "\n"},
+		{name: "encrypted PEM", key: encrypted, passphrase: "s3cret"},
+		{name: "encrypted PEM without passphrase", key: encrypted, wantErr: "requires a passphrase"},
+		{name: "not a key", key: "not a key", wantErr: "neither PEM-encoded nor base64-encoded PEM"},
+		{name: "base64 of something else", key: base64.StdEncoding.EncodeToString([]byte("not a key")), wantErr: "failed to parse private key"},
+		{name: "key and key path", key: plain, keyPath: writePrivateKey(t, ""), wantErr: "cannot both be given"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manager := newTestManager(t)
+
+			// The test server only accepts the password, but the key must
+			// still be usable for the connection to proceed
+			params := server.params("default")
+			params.PrivateKey = tt.key
+			params.PrivateKeyPath = tt.keyPath
+			params.Passphrase = tt.passphrase
+			_, err := manager.Connect(params)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				manager.CloseAll()
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("expected an error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestConnect_MultipleKeyFiles(t *testing.T) {
+	server := newTestServer(t)
+	authorizedKey := writePrivateKey(t, "")
+	server.authorize(publicKeyOf(t, authorizedKey))
+
+	badKey := filepath.Join(t.TempDir(), "id_bad")
+	if err := os.WriteFile(badKey, []byte("not a key"), 0600); err != nil {
+		t.Fatalf("failed to write bad key: %v", err)
+	}
+
+	// The unusable key is skipped, and the server accepts the last one
+	manager := newTestManager(t)
+	params := server.params("default")
+	params.Password = ""
+	params.PrivateKeyPath = badKey
+	params.PrivateKeyPaths = []string{writePrivateKey(t, ""), authorizedKey}
+	result, err := manager.Connect(params)
+	if err != nil {
+		t.Fatalf("expected the authorized key to be accepted, got %v", err)
+	}
+	if len(result.Warnings) != 1 || !strings.Contains(result.Warnings[0], badKey) {
+		t.Errorf("expected a warning about the skipped key, got %v", result.Warnings)
+	}
+	manager.CloseAll()
+
+	// Without a usable key or another method, connecting fails
+	params.PrivateKeyPaths = []string{filepath.Join(t.TempDir(), "missing")}
+	if _, err := manager.Connect(params); err == nil || !strings.Contains(err.Error(), "none of the 2 private key files") {
+		t.Errorf("expected an error about the unusable keys, got %v", err)
+	}
+}
+
+// startTestAgent serves an SSH agent holding keys at $SSH_AUTH_SOCK for the
+// rest of the test and returns their public keys
+func startTestAgent(t *testing.T, count int) []ssh.PublicKey {
+	t.Helper()
+
+	keyring := agent.NewKeyring()
+	publicKeys := make([]ssh.PublicKey, count)
+	for i := range publicKeys {
+		pub, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatalf("failed to generate key: %v", err)
+		}
+		if err := keyring.Add(agent.AddedKey{PrivateKey: priv}); err != nil {
+			t.Fatalf("failed to add key to agent: %v", err)
+		}
+		if publicKeys[i], err = ssh.NewPublicKey(pub); err != nil {
+			t.Fatalf("failed to convert public key: %v", err)
+		}
+	}
+
+	socket := filepath.Join(t.TempDir(), "agent.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("failed to listen on agent socket: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = listener.Close()
+	})
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer func() {
+					_ = conn.Close()
+				}()
+				_ = agent.ServeAgent(keyring, conn)
+			}()
+		}
+	}()
+
+	t.Setenv("SSH_AUTH_SOCK", socket)
+	return publicKeys
+}
+
+// publicKeyOf returns the public key of a private key file
+func publicKeyOf(t *testing.T, keyPath string) ssh.PublicKey {
+	t.Helper()
+
+	keyData, err := os.ReadFile(keyPath)
+	if err != nil {
+		t.Fatalf("failed to read private key: %v", err)
+	}
+	signer, err := ssh.ParsePrivateKey(keyData)
+	if err != nil {
+		t.Fatalf("failed to parse private key: %v", err)
+	}
+	return signer.PublicKey()
+}
+
+func TestConnect_Agent(t *testing.T) {
+	server := newTestServer(t)
+	agentKeys := startTestAgent(t, 2)
+	server.authorize(agentKeys[1])
+
+	unauthorizedKey := writePrivateKey(t, "")
+	authorizedKey := writePrivateKey(t, "")
+	server.authorize(publicKeyOf(t, authorizedKey))
+
+	tests := []struct {
+		name     string
+		password string
+		keyPath  string
+	}{
+		{name: "agent only"},
+		{name: "agent with password", password: testPassword},
+		{name: "agent with unauthorized key", keyPath: unauthorizedKey},
+		{name: "wrong password", password: "guess"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manager := newTestManager(t)
+			params := server.params("default")
+			params.Password = tt.password
+			params.PrivateKeyPath = tt.keyPath
+			params.UseAgent = true
+			if _, err := manager.Connect(params); err != nil {
+				t.Fatalf("expected the agent key to be accepted, got %v", err)
+			}
+			manager.CloseAll()
+		})
+	}
+
+	t.Run("fallback to key", func(t *testing.T) {
+		startTestAgent(t, 1)
+		manager := newTestManager(t)
+		params := server.params("default")
+		params.Password = ""
+		params.PrivateKeyPath = authorizedKey
+		params.UseAgent = true
+		if _, err := manager.Connect(params); err != nil {
+			t.Fatalf("expected the private key to be tried after the agent's, got %v", err)
+		}
+		manager.CloseAll()
+	})
+
+	t.Run("fallback to password", func(t *testing.T) {
+		startTestAgent(t, 1)
+		manager := newTestManager(t)
+		params := server.params("default")
+		params.UseAgent = true
+		if _, err := manager.Connect(params); err != nil {
+			t.Fatalf("expected the password to be tried after the agent, got %v", err)
+		}
+		manager.CloseAll()
+	})
+
+	errorTests := []struct {
+		name    string
+		socket  string
+		keys    int
+		wantErr string
+	}{
+		{name: "socket unset", wantErr: "SSH_AUTH_SOCK is not set"},
+		{name: "socket unreachable", socket: filepath.Join(t.TempDir(), "missing.sock"), wantErr: "failed to connect to SSH agent"},
+		{name: "no keys", keys: 0, socket: "agent", wantErr: "holds no keys"},
+		{name: "key not accepted", keys: 1, socket: "agent", wantErr: "unable to authenticate"},
+	}
+
+	for _, tt := range errorTests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.socket == "agent" {
+				startTestAgent(t, tt.keys)
+			} else {
+				t.Setenv("SSH_AUTH_SOCK", tt.socket)
+			}
+			manager := newTestManager(t)
+			params := server.params("default")
+			params.Password = ""
+			params.UseAgent = true
+			_, err := manager.Connect(params)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("expected an error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestConnect_KeyPermissions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file modes do not carry permissions on Windows")
+	}
+	server := newTestServer(t)
+
+	tests := []struct {
+		name    string
+		mode    os.FileMode
+		strict  bool
+		warning bool
+		wantErr bool
+	}{
+		{name: "private key", mode: 0600},
+		{name: "read-only private key", mode: 0400, strict: true},
+		{name: "world-readable key", mode: 0644, warning: true},
+		{name: "group-readable key", mode: 0640, warning: true},
+		{name: "world-readable key, strict", mode: 0644, strict: true, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writePrivateKey(t, "")
+			if err := os.Chmod(path, tt.mode); err != nil {
+				t.Fatalf("failed to change mode: %v", err)
+			}
+			manager := newTestManager(t, WithStrictKeyPermissions(tt.strict))
+
+			params := server.params("default")
+			params.PrivateKeyPath = path
+			result, err := manager.Connect(params)
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "is accessible by others (mode 0644)") {
+					t.Fatalf("expected the key to be refused, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			defer manager.CloseAll()
+
+			if tt.warning != (len(result.Warnings) == 1) {
+				t.Errorf("expected warning %v, got %q", tt.warning, result.Warnings)
+			}
+			if tt.warning && !strings.Contains(result.Warnings[0], path) {
+				t.Errorf("expected the warning to name the key file, got %q", result.Warnings[0])
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package ssh
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"net"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// Host key verification modes reported by ManagerConfig.HostKeyMode
+const (
+	HostKeyModeInsecure   = "insecure"
+	HostKeyModeKnownHosts = "known_hosts"
+)
+
+// KnownHosts verifies server host keys against an OpenSSH known_hosts file
+type KnownHosts struct {
+	path string
+}
+
+// LoadKnownHosts checks that path is a readable known_hosts file and returns
+// a verifier for it. The file is read again for every connection, so hosts
+// added to it later are accepted without a restart.
+func LoadKnownHosts(path string) (*KnownHosts, error) {
+	if _, err := knownhosts.New(path); err != nil {
+		return nil, fmt.Errorf("failed to load known_hosts file: %w", err)
+	}
+	return &KnownHosts{path: path}, nil
+}
+
+// Path returns the known_hosts file
+func (k *KnownHosts) Path() string {
+	return k.path
+}
+
+// callback returns a host key callback for the current contents of the file,
+// along with the host key algorithms matching the keys it lists for addr
+// ("host:port"), so that the server is asked for a key that can be verified.
+// Verification failures explain whether the host is unknown or its key
+// changed.
+func (k *KnownHosts) callback(addr string) (ssh.HostKeyCallback, []string, error) {
+	verify, err := knownhosts.New(k.path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load known_hosts file: %w", err)
+	}
+	algorithms, err := knownAlgorithms(verify, addr)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		err := verify(hostname, remote, key)
+		var keyErr *knownhosts.KeyError
+		var revokedErr *knownhosts.RevokedError
+		switch {
+		case err == nil:
+			return nil
+		case errors.As(err, &revokedErr):
+			return fmt.Errorf("host key verification failed for %s: the %s key %s is revoked in %s",
+				hostname, key.Type(), ssh.FingerprintSHA256(key), k.path)
+		case errors.As(err, &keyErr) && len(keyErr.Want) == 0:
+			return fmt.Errorf("host key verification failed for %s: the host is not in %s (it offered %s %s; add it once verified, e.g. via ssh_hostkey)",
+				hostname, k.path, key.Type(), ssh.FingerprintSHA256(key))
+		case errors.As(err, &keyErr):
+			return fmt.Errorf("host key verification failed for %s: the %s key %s does not match the one in %s:%d, the connection may be intercepted",
+				hostname, key.Type(), ssh.FingerprintSHA256(key), keyErr.Want[0].Filename, keyErr.Want[0].Line)
+		}
+		return fmt.Errorf("host key verification failed for %s: %w", hostname, err)
+	}, algorithms, nil
+}
+
+// knownAlgorithms returns the host key algorithms for the keys verify knows
+// for addr, or nil if the host is unknown. The keys are found by verifying a
+// key the file cannot list: the error names every key it expected instead.
+func knownAlgorithms(verify ssh.HostKeyCallback, addr string) ([]string, error) {
+	public, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate probe key: %w", err)
+	}
+	probe, err := ssh.NewPublicKey(public)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate probe key: %w", err)
+	}
+
+	var keyErr *knownhosts.KeyError
+	if !errors.As(verify(addr, &net.TCPAddr{IP: net.IPv4zero}, probe), &keyErr) {
+		return nil, nil
+	}
+	var algorithms []string
+	seen := map[string]bool{}
+	for _, known := range keyErr.Want {
+		for _, algorithm := range keyAlgorithms(known.Key.Type()) {
+			if !seen[algorithm] {
+				seen[algorithm] = true
+				algorithms = append(algorithms, algorithm)
+			}
+		}
+	}
+	return algorithms, nil
+}
+
+// keyAlgorithms returns the signature algorithms a host key of the given
+// type may be offered with
+func keyAlgorithms(keyType string) []string {
+	if keyType == ssh.KeyAlgoRSA {
+		return []string{ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSA}
+	}
+	return []string{keyType}
+}
+
+// WithKnownHosts verifies the host keys of all connections against a
+// known_hosts file (nil: any host key is accepted). A fingerprint pinned by a
+// connection must match as well.
+func WithKnownHosts(knownHosts *KnownHosts) ManagerOption {
+	return func(c *ManagerConfig) {
+		c.KnownHosts = knownHosts
+	}
+}
@@ -0,0 +1,147 @@
+package ssh
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// writeKnownHosts writes a known_hosts file listing key for the test server
+// and returns its path
+func writeKnownHosts(t *testing.T, server *testServer, key ssh.PublicKey) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "known_hosts")
+	var content string
+	if key != nil {
+		address := knownhosts.Normalize(fmt.Sprintf("127.0.0.1:%d", server.Port()))
+		content = knownhosts.Line([]string{address}, key) + "\n"
+	}
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write known_hosts: %v", err)
+	}
+	return path
+}
+
+func TestConnect_KnownHosts(t *testing.T) {
+	server := newTestServer(t)
+
+	otherPub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	otherKey, err := ssh.NewPublicKey(otherPub)
+	if err != nil {
+		t.Fatalf("failed to create public key: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		key     ssh.PublicKey
+		wantErr string
+	}{
+		{name: "known", key: server.hostKey.PublicKey()},
+		{name: "unknown", wantErr: "the host is not in"},
+		{name: "mismatch", key: otherKey, wantErr: "does not match"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			knownHosts, err := LoadKnownHosts(writeKnownHosts(t, server, tt.key))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			manager := newTestManager(t, WithKnownHosts(knownHosts))
+			if mode := manager.Config().HostKeyMode(); mode != HostKeyModeKnownHosts {
+				t.Errorf("expected host key mode %s, got %s", HostKeyModeKnownHosts, mode)
+			}
+
+			_, err = manager.Connect(server.params("default"))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("expected a known host key to be accepted, got %v", err)
+				}
+				manager.CloseAll()
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected an error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestConnect_KnownHostsPinnedKey(t *testing.T) {
+	server := newTestServer(t)
+
+	// A fingerprint pinned by the connection cannot admit a host missing
+	// from the known_hosts file
+	knownHosts, err := LoadKnownHosts(writeKnownHosts(t, server, nil))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	manager := newTestManager(t, WithKnownHosts(knownHosts))
+
+	params := server.params("default")
+	params.HostKeyFingerprint = ssh.FingerprintSHA256(server.hostKey.PublicKey())
+	if _, err := manager.Connect(params); err == nil || !strings.Contains(err.Error(), "not in") {
+		t.Fatalf("expected the unknown host to be refused despite the pinned key, got %v", err)
+	}
+
+	// Nor can a known host pass with a key other than the pinned one
+	knownHosts, err = LoadKnownHosts(writeKnownHosts(t, server, server.hostKey.PublicKey()))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	manager = newTestManager(t, WithKnownHosts(knownHosts))
+	params.HostKeyFingerprint = "SHA256:AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA"
+	if _, err := manager.Connect(params); err == nil || !strings.Contains(err.Error(), "host key mismatch") {
+		t.Fatalf("expected the pinned fingerprint to be enforced, got %v", err)
+	}
+
+	params.HostKeyFingerprint = ssh.FingerprintSHA256(server.hostKey.PublicKey())
+	if _, err := manager.Connect(params); err != nil {
+		t.Fatalf("expected a key passing both checks to be accepted, got %v", err)
+	}
+	manager.CloseAll()
+}
+
+func TestConnect_KnownHostsKeyType(t *testing.T) {
+	server := newTestServer(t)
+
+	// The server also offers an RSA key, which clients prefer, but only its
+	// Ed25519 key is known and must be asked for instead
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	signer, err := ssh.NewSignerFromKey(rsaKey)
+	if err != nil {
+		t.Fatalf("failed to create signer: %v", err)
+	}
+	server.config.AddHostKey(signer)
+
+	knownHosts, err := LoadKnownHosts(writeKnownHosts(t, server, server.hostKey.PublicKey()))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	manager := newTestManager(t, WithKnownHosts(knownHosts))
+	if _, err := manager.Connect(server.params("default")); err != nil {
+		t.Fatalf("expected the known Ed25519 key to be used, got %v", err)
+	}
+	manager.CloseAll()
+}
+
+func TestLoadKnownHosts_Missing(t *testing.T) {
+	if _, err := LoadKnownHosts(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
@@ -0,0 +1,99 @@
+package ssh
+
+import (
+	"sync"
+	"time"
+)
+
+// expiredMemory is how long the IDs of expired sessions are remembered, to
+// tell why they are gone
+const expiredMemory = time.Hour
+
+// WithMaxSessionLifetime closes connections once they have been open for
+// lifetime, whether or not they are in use, aborting any running command
+// (0: never). Reconnecting does not restart the lifetime. onExpire, if set,
+// is called with each connection closed this way.
+func WithMaxSessionLifetime(lifetime time.Duration, onExpire func(ConnectionInfo)) ManagerOption {
+	return func(c *ManagerConfig) {
+		c.MaxSessionLifetime = lifetime
+		c.OnSessionExpired = onExpire
+	}
+}
+
+// expiredSessions remembers the connections closed for reaching the maximum
+// session lifetime, until their ID is connected again or expiredMemory
+// passes
+type expiredSessions struct {
+	mu       sync.Mutex
+	sessions map[string]expiredSession
+}
+
+// expiredSession is a connection closed for reaching its lifetime
+type expiredSession struct {
+	at       time.Time
+	lifetime time.Duration
+}
+
+// add records that connection id expired at now, and forgets the sessions
+// that expired long before
+func (e *expiredSessions) add(id string, lifetime time.Duration, now time.Time) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.sessions == nil {
+		e.sessions = make(map[string]expiredSession)
+	}
+	for old, session := range e.sessions {
+		if now.Sub(session.at) > expiredMemory {
+			delete(e.sessions, old)
+		}
+	}
+	e.sessions[id] = expiredSession{at: now, lifetime: lifetime}
+}
+
+// forget drops connection id, connected again
+func (e *expiredSessions) forget(id string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	delete(e.sessions, id)
+}
+
+// lifetime returns the lifetime connection id was closed after, 0 if it did
+// not expire
+func (e *expiredSessions) lifetime(id string) time.Duration {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.sessions[id].lifetime
+}
+
+// reapExpired aborts the connections created more than MaxSessionLifetime
+// before now, including those running a command. As in reapIdle, they are
+// closed after the manager lock is released.
+func (m *Manager) reapExpired(now time.Time) {
+	var expired []ConnectionInfo
+	var closing []*Connection
+
+	m.mu.Lock()
+	for id, conn := range m.connections {
+		if now.Sub(conn.Info.Created) < m.config.MaxSessionLifetime {
+			continue
+		}
+
+		expired = append(expired, m.info(conn, now))
+		closing = append(closing, conn)
+
+		delete(m.connections, id)
+		m.expired.add(id, m.config.MaxSessionLifetime, now)
+		m.updateIndex(id)
+	}
+	m.mu.Unlock()
+
+	for _, conn := range closing {
+		conn.abort()
+	}
+	if m.config.OnSessionExpired != nil {
+		for _, info := range expired {
+			m.config.OnSessionExpired(info)
+		}
+	}
+}
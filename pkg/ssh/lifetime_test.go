@@ -0,0 +1,60 @@
+package ssh
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestManager_MaxSessionLifetime(t *testing.T) {
+	server := newTestServer(t)
+	expired := make(chan ConnectionInfo, 1)
+	manager := newTestManager(t, WithMaxSessionLifetime(time.Second, func(info ConnectionInfo) {
+		expired <- info
+	}))
+	t.Cleanup(manager.CloseAll)
+	connectTestServer(t, manager, server, "default")
+
+	// Keep the connection busy: it expires anyway
+	start := time.Now()
+	var err error
+	for time.Since(start) < 5*time.Second {
+		if _, err = manager.Execute("default", "sleep 0.1"); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		t.Fatal("expected the active connection to expire")
+	}
+	if age := time.Since(start); age < time.Second {
+		t.Errorf("expected the connection to live for its lifetime, it was closed after %v", age)
+	}
+
+	select {
+	case info := <-expired:
+		if info.ID != "default" {
+			t.Errorf("expected connection 'default' to expire, got %q", info.ID)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the expiry to be reported")
+	}
+
+	_, err = manager.Execute("default", "true")
+	var notFound *ConnectionNotFoundError
+	if !errors.As(err, &notFound) || ErrorCodeOf(err) != CodeNotFound || !strings.Contains(err.Error(), "session expired after the maximum lifetime of 1s") {
+		t.Fatalf("expected a session expired error, got %v", err)
+	}
+
+	// Connecting again starts a new session
+	connectTestServer(t, manager, server, "default")
+	if _, err := manager.Execute("default", "true"); err != nil {
+		t.Errorf("expected the new session to work, got %v", err)
+	}
+	if err := manager.Close("default"); err != nil {
+		t.Fatalf("failed to close: %v", err)
+	}
+	if _, err := manager.Execute("default", "true"); err == nil || strings.Contains(err.Error(), "expired") {
+		t.Errorf("expected a plain not found error once closed, got %v", err)
+	}
+}
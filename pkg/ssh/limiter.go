@@ -0,0 +1,155 @@
+package ssh
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrShuttingDown is returned for commands submitted while the server is
+// shutting down
+var ErrShuttingDown = errors.New("server is shutting down, no new commands are accepted")
+
+// execLimiter caps the number of commands running at once across all
+// connections. Commands beyond the cap wait in a bounded queue; once the
+// queue is full they are rejected.
+type execLimiter struct {
+	// slots is nil when the number of commands is unlimited
+	slots      chan struct{}
+	maxWaiting int64
+
+	running atomic.Int64
+	waiting atomic.Int64
+
+	// mu guards draining and additions to active, so that no command is
+	// admitted once drain has started waiting
+	mu       sync.Mutex
+	draining bool
+	active   sync.WaitGroup
+}
+
+// newExecLimiter returns a limiter allowing max concurrent commands (no limit
+// if max is not positive) with up to queue commands waiting for a slot
+func newExecLimiter(max, queue int) *execLimiter {
+	l := &execLimiter{}
+	if max > 0 {
+		l.slots = make(chan struct{}, max)
+	}
+	if queue > 0 {
+		l.maxWaiting = int64(queue)
+	}
+	return l
+}
+
+// acquire takes a slot, waiting in the queue if all slots are in use. It
+// fails immediately if the queue is full as well, or if the limiter is
+// draining.
+func (l *execLimiter) acquire() error {
+	l.mu.Lock()
+	if l.draining {
+		l.mu.Unlock()
+		return ErrShuttingDown
+	}
+	l.active.Add(1)
+	l.mu.Unlock()
+
+	if l.slots != nil {
+		select {
+		case l.slots <- struct{}{}:
+		default:
+			if err := l.wait(); err != nil {
+				l.active.Done()
+				return err
+			}
+		}
+
+		// Commands still queued when draining started never begin
+		if l.isDraining() {
+			<-l.slots
+			l.active.Done()
+			return ErrShuttingDown
+		}
+	}
+	l.running.Add(1)
+	return nil
+}
+
+// wait queues for a free slot
+func (l *execLimiter) wait() error {
+	if l.waiting.Add(1) > l.maxWaiting {
+		l.waiting.Add(-1)
+		return fmt.Errorf("server busy: %d commands are already running and %d are queued, try again later",
+			cap(l.slots), l.maxWaiting)
+	}
+	defer l.waiting.Add(-1)
+
+	l.slots <- struct{}{}
+	return nil
+}
+
+// release frees a slot taken by acquire
+func (l *execLimiter) release() {
+	l.running.Add(-1)
+	if l.slots != nil {
+		<-l.slots
+	}
+	l.active.Done()
+}
+
+// isDraining reports whether drain has been called
+func (l *execLimiter) isDraining() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.draining
+}
+
+// drain stops admitting commands and waits up to timeout for the running
+// ones to finish. It reports whether they all finished in time.
+func (l *execLimiter) drain(timeout time.Duration) bool {
+	l.mu.Lock()
+	l.draining = true
+	l.mu.Unlock()
+
+	if timeout <= 0 {
+		return l.running.Load() == 0
+	}
+
+	done := make(chan struct{})
+	go func() {
+		l.active.Wait()
+		close(done)
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-done:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+// ExecStats describes the server-wide command concurrency
+type ExecStats struct {
+	// Running is the number of commands currently executing
+	Running int
+
+	// Queued is the number of commands waiting for a free slot
+	Queued int
+
+	// Max is the concurrency cap (0 when unlimited)
+	Max int
+}
+
+// stats returns the current concurrency
+func (l *execLimiter) stats() ExecStats {
+	return ExecStats{
+		Running: int(l.running.Load()),
+		Queued:  int(l.waiting.Load()),
+		Max:     cap(l.slots),
+	}
+}
@@ -0,0 +1,99 @@
+package ssh
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestExecLimiter(t *testing.T) {
+	limiter := newExecLimiter(1, 1)
+
+	if err := limiter.acquire(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The second caller queues until the slot is released
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if err := limiter.acquire(); err != nil {
+			t.Errorf("unexpected error: %v", err)
+			return
+		}
+		limiter.release()
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for limiter.stats().Queued != 1 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if stats := limiter.stats(); stats != (ExecStats{Running: 1, Queued: 1, Max: 1}) {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+
+	// The queue is full, so a third caller is rejected
+	if err := limiter.acquire(); err == nil || !strings.Contains(err.Error(), "server busy") {
+		t.Errorf("expected a server busy error, got %v", err)
+	}
+
+	limiter.release()
+	wg.Wait()
+
+	if stats := limiter.stats(); stats != (ExecStats{Max: 1}) {
+		t.Errorf("unexpected stats after release: %+v", stats)
+	}
+}
+
+func TestExecLimiter_Unlimited(t *testing.T) {
+	limiter := newExecLimiter(0, 0)
+	for i := 0; i < 3; i++ {
+		if err := limiter.acquire(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if stats := limiter.stats(); stats != (ExecStats{Running: 3}) {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestExecLimiter_Drain(t *testing.T) {
+	limiter := newExecLimiter(1, 1)
+
+	if err := limiter.acquire(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// A queued command is rejected once draining starts
+	queued := make(chan error, 1)
+	go func() {
+		queued <- limiter.acquire()
+	}()
+	deadline := time.Now().Add(time.Second)
+	for limiter.stats().Queued != 1 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	if limiter.drain(50 * time.Millisecond) {
+		t.Errorf("expected the drain to time out while a command is running")
+	}
+	if err := limiter.acquire(); err != ErrShuttingDown {
+		t.Errorf("expected new commands to be rejected, got %v", err)
+	}
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		limiter.release()
+	}()
+	if !limiter.drain(time.Second) {
+		t.Errorf("expected the drain to finish once the running command is released")
+	}
+	if err := <-queued; err != ErrShuttingDown {
+		t.Errorf("expected the queued command to be rejected, got %v", err)
+	}
+	if stats := limiter.stats(); stats != (ExecStats{Max: 1}) {
+		t.Errorf("unexpected stats after drain: %+v", stats)
+	}
+}
@@ -0,0 +1,363 @@
+package ssh
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	// DefaultMaxLocalOutput bounds the bytes written to a local file by
+	// ExecuteToLocal unless configured otherwise
+	DefaultMaxLocalOutput int64 = 1 << 30
+
+	// MaxLocalOutputTimeout is the longest timeout ExecuteToLocal accepts
+	MaxLocalOutputTimeout = time.Hour
+)
+
+// filterLineLimit is the longest line held back so that the output filter
+// sees it whole; longer lines are filtered in pieces
+const filterLineLimit = 64 * 1024
+
+// LocalOutputResult describes a command whose stdout was streamed to a local
+// file
+type LocalOutputResult struct {
+	// Path is the absolute path of the written file
+	Path string
+
+	BytesWritten int64
+	Stderr       string
+
+	// ExitCode is -1 when the command was stopped at the size limit
+	ExitCode int
+
+	// Truncated is set when the output reached the size limit. The file then
+	// holds the output up to the limit and the command was stopped.
+	Truncated bool
+
+	Duration    time.Duration
+	Reconnected bool
+}
+
+// ExecuteToLocal runs a command and streams its stdout into a file on this
+// machine, below the configured local output directory, without holding the
+// output in memory. localPath may be relative to that directory or absolute
+// within it; missing parent directories are created, and an existing file is
+// only replaced with overwrite.
+//
+// The command runs in a separate session started in the working directory of
+// the connection's persistent shell. Other shell state, such as variables,
+// does not carry over. A timeout of zero uses the configured command timeout.
+func (m *Manager) ExecuteToLocal(id, command, localPath string, overwrite bool, timeout time.Duration) (*LocalOutputResult, error) {
+	if m.config.LocalOutputDir == "" {
+		return nil, fmt.Errorf("no local output directory is configured")
+	}
+	if timeout <= 0 {
+		timeout = m.config.CommandTimeout
+	}
+
+	var result *LocalOutputResult
+	reconnected, err := m.runWithReconnect(id, func(executor *ShellExecutor) error {
+		m.mu.RLock()
+		conn, exists := m.connections[id]
+		m.mu.RUnlock()
+
+		if !exists || conn.executor != executor {
+			return fmt.Errorf("connection '%s' was closed", id)
+		}
+
+		command, err := inShellDirectory(executor, command)
+		if err != nil {
+			return err
+		}
+
+		result, err = conn.streamToLocal(command, m.config.LocalOutputDir, localPath, overwrite, m.config.MaxLocalOutput, timeout)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result.Reconnected = reconnected
+	return result, nil
+}
+
+// inShellDirectory prefixes command, meant for a separate session, with a
+// change to the working directory of the persistent shell. Querying the
+// directory also detects a dropped connection before anything runs, so the
+// command can be retried after a reconnect.
+func inShellDirectory(executor *ShellExecutor, command string) (string, error) {
+	pwd, err := executor.ExecuteWithOptions("pwd", ExecuteOptions{inShell: true})
+	if err != nil {
+		return "", err
+	}
+	if pwd.ExitCode == 0 && pwd.Stdout != "" {
+		command = fmt.Sprintf("cd %s || exit\n%s", shellQuote(pwd.Stdout), command)
+	}
+	return command, nil
+}
+
+// streamToLocal runs command in a new session of the connection with its
+// stdout written to localPath below dir
+func (c *Connection) streamToLocal(command, dir, localPath string, overwrite bool, maxBytes int64, timeout time.Duration) (*LocalOutputResult, error) {
+	file, path, err := createLocalFile(dir, localPath, overwrite)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = file.Close() // Best effort cleanup; errors are checked below
+	}()
+
+	session, err := c.client.NewSession()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create session: %w", err)
+	}
+	defer func() {
+		_ = session.Close() // Best effort cleanup
+	}()
+
+	filter := c.executor.Options().OutputFilter
+	stdout := &localOutputWriter{
+		file:  file,
+		limit: maxBytes,
+		// Closing the session stops the command once the limit is reached
+		stop: func() { _ = session.Close() },
+	}
+	stdoutFilter := &lineFilterWriter{w: stdout, stream: StreamStdout, filter: filter}
+	stderr := &limitedBuffer{limit: MaxOutputSize}
+	session.Stdout = stdoutFilter
+	session.Stderr = stderr
+
+	started := time.Now()
+	if err := session.Start(command); err != nil {
+		return nil, fmt.Errorf("failed to start command: %w", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- session.Wait()
+	}()
+
+	var waitErr error
+	select {
+	case waitErr = <-done:
+	case <-time.After(timeout):
+		_ = session.Close()
+		<-done
+		return nil, &ConnectionError{Code: CodeTimeout, Err: fmt.Errorf("command execution timed out after %s; %d bytes were written to %s", timeout, stdout.written, path)}
+	}
+
+	if err := stdoutFilter.flush(); err != nil {
+		return nil, err
+	}
+	if err := file.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if stdout.err != nil {
+		return nil, stdout.err
+	}
+
+	exitCode := 0
+	var exitErr *ssh.ExitError
+	switch {
+	case stdout.truncated:
+		exitCode = -1
+	case errors.As(waitErr, &exitErr):
+		exitCode = exitErr.ExitStatus()
+	case waitErr != nil:
+		return nil, &ConnectionLostError{Sent: true, Err: fmt.Errorf("command session failed: %w", waitErr)}
+	}
+
+	stderrData := stderr.buf.Bytes()
+	if filter != nil {
+		stderrData = filter(StreamStderr, stderrData)
+	}
+
+	return &LocalOutputResult{
+		Path:         path,
+		BytesWritten: stdout.written,
+		Stderr:       strings.TrimSpace(string(stderrData)),
+		ExitCode:     exitCode,
+		Truncated:    stdout.truncated,
+		Duration:     time.Since(started),
+	}, nil
+}
+
+// localRoot resolves localPath, relative to dir or absolute within it, and
+// opens dir as a root confining access to it, including through symbolic
+// links. It returns the root, the path relative to it and the absolute path.
+func localRoot(dir, localPath string) (*os.Root, string, string, error) {
+	if strings.TrimSpace(localPath) == "" || strings.ContainsRune(localPath, 0) {
+		return nil, "", "", fmt.Errorf("invalid local path %q", localPath)
+	}
+
+	base, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, "", "", fmt.Errorf("failed to resolve local output directory: %w", err)
+	}
+
+	rel := filepath.Clean(localPath)
+	if filepath.IsAbs(rel) {
+		if rel, err = filepath.Rel(base, rel); err != nil {
+			return nil, "", "", fmt.Errorf("local path '%s' is outside of %s", localPath, base)
+		}
+	}
+	if rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return nil, "", "", fmt.Errorf("local path '%s' is outside of %s", localPath, base)
+	}
+
+	root, err := os.OpenRoot(base)
+	if err != nil {
+		return nil, "", "", fmt.Errorf("failed to open local output directory: %w", err)
+	}
+	return root, rel, filepath.Join(base, rel), nil
+}
+
+// openLocalFile opens localPath below dir for reading, refusing paths that
+// leave dir, and returns the file with its absolute path
+func openLocalFile(dir, localPath string) (*os.File, string, error) {
+	root, rel, abs, err := localRoot(dir, localPath)
+	if err != nil {
+		return nil, "", err
+	}
+	defer func() {
+		_ = root.Close() // Open files stay usable
+	}()
+
+	file, err := root.Open(rel)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, "", fmt.Errorf("local file '%s' does not exist", localPath)
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to open local file '%s': %w", localPath, err)
+	}
+	return file, abs, nil
+}
+
+// createLocalFile creates localPath below dir, refusing paths that leave dir,
+// including through symbolic links, and returns the file with its absolute
+// path
+func createLocalFile(dir, localPath string, overwrite bool) (*os.File, string, error) {
+	root, rel, abs, err := localRoot(dir, localPath)
+	if err != nil {
+		return nil, "", err
+	}
+	defer func() {
+		_ = root.Close() // Open files stay usable
+	}()
+
+	if parent := filepath.Dir(rel); parent != "." {
+		if err := root.MkdirAll(parent, 0o755); err != nil {
+			return nil, "", fmt.Errorf("failed to create directory for '%s': %w", localPath, err)
+		}
+	}
+
+	flags := os.O_WRONLY | os.O_CREATE | os.O_EXCL
+	if overwrite {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	}
+	file, err := root.OpenFile(rel, flags, 0o644)
+	if errors.Is(err, os.ErrExist) {
+		return nil, "", fmt.Errorf("local file '%s' already exists; set overwrite to replace it", localPath)
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create local file '%s': %w", localPath, err)
+	}
+	return file, abs, nil
+}
+
+// localOutputWriter writes to a file up to limit bytes, after which it
+// discards the rest and calls stop once
+type localOutputWriter struct {
+	file      io.Writer
+	limit     int64
+	stop      func()
+	written   int64
+	truncated bool
+	err       error
+}
+
+// Write never fails, so that the session keeps draining the command's output;
+// a write error is kept in err and stops the command like the limit
+func (w *localOutputWriter) Write(p []byte) (int, error) {
+	if w.truncated || w.err != nil {
+		return len(p), nil
+	}
+
+	data := p
+	if w.limit > 0 && w.written+int64(len(data)) > w.limit {
+		data = data[:w.limit-w.written]
+		w.truncated = true
+	}
+
+	n, err := w.file.Write(data)
+	w.written += int64(n)
+	if err != nil {
+		w.err = fmt.Errorf("failed to write local file: %w", err)
+	}
+	if w.truncated || w.err != nil {
+		w.stop()
+	}
+	return len(p), nil
+}
+
+// lineFilterWriter applies an output filter to whole lines before passing
+// them on, so that patterns are not split across writes. Lines longer than
+// filterLineLimit are cut at a character boundary.
+type lineFilterWriter struct {
+	w      io.Writer
+	stream string
+	filter OutputFilter
+	buf    []byte
+}
+
+func (w *lineFilterWriter) Write(p []byte) (int, error) {
+	if w.filter == nil {
+		return w.w.Write(p)
+	}
+
+	w.buf = append(w.buf, p...)
+	end := bytes.LastIndexByte(w.buf, '\n') + 1
+	if end == 0 && len(w.buf) < filterLineLimit {
+		return len(p), nil
+	}
+	if end == 0 {
+		end = len(w.buf) - incompleteUTF8Suffix(w.buf)
+	}
+
+	if _, err := w.w.Write(w.filter(w.stream, w.buf[:end])); err != nil {
+		return 0, err
+	}
+	w.buf = append(w.buf[:0], w.buf[end:]...)
+	return len(p), nil
+}
+
+// flush passes on the last, unterminated line
+func (w *lineFilterWriter) flush() error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+	_, err := w.w.Write(w.filter(w.stream, w.buf))
+	w.buf = nil
+	return err
+}
+
+// limitedBuffer keeps the first limit bytes written to it
+type limitedBuffer struct {
+	buf   bytes.Buffer
+	limit int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - b.buf.Len(); room > 0 {
+		b.buf.Write(p[:min(room, len(p))])
+	}
+	return len(p), nil
+}
@@ -0,0 +1,149 @@
+package ssh
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCreateLocalFile(t *testing.T) {
+	dir := t.TempDir()
+	outside := t.TempDir()
+	if err := os.Symlink(outside, filepath.Join(dir, "escape")); err != nil {
+		t.Fatalf("failed to create symlink: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		localPath string
+		expected  string
+		wantErr   bool
+	}{
+		{"relative", "out.log", filepath.Join(dir, "out.log"), false},
+		{"nested", "logs/web/out.log", filepath.Join(dir, "logs", "web", "out.log"), false},
+		{"absolute inside", filepath.Join(dir, "abs.log"), filepath.Join(dir, "abs.log"), false},
+		{"absolute outside", filepath.Join(outside, "out.log"), "", true},
+		{"parent", "../out.log", "", true},
+		{"cleaned parent", "logs/../../out.log", "", true},
+		{"directory itself", ".", "", true},
+		{"symlink", "escape/out.log", "", true},
+		{"empty", " ", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, path, err := createLocalFile(dir, tt.localPath, false)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("createLocalFile(%q) error = %v, wantErr %v", tt.localPath, err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			_ = file.Close()
+			if path != tt.expected {
+				t.Errorf("createLocalFile(%q) path = %q, want %q", tt.localPath, path, tt.expected)
+			}
+		})
+	}
+
+	if entries, _ := os.ReadDir(outside); len(entries) != 0 {
+		t.Errorf("expected nothing to be written outside of the directory, got %v", entries)
+	}
+	if _, _, err := createLocalFile(dir, "out.log", false); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("expected an existing file to be refused, got %v", err)
+	}
+	file, _, err := createLocalFile(dir, "out.log", true)
+	if err != nil {
+		t.Fatalf("expected overwrite to replace the file, got %v", err)
+	}
+	_ = file.Close()
+}
+
+func TestLineFilterWriter(t *testing.T) {
+	var out strings.Builder
+	upper := func(stream string, data []byte) []byte {
+		return []byte(strings.ToUpper(string(data)))
+	}
+	w := &lineFilterWriter{w: &out, stream: StreamStdout, filter: upper}
+
+	for _, part := range []string{"sec", "ret\nnext ", "line\nta", "il"} {
+		if _, err := w.Write([]byte(part)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if out.String() != "SECRET\nNEXT LINE\n" {
+		t.Errorf("expected only complete lines to be written, got %q", out.String())
+	}
+	if err := w.flush(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out.String() != "SECRET\nNEXT LINE\nTAIL" {
+		t.Errorf("expected flush to write the rest, got %q", out.String())
+	}
+}
+
+func TestExecuteToLocal(t *testing.T) {
+	server := newTestServer(t)
+	dir := t.TempDir()
+	manager := newTestManager(t, WithLocalOutput(dir, 0))
+	connectTestServer(t, manager, server, "default")
+
+	remoteDir := t.TempDir()
+	if _, err := manager.Execute("default", "cd "+shellQuote(remoteDir)+" && printf 'a\\nb\\n' > input.txt"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Runs in the shell's working directory
+	result, err := manager.ExecuteToLocal("default", "cat input.txt; echo warn >&2; exit 3", "copy/input.txt", false, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.ExitCode != 3 || result.BytesWritten != 4 || result.Stderr != "warn" || result.Truncated {
+		t.Errorf("unexpected result: %+v", result)
+	}
+	if result.Path != filepath.Join(dir, "copy", "input.txt") {
+		t.Errorf("unexpected path %q", result.Path)
+	}
+	data, err := os.ReadFile(result.Path)
+	if err != nil || string(data) != "a\nb\n" {
+		t.Errorf("expected the output in the local file, got %q, %v", data, err)
+	}
+
+	if _, err := manager.ExecuteToLocal("default", "echo hi", "../escape.txt", false, 0); err == nil {
+		t.Errorf("expected a path outside of the directory to be refused")
+	}
+}
+
+func TestExecuteToLocal_Limit(t *testing.T) {
+	server := newTestServer(t)
+	dir := t.TempDir()
+	manager := newTestManager(t, WithLocalOutput(dir, 1000))
+	connectTestServer(t, manager, server, "default")
+
+	result, err := manager.ExecuteToLocal("default", "yes", "yes.txt", false, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !result.Truncated || result.BytesWritten != 1000 || result.ExitCode != -1 {
+		t.Errorf("expected the command to be stopped at the limit, got %+v", result)
+	}
+	if info, err := os.Stat(result.Path); err != nil || info.Size() != 1000 {
+		t.Errorf("expected a 1000 byte file, got %v, %v", info, err)
+	}
+
+	// The shell is still usable
+	if out, err := manager.Execute("default", "echo ok"); err != nil || out.Stdout != "ok" {
+		t.Errorf("expected the shell to keep working, got %+v, %v", out, err)
+	}
+}
+
+func TestExecuteToLocal_Disabled(t *testing.T) {
+	server := newTestServer(t)
+	manager := newTestManager(t)
+	connectTestServer(t, manager, server, "default")
+
+	if _, err := manager.ExecuteToLocal("default", "echo hi", "out.txt", false, 0); err == nil {
+		t.Errorf("expected an error without a local output directory")
+	}
+}
@@ -1,17 +1,24 @@
 package ssh
 
 import (
+	"bytes"
+	"context"
+	"encoding/base64"
 	"fmt"
+	"maps"
 	"net"
 	"os"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/pkg/sftp"
 	"golang.org/x/crypto/ssh"
 )
 
 const (
-	// MaxConnections is the maximum number of concurrent connections allowed
+	// MaxConnections is the default maximum number of concurrent connections
 	MaxConnections = 100
 
 	// SSHDialTimeout is the timeout for SSH connection establishment
@@ -25,6 +32,46 @@ type ConnectionInfo struct {
 	Port     int
 	Username string
 	Created  time.Time
+
+	// LastUsed is when the last command on the connection finished or a file
+	// operation on it started, Created if none has
+	LastUsed time.Time
+
+	// AutoReconnect is set when the connection is re-established after it
+	// drops; Reconnects counts how often that happened
+	AutoReconnect bool
+	Reconnects    int
+
+	// Reconnect reports whether reconnecting is throttled after failures
+	Reconnect ReconnectStatus
+
+	// CommandPrefix is placed before every command (see Manager.SetPrefix)
+	CommandPrefix string
+
+	// TimeBudget is the command time budget and its use
+	TimeBudget TimeBudget
+
+	// LogLevel is the logging verbosity for operations on the connection,
+	// empty for the server's
+	LogLevel string
+
+	// HostKeyFingerprint is the SHA256 fingerprint of the host key the
+	// server presented, in OpenSSH format, e.g. for pinning it later
+	HostKeyFingerprint string
+
+	// Tags are labels given on connect for grouping connections
+	Tags map[string]string
+}
+
+// HasTags reports whether the connection has every tag in filter with the
+// same value; an empty filter matches any connection
+func (i ConnectionInfo) HasTags(filter map[string]string) bool {
+	for key, value := range filter {
+		if tag, ok := i.Tags[key]; !ok || tag != value {
+			return false
+		}
+	}
+	return true
 }
 
 // Connection represents an active SSH connection with a persistent shell
@@ -32,142 +79,979 @@ type Connection struct {
 	Info     ConnectionInfo
 	client   *ssh.Client
 	executor *ShellExecutor
+
+	// jump is the client of the jump host the connection goes through, nil
+	// without one
+	jump *ssh.Client
+
+	// sftp is opened on first use by the file tools
+	sftp   *sftp.Client
+	sftpMu sync.Mutex
+
+	// credentials are the retained authentication secrets, nil unless a
+	// feature needs to authenticate again
+	credentials *credentials
+
+	// params are the connect parameters without secrets, kept to reconnect
+	params ConnectParams
+
+	// reconnectMu serializes attempts to re-establish the connection
+	reconnectMu sync.Mutex
+
+	// breaker throttles those attempts after failures
+	breaker reconnectBreaker
+
+	// budget bounds the command time the connection may use
+	budget *timeBudget
+
+	// limiter bounds the commands the connection may run a minute, nil if
+	// unlimited
+	limiter *rateLimiter
+
+	// lastUsed holds the UnixNano time of ConnectionInfo.LastUsed
+	lastUsed atomic.Int64
+
+	// active counts the commands in flight, which keep the connection from
+	// being closed as idle
+	active atomic.Int32
+
+	// keyWarnings tell which private key files establish skipped and why,
+	// reported by Connect
+	keyWarnings []string
+
+	// keepalive is the connection's keepalive goroutine, nil without one
+	keepalive *keepalive
+}
+
+// touch records that the connection was just used
+func (c *Connection) touch() {
+	c.lastUsed.Store(time.Now().UnixNano())
+}
+
+// close stops the connection's keepalive, closes its SFTP client, executor,
+// client and jump host client and wipes its retained credentials
+func (c *Connection) close() {
+	c.keepalive.stop()
+	if c.credentials != nil {
+		c.credentials.wipe()
+	}
+	if c.sftp != nil {
+		_ = c.sftp.Close() // Best effort cleanup
+	}
+	if c.executor != nil {
+		_ = c.executor.Close() // Best effort cleanup
+	}
+	if c.client != nil {
+		_ = c.client.Close() // Best effort cleanup
+	}
+	if c.jump != nil {
+		_ = c.jump.Close() // Best effort cleanup
+	}
+}
+
+// abort closes the connection, ending any command running on it. The
+// transport is closed first: closing the shell waits for a running command,
+// which then fails with a lost connection instead of running to its end or
+// timeout, and the server hangs up the command's processes.
+func (c *Connection) abort() {
+	if c.client != nil {
+		_ = c.client.Close() // Best effort cleanup
+	}
+	c.close()
+}
+
+// ManagerConfig holds the tunable settings of a Manager
+type ManagerConfig struct {
+	// MaxConnections is the maximum number of concurrent connections
+	MaxConnections int
+
+	// DialTimeout bounds SSH connection establishment
+	DialTimeout time.Duration
+
+	// CommandTimeout bounds each command execution
+	CommandTimeout time.Duration
+
+	// IdleOutputThreshold is passed to every shell executor (see ShellOptions)
+	IdleOutputThreshold time.Duration
+
+	// AllowProxyCommand permits connections to use a local proxy command
+	AllowProxyCommand bool
+
+	// AllowSessionCommands lets commands replace or exit the persistent
+	// shell (see ShellOptions)
+	AllowSessionCommands bool
+
+	// AllowPTY permits connections to run their shell on a pseudo-terminal
+	AllowPTY bool
+
+	// StrictKeyPermissions refuses private key files others may read,
+	// instead of reporting them as a warning
+	StrictKeyPermissions bool
+
+	// PathPolicy restricts the remote paths of SFTP operations (nil allows all)
+	PathPolicy *PathPolicy
+
+	// OutputFilter transforms the output of every command (nil: none)
+	OutputFilter OutputFilter
+
+	// MaxConcurrentExecs caps the commands running at once across all
+	// connections (0: unlimited)
+	MaxConcurrentExecs int
+
+	// ExecQueueSize is the number of commands that may wait for a free slot
+	// once MaxConcurrentExecs is reached; further commands are rejected
+	ExecQueueSize int
+
+	// ReconnectPolicy throttles attempts to re-establish dropped connections
+	ReconnectPolicy ReconnectPolicy
+
+	// LocalOutputDir is the local directory ExecuteToLocal writes into
+	// (empty: disabled)
+	LocalOutputDir string
+
+	// MaxLocalOutput bounds the bytes ExecuteToLocal writes per command
+	MaxLocalOutput int64
+
+	// MaxTransferSize bounds the size of a file copied by Upload
+	MaxTransferSize int64
+
+	// Presets are the connection presets by name
+	Presets map[string]*Preset
+
+	// ConnectionIndex, when set, records the connection IDs in use so that
+	// those lost by a restart can be recognised
+	ConnectionIndex *ConnectionIndex
+
+	// TimeBudget is the command time each connection may use (0: unlimited)
+	TimeBudget time.Duration
+
+	// MaxCommandsPerMinute is the number of commands each connection may run
+	// a minute (0: unlimited)
+	MaxCommandsPerMinute int
+
+	// KnownHosts, when set, verifies server host keys (nil: any is accepted)
+	KnownHosts *KnownHosts
+
+	// IdleTimeout closes connections unused for that long (0: never)
+	IdleTimeout time.Duration
+
+	// OnIdleReap, when set, is called with each connection closed as idle
+	OnIdleReap func(ConnectionInfo)
+
+	// MaxSessionLifetime closes connections open for that long, however
+	// active (0: never)
+	MaxSessionLifetime time.Duration
+
+	// OnSessionExpired, when set, is called with each connection closed for
+	// reaching MaxSessionLifetime
+	OnSessionExpired func(ConnectionInfo)
+
+	// KeepaliveInterval is how often connections are sent a keepalive
+	// request (0: never)
+	KeepaliveInterval time.Duration
+
+	// OnKeepaliveFailure, when set, is called with each connection whose
+	// keepalive failed
+	OnKeepaliveFailure func(ConnectionInfo, error)
+
+	// OnConnectRetry, when set, is called before each retry of a failed
+	// connection attempt (see WithConnectRetryHook)
+	OnConnectRetry func(id string, attempt int, wait time.Duration, err error)
+}
+
+// HostKeyMode describes how server host keys are verified
+func (c ManagerConfig) HostKeyMode() string {
+	if c.KnownHosts != nil {
+		return HostKeyModeKnownHosts
+	}
+	return HostKeyModeInsecure
+}
+
+// ManagerOption configures a Manager
+type ManagerOption func(*ManagerConfig)
+
+// WithIdleOutputThreshold sets the output silence after which a timed out
+// command is reported as possibly hung
+func WithIdleOutputThreshold(d time.Duration) ManagerOption {
+	return func(c *ManagerConfig) {
+		c.IdleOutputThreshold = d
+	}
+}
+
+// WithAllowProxyCommand permits connections to run a local proxy command as
+// their transport
+func WithAllowProxyCommand(allow bool) ManagerOption {
+	return func(c *ManagerConfig) {
+		c.AllowProxyCommand = allow
+	}
+}
+
+// WithAllowSessionCommands lets commands such as 'exec', 'exit' or a bare
+// 'bash' run even though they make the persistent shell unusable
+func WithAllowSessionCommands(allow bool) ManagerOption {
+	return func(c *ManagerConfig) {
+		c.AllowSessionCommands = allow
+	}
+}
+
+// WithAllowPTY permits connections to request a pseudo-terminal for their
+// shell
+func WithAllowPTY(allow bool) ManagerOption {
+	return func(c *ManagerConfig) {
+		c.AllowPTY = allow
+	}
+}
+
+// WithStrictKeyPermissions refuses private key files readable by the group
+// or others, as OpenSSH does, instead of only warning about them
+func WithStrictKeyPermissions(strict bool) ManagerOption {
+	return func(c *ManagerConfig) {
+		c.StrictKeyPermissions = strict
+	}
+}
+
+// WithMaxConnections sets how many connections may be open at once;
+// connecting more fails with CodeLimitReached
+func WithMaxConnections(max int) ManagerOption {
+	return func(c *ManagerConfig) {
+		c.MaxConnections = max
+	}
+}
+
+// WithMaxConcurrentExecs caps the commands running at once across all
+// connections. Up to queueSize further commands wait for a free slot, the
+// rest are rejected with a "server busy" error.
+func WithMaxConcurrentExecs(max, queueSize int) ManagerOption {
+	return func(c *ManagerConfig) {
+		c.MaxConcurrentExecs = max
+		c.ExecQueueSize = queueSize
+	}
+}
+
+// WithReconnectPolicy sets how attempts to re-establish a dropped
+// connection are throttled after failures
+func WithReconnectPolicy(policy ReconnectPolicy) ManagerOption {
+	return func(c *ManagerConfig) {
+		c.ReconnectPolicy = policy
+	}
+}
+
+// WithOutputFilter registers a filter run on command output before it is
+// returned. Filters registered by repeated calls run in registration order.
+func WithOutputFilter(filter OutputFilter) ManagerOption {
+	return func(c *ManagerConfig) {
+		c.OutputFilter = ChainOutputFilters(c.OutputFilter, filter)
+	}
+}
+
+// WithPathPolicy restricts the remote paths SFTP operations may access
+func WithPathPolicy(policy *PathPolicy) ManagerOption {
+	return func(c *ManagerConfig) {
+		c.PathPolicy = policy
+	}
+}
+
+// WithLocalOutput enables ExecuteToLocal, writing into dir at most maxBytes
+// per command (DefaultMaxLocalOutput if not positive)
+func WithLocalOutput(dir string, maxBytes int64) ManagerOption {
+	return func(c *ManagerConfig) {
+		c.LocalOutputDir = dir
+		if maxBytes > 0 {
+			c.MaxLocalOutput = maxBytes
+		}
+	}
 }
 
 // Manager manages SSH connections
 type Manager struct {
 	connections map[string]*Connection
 	validator   *HostValidator
+	config      ManagerConfig
+	execs       *execLimiter
+	requests    *requestRegistry
+	reaper      *idleReaper
+	expired     expiredSessions
+	metrics     commandMetrics
 	mu          sync.RWMutex
 }
 
 // NewManager creates a new SSH connection manager
-func NewManager(validator *HostValidator) *Manager {
-	return &Manager{
+func NewManager(validator *HostValidator, opts ...ManagerOption) *Manager {
+	config := ManagerConfig{
+		MaxConnections:      MaxConnections,
+		DialTimeout:         SSHDialTimeout,
+		CommandTimeout:      DefaultCommandTimeout,
+		IdleOutputThreshold: DefaultIdleOutputThreshold,
+		ReconnectPolicy:     DefaultReconnectPolicy,
+		MaxLocalOutput:      DefaultMaxLocalOutput,
+		MaxTransferSize:     DefaultMaxTransferSize,
+	}
+	for _, opt := range opts {
+		opt(&config)
+	}
+
+	m := &Manager{
 		connections: make(map[string]*Connection),
 		validator:   validator,
+		config:      config,
+		execs:       newExecLimiter(config.MaxConcurrentExecs, config.ExecQueueSize),
+		requests:    newRequestRegistry(),
 	}
+	if config.IdleTimeout > 0 || config.MaxSessionLifetime > 0 {
+		m.reaper = startIdleReaper(m)
+	}
+	return m
+}
+
+// Connection id conflict policies for ConnectParams.OnConflict
+const (
+	// ConflictError fails the connect if the id is already in use
+	ConflictError = "error"
+	// ConflictReuse returns the existing connection if it targets the same
+	// host, port and username
+	ConflictReuse = "reuse"
+	// ConflictReplace closes the existing connection once the new one is
+	// established
+	ConflictReplace = "replace"
+)
+
+// ConnectParams holds the parameters of a new SSH connection
+type ConnectParams struct {
+	ID             string
+	Host           string
+	Port           int
+	Username       string
+	Password       string
+	PrivateKeyPath string
+
+	// PrivateKeyPaths are further private key files, offered in order after
+	// PrivateKeyPath so that the server may accept any of them. Of several
+	// files, one that cannot be read or parsed is skipped with a warning as
+	// long as another key or authentication method remains.
+	PrivateKeyPaths []string
+
+	// PrivateKey is the private key itself, PEM-encoded, possibly wrapped in
+	// base64, as an alternative to PrivateKeyPath where there is no file to
+	// read it from. It is never written to disk.
+	PrivateKey string
+
+	// Passphrase decrypts the private key, if it is encrypted
+	Passphrase string
+
+	// JumpHost, when set, is a bastion the connection is tunneled through,
+	// like OpenSSH's ProxyJump. It must pass the host validator too.
+	// JumpPort defaults to 22 and JumpUsername to Username. The jump host
+	// authenticates with JumpPrivateKeyPath, which must not be encrypted, or
+	// the SSH agent's keys with UseAgent.
+	JumpHost           string
+	JumpPort           int
+	JumpUsername       string
+	JumpPrivateKeyPath string
+
+	// UseAgent authenticates with the keys of the SSH agent at
+	// $SSH_AUTH_SOCK, tried before the private key and the password
+	UseAgent bool
+
+	// ProxyCommand, when set, is run locally and its stdin/stdout are used as
+	// the transport instead of a direct TCP connection, like OpenSSH's
+	// ProxyCommand. The tokens %h, %p and %r are expanded.
+	ProxyCommand string
+
+	// Ciphers, MACs and KeyExchanges, when set, replace the algorithms of
+	// each kind offered to the server, in order of preference, e.g. to reach
+	// a legacy appliance or to restrict those used with a hardened one. The
+	// names must be implemented by golang.org/x/crypto/ssh, insecure ones
+	// included (see checkAlgorithms).
+	Ciphers      []string
+	MACs         []string
+	KeyExchanges []string
+
+	// OnConflict decides what happens when ID is already in use
+	// (default: ConflictError)
+	OnConflict string
+
+	// DisableHistory keeps the agent's commands out of the remote shell history
+	DisableHistory bool
+
+	// SubshellPerCommand runs each command in a child shell (see ShellOptions)
+	SubshellPerCommand bool
+
+	// RequestPTY runs the shell on a pseudo-terminal (see ShellOptions)
+	RequestPTY bool
+
+	// Tags label the connection (see ConnectionInfo)
+	Tags map[string]string
+
+	// MaxRetries is how many more times to try connecting after a transient
+	// failure such as a refused connection or a timeout, at most
+	// MaxConnectRetries. RetryBackoff is the wait before the first retry,
+	// doubled after each (0: DefaultRetryBackoff). Reconnecting a dropped
+	// connection does not retry.
+	MaxRetries   int
+	RetryBackoff time.Duration
+
+	// Shell replaces the login shell with a POSIX shell and SkipShellInit
+	// leaves out the echo and prompt setup (see ShellOptions)
+	Shell         string
+	SkipShellInit bool
+
+	// HostKeyFingerprint, when set, pins the server's host key to this
+	// SHA256 fingerprint (see FetchHostKey), in addition to the known_hosts
+	// file if configured; otherwise any host key is accepted
+	HostKeyFingerprint string
+
+	// AutoReconnect re-establishes the connection when it drops, retaining
+	// the credentials for as long as the connection lives. A command is only
+	// retried on the new connection if it never reached the old one.
+	AutoReconnect bool
+
+	// TimeBudget bounds the command time the connection may use, at most the
+	// server's budget (0: the server's budget, if any)
+	TimeBudget time.Duration
+
+	// LogLevel overrides the server's logging verbosity for operations on
+	// the connection (empty: the server's). It is a logrus level name, which
+	// the caller is expected to have validated.
+	LogLevel string
+}
+
+// ConnectResult describes an established (or reused) connection
+type ConnectResult struct {
+	Info     ConnectionInfo
+	Reused   bool
+	Replaced bool
+
+	// LostOnRestart is set when the ID was in use before the server
+	// restarted, i.e. the caller may expect state that is gone
+	LostOnRestart bool
+
+	// Warnings are problems that did not prevent connecting, such as a
+	// private key file others may read
+	Warnings []string
 }
 
-// Connect establishes a new SSH connection
-func (m *Manager) Connect(id, host string, port int, username, password, privateKeyPath string) error {
+// Connect establishes a new SSH connection. The manager lock is not held
+// while dialing, authenticating and waiting between retries, so that other
+// connections stay usable; conflicts and the connection limit are checked
+// again before the new connection is added.
+func (m *Manager) Connect(params ConnectParams) (*ConnectResult, error) {
+	// net.JoinHostPort adds the brackets of an IPv6 address itself
+	params.Host = unbracketHost(params.Host)
+	params.JumpHost = unbracketHost(params.JumpHost)
+
+	m.mu.RLock()
+	_, reused, err := m.checkConnect(params)
+	m.mu.RUnlock()
+	if reused != nil || err != nil {
+		return reused, err
+	}
+
+	budget, err := m.newTimeBudget(params.TimeBudget)
+	if err != nil {
+		return nil, err
+	}
+
+	warnings, err := m.checkKeyFiles(params)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := m.establishWithRetry(params)
+	if err != nil {
+		return nil, err
+	}
+	warnings = append(warnings, conn.keyWarnings...)
+
 	m.mu.Lock()
-	defer m.mu.Unlock()
 
-	// Check connection limit
-	if len(m.connections) >= MaxConnections {
-		return fmt.Errorf("connection limit reached (%d/%d)", len(m.connections), MaxConnections)
+	// A concurrent Connect may have added the ID or used up the limit
+	existing, reused, err := m.checkConnect(params)
+	if reused != nil || err != nil {
+		m.mu.Unlock()
+		conn.close()
+		return reused, err
 	}
+	exists := existing != nil
 
-	// Check if connection already exists
-	if _, exists := m.connections[id]; exists {
-		return fmt.Errorf("connection with ID '%s' already exists", id)
+	// Store connection
+	conn.Info = ConnectionInfo{
+		ID:                 params.ID,
+		Host:               params.Host,
+		Port:               params.Port,
+		Username:           params.Username,
+		Created:            time.Now(),
+		AutoReconnect:      params.AutoReconnect,
+		LogLevel:           params.LogLevel,
+		HostKeyFingerprint: conn.Info.HostKeyFingerprint,
+		Tags:               maps.Clone(params.Tags),
 	}
+	conn.params = stripCredentials(params)
+	conn.budget = budget
+	conn.limiter = newRateLimiter(m.config.MaxCommandsPerMinute)
+	conn.lastUsed.Store(conn.Info.Created.UnixNano())
+	if params.AutoReconnect {
+		conn.credentials = retainCredentials(params)
+	}
+	m.connections[params.ID] = conn
+	m.expired.forget(params.ID)
+	m.startKeepalive(conn)
+
+	lost := m.config.ConnectionIndex != nil && m.config.ConnectionIndex.wasLost(params.ID)
+	m.updateIndex(params.ID)
+	result := &ConnectResult{Info: conn.Info, Replaced: exists, LostOnRestart: lost, Warnings: warnings}
+	m.mu.Unlock()
 
+	// Closing the replaced connection waits for a command running on it, so
+	// it is done once other operations may go on
+	if exists {
+		existing.close()
+	}
+	return result, nil
+}
+
+// checkConnect checks a connection request against the existing
+// connections and the connection limit. It returns the connection a replace
+// would close, or the result of reusing an existing one. The caller holds
+// the manager lock.
+func (m *Manager) checkConnect(params ConnectParams) (*Connection, *ConnectResult, error) {
+	existing, exists := m.connections[params.ID]
+	if exists {
+		switch params.OnConflict {
+		case "", ConflictError:
+			return nil, nil, fmt.Errorf("connection with ID '%s' already exists", params.ID)
+		case ConflictReuse:
+			info := existing.Info
+			if info.Host != params.Host || info.Port != params.Port || info.Username != params.Username {
+				return nil, nil, fmt.Errorf("connection with ID '%s' already exists for %s@%s:%d, cannot reuse it for %s@%s:%d",
+					params.ID, info.Username, info.Host, info.Port, params.Username, params.Host, params.Port)
+			}
+			return nil, &ConnectResult{Info: info, Reused: true}, nil
+		case ConflictReplace:
+			// The existing connection is closed once the new one is up
+		default:
+			return nil, nil, fmt.Errorf("invalid conflict policy '%s' (expected error, reuse or replace)", params.OnConflict)
+		}
+	}
+
+	// Check connection limit; a replaced connection frees its slot
+	active := len(m.connections)
+	if exists {
+		active--
+	}
+	if active >= m.config.MaxConnections {
+		return nil, nil, &ConnectionError{Code: CodeLimitReached, Err: fmt.Errorf("connection limit reached (%d/%d): close a connection with ssh_close or raise --max-connections", len(m.connections), m.config.MaxConnections)}
+	}
+	return existing, nil, nil
+}
+
+// establish validates the target of a connection, authenticates and starts
+// its persistent shell. The returned connection holds only the clients, the
+// executor and the host key fingerprint in its Info.
+func (m *Manager) establish(params ConnectParams) (*Connection, error) {
 	// Validate host
-	if err := m.validator.Validate(host); err != nil {
-		return err
+	if err := m.validator.Validate(params.Host, params.Port); err != nil {
+		return nil, err
+	}
+
+	if params.ProxyCommand != "" && !m.config.AllowProxyCommand {
+		return nil, fmt.Errorf("proxy commands are disabled on this server")
+	}
+
+	if params.RequestPTY && !m.config.AllowPTY {
+		return nil, fmt.Errorf("PTY mode is disabled on this server")
+	}
+
+	if params.JumpHost != "" {
+		if params.ProxyCommand != "" {
+			return nil, fmt.Errorf("a jump host cannot be combined with a proxy command")
+		}
+		if err := m.validator.Validate(params.JumpHost, params.jumpPort()); err != nil {
+			return nil, fmt.Errorf("jump host: %w", err)
+		}
 	}
 
 	// Prepare SSH config
-	// Use InsecureIgnoreHostKey for now but this should be configurable in production
-	// See: https://pkg.go.dev/golang.org/x/crypto/ssh#InsecureIgnoreHostKey
-	// #nosec G106 - Host key verification intentionally disabled for dynamic SSH connections
+	addr := net.JoinHostPort(params.Host, fmt.Sprintf("%d", params.Port))
+	hostKeyCallback, hostKeyAlgorithms, err := m.hostKeyCallback(addr, params.HostKeyFingerprint)
+	if err != nil {
+		return nil, err
+	}
+	// Record the host key once it passed verification
+	var fingerprint string
 	config := &ssh.ClientConfig{
-		User: username,
+		User: params.Username,
 		Auth: []ssh.AuthMethod{},
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
-		Timeout:         SSHDialTimeout,
+		HostKeyCallback: func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			if err := hostKeyCallback(hostname, remote, key); err != nil {
+				return err
+			}
+			fingerprint = ssh.FingerprintSHA256(key)
+			return nil
+		},
+		HostKeyAlgorithms: hostKeyAlgorithms,
+		Timeout:           m.config.DialTimeout,
 	}
-
-	// Add authentication methods
-	if password != "" {
-		config.Auth = append(config.Auth, ssh.Password(password))
+	if err := checkAlgorithms(params); err != nil {
+		return nil, err
 	}
+	config.Ciphers = params.Ciphers
+	config.MACs = params.MACs
+	config.KeyExchanges = params.KeyExchanges
 
-	if privateKeyPath != "" {
-		// Read private key from file
-		// #nosec G304 - Private key path is user-provided and validated by the validator
-		keyData, err := os.ReadFile(privateKeyPath)
+	// Add authentication methods. The agent's keys and the private key are
+	// offered by a single method, as a method that failed is not tried again.
+	var agentKeys, signers []ssh.Signer
+	if params.UseAgent {
+		agentConn, keys, err := agentSigners()
 		if err != nil {
-			return fmt.Errorf("failed to read private key file '%s': %w", privateKeyPath, err)
+			return nil, fmt.Errorf("cannot use the SSH agent: %w", err)
+		}
+		defer func() {
+			_ = agentConn.Close() // Best effort cleanup
+		}()
+		if len(keys) == 0 && params.Password == "" && len(params.keyPaths()) == 0 && params.PrivateKey == "" {
+			return nil, fmt.Errorf("cannot use the SSH agent: it holds no keys")
 		}
+		agentKeys = keys
+		signers = append(signers, keys...)
+	}
 
-		signer, err := ssh.ParsePrivateKey(keyData)
+	var keyWarnings []string
+	switch keyPaths := params.keyPaths(); {
+	case len(keyPaths) > 0 && params.PrivateKey != "":
+		return nil, fmt.Errorf("a private key and a private key path cannot both be given")
+	case len(keyPaths) > 0:
+		keys, warnings, err := loadKeyFiles(keyPaths, params.Passphrase, len(signers) > 0 || params.Password != "")
+		if err != nil {
+			return nil, err
+		}
+		signers = append(signers, keys...)
+		keyWarnings = warnings
+	case params.PrivateKey != "":
+		signer, err := decodePrivateKey(params.PrivateKey, params.Passphrase)
 		if err != nil {
-			return fmt.Errorf("failed to parse private key: %w", err)
+			return nil, err
 		}
-		config.Auth = append(config.Auth, ssh.PublicKeys(signer))
+		signers = append(signers, signer)
+	}
+
+	if len(signers) > 0 {
+		config.Auth = append(config.Auth, ssh.PublicKeys(signers...))
+	}
+	if params.Password != "" {
+		config.Auth = append(config.Auth, ssh.Password(params.Password))
 	}
 
 	if len(config.Auth) == 0 {
-		return fmt.Errorf("no authentication method provided (password or private key required)")
+		return nil, fmt.Errorf("no authentication method provided (password, private key or SSH agent required)")
+	}
+
+	conn := &Connection{keyWarnings: keyWarnings}
+	if params.JumpHost != "" {
+		if conn.jump, err = m.dialJump(params, agentKeys); err != nil {
+			return nil, err
+		}
 	}
 
 	// Connect to SSH server
-	addr := net.JoinHostPort(host, fmt.Sprintf("%d", port))
-	client, err := ssh.Dial("tcp", addr, config)
-	if err != nil {
-		return fmt.Errorf("failed to connect to %s: %w", addr, err)
+	if conn.client, err = m.dial(params, config, conn.jump); err != nil {
+		conn.close()
+		return nil, err
 	}
+	conn.Info.HostKeyFingerprint = fingerprint
 
 	// Create persistent shell executor
-	executor, err := NewShellExecutor(client)
+	conn.executor, err = NewShellExecutor(conn.client, ShellOptions{
+		CommandTimeout:       m.config.CommandTimeout,
+		IdleOutputThreshold:  m.config.IdleOutputThreshold,
+		DisableHistory:       params.DisableHistory,
+		SubshellPerCommand:   params.SubshellPerCommand,
+		RequestPTY:           params.RequestPTY,
+		Shell:                params.Shell,
+		SkipInit:             params.SkipShellInit,
+		AllowSessionCommands: m.config.AllowSessionCommands,
+		OutputFilter:         m.config.OutputFilter,
+	})
 	if err != nil {
-		_ = client.Close() // Best effort cleanup
-		return fmt.Errorf("failed to create shell executor: %w", err)
+		conn.close()
+		return nil, fmt.Errorf("failed to create shell executor: %w", err)
 	}
 
-	// Store connection
-	m.connections[id] = &Connection{
-		Info: ConnectionInfo{
-			ID:       id,
-			Host:     host,
-			Port:     port,
-			Username: username,
-			Created:  time.Now(),
-		},
-		client:   client,
-		executor: executor,
+	return conn, nil
+}
+
+// hostKeyCallback returns the host key verification for a connection to addr
+// ("host:port") and the host key algorithms to ask the server for (nil: the
+// defaults). The key must be in the server's known_hosts file if configured
+// and match the pinned fingerprint if any, so that a pinned key cannot
+// override the known_hosts file. Otherwise any host key is accepted.
+func (m *Manager) hostKeyCallback(addr, fingerprint string) (ssh.HostKeyCallback, []string, error) {
+	var callbacks []ssh.HostKeyCallback
+	var algorithms []string
+	if m.config.KnownHosts != nil {
+		callback, known, err := m.config.KnownHosts.callback(addr)
+		if err != nil {
+			return nil, nil, err
+		}
+		callbacks = append(callbacks, callback)
+		algorithms = known
+	}
+	if fingerprint != "" {
+		callbacks = append(callbacks, pinnedHostKey(fingerprint))
 	}
 
-	return nil
+	if len(callbacks) == 0 {
+		// See: https://pkg.go.dev/golang.org/x/crypto/ssh#InsecureIgnoreHostKey
+		// #nosec G106 - Host key verification is opt-in for dynamic SSH connections
+		return ssh.InsecureIgnoreHostKey(), nil, nil
+	}
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		for _, callback := range callbacks {
+			if err := callback(hostname, remote, key); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, algorithms, nil
+}
+
+// readPrivateKey reads and parses a private key file
+func readPrivateKey(path, passphrase string) (ssh.Signer, error) {
+	// #nosec G304 - Private key path is user-provided and validated by the validator
+	keyData, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read private key file '%s': %w", path, err)
+	}
+
+	signer, err := parsePrivateKey(keyData, path, passphrase)
+	zero(keyData)
+	return signer, err
+}
+
+// decodePrivateKey parses a private key given inline, either PEM-encoded or
+// as the base64 encoding of the PEM
+func decodePrivateKey(key, passphrase string) (ssh.Signer, error) {
+	keyData := []byte(strings.TrimSpace(key))
+	if !bytes.HasPrefix(keyData, []byte("-----BEGIN ")) {
+		// Line breaks in the base64 are ignored
+		encoded := strings.Join(strings.Fields(key), "")
+		decoded, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("the private key is neither PEM-encoded nor base64-encoded PEM")
+		}
+		keyData = decoded
+	}
+
+	signer, err := parsePrivateKey(keyData, "inline", passphrase)
+	zero(keyData)
+	return signer, err
+}
+
+// dial opens the SSH client connection, either directly over TCP, through
+// the jump host client or through the proxy command
+func (m *Manager) dial(params ConnectParams, config *ssh.ClientConfig, jump *ssh.Client) (*ssh.Client, error) {
+	addr := net.JoinHostPort(params.Host, fmt.Sprintf("%d", params.Port))
+
+	var conn net.Conn
+	var via string
+	switch {
+	case jump != nil:
+		var err error
+		if conn, err = jump.Dial("tcp", addr); err != nil {
+			return nil, fmt.Errorf("failed to connect to %s via jump host: %w", addr, err)
+		}
+		via = " via jump host"
+	case params.ProxyCommand != "":
+		command := expandProxyCommand(params.ProxyCommand, params.Host, params.Port, params.Username)
+		var err error
+		if conn, err = dialProxyCommand(command); err != nil {
+			return nil, err
+		}
+		via = " via proxy command"
+	default:
+		client, err := ssh.Dial("tcp", addr, config)
+		if err != nil {
+			return nil, classifyDialError(fmt.Errorf("failed to connect to %s: %w", addr, err))
+		}
+		return client, nil
+	}
+
+	// Pipes and forwarded channels have no deadlines, so bound the handshake
+	// by closing the connection when the dial timeout expires
+	timer := time.AfterFunc(config.Timeout, func() {
+		_ = conn.Close() // Best effort cleanup
+	})
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
+	if !timer.Stop() && err == nil {
+		_ = sshConn.Close() // Best effort cleanup
+		return nil, &ConnectionError{Code: CodeTimeout, Err: fmt.Errorf("failed to connect to %s%s: handshake timed out", addr, via)}
+	}
+	if err != nil {
+		_ = conn.Close() // Best effort cleanup
+		return nil, classifyDialError(fmt.Errorf("failed to connect to %s%s: %w", addr, via, err))
+	}
+
+	return ssh.NewClient(sshConn, chans, reqs), nil
 }
 
 // Execute runs a command on an existing connection
 func (m *Manager) Execute(id, command string) (*CommandResult, error) {
+	return m.ExecuteWithOptions(id, command, ExecuteOptions{})
+}
+
+// ExecuteWithOptions runs a command on an existing connection with per-call
+// execution options
+func (m *Manager) ExecuteWithOptions(id, command string, opts ExecuteOptions) (*CommandResult, error) {
+	var request *activeRequest
+	if opts.RequestID != "" {
+		var err error
+		if request, err = m.requests.add(opts.RequestID, id); err != nil {
+			return nil, err
+		}
+		defer m.requests.remove(opts.RequestID)
+		opts.Cancelled = request.cancelled.Load
+	}
+
+	var result *CommandResult
+	reconnected, err := m.runWithReconnect(id, func(executor *ShellExecutor) error {
+		var err error
+		result, err = executor.ExecuteWithOptions(command, opts)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result.Reconnected = reconnected
+	result.Cancelled = request != nil && request.cancelled.Load()
+	return result, nil
+}
+
+// ExecuteToFile runs a command on an existing connection with its stdout
+// redirected to a remote file, returning the result and the file size. The
+// command is interrupted when ctx is cancelled.
+func (m *Manager) ExecuteToFile(ctx context.Context, id, command, remotePath string) (*CommandResult, int64, error) {
+	var result *CommandResult
+	var size int64
+	reconnected, err := m.runWithReconnect(id, func(executor *ShellExecutor) error {
+		var err error
+		result, size, err = executor.ExecuteToFile(ctx, command, remotePath)
+		return err
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	result.Reconnected = reconnected
+	return result, size, nil
+}
+
+// ExecuteTee runs a command on an existing connection, saving its full stdout
+// to a remote file and returning at most previewBytes of it. The command is
+// interrupted when ctx is cancelled.
+func (m *Manager) ExecuteTee(ctx context.Context, id, command, remotePath string, previewBytes int) (*CommandResult, int64, error) {
+	var result *CommandResult
+	var size int64
+	reconnected, err := m.runWithReconnect(id, func(executor *ShellExecutor) error {
+		var err error
+		result, size, err = executor.ExecuteTee(ctx, command, remotePath, previewBytes)
+		return err
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	result.Reconnected = reconnected
+	return result, size, nil
+}
+
+// ExecuteSudo runs a command through sudo on an existing connection
+func (m *Manager) ExecuteSudo(id, command string, opts SudoOptions) (*SudoResult, error) {
+	var result *SudoResult
+	reconnected, err := m.runWithReconnect(id, func(executor *ShellExecutor) error {
+		var err error
+		result, err = executor.ExecuteSudo(command, opts)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result.Reconnected = reconnected
+	return result, nil
+}
+
+// ShellSettings describes the persistent shell of a connection
+type ShellSettings struct {
+	HistoryDisabled     bool
+	SubshellPerCommand  bool
+	PTY                 bool
+	CommandPrefix       string
+	HistFile            string
+	HistSize            string
+	CommandTimeout      time.Duration
+	IdleOutputThreshold time.Duration
+}
+
+// ShellSettings reports the shell settings of a connection, including the
+// live HISTFILE and HISTSIZE values. If disableHistory is set, history is
+// disabled first.
+func (m *Manager) ShellSettings(id string, disableHistory bool) (*ShellSettings, error) {
 	m.mu.RLock()
 	conn, exists := m.connections[id]
 	m.mu.RUnlock()
 
 	if !exists {
-		return nil, fmt.Errorf("connection '%s' not found", id)
+		return nil, m.connectionNotFound(id)
+	}
+
+	if disableHistory {
+		if err := conn.executor.DisableHistory(); err != nil {
+			return nil, err
+		}
+	}
+
+	result, err := conn.executor.ExecuteWithOptions(`printf 'HISTFILE=%s\nHISTSIZE=%s\n' "${HISTFILE-}" "${HISTSIZE-}"`, ExecuteOptions{inShell: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query shell settings: %w", err)
 	}
 
-	return conn.executor.Execute(command)
+	options := conn.executor.Options()
+	settings := &ShellSettings{
+		HistoryDisabled:     conn.executor.HistoryDisabled(),
+		SubshellPerCommand:  options.SubshellPerCommand,
+		PTY:                 options.RequestPTY,
+		CommandPrefix:       conn.executor.Prefix(),
+		CommandTimeout:      options.CommandTimeout,
+		IdleOutputThreshold: options.IdleOutputThreshold,
+	}
+	for _, line := range strings.Split(result.Stdout, "\n") {
+		if value, ok := strings.CutPrefix(line, "HISTFILE="); ok {
+			settings.HistFile = value
+		} else if value, ok := strings.CutPrefix(line, "HISTSIZE="); ok {
+			settings.HistSize = value
+		}
+	}
+
+	return settings, nil
 }
 
 // Close closes an SSH connection
 func (m *Manager) Close(id string) error {
 	m.mu.Lock()
-	defer m.mu.Unlock()
-
 	conn, exists := m.connections[id]
 	if !exists {
-		return fmt.Errorf("connection '%s' not found", id)
-	}
-
-	// Close executor and client
-	if conn.executor != nil {
-		_ = conn.executor.Close() // Best effort cleanup
-	}
-	if conn.client != nil {
-		_ = conn.client.Close() // Best effort cleanup
+		m.mu.Unlock()
+		return m.connectionNotFound(id)
 	}
 
 	// Remove from map
 	delete(m.connections, id)
+	m.updateIndex(id)
+	m.mu.Unlock()
 
+	// Closing waits for a running command, without blocking the others
+	conn.close()
 	return nil
 }
 
@@ -176,26 +1060,108 @@ func (m *Manager) List() []ConnectionInfo {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
+	now := time.Now()
 	infos := make([]ConnectionInfo, 0, len(m.connections))
 	for _, conn := range m.connections {
-		infos = append(infos, conn.Info)
+		infos = append(infos, m.info(conn, now))
 	}
 
 	return infos
 }
 
-// CloseAll closes all active connections
+// Info returns information about a connection
+func (m *Manager) Info(id string) (ConnectionInfo, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	conn, exists := m.connections[id]
+	if !exists {
+		return ConnectionInfo{}, m.connectionNotFound(id)
+	}
+	return m.info(conn, time.Now()), nil
+}
+
+// info returns the current information about conn. The caller must hold m.mu.
+func (m *Manager) info(conn *Connection, now time.Time) ConnectionInfo {
+	info := conn.Info
+	info.Reconnect = conn.breaker.status(now)
+	info.CommandPrefix = conn.executor.Prefix()
+	info.TimeBudget = conn.budget.status(m.config.TimeBudget)
+	info.LastUsed = time.Unix(0, conn.lastUsed.Load())
+	return info
+}
+
+// Config returns the effective manager configuration
+func (m *Manager) Config() ManagerConfig {
+	return m.config
+}
+
+// AllowedHostPatterns returns the number of allowed host patterns
+func (m *Manager) AllowedHostPatterns() int {
+	return m.validator.PatternCount()
+}
+
+// LogLevel returns the logging verbosity of a connection, empty if it uses
+// the server's or does not exist
+func (m *Manager) LogLevel(id string) string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if conn, exists := m.connections[id]; exists {
+		return conn.Info.LogLevel
+	}
+	return ""
+}
+
+// ExecStats returns the server-wide command concurrency
+func (m *Manager) ExecStats() ExecStats {
+	return m.execs.stats()
+}
+
+// Count returns the number of active connections
+func (m *Manager) Count() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return len(m.connections)
+}
+
+// CloseAll stops the idle reaper and closes all active connections
 func (m *Manager) CloseAll() {
+	m.reaper.stop()
+
+	for _, conn := range m.removeAll() {
+		conn.close()
+	}
+}
+
+// Shutdown stops accepting new commands, waits up to grace for the running
+// ones to finish and then closes all connections. Commands still running
+// once the grace period expired are aborted. It reports whether every
+// running command finished within the grace period.
+func (m *Manager) Shutdown(grace time.Duration) bool {
+	if m.execs.drain(grace) {
+		m.CloseAll()
+		return true
+	}
+
+	m.reaper.stop()
+	for _, conn := range m.removeAll() {
+		conn.abort()
+	}
+	return false
+}
+
+// removeAll removes every connection and returns them, to be closed by the
+// caller once the manager lock is released
+func (m *Manager) removeAll() []*Connection {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	removed := make([]*Connection, 0, len(m.connections))
 	for id, conn := range m.connections {
-		if conn.executor != nil {
-			_ = conn.executor.Close() // Best effort cleanup
-		}
-		if conn.client != nil {
-			_ = conn.client.Close() // Best effort cleanup
-		}
+		removed = append(removed, conn)
 		delete(m.connections, id)
 	}
+	return removed
 }
@@ -0,0 +1,337 @@
+package ssh
+
+import (
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestManager returns a manager that allows localhost
+func newTestManager(t *testing.T, opts ...ManagerOption) *Manager {
+	t.Helper()
+
+	validator, err := NewHostValidator("localhost,127.0.0.1")
+	if err != nil {
+		t.Fatalf("failed to create validator: %v", err)
+	}
+	return NewManager(validator, opts...)
+}
+
+func TestManager_ConnectConflict(t *testing.T) {
+	manager := newTestManager(t)
+	manager.connections["web"] = &Connection{
+		Info: ConnectionInfo{
+			ID:       "web",
+			Host:     "localhost",
+			Port:     22,
+			Username: "deploy",
+			Created:  time.Now(),
+		},
+	}
+
+	tests := []struct {
+		name          string
+		params        ConnectParams
+		expectReused  bool
+		errorContains string
+	}{
+		{
+			name:          "default policy errors",
+			params:        ConnectParams{ID: "web", Host: "localhost", Port: 22, Username: "deploy"},
+			errorContains: "already exists",
+		},
+		{
+			name:         "reuse with matching parameters",
+			params:       ConnectParams{ID: "web", Host: "localhost", Port: 22, Username: "deploy", OnConflict: ConflictReuse},
+			expectReused: true,
+		},
+		{
+			name:          "reuse with different user",
+			params:        ConnectParams{ID: "web", Host: "localhost", Port: 22, Username: "root", OnConflict: ConflictReuse},
+			errorContains: "cannot reuse",
+		},
+		{
+			name:          "invalid policy",
+			params:        ConnectParams{ID: "web", Host: "localhost", Port: 22, Username: "deploy", OnConflict: "merge"},
+			errorContains: "invalid conflict policy",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := manager.Connect(tt.params)
+			if tt.errorContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errorContains) {
+					t.Errorf("expected error containing %q but got %v", tt.errorContains, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if result.Reused != tt.expectReused {
+				t.Errorf("expected reused=%v, got %v", tt.expectReused, result.Reused)
+			}
+		})
+	}
+}
+
+func TestManager_ShellSettingsHistory(t *testing.T) {
+	server := newTestServer(t)
+	manager := newTestManager(t)
+
+	params := server.params("quiet")
+	params.DisableHistory = true
+	if _, err := manager.Connect(params); err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	connectTestServer(t, manager, server, "default")
+
+	settings, err := manager.ShellSettings("quiet", false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !settings.HistoryDisabled || settings.HistFile != "" || settings.HistSize != "0" {
+		t.Errorf("expected history to be disabled, got %+v", settings)
+	}
+
+	if _, err := manager.Execute("default", "export HISTFILE=/tmp/history HISTSIZE=500"); err != nil {
+		t.Fatalf("failed to execute: %v", err)
+	}
+	settings, err = manager.ShellSettings("default", false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if settings.HistoryDisabled || settings.HistFile != "/tmp/history" {
+		t.Errorf("expected history to be enabled, got %+v", settings)
+	}
+
+	settings, err = manager.ShellSettings("default", true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !settings.HistoryDisabled || settings.HistFile != "" || settings.HistSize != "0" {
+		t.Errorf("expected history to be disabled at runtime, got %+v", settings)
+	}
+
+	_ = manager.Close("quiet")
+}
+
+func TestManager_Shutdown(t *testing.T) {
+	server := newTestServer(t)
+	manager := newTestManager(t)
+	connectTestServer(t, manager, server, "default")
+
+	type outcome struct {
+		result *CommandResult
+		err    error
+	}
+	running := make(chan outcome, 1)
+	go func() {
+		result, err := manager.Execute("default", "sleep 0.3; echo deployed")
+		running <- outcome{result, err}
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for manager.ExecStats().Running != 1 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	if !manager.Shutdown(5 * time.Second) {
+		t.Errorf("expected the running command to finish within the grace period")
+	}
+
+	got := <-running
+	if got.err != nil || got.result.Stdout != "deployed" {
+		t.Errorf("expected the running command to complete, got %+v, %v", got.result, got.err)
+	}
+	if manager.Count() != 0 {
+		t.Errorf("expected all connections to be closed, got %d", manager.Count())
+	}
+	if _, err := manager.Execute("default", "echo late"); err == nil {
+		t.Errorf("expected commands after shutdown to fail")
+	}
+}
+
+func TestManager_ShutdownAborts(t *testing.T) {
+	server := newTestServer(t)
+	manager := newTestManager(t)
+	connectTestServer(t, manager, server, "default")
+
+	running := make(chan error, 1)
+	go func() {
+		_, err := manager.Execute("default", "sleep 3")
+		running <- err
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for manager.ExecStats().Running != 1 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	started := time.Now()
+	if manager.Shutdown(200 * time.Millisecond) {
+		t.Errorf("expected the running command not to finish within the grace period")
+	}
+	if elapsed := time.Since(started); elapsed > time.Second {
+		t.Errorf("expected the running command to be aborted after the grace period, took %s", elapsed)
+	}
+	if err := <-running; err == nil {
+		t.Errorf("expected the aborted command to fail")
+	}
+	if manager.Count() != 0 {
+		t.Errorf("expected all connections to be closed, got %d", manager.Count())
+	}
+}
+
+func TestManager_CloseBusy(t *testing.T) {
+	server := newTestServer(t)
+	manager := newTestManager(t)
+	t.Cleanup(manager.CloseAll)
+	connectTestServer(t, manager, server, "a")
+	connectTestServer(t, manager, server, "b")
+
+	running := make(chan error, 1)
+	go func() {
+		_, err := manager.Execute("a", "sleep 1")
+		running <- err
+	}()
+	deadline := time.Now().Add(time.Second)
+	for manager.ExecStats().Running != 1 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	// Closing "a" waits for its command, but not with the manager locked
+	closed := make(chan error, 1)
+	go func() {
+		closed <- manager.Close("a")
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	started := time.Now()
+	if _, err := manager.Execute("b", "true"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if elapsed := time.Since(started); elapsed > 500*time.Millisecond {
+		t.Errorf("expected other connections to stay usable while one is closed, took %s", elapsed)
+	}
+	if err := <-closed; err != nil {
+		t.Errorf("failed to close: %v", err)
+	}
+	if err := <-running; err != nil {
+		t.Errorf("expected the running command to complete, got %v", err)
+	}
+}
+
+func TestManager_LastUsed(t *testing.T) {
+	server := newTestServer(t)
+	manager := newTestManager(t)
+	connectTestServer(t, manager, server, "default")
+
+	lastUsed := func() time.Time {
+		t.Helper()
+		infos := manager.List()
+		if len(infos) != 1 {
+			t.Fatalf("expected 1 connection, got %d", len(infos))
+		}
+		return infos[0].LastUsed
+	}
+
+	created := lastUsed()
+	if !created.Equal(manager.List()[0].Created) {
+		t.Errorf("expected last used to be the creation time before any command, got %v", created)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	before := time.Now()
+	if _, err := manager.Execute("default", "true"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	used := lastUsed()
+	if used.Before(before) {
+		t.Errorf("expected last used to advance past %v after a command, got %v", before, used)
+	}
+
+	if _, err := manager.Execute("default", "true"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if again := lastUsed(); !again.After(used) {
+		t.Errorf("expected last used to advance again, got %v then %v", used, again)
+	}
+}
+
+func TestManager_Tags(t *testing.T) {
+	server := newTestServer(t)
+	manager := newTestManager(t)
+	t.Cleanup(manager.CloseAll)
+
+	hosts := map[string]map[string]string{
+		"web1": {"env": "prod", "role": "web"},
+		"web2": {"env": "staging", "role": "web"},
+		"db1":  {"env": "prod", "role": "db"},
+		"tmp":  nil,
+	}
+	for id, tags := range hosts {
+		params := server.params(id)
+		params.Tags = tags
+		if _, err := manager.Connect(params); err != nil {
+			t.Fatalf("failed to connect %s: %v", id, err)
+		}
+	}
+	// The connection keeps its own copy of the tags
+	hosts["web1"]["env"] = "changed"
+
+	tests := []struct {
+		filter map[string]string
+		want   []string
+	}{
+		{filter: nil, want: []string{"db1", "tmp", "web1", "web2"}},
+		{filter: map[string]string{"env": "prod"}, want: []string{"db1", "web1"}},
+		{filter: map[string]string{"env": "prod", "role": "web"}, want: []string{"web1"}},
+		{filter: map[string]string{"role": ""}, want: nil},
+		{filter: map[string]string{"owner": "alice"}, want: nil},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, info := range manager.List() {
+			if info.HasTags(tt.filter) {
+				got = append(got, info.ID)
+			}
+		}
+		sort.Strings(got)
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("filter %v: got %v, want %v", tt.filter, got, tt.want)
+		}
+	}
+}
+
+func TestManager_MaxConnections(t *testing.T) {
+	server := newTestServer(t)
+	manager := newTestManager(t, WithMaxConnections(1))
+	t.Cleanup(manager.CloseAll)
+
+	if _, err := manager.Connect(server.params("first")); err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+
+	_, err := manager.Connect(server.params("second"))
+	if ErrorCodeOf(err) != CodeLimitReached || !strings.Contains(err.Error(), "(1/1)") {
+		t.Fatalf("expected the connection limit to be reached, got %v", err)
+	}
+
+	// Replacing the open connection does not need another slot
+	params := server.params("first")
+	params.OnConflict = ConflictReplace
+	if _, err := manager.Connect(params); err != nil {
+		t.Errorf("expected replacing the connection to succeed, got %v", err)
+	}
+
+	// Closing it frees the slot
+	if err := manager.Close("first"); err != nil {
+		t.Fatalf("failed to close: %v", err)
+	}
+	if _, err := manager.Connect(server.params("second")); err != nil {
+		t.Errorf("expected a connection after closing one, got %v", err)
+	}
+}
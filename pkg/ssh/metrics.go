@@ -0,0 +1,83 @@
+package ssh
+
+import (
+	"sync"
+	"time"
+)
+
+// CommandDurationBuckets are the upper bounds of the command duration
+// histogram, in seconds
+var CommandDurationBuckets = []float64{0.1, 0.5, 1, 5, 10, 30, 60, 300}
+
+// Metrics counts the commands run by a manager since it was created
+type Metrics struct {
+	// ActiveConnections is the number of open connections
+	ActiveConnections int
+
+	// CommandsExecuted counts every command run, CommandsFailed those that
+	// returned an error rather than an exit code, and CommandsTimedOut those
+	// among them that timed out
+	CommandsExecuted uint64
+	CommandsFailed   uint64
+	CommandsTimedOut uint64
+
+	// DurationCounts holds the number of commands that took at most each of
+	// CommandDurationBuckets, cumulatively; DurationSum is the total time
+	DurationCounts []uint64
+	DurationSum    time.Duration
+}
+
+// commandMetrics accumulates Metrics
+type commandMetrics struct {
+	mu       sync.Mutex
+	executed uint64
+	failed   uint64
+	timedOut uint64
+	buckets  []uint64
+	sum      time.Duration
+}
+
+// record counts a command that took d and failed with err, if not nil
+func (c *commandMetrics) record(d time.Duration, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.buckets == nil {
+		c.buckets = make([]uint64, len(CommandDurationBuckets))
+	}
+	c.executed++
+	if err != nil {
+		c.failed++
+		if ErrorCodeOf(err) == CodeTimeout {
+			c.timedOut++
+		}
+	}
+	for i, bound := range CommandDurationBuckets {
+		if d.Seconds() <= bound {
+			c.buckets[i]++
+		}
+	}
+	c.sum += d
+}
+
+// Metrics returns the command counts and the number of open connections
+func (m *Manager) Metrics() Metrics {
+	m.mu.RLock()
+	active := len(m.connections)
+	m.mu.RUnlock()
+
+	c := &m.metrics
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	counts := make([]uint64, len(CommandDurationBuckets))
+	copy(counts, c.buckets)
+	return Metrics{
+		ActiveConnections: active,
+		CommandsExecuted:  c.executed,
+		CommandsFailed:    c.failed,
+		CommandsTimedOut:  c.timedOut,
+		DurationCounts:    counts,
+		DurationSum:       c.sum,
+	}
+}
@@ -0,0 +1,46 @@
+package ssh
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMetrics(t *testing.T) {
+	server := newTestServer(t)
+	manager := newTestManager(t)
+
+	if metrics := manager.Metrics(); metrics.ActiveConnections != 0 || metrics.CommandsExecuted != 0 {
+		t.Fatalf("expected no connections nor commands, got %+v", metrics)
+	}
+
+	connectTestServer(t, manager, server, "default")
+	if _, err := manager.Execute("default", "exit 3"); err == nil {
+		t.Fatalf("expected the session command to be rejected")
+	}
+	if _, err := manager.Execute("default", "false"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := manager.ExecuteWithOptions("default", "sleep 2", ExecuteOptions{Timeout: 200 * time.Millisecond}); err == nil {
+		t.Fatalf("expected a timeout")
+	}
+
+	metrics := manager.Metrics()
+	if metrics.ActiveConnections != 1 {
+		t.Errorf("expected 1 active connection, got %d", metrics.ActiveConnections)
+	}
+	// The rejected command counts as a failure; a non-zero exit code does not
+	if metrics.CommandsExecuted != 3 || metrics.CommandsFailed != 2 || metrics.CommandsTimedOut != 1 {
+		t.Errorf("unexpected command counts: %+v", metrics)
+	}
+	if metrics.DurationSum < 200*time.Millisecond {
+		t.Errorf("expected the timed out command to be timed, got %s", metrics.DurationSum)
+	}
+	// Buckets are cumulative: all commands took at most 5s, the fast ones at
+	// most 0.1s
+	if last := metrics.DurationCounts[len(metrics.DurationCounts)-1]; last != 3 {
+		t.Errorf("expected every command in the last bucket, got %v", metrics.DurationCounts)
+	}
+	if metrics.DurationCounts[0] != 2 {
+		t.Errorf("expected 2 commands under 0.1s, got %v", metrics.DurationCounts)
+	}
+}
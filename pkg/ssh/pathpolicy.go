@@ -0,0 +1,126 @@
+package ssh
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/gobwas/glob"
+)
+
+// pathPattern is a single SFTP path policy entry
+type pathPattern struct {
+	raw string
+
+	// glob is nil for plain directory prefixes
+	glob glob.Glob
+}
+
+// matches reports whether p or one of its parent directories matches the
+// pattern, so that a pattern naming a directory covers everything beneath it
+func (pp pathPattern) matches(p string) bool {
+	for {
+		if pp.glob != nil && pp.glob.Match(p) || pp.glob == nil && p == pp.raw {
+			return true
+		}
+		if p == "/" {
+			return false
+		}
+		p = path.Dir(p)
+	}
+}
+
+// PathPolicy restricts the remote paths the SFTP tools may access. A path is
+// allowed if it matches an allowed pattern (or no allowed patterns are set)
+// and no denied pattern. Deny takes precedence.
+type PathPolicy struct {
+	allowed []pathPattern
+	denied  []pathPattern
+}
+
+// NewPathPolicy creates a path policy from comma-separated lists of absolute
+// path patterns. A pattern without glob characters matches that path and
+// everything beneath it; otherwise it is a glob in which '*' does not cross
+// '/' and '**' does, e.g. /srv/app/* or /home/*/logs.
+func NewPathPolicy(allowedPaths, deniedPaths string) (*PathPolicy, error) {
+	allowed, err := parsePathPatterns(allowedPaths)
+	if err != nil {
+		return nil, err
+	}
+	denied, err := parsePathPatterns(deniedPaths)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PathPolicy{
+		allowed: allowed,
+		denied:  denied,
+	}, nil
+}
+
+// parsePathPatterns parses a comma-separated list of path patterns
+func parsePathPatterns(list string) ([]pathPattern, error) {
+	var patterns []pathPattern
+	for _, raw := range strings.Split(list, ",") {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		if !strings.HasPrefix(raw, "/") {
+			return nil, fmt.Errorf("invalid path pattern '%s': must be absolute", raw)
+		}
+
+		if !strings.ContainsAny(raw, "*?[{") {
+			patterns = append(patterns, pathPattern{raw: path.Clean(raw)})
+			continue
+		}
+
+		compiled, err := glob.Compile(raw, '/')
+		if err != nil {
+			return nil, fmt.Errorf("invalid path pattern '%s': %w", raw, err)
+		}
+		patterns = append(patterns, pathPattern{raw: raw, glob: compiled})
+	}
+	return patterns, nil
+}
+
+// Check returns an error unless the resolved absolute path is permitted. A
+// nil policy permits every path.
+func (p *PathPolicy) Check(resolved string) error {
+	if p == nil {
+		return nil
+	}
+
+	resolved = path.Clean(resolved)
+	for _, pattern := range p.denied {
+		if pattern.matches(resolved) {
+			return fmt.Errorf("path policy violation: '%s' is denied by '%s'", resolved, pattern.raw)
+		}
+	}
+
+	if len(p.allowed) == 0 {
+		return nil
+	}
+	for _, pattern := range p.allowed {
+		if pattern.matches(resolved) {
+			return nil
+		}
+	}
+	return fmt.Errorf("path policy violation: '%s' is not under an allowed path", resolved)
+}
+
+// AllowedCount returns the number of allowed path patterns
+func (p *PathPolicy) AllowedCount() int {
+	if p == nil {
+		return 0
+	}
+	return len(p.allowed)
+}
+
+// DeniedCount returns the number of denied path patterns
+func (p *PathPolicy) DeniedCount() int {
+	if p == nil {
+		return 0
+	}
+	return len(p.denied)
+}
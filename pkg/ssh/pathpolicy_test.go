@@ -0,0 +1,155 @@
+package ssh
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPathPolicy_Check(t *testing.T) {
+	tests := []struct {
+		name        string
+		allowed     string
+		denied      string
+		path        string
+		expectError bool
+	}{
+		{
+			name:        "no policy",
+			path:        "/etc/shadow",
+			expectError: false,
+		},
+		{
+			name:        "prefix allows subtree",
+			allowed:     "/srv/app",
+			path:        "/srv/app/config/app.yml",
+			expectError: false,
+		},
+		{
+			name:        "prefix allows itself",
+			allowed:     "/srv/app",
+			path:        "/srv/app",
+			expectError: false,
+		},
+		{
+			name:        "prefix does not match sibling",
+			allowed:     "/srv/app",
+			path:        "/srv/application/secret",
+			expectError: true,
+		},
+		{
+			name:        "glob allows entries below",
+			allowed:     "/srv/app/*",
+			path:        "/srv/app/logs/today.log",
+			expectError: false,
+		},
+		{
+			name:        "glob does not match parent",
+			allowed:     "/srv/app/*",
+			path:        "/srv/app",
+			expectError: true,
+		},
+		{
+			name:        "glob star does not cross slash",
+			allowed:     "/home/*/logs",
+			path:        "/home/alice/private/logs",
+			expectError: true,
+		},
+		{
+			name:        "outside allowed paths",
+			allowed:     "/srv/app,/var/log",
+			path:        "/etc/passwd",
+			expectError: true,
+		},
+		{
+			name:        "deny takes precedence",
+			allowed:     "/srv/app",
+			denied:      "/srv/app/secrets",
+			path:        "/srv/app/secrets/key.pem",
+			expectError: true,
+		},
+		{
+			name:        "deny glob",
+			denied:      "/**/*.pem",
+			path:        "/srv/app/tls/server.pem",
+			expectError: true,
+		},
+		{
+			name:        "dot dot is cleaned",
+			allowed:     "/srv/app",
+			path:        "/srv/app/../../etc/passwd",
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy, err := NewPathPolicy(tt.allowed, tt.denied)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			err = policy.Check(tt.path)
+			if tt.expectError && err == nil {
+				t.Errorf("expected error but got nil")
+			}
+			if !tt.expectError && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestNewPathPolicy_Invalid(t *testing.T) {
+	if _, err := NewPathPolicy("relative/path", ""); err == nil {
+		t.Errorf("expected error for a relative pattern")
+	}
+	if _, err := NewPathPolicy("", "/srv/[app"); err == nil {
+		t.Errorf("expected error for an invalid glob")
+	}
+}
+
+func TestReadFiles_PathPolicy(t *testing.T) {
+	dir := t.TempDir()
+	allowed := filepath.Join(dir, "allowed")
+	if err := os.MkdirAll(filepath.Join(allowed, "secret"), 0o700); err != nil {
+		t.Fatalf("failed to create directories: %v", err)
+	}
+	for _, name := range []string{"app.conf", "secret/key"} {
+		if err := os.WriteFile(filepath.Join(allowed, name), []byte(name), 0o600); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "outside"), []byte("outside"), 0o600); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	policy, err := NewPathPolicy(allowed, filepath.Join(allowed, "secret"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	server := newTestServer(t)
+	manager := newTestManager(t, WithPathPolicy(policy))
+	connectTestServer(t, manager, server, "default")
+
+	paths := []string{
+		filepath.Join(allowed, "app.conf"),
+		filepath.Join(allowed, "secret/key"),
+		filepath.Join(dir, "outside"),
+		filepath.Join(allowed, "../outside"),
+	}
+	results, err := manager.ReadFiles("default", paths, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if results[0].Error != "" || string(results[0].Content) != "app.conf" {
+		t.Errorf("expected allowed file to be read, got %+v", results[0])
+	}
+	for _, result := range results[1:] {
+		if !strings.Contains(result.Error, "path policy violation") {
+			t.Errorf("expected a path policy violation for %s, got %+v", result.Path, result)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package ssh
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// listeningPortsCommand lists listening TCP sockets with ss, falling back to
+// netstat. The first output line names the tool that produced the listing.
+const listeningPortsCommand = `if command -v ss >/dev/null 2>&1; then echo 'source=ss'; ss -tlnp; ` +
+	`elif command -v netstat >/dev/null 2>&1; then echo 'source=netstat'; netstat -tlnp 2>/dev/null || netstat -an; ` +
+	`else echo 'source=none'; fi`
+
+// ListeningPort is a socket listening for TCP connections on the remote host
+type ListeningPort struct {
+	Proto        string
+	LocalAddress string
+	Port         int
+
+	// PID and Program are empty when the remote user may not see the owning
+	// process, which usually requires root
+	PID     int
+	Program string
+}
+
+// ListeningPorts returns the TCP ports listening on the remote host along
+// with the tool ("ss" or "netstat") the listing came from
+func (m *Manager) ListeningPorts(id string) ([]ListeningPort, string, error) {
+	result, err := m.Execute(id, listeningPortsCommand)
+	if err != nil {
+		return nil, "", err
+	}
+
+	source, listing, _ := strings.Cut(result.Stdout, "\n")
+	source = strings.TrimPrefix(strings.TrimSpace(source), "source=")
+	if result.ExitCode != 0 {
+		return nil, source, fmt.Errorf("%s exited with code %d: %s", source, result.ExitCode, result.Stderr)
+	}
+
+	switch source {
+	case "ss":
+		return parseSSListeners(listing), source, nil
+	case "netstat":
+		return parseNetstatListeners(listing), source, nil
+	default:
+		return nil, source, fmt.Errorf("neither ss nor netstat is available on the remote host")
+	}
+}
+
+// parseSSListeners parses the output of "ss -tlnp":
+//
+//	State  Recv-Q Send-Q Local Address:Port Peer Address:Port Process
+//	LISTEN 0      128    0.0.0.0:22         0.0.0.0:*         users:(("sshd",pid=812,fd=3))
+func parseSSListeners(output string) []ListeningPort {
+	var ports []ListeningPort
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 5 || fields[0] != "LISTEN" {
+			continue
+		}
+
+		address, port, ok := splitListenAddress(fields[3])
+		if !ok {
+			continue
+		}
+
+		entry := ListeningPort{
+			Proto:        listenProto("tcp", address),
+			LocalAddress: address,
+			Port:         port,
+		}
+		if len(fields) > 5 {
+			entry.PID, entry.Program = parseSSProcess(strings.Join(fields[5:], " "))
+		}
+		ports = append(ports, entry)
+	}
+	return ports
+}
+
+// parseSSProcess extracts the first program and pid from an ss process
+// column such as users:(("nginx",pid=1201,fd=6),("nginx",pid=1202,fd=6))
+func parseSSProcess(process string) (int, string) {
+	var pid int
+	var program string
+
+	if _, rest, found := strings.Cut(process, `(("`); found {
+		program, _, _ = strings.Cut(rest, `"`)
+	}
+	if _, rest, found := strings.Cut(process, "pid="); found {
+		end := strings.IndexFunc(rest, func(r rune) bool { return r < '0' || r > '9' })
+		if end < 0 {
+			end = len(rest)
+		}
+		pid, _ = strconv.Atoi(rest[:end])
+	}
+	return pid, program
+}
+
+// parseNetstatListeners parses the output of "netstat -tlnp" on Linux
+// (PID/Program name in the last column) and "netstat -an" elsewhere, where
+// the port is separated from the address by a dot
+func parseNetstatListeners(output string) []ListeningPort {
+	var ports []ListeningPort
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 6 || !strings.HasPrefix(fields[0], "tcp") {
+			continue
+		}
+
+		state := -1
+		for i, field := range fields {
+			if field == "LISTEN" {
+				state = i
+				break
+			}
+		}
+		if state < 0 {
+			continue
+		}
+
+		address, port, ok := splitListenAddress(fields[3])
+		if !ok {
+			continue
+		}
+
+		proto := fields[0]
+		if proto == "tcp" || proto == "tcp4" {
+			proto = listenProto("tcp", address)
+		}
+
+		entry := ListeningPort{
+			Proto:        proto,
+			LocalAddress: address,
+			Port:         port,
+		}
+		if state+1 < len(fields) {
+			// Program names may contain spaces, e.g. "1201/nginx: master"
+			if pid, program, found := strings.Cut(strings.Join(fields[state+1:], " "), "/"); found {
+				entry.PID, _ = strconv.Atoi(pid)
+				entry.Program = program
+			}
+		}
+		ports = append(ports, entry)
+	}
+	return ports
+}
+
+// splitListenAddress splits a local address such as 0.0.0.0:22, [::]:80,
+// *:443, 127.0.0.53%lo:53 or the BSD form *.22 / 127.0.0.1.5432 into the
+// address and the port
+func splitListenAddress(local string) (string, int, bool) {
+	separator := strings.LastIndexByte(local, ':')
+	if separator < 0 {
+		separator = strings.LastIndexByte(local, '.')
+	}
+	if separator <= 0 {
+		return "", 0, false
+	}
+
+	port, err := strconv.Atoi(local[separator+1:])
+	if err != nil {
+		return "", 0, false
+	}
+
+	address := strings.TrimSuffix(strings.TrimPrefix(local[:separator], "["), "]")
+	return address, port, true
+}
+
+// listenProto returns proto with a "6" suffix for IPv6 addresses
+func listenProto(proto, address string) string {
+	if strings.Contains(address, ":") {
+		return proto + "6"
+	}
+	return proto
+}
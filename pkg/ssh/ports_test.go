@@ -0,0 +1,67 @@
+package ssh
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseSSListeners(t *testing.T) {
+	output := `State  Recv-Q Send-Q Local Address:Port  Peer Address:Port Process
+LISTEN 0      4096   127.0.0.53%lo:53       0.0.0.0:*     users:(("systemd-resolve",pid=601,fd=14))
+LISTEN 0      128    0.0.0.0:22           0.0.0.0:*     users:(("sshd",pid=812,fd=3))
+LISTEN 0      511    *:80                 *:*
+LISTEN 0      128    [::]:22              [::]:*        users:(("sshd",pid=812,fd=4))`
+
+	expected := []ListeningPort{
+		{Proto: "tcp", LocalAddress: "127.0.0.53%lo", Port: 53, PID: 601, Program: "systemd-resolve"},
+		{Proto: "tcp", LocalAddress: "0.0.0.0", Port: 22, PID: 812, Program: "sshd"},
+		{Proto: "tcp", LocalAddress: "*", Port: 80},
+		{Proto: "tcp6", LocalAddress: "::", Port: 22, PID: 812, Program: "sshd"},
+	}
+
+	if got := parseSSListeners(output); !reflect.DeepEqual(got, expected) {
+		t.Errorf("parseSSListeners() = %+v, want %+v", got, expected)
+	}
+}
+
+func TestParseNetstatListeners(t *testing.T) {
+	tests := []struct {
+		name     string
+		output   string
+		expected []ListeningPort
+	}{
+		{
+			name: "linux",
+			output: `Active Internet connections (only servers)
+Proto Recv-Q Send-Q Local Address           Foreign Address         State       PID/Program name
+tcp        0      0 0.0.0.0:22              0.0.0.0:*               LISTEN      812/sshd
+tcp        0      0 127.0.0.1:5432          0.0.0.0:*               LISTEN      -
+tcp6       0      0 :::80                   :::*                    LISTEN      1201/nginx: master`,
+			expected: []ListeningPort{
+				{Proto: "tcp", LocalAddress: "0.0.0.0", Port: 22, PID: 812, Program: "sshd"},
+				{Proto: "tcp", LocalAddress: "127.0.0.1", Port: 5432},
+				{Proto: "tcp6", LocalAddress: "::", Port: 80, PID: 1201, Program: "nginx: master"},
+			},
+		},
+		{
+			name: "bsd",
+			output: `Active Internet connections (including servers)
+Proto Recv-Q Send-Q  Local Address          Foreign Address        (state)
+tcp4       0      0  127.0.0.1.5432         *.*                    LISTEN
+tcp46      0      0  *.8080                 *.*                    LISTEN
+tcp4       0      0  10.0.0.5.22            10.0.0.9.51234         ESTABLISHED`,
+			expected: []ListeningPort{
+				{Proto: "tcp", LocalAddress: "127.0.0.1", Port: 5432},
+				{Proto: "tcp46", LocalAddress: "*", Port: 8080},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseNetstatListeners(tt.output); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("parseNetstatListeners() = %+v, want %+v", got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package ssh
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MaxCommandPrefixSize bounds the length of a command prefix
+const MaxCommandPrefixSize = 1024
+
+// validateCommandPrefix checks that prefix is a plain list of words, such as
+// "timeout 60" or "nice -n 19", that cannot change the meaning of the
+// command it is placed before: no quoting, expansions, redirections or
+// command separators. Unless session commands are allowed, it must not
+// replace the persistent shell either, as "exec" would.
+func validateCommandPrefix(prefix string, allowSessionCommands bool) error {
+	if len(prefix) > MaxCommandPrefixSize {
+		return fmt.Errorf("command prefix too long (max %d bytes)", MaxCommandPrefixSize)
+	}
+	for _, r := range prefix {
+		if !isPrefixRune(r) {
+			return fmt.Errorf("command prefix contains invalid character %q (only words of alphanumerics and -_./:=+,@%% separated by spaces are allowed)", r)
+		}
+	}
+	if !allowSessionCommands {
+		if err := checkSessionCommand(prefix + " sh -c :"); err != nil {
+			return fmt.Errorf("invalid command prefix: %w", err)
+		}
+	}
+	return nil
+}
+
+// isPrefixRune reports whether r may appear in a command prefix
+func isPrefixRune(r rune) bool {
+	switch {
+	case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		return true
+	}
+	return strings.ContainsRune(" -_./:=+,@%", r)
+}
+
+// SetPrefix sets the words placed before every subsequent command, e.g.
+// "timeout 60", or clears them when prefix is blank. A prefixed command runs
+// in a child shell, as with SubshellPerCommand, so that the prefix covers all
+// of it; internal commands of the executor are not prefixed.
+func (e *ShellExecutor) SetPrefix(prefix string) error {
+	prefix = strings.Join(strings.Fields(prefix), " ")
+	if prefix == "" {
+		e.prefix.Store(nil)
+		return nil
+	}
+	if err := validateCommandPrefix(prefix, e.options.AllowSessionCommands); err != nil {
+		return err
+	}
+	e.prefix.Store(&prefix)
+	return nil
+}
+
+// Prefix returns the command prefix, empty if unset
+func (e *ShellExecutor) Prefix() string {
+	if prefix := e.prefix.Load(); prefix != nil {
+		return *prefix
+	}
+	return ""
+}
+
+// SetPrefix sets the command prefix of a connection, clearing it when prefix
+// is blank, and returns the prefix in effect. The prefix is kept when the
+// connection is re-established.
+func (m *Manager) SetPrefix(id, prefix string) (string, error) {
+	m.mu.RLock()
+	conn, exists := m.connections[id]
+	m.mu.RUnlock()
+
+	if !exists {
+		return "", m.connectionNotFound(id)
+	}
+
+	if err := conn.executor.SetPrefix(prefix); err != nil {
+		return "", err
+	}
+	return conn.executor.Prefix(), nil
+}
@@ -0,0 +1,141 @@
+package ssh
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestValidateCommandPrefix(t *testing.T) {
+	tests := []struct {
+		prefix  string
+		wantErr string
+	}{
+		{prefix: "timeout 60"},
+		{prefix: "nice -n 19"},
+		{prefix: "env LC_ALL=C /opt/wrap.sh --mode=strict"},
+		{prefix: "timeout 60; rm -rf /", wantErr: "invalid character ';'"},
+		{prefix: "env FOO=$HOME", wantErr: "invalid character '$'"},
+		{prefix: "nice 'x'", wantErr: "invalid character"},
+		{prefix: "tee >/tmp/out", wantErr: "invalid character '>'"},
+		{prefix: "exec", wantErr: "invalid command prefix"},
+		{prefix: strings.Repeat("a", MaxCommandPrefixSize+1), wantErr: "too long"},
+	}
+
+	for _, tt := range tests {
+		err := validateCommandPrefix(tt.prefix, false)
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Errorf("%q: unexpected error: %v", tt.prefix, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%q: expected error containing %q, got %v", tt.prefix, tt.wantErr, err)
+		}
+	}
+
+	if err := validateCommandPrefix("exec", true); err != nil {
+		t.Errorf("expected exec to be allowed with session commands, got %v", err)
+	}
+}
+
+func TestSetPrefix(t *testing.T) {
+	server := newTestServer(t)
+	manager := newTestManager(t)
+	connectAutoReconnect(t, manager, server, "default")
+
+	result, err := manager.Execute("default", "nice")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	base, err := strconv.Atoi(result.Stdout)
+	if err != nil {
+		t.Fatalf("unexpected niceness %q", result.Stdout)
+	}
+	niced := strconv.Itoa(min(base+5, 19))
+
+	prefix, err := manager.SetPrefix("default", "  nice  -n 5 ")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if prefix != "nice -n 5" {
+		t.Errorf("expected the prefix to be normalized, got %q", prefix)
+	}
+
+	// The prefix covers compound commands, while the working directory and
+	// exported variables still carry over
+	dir := t.TempDir()
+	steps := []struct {
+		command string
+		stdout  string
+	}{
+		{command: "cd " + shellQuote(dir) + " && export KEPT=yes; nice", stdout: niced},
+		{command: "true; echo \"$KEPT\"; pwd; nice", stdout: "yes\n" + dir + "\n" + niced},
+	}
+	for _, step := range steps {
+		result, err := manager.Execute("default", step.command)
+		if err != nil {
+			t.Fatalf("%q: unexpected error: %v", step.command, err)
+		}
+		if result.Stdout != step.stdout {
+			t.Errorf("%q: stdout = %q, want %q", step.command, result.Stdout, step.stdout)
+		}
+	}
+
+	// The prefix is kept when the connection is re-established
+	server.DropConnections()
+	waitConnectionLost(t, manager, "default")
+	result, err = manager.Execute("default", "nice")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !result.Reconnected || result.Stdout != niced {
+		t.Errorf("expected the prefix to apply after reconnecting, got %+v", result)
+	}
+	if infos := manager.List(); len(infos) != 1 || infos[0].CommandPrefix != "nice -n 5" {
+		t.Errorf("expected the prefix to be listed, got %+v", infos)
+	}
+
+	if _, err := manager.SetPrefix("default", "nice; true"); err == nil {
+		t.Error("expected an invalid prefix to be rejected")
+	}
+
+	if prefix, err := manager.SetPrefix("default", ""); err != nil || prefix != "" {
+		t.Fatalf("expected the prefix to be cleared, got %q, %v", prefix, err)
+	}
+	result, err = manager.Execute("default", "nice")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Stdout != strconv.Itoa(base) {
+		t.Errorf("expected no prefix once cleared, got niceness %q", result.Stdout)
+	}
+}
+
+func TestSetPrefix_Sudo(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "sudo"), []byte(fakeSudo), 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	server := newTestServer(t)
+	manager := newTestManager(t)
+	connectTestServer(t, manager, server, "default")
+	if _, err := manager.Execute("default", "export PATH="+shellQuote(dir)+":$PATH"); err != nil {
+		t.Fatalf("failed to set PATH: %v", err)
+	}
+	if _, err := manager.SetPrefix("default", "env MCP_PREFIXED=1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	result, err := manager.ExecuteSudo("default", `echo "$MCP_PREFIXED"`, SudoOptions{Password: "hunter2", StripNoise: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.AuthFailed || result.Stdout != "1" {
+		t.Errorf("expected sudo to run with the prefix, got %+v", result)
+	}
+}
@@ -0,0 +1,221 @@
+package ssh
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// PresetEnvPrefix starts the names of the environment variables defining
+// connection presets, e.g. MCP_SSH_CONN_PROD="host=db.example.com;user=app;key=/keys/id_ed25519"
+const PresetEnvPrefix = "MCP_SSH_CONN_"
+
+// Preset is a connection defined by the operator that ssh_connect can use by
+// name instead of being given the host and credentials. Secrets are not held
+// in the preset itself but named by environment variable, and only read when
+// connecting.
+type Preset struct {
+	Name     string
+	Host     string
+	Port     int
+	Username string
+
+	PrivateKeyPath string
+
+	// PasswordEnv names the environment variable holding the password
+	PasswordEnv string
+
+	// PassphraseEnv names the environment variable holding the passphrase of
+	// an encrypted private key
+	PassphraseEnv string
+
+	HostKeyFingerprint string
+	AutoReconnect      bool
+	DisableHistory     bool
+	SubshellPerCommand bool
+}
+
+// LoadPresets parses the connection presets among environ, a list of
+// "NAME=value" entries as returned by os.Environ. Preset names are the
+// lowercased variable name suffixes. It returns the valid presets sorted by
+// name and an error for each invalid one.
+func LoadPresets(environ []string, validator *HostValidator) ([]*Preset, []error) {
+	var presets []*Preset
+	var errs []error
+	for _, entry := range environ {
+		key, value, found := strings.Cut(entry, "=")
+		if !found || !strings.HasPrefix(key, PresetEnvPrefix) {
+			continue
+		}
+
+		name := strings.ToLower(strings.TrimPrefix(key, PresetEnvPrefix))
+		preset, err := ParsePreset(name, value)
+		if err == nil && validator != nil {
+			err = validator.Validate(preset.Host, preset.Port)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", key, err))
+			continue
+		}
+		presets = append(presets, preset)
+	}
+
+	sort.Slice(presets, func(i, j int) bool {
+		return presets[i].Name < presets[j].Name
+	})
+	return presets, errs
+}
+
+// ParsePreset parses a preset specification: semicolon-separated key=value
+// pairs with the keys host, port, user, key (private key path), password_env,
+// passphrase_env, host_key_fingerprint, auto_reconnect, disable_history and
+// subshell_per_command. host, user and one of key or password_env are
+// required; passphrase_env requires key.
+func ParsePreset(name, spec string) (*Preset, error) {
+	if name == "" {
+		return nil, fmt.Errorf("preset name cannot be empty")
+	}
+	for _, r := range name {
+		if !((r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '-' || r == '_') {
+			return nil, fmt.Errorf("preset name '%s' contains invalid characters (only alphanumeric, dash, underscore allowed)", name)
+		}
+	}
+
+	preset := &Preset{Name: name, Port: 22}
+	seen := make(map[string]bool)
+	for _, field := range strings.Split(spec, ";") {
+		if strings.TrimSpace(field) == "" {
+			continue
+		}
+
+		key, value, found := strings.Cut(field, "=")
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if !found || value == "" {
+			return nil, fmt.Errorf("expected key=value, got %q", field)
+		}
+		if seen[key] {
+			return nil, fmt.Errorf("duplicate key '%s'", key)
+		}
+		seen[key] = true
+
+		var err error
+		switch key {
+		case "host":
+			preset.Host = value
+		case "port":
+			preset.Port, err = strconv.Atoi(value)
+			if err == nil && (preset.Port < 1 || preset.Port > 65535) {
+				err = errors.New("out of range")
+			}
+		case "user":
+			preset.Username = value
+		case "key":
+			preset.PrivateKeyPath = value
+		case "password_env":
+			preset.PasswordEnv = value
+		case "passphrase_env":
+			preset.PassphraseEnv = value
+		case "host_key_fingerprint":
+			preset.HostKeyFingerprint = value
+		case "auto_reconnect":
+			preset.AutoReconnect, err = strconv.ParseBool(value)
+		case "disable_history":
+			preset.DisableHistory, err = strconv.ParseBool(value)
+		case "subshell_per_command":
+			preset.SubshellPerCommand, err = strconv.ParseBool(value)
+		default:
+			return nil, fmt.Errorf("unknown key '%s'", key)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q", key, value)
+		}
+	}
+
+	if preset.Host == "" {
+		return nil, fmt.Errorf("'host' is required")
+	}
+	if preset.Username == "" {
+		return nil, fmt.Errorf("'user' is required")
+	}
+	if preset.PrivateKeyPath == "" && preset.PasswordEnv == "" {
+		return nil, fmt.Errorf("either 'key' or 'password_env' is required")
+	}
+	if preset.PassphraseEnv != "" && preset.PrivateKeyPath == "" {
+		return nil, fmt.Errorf("'passphrase_env' requires 'key'")
+	}
+	return preset, nil
+}
+
+// Auth describes how the preset authenticates, for reporting
+func (p *Preset) Auth() string {
+	switch {
+	case p.PrivateKeyPath != "" && p.PasswordEnv != "":
+		return "key+password"
+	case p.PrivateKeyPath != "":
+		return "key"
+	}
+	return "password"
+}
+
+// ConnectParams returns the parameters connecting to the preset as id,
+// reading the password and key passphrase from their environment variables
+func (p *Preset) ConnectParams(id string) (ConnectParams, error) {
+	params := ConnectParams{
+		ID:                 id,
+		Host:               p.Host,
+		Port:               p.Port,
+		Username:           p.Username,
+		PrivateKeyPath:     p.PrivateKeyPath,
+		HostKeyFingerprint: p.HostKeyFingerprint,
+		AutoReconnect:      p.AutoReconnect,
+		DisableHistory:     p.DisableHistory,
+		SubshellPerCommand: p.SubshellPerCommand,
+	}
+	if p.PasswordEnv != "" {
+		password, ok := os.LookupEnv(p.PasswordEnv)
+		if !ok || password == "" {
+			return ConnectParams{}, fmt.Errorf("environment variable '%s' holding the password of preset '%s' is not set", p.PasswordEnv, p.Name)
+		}
+		params.Password = password
+	}
+	if p.PassphraseEnv != "" {
+		passphrase, ok := os.LookupEnv(p.PassphraseEnv)
+		if !ok || passphrase == "" {
+			return ConnectParams{}, fmt.Errorf("environment variable '%s' holding the key passphrase of preset '%s' is not set", p.PassphraseEnv, p.Name)
+		}
+		params.Passphrase = passphrase
+	}
+	return params, nil
+}
+
+// WithPresets makes connection presets available by name
+func WithPresets(presets []*Preset) ManagerOption {
+	return func(c *ManagerConfig) {
+		c.Presets = make(map[string]*Preset, len(presets))
+		for _, preset := range presets {
+			c.Presets[preset.Name] = preset
+		}
+	}
+}
+
+// Preset returns the connection preset called name
+func (m *Manager) Preset(name string) (*Preset, error) {
+	preset, exists := m.config.Presets[strings.ToLower(name)]
+	if !exists {
+		return nil, fmt.Errorf("connection preset '%s' not found", name)
+	}
+	return preset, nil
+}
+
+// PresetNames returns the names of the connection presets, sorted
+func (m *Manager) PresetNames() []string {
+	names := make([]string, 0, len(m.config.Presets))
+	for name := range m.config.Presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,145 @@
+package ssh
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestParsePreset(t *testing.T) {
+	tests := []struct {
+		name     string
+		spec     string
+		expected Preset
+		wantErr  string
+	}{
+		{
+			name: "key",
+			spec: "host=db.example.com;user=app;key=/keys/id_ed25519",
+			expected: Preset{Name: "key", Host: "db.example.com", Port: 22, Username: "app",
+				PrivateKeyPath: "/keys/id_ed25519"},
+		},
+		{
+			name: "encrypted",
+			spec: "host=db.example.com;user=app;key=/keys/id_ed25519;passphrase_env=KEY_PASS",
+			expected: Preset{Name: "encrypted", Host: "db.example.com", Port: 22, Username: "app",
+				PrivateKeyPath: "/keys/id_ed25519", PassphraseEnv: "KEY_PASS"},
+		},
+		{
+			name: "full",
+			spec: " host = 10.0.0.5 ; port=2222;user=root;password_env=PROD_PASS;auto_reconnect=true;disable_history=1;host_key_fingerprint=SHA256:abc; ",
+			expected: Preset{Name: "full", Host: "10.0.0.5", Port: 2222, Username: "root", PasswordEnv: "PROD_PASS",
+				AutoReconnect: true, DisableHistory: true, HostKeyFingerprint: "SHA256:abc"},
+		},
+		{name: "nohost", spec: "user=app;key=/k", wantErr: "'host' is required"},
+		{name: "nouser", spec: "host=h;key=/k", wantErr: "'user' is required"},
+		{name: "noauth", spec: "host=h;user=app", wantErr: "'key' or 'password_env'"},
+		{name: "passphrase", spec: "host=h;user=app;password_env=P;passphrase_env=K", wantErr: "'passphrase_env' requires 'key'"},
+		{name: "port", spec: "host=h;user=app;key=/k;port=70000", wantErr: "invalid port"},
+		{name: "bool", spec: "host=h;user=app;key=/k;auto_reconnect=maybe", wantErr: "invalid auto_reconnect"},
+		{name: "unknown", spec: "host=h;user=app;password=secret", wantErr: "unknown key 'password'"},
+		{name: "duplicate", spec: "host=h;host=i;user=app;key=/k", wantErr: "duplicate key"},
+		{name: "malformed", spec: "host=h;user;key=/k", wantErr: "expected key=value"},
+		{name: "bad.name", spec: "host=h;user=app;key=/k", wantErr: "invalid characters"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			preset, err := ParsePreset(tt.name, tt.spec)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if *preset != tt.expected {
+				t.Errorf("ParsePreset() = %+v, want %+v", *preset, tt.expected)
+			}
+		})
+	}
+}
+
+func TestLoadPresets(t *testing.T) {
+	validator, err := NewHostValidator("*.example.com")
+	if err != nil {
+		t.Fatalf("failed to create validator: %v", err)
+	}
+
+	presets, errs := LoadPresets([]string{
+		"HOME=/root",
+		"MCP_SSH_CONN_WEB=host=web.example.com;user=deploy;key=/k",
+		"MCP_SSH_CONN_DB=host=db.example.com;user=app;password_env=DB_PASS",
+		"MCP_SSH_CONN_EVIL=host=evil.test;user=app;key=/k",
+		"MCP_SSH_CONN_BROKEN=host=x.example.com",
+	}, validator)
+
+	if len(presets) != 2 || presets[0].Name != "db" || presets[1].Name != "web" {
+		t.Errorf("expected the db and web presets, got %+v", presets)
+	}
+	if len(errs) != 2 {
+		t.Fatalf("expected two errors, got %v", errs)
+	}
+	for _, err := range errs {
+		if !strings.HasPrefix(err.Error(), "MCP_SSH_CONN_EVIL:") && !strings.HasPrefix(err.Error(), "MCP_SSH_CONN_BROKEN:") {
+			t.Errorf("expected errors to name their variable, got %v", err)
+		}
+	}
+}
+
+func TestPreset_ConnectParams(t *testing.T) {
+	preset := &Preset{Name: "db", Host: "db.example.com", Port: 22, Username: "app", PasswordEnv: "MCP_SSH_TEST_DB_PASS"}
+
+	if _, err := preset.ConnectParams("db"); err == nil || !strings.Contains(err.Error(), "MCP_SSH_TEST_DB_PASS") {
+		t.Errorf("expected an error naming the unset variable, got %v", err)
+	}
+
+	// Read when connecting, not when the preset is loaded
+	t.Setenv("MCP_SSH_TEST_DB_PASS", "hunter2")
+	params, err := preset.ConnectParams("db-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if params.ID != "db-1" || params.Password != "hunter2" || params.Host != "db.example.com" {
+		t.Errorf("unexpected params: %+v", params)
+	}
+}
+
+func TestManager_ConnectPreset(t *testing.T) {
+	server := newTestServer(t)
+	t.Setenv("MCP_SSH_TEST_PASS", testPassword)
+
+	presets, errs := LoadPresets([]string{
+		fmt.Sprintf("MCP_SSH_CONN_LOCAL=host=127.0.0.1;port=%d;user=%s;password_env=MCP_SSH_TEST_PASS", server.Port(), testUsername),
+	}, nil)
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	manager := newTestManager(t, WithPresets(presets))
+	t.Cleanup(manager.CloseAll)
+
+	if names := manager.PresetNames(); len(names) != 1 || names[0] != "local" {
+		t.Errorf("unexpected preset names %v", names)
+	}
+	if _, err := manager.Preset("missing"); err == nil {
+		t.Errorf("expected an error for an unknown preset")
+	}
+
+	preset, err := manager.Preset("LOCAL")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	params, err := preset.ConnectParams("default")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := manager.Connect(params); err != nil {
+		t.Fatalf("failed to connect with the preset: %v", err)
+	}
+	result, err := manager.Execute("default", "echo ok")
+	if err != nil || result.Stdout != "ok" {
+		t.Errorf("unexpected result %+v, %v", result, err)
+	}
+}
@@ -0,0 +1,123 @@
+package ssh
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// proxyConn is a net.Conn backed by the stdin/stdout of a local proxy command
+type proxyConn struct {
+	cmd       *exec.Cmd
+	stdin     io.WriteCloser
+	stdout    io.ReadCloser
+	closeOnce sync.Once
+}
+
+// dialProxyCommand starts the proxy command and returns a connection that
+// reads from its stdout and writes to its stdin. The process is killed when
+// the connection is closed.
+func dialProxyCommand(command string) (net.Conn, error) {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		// #nosec G204 - Proxy commands are only run when explicitly allowed by the operator
+		cmd = exec.CommandContext(context.Background(), "cmd", "/C", command)
+	} else {
+		// #nosec G204 - Proxy commands are only run when explicitly allowed by the operator
+		cmd = exec.CommandContext(context.Background(), "sh", "-c", command)
+	}
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get proxy command stdin: %w", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get proxy command stdout: %w", err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start proxy command: %w", err)
+	}
+
+	return &proxyConn{
+		cmd:    cmd,
+		stdin:  stdin,
+		stdout: stdout,
+	}, nil
+}
+
+func (c *proxyConn) Read(b []byte) (int, error) {
+	return c.stdout.Read(b)
+}
+
+func (c *proxyConn) Write(b []byte) (int, error) {
+	return c.stdin.Write(b)
+}
+
+// Close closes the pipes and kills the proxy command
+func (c *proxyConn) Close() error {
+	c.closeOnce.Do(func() {
+		_ = c.stdin.Close() // Best effort cleanup
+		if c.cmd.Process != nil {
+			_ = c.cmd.Process.Kill() // Best effort cleanup
+		}
+		// Wait closes stdout and reaps the process
+		_ = c.cmd.Wait()
+	})
+	return nil
+}
+
+func (c *proxyConn) LocalAddr() net.Addr {
+	return proxyAddr("local")
+}
+
+func (c *proxyConn) RemoteAddr() net.Addr {
+	return proxyAddr(strings.Join(c.cmd.Args, " "))
+}
+
+// Deadlines are not supported on pipes; timeouts are enforced by the caller
+func (c *proxyConn) SetDeadline(t time.Time) error      { return nil }
+func (c *proxyConn) SetReadDeadline(t time.Time) error  { return nil }
+func (c *proxyConn) SetWriteDeadline(t time.Time) error { return nil }
+
+// proxyAddr is the net.Addr of a proxy command connection
+type proxyAddr string
+
+func (a proxyAddr) Network() string { return "proxy-command" }
+func (a proxyAddr) String() string  { return string(a) }
+
+// expandProxyCommand replaces the OpenSSH ProxyCommand tokens %h (host),
+// %p (port), %r (remote user) and %% in command. Host and user come from the
+// MCP client, so they are shell-quoted to prevent command injection.
+func expandProxyCommand(command, host string, port int, username string) string {
+	var b strings.Builder
+	for i := 0; i < len(command); i++ {
+		if command[i] != '%' || i == len(command)-1 {
+			b.WriteByte(command[i])
+			continue
+		}
+		i++
+		switch command[i] {
+		case 'h':
+			b.WriteString(shellQuote(host))
+		case 'p':
+			b.WriteString(strconv.Itoa(port))
+		case 'r':
+			b.WriteString(shellQuote(username))
+		case '%':
+			b.WriteByte('%')
+		default:
+			b.WriteByte('%')
+			b.WriteByte(command[i])
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,38 @@
+package ssh
+
+import (
+	"testing"
+)
+
+func TestExpandProxyCommand(t *testing.T) {
+	tests := []struct {
+		name     string
+		command  string
+		expected string
+	}{
+		{
+			name:     "all tokens",
+			command:  "nc -X connect -x proxy:3128 %h %p",
+			expected: "nc -X connect -x proxy:3128 'bastion.example.com' 2222",
+		},
+		{
+			name:     "user and literal percent",
+			command:  "ssh-proxy --user %r --rate 50%%",
+			expected: "ssh-proxy --user 'deploy' --rate 50%",
+		},
+		{
+			name:     "unknown token and trailing percent",
+			command:  "cmd %x 100%",
+			expected: "cmd %x 100%",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := expandProxyCommand(tt.command, "bastion.example.com", 2222, "deploy")
+			if got != tt.expected {
+				t.Errorf("expandProxyCommand() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package ssh
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// rateLimiter is a token bucket bounding the commands a connection may run
+// per minute. The bucket holds a minute's worth of commands, so a burst of
+// that many is allowed, and refills continuously. It is shared by the
+// connections replacing a dropped one, so that reconnecting does not refill
+// it.
+type rateLimiter struct {
+	mu        sync.Mutex
+	perMinute int
+	tokens    float64
+	last      time.Time
+}
+
+// newRateLimiter returns a limiter allowing perMinute commands a minute, nil
+// if perMinute is not positive
+func newRateLimiter(perMinute int) *rateLimiter {
+	if perMinute <= 0 {
+		return nil
+	}
+	return &rateLimiter{perMinute: perMinute, tokens: float64(perMinute), last: time.Now()}
+}
+
+// take uses up a command, failing if there is none left. A nil limiter allows
+// every command.
+func (l *rateLimiter) take(id string, now time.Time) error {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	rate := float64(l.perMinute) / time.Minute.Seconds()
+	if elapsed := now.Sub(l.last).Seconds(); elapsed > 0 {
+		l.tokens = math.Min(float64(l.perMinute), l.tokens+elapsed*rate)
+		l.last = now
+	}
+	if l.tokens >= 1 {
+		l.tokens--
+		return nil
+	}
+
+	retry := math.Ceil((1 - l.tokens) / rate)
+	return &ConnectionError{
+		Code: CodeRateLimited,
+		Err: fmt.Errorf("rate limit exceeded, retry after %.0f seconds: connection '%s' may run %d commands per minute",
+			retry, id, l.perMinute),
+	}
+}
+
+// WithMaxCommandsPerMinute limits every connection to perMinute commands a
+// minute, further commands being rejected until the limit allows them
+// (0: unlimited)
+func WithMaxCommandsPerMinute(perMinute int) ManagerOption {
+	return func(c *ManagerConfig) {
+		c.MaxCommandsPerMinute = perMinute
+	}
+}
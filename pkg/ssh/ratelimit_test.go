@@ -0,0 +1,76 @@
+package ssh
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMaxCommandsPerMinute(t *testing.T) {
+	const limit = 3
+
+	server := newTestServer(t)
+	manager := newTestManager(t, WithMaxCommandsPerMinute(limit))
+	connectTestServer(t, manager, server, "default")
+
+	for i := 0; i < limit; i++ {
+		if _, err := manager.Execute("default", "true"); err != nil {
+			t.Fatalf("command %d: unexpected error: %v", i+1, err)
+		}
+	}
+
+	_, err := manager.Execute("default", "true")
+	if err == nil {
+		t.Fatalf("expected command %d to be rejected", limit+1)
+	}
+	if !strings.Contains(err.Error(), "rate limit exceeded, retry after 20 seconds") {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if code := ErrorCodeOf(err); code != CodeRateLimited {
+		t.Errorf("expected code %q, got %q", CodeRateLimited, code)
+	}
+
+	// Other connections have a limit of their own
+	connectTestServer(t, manager, server, "other")
+	if _, err := manager.Execute("other", "true"); err != nil {
+		t.Errorf("unexpected error on another connection: %v", err)
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	start := time.Now()
+	limiter := newRateLimiter(60)
+	limiter.last = start
+
+	for i := 0; i < 60; i++ {
+		if err := limiter.take("default", start); err != nil {
+			t.Fatalf("command %d: unexpected error: %v", i+1, err)
+		}
+	}
+	if err := limiter.take("default", start); err == nil || !strings.Contains(err.Error(), "retry after 1 seconds") {
+		t.Errorf("expected the bucket to be empty, got %v", err)
+	}
+
+	// A command a second is refilled
+	if err := limiter.take("default", start.Add(time.Second)); err != nil {
+		t.Errorf("expected a refilled command, got %v", err)
+	}
+	if err := limiter.take("default", start.Add(time.Second)); err == nil {
+		t.Errorf("expected a single refilled command")
+	}
+
+	// The bucket holds no more than a minute's worth
+	later := start.Add(time.Hour)
+	for i := 0; i < 60; i++ {
+		if err := limiter.take("default", later); err != nil {
+			t.Fatalf("command %d: unexpected error: %v", i+1, err)
+		}
+	}
+	if err := limiter.take("default", later); err == nil {
+		t.Errorf("expected the bucket to be capped")
+	}
+
+	if err := newRateLimiter(0).take("default", start); err != nil {
+		t.Errorf("expected no limit, got %v", err)
+	}
+}
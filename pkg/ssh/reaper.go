@@ -0,0 +1,107 @@
+package ssh
+
+import (
+	"sync"
+	"time"
+)
+
+// maxReapInterval bounds how often the idle reaper scans the connections
+const maxReapInterval = time.Minute
+
+// WithIdleTimeout closes connections that have not been used for timeout
+// (0: never). onReap, if set, is called with each connection closed this way.
+func WithIdleTimeout(timeout time.Duration, onReap func(ConnectionInfo)) ManagerOption {
+	return func(c *ManagerConfig) {
+		c.IdleTimeout = timeout
+		c.OnIdleReap = onReap
+	}
+}
+
+// idleReaper periodically closes the connections of a manager that have
+// been idle longer than its IdleTimeout or open longer than its
+// MaxSessionLifetime
+type idleReaper struct {
+	stopOnce sync.Once
+	stopCh   chan struct{}
+	done     chan struct{}
+}
+
+// startIdleReaper starts reaping the idle and expired connections of m
+func startIdleReaper(m *Manager) *idleReaper {
+	r := &idleReaper{
+		stopCh: make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+
+	interval := maxReapInterval
+	for _, limit := range []time.Duration{m.config.IdleTimeout, m.config.MaxSessionLifetime} {
+		if limit > 0 {
+			interval = min(max(limit/4, time.Millisecond), interval)
+		}
+	}
+	go func() {
+		defer close(r.done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-r.stopCh:
+				return
+			case now := <-ticker.C:
+				if m.config.MaxSessionLifetime > 0 {
+					m.reapExpired(now)
+				}
+				if m.config.IdleTimeout > 0 {
+					m.reapIdle(now)
+				}
+			}
+		}
+	}()
+	return r
+}
+
+// stop stops the reaper and waits for a scan in progress to finish. It is
+// safe to call on a nil reaper and more than once.
+func (r *idleReaper) stop() {
+	if r == nil {
+		return
+	}
+	r.stopOnce.Do(func() { close(r.stopCh) })
+	<-r.done
+}
+
+// reapIdle closes the connections last used more than IdleTimeout before
+// now. Connections with a command in flight are kept, as their last use only
+// advances once it finishes. Connections are closed after the manager lock is
+// released, as closing one waits for any direct user of its shell.
+func (m *Manager) reapIdle(now time.Time) {
+	var reaped []ConnectionInfo
+	var closing []*Connection
+
+	m.mu.Lock()
+	for id, conn := range m.connections {
+		lastUsed := time.Unix(0, conn.lastUsed.Load())
+		if conn.active.Load() > 0 || now.Sub(lastUsed) <= m.config.IdleTimeout {
+			continue
+		}
+
+		info := conn.Info
+		info.LastUsed = lastUsed
+		reaped = append(reaped, info)
+		closing = append(closing, conn)
+
+		delete(m.connections, id)
+		m.updateIndex(id)
+	}
+	m.mu.Unlock()
+
+	for _, conn := range closing {
+		conn.close()
+	}
+	if m.config.OnIdleReap != nil {
+		for _, info := range reaped {
+			m.config.OnIdleReap(info)
+		}
+	}
+}
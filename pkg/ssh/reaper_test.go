@@ -0,0 +1,99 @@
+package ssh
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestManager_IdleReaper(t *testing.T) {
+	server := newTestServer(t)
+	reaped := make(chan ConnectionInfo, 1)
+	manager := newTestManager(t, WithIdleTimeout(300*time.Millisecond, func(info ConnectionInfo) {
+		reaped <- info
+	}))
+	t.Cleanup(manager.CloseAll)
+	connectTestServer(t, manager, server, "default")
+
+	// A command outlasting the idle timeout keeps the connection open
+	if _, err := manager.Execute("default", "sleep 1"); err != nil {
+		t.Fatalf("expected the connection to survive a long command, got %v", err)
+	}
+
+	var info ConnectionInfo
+	select {
+	case info = <-reaped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the idle connection to be reaped")
+	}
+	if info.ID != "default" {
+		t.Errorf("expected connection 'default' to be reaped, got %q", info.ID)
+	}
+	if idle := time.Since(info.LastUsed); idle < 300*time.Millisecond {
+		t.Errorf("expected the connection to be reaped after the idle timeout, it was idle for %v", idle)
+	}
+
+	_, err := manager.Execute("default", "true")
+	var notFound *ConnectionNotFoundError
+	if !errors.As(err, &notFound) || !strings.Contains(err.Error(), "not found") {
+		t.Fatalf("expected a connection not found error, got %v", err)
+	}
+	if manager.Count() != 0 {
+		t.Errorf("expected no connections, got %d", manager.Count())
+	}
+}
+
+func TestManager_IdleReaperStoppedByCloseAll(t *testing.T) {
+	manager := newTestManager(t, WithIdleTimeout(time.Hour, nil))
+	manager.CloseAll()
+
+	select {
+	case <-manager.reaper.done:
+	default:
+		t.Error("expected CloseAll to stop the idle reaper")
+	}
+
+	// Stopping again, e.g. by Shutdown, does not block
+	manager.Shutdown(0)
+}
+
+func TestManager_IdleReapBusyExecutor(t *testing.T) {
+	server := newTestServer(t)
+	manager := newTestManager(t, WithIdleTimeout(time.Hour, nil))
+	t.Cleanup(manager.CloseAll)
+	connectTestServer(t, manager, server, "default")
+
+	// Direct users of the shell, such as background jobs, are not counted as
+	// active, so the connection is reaped while its shell is busy
+	executor := manager.connections["default"].executor
+	go func() { _, _ = executor.Execute("sleep 2") }()
+	waitRunning(t, manager, "default", "")
+
+	reaped := make(chan struct{})
+	go func() {
+		manager.reapIdle(time.Now().Add(2 * time.Hour))
+		close(reaped)
+	}()
+
+	// Closing the connection waits for the shell, but not under the lock
+	listed := make(chan int, 1)
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		listed <- len(manager.List())
+	}()
+	select {
+	case n := <-listed:
+		if n != 0 {
+			t.Errorf("expected the reaped connection to be gone, got %d connections", n)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the manager to stay usable while the connection is closed")
+	}
+
+	select {
+	case <-reaped:
+	case <-time.After(10 * time.Second):
+		t.Fatal("expected the reap to finish once the command ended")
+	}
+}
@@ -0,0 +1,304 @@
+package ssh
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Reconnect states reported in ReconnectStatus
+const (
+	// ReconnectOK means reconnect attempts are allowed
+	ReconnectOK = "ok"
+
+	// ReconnectBackoff means the last attempts failed and the next one is
+	// delayed
+	ReconnectBackoff = "backoff"
+
+	// ReconnectFailed means too many consecutive attempts failed; the
+	// connection is only retried through Manager.Reconnect
+	ReconnectFailed = "failed"
+)
+
+// ReconnectPolicy throttles attempts to re-establish a dropped connection,
+// so that commands retried in a loop do not hammer a host that is down
+type ReconnectPolicy struct {
+	// Backoff is the wait after the first failed attempt. It doubles with
+	// each further consecutive failure, up to MaxBackoff (0: it stays at
+	// Backoff).
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+
+	// MaxFailures consecutive failures mark the connection as failed
+	// (0: never)
+	MaxFailures int
+}
+
+// DefaultReconnectPolicy is the reconnect policy of a Manager
+var DefaultReconnectPolicy = ReconnectPolicy{
+	Backoff:     time.Second,
+	MaxBackoff:  30 * time.Second,
+	MaxFailures: 5,
+}
+
+// backoff returns the wait after the given number of consecutive failures
+func (p ReconnectPolicy) backoff(failures int) time.Duration {
+	wait := p.Backoff
+	for i := 1; i < failures && wait < p.MaxBackoff; i++ {
+		wait *= 2
+	}
+	if p.MaxBackoff > 0 && wait > p.MaxBackoff {
+		wait = p.MaxBackoff
+	}
+	return wait
+}
+
+// ReconnectStatus describes the reconnect throttling of a connection
+type ReconnectStatus struct {
+	// State is ReconnectOK, ReconnectBackoff or ReconnectFailed
+	State string
+
+	// Failures counts the consecutive failed attempts
+	Failures int
+
+	// RetryAt is when the next attempt is allowed in ReconnectBackoff
+	RetryAt time.Time
+
+	// LastError is the error of the last failed attempt
+	LastError string
+}
+
+// reconnectBreaker tracks the consecutive failed reconnect attempts of a
+// connection
+type reconnectBreaker struct {
+	mu       sync.Mutex
+	failures int
+	retryAt  time.Time
+	failed   bool
+	lastErr  string
+}
+
+// allow returns an error if an attempt is not allowed at now
+func (b *reconnectBreaker) allow(now time.Time) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch {
+	case b.failed:
+		return fmt.Errorf("reconnecting was given up after %d consecutive failures (last error: %s); "+
+			"use ssh_reconnect to try again", b.failures, b.lastErr)
+	case now.Before(b.retryAt):
+		return fmt.Errorf("reconnecting is backing off after %d consecutive failures (last error: %s); "+
+			"next attempt allowed in %s", b.failures, b.lastErr, b.retryAt.Sub(now).Round(time.Millisecond))
+	}
+	return nil
+}
+
+// failure records a failed attempt
+func (b *reconnectBreaker) failure(err error, now time.Time, policy ReconnectPolicy) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	b.lastErr = err.Error()
+	b.retryAt = now.Add(policy.backoff(b.failures))
+	if policy.MaxFailures > 0 && b.failures >= policy.MaxFailures {
+		b.failed = true
+	}
+}
+
+// reset clears the recorded failures
+func (b *reconnectBreaker) reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures = 0
+	b.retryAt = time.Time{}
+	b.failed = false
+	b.lastErr = ""
+}
+
+// status returns the breaker state at now
+func (b *reconnectBreaker) status(now time.Time) ReconnectStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	status := ReconnectStatus{
+		State:     ReconnectOK,
+		Failures:  b.failures,
+		LastError: b.lastErr,
+	}
+	switch {
+	case b.failed:
+		status.State = ReconnectFailed
+	case now.Before(b.retryAt):
+		status.State = ReconnectBackoff
+		status.RetryAt = b.retryAt
+	}
+	return status
+}
+
+// runWithReconnect runs fn on the shell of an existing connection within an
+// execution slot. If the connection is lost and has AutoReconnect set, it is
+// re-established. fn is then retried once on the new shell, but only if none
+// of its commands reached the old one, so that a command never runs twice. It
+// reports whether fn ran on a re-established connection.
+func (m *Manager) runWithReconnect(id string, fn func(*ShellExecutor) error) (reconnected bool, err error) {
+	m.mu.RLock()
+	conn, exists := m.connections[id]
+	m.mu.RUnlock()
+
+	if !exists {
+		return false, m.connectionNotFound(id)
+	}
+
+	if err := conn.budget.check(id, m.config.TimeBudget); err != nil {
+		return false, err
+	}
+	if err := conn.limiter.take(id, time.Now()); err != nil {
+		return false, err
+	}
+
+	if err := m.execs.acquire(); err != nil {
+		return false, err
+	}
+	defer m.execs.release()
+
+	// The time waiting for a slot is not charged, reconnecting and a retry are
+	started := time.Now()
+	used := conn
+	used.active.Add(1)
+	defer func() {
+		conn.budget.charge(time.Since(started))
+		m.metrics.record(time.Since(started), err)
+		used.touch()
+		used.active.Add(-1)
+	}()
+
+	sent := conn.executor.commandsSent()
+	err = fn(conn.executor)
+
+	var lost *ConnectionLostError
+	if err == nil || !conn.params.AutoReconnect || !errors.As(err, &lost) {
+		return false, err
+	}
+
+	// fn may run several commands, e.g. the command and a stat of its output
+	// file, so also check that none of them was written before the failure
+	retry := !lost.Sent && conn.executor.commandsSent() == sent
+
+	fresh, reconnectErr := m.reconnect(id, conn)
+	if reconnectErr != nil {
+		return false, fmt.Errorf("%w (reconnect failed: %v)", err, reconnectErr)
+	}
+	if !retry {
+		return false, fmt.Errorf("%w; the connection dropped after the command was sent, so it may have run and was not retried. "+
+			"The connection has been re-established with a fresh shell", err)
+	}
+
+	fresh.active.Add(1)
+	conn.active.Add(-1)
+	used = fresh
+	return true, fn(fresh.executor)
+}
+
+// Reconnect re-establishes a connection now, replacing its transport and
+// shell, and clears any reconnect backoff or failed state. Commands running on
+// the connection are aborted. It requires the credentials retained with
+// AutoReconnect.
+func (m *Manager) Reconnect(id string) (*ConnectionInfo, error) {
+	m.mu.RLock()
+	conn, exists := m.connections[id]
+	m.mu.RUnlock()
+
+	if !exists {
+		return nil, m.connectionNotFound(id)
+	}
+	if !conn.params.AutoReconnect {
+		return nil, fmt.Errorf("connection '%s' was not opened with auto_reconnect, so no credentials were retained; connect again instead", id)
+	}
+
+	conn.breaker.reset()
+	fresh, err := m.reconnect(id, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	info := fresh.Info
+	m.mu.RUnlock()
+	info.Reconnect = fresh.breaker.status(time.Now())
+	return &info, nil
+}
+
+// reconnect replaces a lost connection with a new one using the stored
+// connect parameters and credentials. The new connection starts a fresh
+// shell. If a concurrent command already re-established the connection, that
+// connection is returned. Attempts are throttled after failures according to
+// the ReconnectPolicy.
+func (m *Manager) reconnect(id string, old *Connection) (*Connection, error) {
+	old.reconnectMu.Lock()
+	defer old.reconnectMu.Unlock()
+
+	m.mu.RLock()
+	current, exists := m.connections[id]
+	creds := old.credentials
+	m.mu.RUnlock()
+
+	switch {
+	case !exists:
+		return nil, fmt.Errorf("connection '%s' was closed", id)
+	case current != old:
+		return current, nil
+	case creds == nil:
+		return nil, fmt.Errorf("the credentials of connection '%s' were forgotten", id)
+	}
+
+	if err := old.breaker.allow(time.Now()); err != nil {
+		return nil, err
+	}
+
+	params := old.params
+	creds.restore(&params)
+
+	conn, err := m.establish(params)
+	if err != nil {
+		old.breaker.failure(err, time.Now(), m.config.ReconnectPolicy)
+		return nil, err
+	}
+
+	m.mu.Lock()
+	if m.connections[id] != old {
+		m.mu.Unlock()
+		conn.close()
+		return nil, fmt.Errorf("connection '%s' was closed or replaced while reconnecting", id)
+	}
+
+	// The command prefix is a setting of the connection, not shell state
+	if prefix := old.executor.Prefix(); prefix != "" {
+		conn.executor.prefix.Store(&prefix)
+	}
+
+	// The server may present another host key than before
+	fingerprint := conn.Info.HostKeyFingerprint
+	conn.Info = old.Info
+	conn.Info.HostKeyFingerprint = fingerprint
+	conn.credentials = old.credentials
+	conn.params = old.params
+	conn.budget = old.budget
+	conn.limiter = old.limiter
+	conn.lastUsed.Store(old.lastUsed.Load())
+	conn.Info.Reconnects++
+
+	// The credentials now belong to the new connection
+	old.credentials = nil
+	m.connections[id] = conn
+	m.startKeepalive(conn)
+	m.mu.Unlock()
+
+	// A command still running holds the old shell, so closing it must not
+	// block other calls on the manager
+	old.abort()
+	return conn, nil
+}
@@ -0,0 +1,270 @@
+package ssh
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// connectAutoReconnect connects the manager to the test server with
+// auto-reconnect enabled
+func connectAutoReconnect(t *testing.T, manager *Manager, server *testServer, id string) {
+	t.Helper()
+
+	params := server.params(id)
+	params.AutoReconnect = true
+	if _, err := manager.Connect(params); err != nil {
+		t.Fatalf("failed to connect to test server: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = manager.Close(id)
+	})
+}
+
+// waitConnectionLost waits until the connection's shell notices that the
+// transport is gone
+func waitConnectionLost(t *testing.T, manager *Manager, id string) {
+	t.Helper()
+
+	manager.mu.RLock()
+	executor := manager.connections[id].executor
+	manager.mu.RUnlock()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for executor.streamError() == nil {
+		if time.Now().After(deadline) {
+			t.Fatalf("connection was not detected as lost")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestAutoReconnect_RetriesUnsentCommand(t *testing.T) {
+	server := newTestServer(t)
+	manager := newTestManager(t)
+	connectAutoReconnect(t, manager, server, "default")
+
+	if _, err := manager.Execute("default", "export STATE=old"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	server.DropConnections()
+	waitConnectionLost(t, manager, "default")
+
+	result, err := manager.Execute("default", `echo "ok${STATE}"`)
+	if err != nil {
+		t.Fatalf("expected the command to be retried on a new connection, got %v", err)
+	}
+	if !result.Reconnected {
+		t.Errorf("expected the result to be marked as reconnected")
+	}
+	if result.Stdout != "ok" {
+		t.Errorf("expected the command to run in a fresh shell, got %q", result.Stdout)
+	}
+
+	result, err = manager.Execute("default", "echo again")
+	if err != nil || result.Reconnected {
+		t.Errorf("expected a plain execution on the new connection, got %+v, %v", result, err)
+	}
+
+	infos := manager.List()
+	if len(infos) != 1 || infos[0].Reconnects != 1 || !infos[0].AutoReconnect {
+		t.Errorf("expected one reconnect to be recorded, got %+v", infos)
+	}
+}
+
+func TestAutoReconnect_DoesNotRetrySentCommand(t *testing.T) {
+	server := newTestServer(t)
+	manager := newTestManager(t)
+	connectAutoReconnect(t, manager, server, "default")
+
+	go func() {
+		time.Sleep(300 * time.Millisecond)
+		server.DropConnections()
+	}()
+
+	_, err := manager.Execute("default", "sleep 2; echo done")
+	if err == nil {
+		t.Fatalf("expected an error when the connection drops mid-command")
+	}
+	var lost *ConnectionLostError
+	if !errors.As(err, &lost) || !lost.Sent {
+		t.Errorf("expected a connection lost error for a sent command, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "not retried") {
+		t.Errorf("expected the error to explain the command was not retried, got %v", err)
+	}
+
+	// The connection was re-established for the next command
+	result, err := manager.Execute("default", "echo next")
+	if err != nil || result.Stdout != "next" || result.Reconnected {
+		t.Errorf("expected the next command to run on the new connection, got %+v, %v", result, err)
+	}
+}
+
+func TestAutoReconnect_Disabled(t *testing.T) {
+	server := newTestServer(t)
+	manager := newTestManager(t)
+	connectTestServer(t, manager, server, "default")
+
+	server.DropConnections()
+	waitConnectionLost(t, manager, "default")
+
+	_, err := manager.Execute("default", "echo ok")
+	var lost *ConnectionLostError
+	if !errors.As(err, &lost) || lost.Sent {
+		t.Errorf("expected a connection lost error for an unsent command, got %v", err)
+	}
+}
+
+func TestAutoReconnect_ForgottenCredentials(t *testing.T) {
+	server := newTestServer(t)
+	manager := newTestManager(t)
+	connectAutoReconnect(t, manager, server, "default")
+
+	if forgotten, err := manager.ForgetCredentials("default"); err != nil || !forgotten {
+		t.Fatalf("expected the credentials to be forgotten, got %v, %v", forgotten, err)
+	}
+
+	server.DropConnections()
+	waitConnectionLost(t, manager, "default")
+
+	_, err := manager.Execute("default", "echo ok")
+	if err == nil || !strings.Contains(err.Error(), "forgotten") {
+		t.Errorf("expected reconnecting to fail without credentials, got %v", err)
+	}
+}
+
+func TestReconnectPolicy_Backoff(t *testing.T) {
+	policy := ReconnectPolicy{Backoff: time.Second, MaxBackoff: 5 * time.Second}
+	expected := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	for i, want := range expected {
+		if got := policy.backoff(i + 1); got != want {
+			t.Errorf("backoff(%d) = %v, want %v", i+1, got, want)
+		}
+	}
+
+	constant := ReconnectPolicy{Backoff: time.Second}
+	if got := constant.backoff(4); got != time.Second {
+		t.Errorf("expected the backoff not to grow without MaxBackoff, got %v", got)
+	}
+}
+
+func TestAutoReconnect_Breaker(t *testing.T) {
+	server := newTestServer(t)
+	manager := newTestManager(t, WithReconnectPolicy(ReconnectPolicy{
+		Backoff:     200 * time.Millisecond,
+		MaxBackoff:  300 * time.Millisecond,
+		MaxFailures: 3,
+	}))
+	connectAutoReconnect(t, manager, server, "default")
+
+	status := func() ReconnectStatus {
+		t.Helper()
+		infos := manager.List()
+		if len(infos) != 1 {
+			t.Fatalf("expected one connection, got %+v", infos)
+		}
+		return infos[0].Reconnect
+	}
+
+	server.Close()
+	waitConnectionLost(t, manager, "default")
+
+	// The first attempt dials and fails
+	if _, err := manager.Execute("default", "echo ok"); err == nil || !strings.Contains(err.Error(), "reconnect failed") {
+		t.Fatalf("expected the reconnect to fail, got %v", err)
+	}
+	if s := status(); s.State != ReconnectBackoff || s.Failures != 1 || s.LastError == "" || s.RetryAt.IsZero() {
+		t.Errorf("expected a backoff after one failure, got %+v", s)
+	}
+
+	// Attempts within the backoff fail without dialing
+	if _, err := manager.Execute("default", "echo ok"); err == nil || !strings.Contains(err.Error(), "backing off") {
+		t.Errorf("expected the attempt to be throttled, got %v", err)
+	}
+	if s := status(); s.Failures != 1 {
+		t.Errorf("expected the throttled attempt not to count, got %+v", s)
+	}
+
+	for failures := 2; failures <= 3; failures++ {
+		time.Sleep(time.Until(status().RetryAt) + 20*time.Millisecond)
+		if _, err := manager.Execute("default", "echo ok"); err == nil {
+			t.Fatalf("expected the reconnect to fail")
+		}
+		if s := status(); s.Failures != failures {
+			t.Fatalf("expected %d failures, got %+v", failures, s)
+		}
+	}
+	if s := status(); s.State != ReconnectFailed {
+		t.Errorf("expected the connection to be marked failed, got %+v", s)
+	}
+
+	// A failed connection stays failed when the host comes back...
+	server.restart()
+	time.Sleep(400 * time.Millisecond)
+	if _, err := manager.Execute("default", "echo ok"); err == nil || !strings.Contains(err.Error(), "given up") {
+		t.Errorf("expected the failed connection not to be retried, got %v", err)
+	}
+
+	// ...until it is retried explicitly
+	info, err := manager.Reconnect("default")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if info.Reconnects != 1 || info.Reconnect.State != ReconnectOK || info.Reconnect.Failures != 0 {
+		t.Errorf("expected a healthy reconnected connection, got %+v", info)
+	}
+	result, err := manager.Execute("default", "echo ok")
+	if err != nil || result.Stdout != "ok" {
+		t.Errorf("expected the command to run on the new connection, got %+v, %v", result, err)
+	}
+}
+
+func TestReconnect_RequiresAutoReconnect(t *testing.T) {
+	server := newTestServer(t)
+	manager := newTestManager(t)
+	connectTestServer(t, manager, server, "default")
+
+	if _, err := manager.Reconnect("default"); err == nil || !strings.Contains(err.Error(), "auto_reconnect") {
+		t.Errorf("expected an error without auto_reconnect, got %v", err)
+	}
+	if _, err := manager.Reconnect("missing"); err == nil {
+		t.Errorf("expected an error for an unknown connection")
+	}
+}
+
+func TestReconnect_AbortsRunningCommand(t *testing.T) {
+	server := newTestServer(t)
+	manager := newTestManager(t)
+	connectAutoReconnect(t, manager, server, "default")
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := manager.ExecuteWithOptions("default", "sleep 6", ExecuteOptions{Timeout: 10 * time.Second})
+		done <- err
+	}()
+	waitRunning(t, manager, "default", "")
+
+	started := time.Now()
+	if _, err := manager.Reconnect("default"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("expected the running command to fail")
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("expected the running command to be aborted")
+	}
+	if elapsed := time.Since(started); elapsed > 3*time.Second {
+		t.Errorf("expected the reconnect not to wait for the command, took %v", elapsed)
+	}
+
+	result, err := manager.Execute("default", "echo ok")
+	if err != nil || result.Stdout != "ok" {
+		t.Errorf("expected the new connection to work, got %+v, %v", result, err)
+	}
+}
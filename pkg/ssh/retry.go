@@ -0,0 +1,74 @@
+package ssh
+
+import (
+	"errors"
+	"io"
+	"net"
+	"syscall"
+	"time"
+)
+
+const (
+	// DefaultRetryBackoff is the wait before the first retry of a connection
+	// attempt when ConnectParams.RetryBackoff is not set
+	DefaultRetryBackoff = 500 * time.Millisecond
+
+	// MaxConnectRetries bounds ConnectParams.MaxRetries
+	MaxConnectRetries = 10
+
+	// maxRetryWait caps the wait between two connection attempts
+	maxRetryWait = 30 * time.Second
+)
+
+// WithConnectRetryHook sets a function called before each retry of a failed
+// connection attempt, with the connection ID, the number of the attempt about
+// to be made (2 for the first retry), the wait before it and the error of the
+// attempt that failed
+func WithConnectRetryHook(onRetry func(id string, attempt int, wait time.Duration, err error)) ManagerOption {
+	return func(c *ManagerConfig) {
+		c.OnConnectRetry = onRetry
+	}
+}
+
+// establishWithRetry establishes a connection like establish, trying again
+// up to params.MaxRetries times after transient failures. The wait before a
+// retry starts at params.RetryBackoff and doubles each time.
+func (m *Manager) establishWithRetry(params ConnectParams) (*Connection, error) {
+	wait := params.RetryBackoff
+	if wait <= 0 {
+		wait = DefaultRetryBackoff
+	}
+	retries := min(params.MaxRetries, MaxConnectRetries)
+
+	for attempt := 1; ; attempt++ {
+		conn, err := m.establish(params)
+		if err == nil || attempt > retries || !isTransientDialError(err) {
+			return conn, err
+		}
+
+		if m.config.OnConnectRetry != nil {
+			m.config.OnConnectRetry(params.ID, attempt+1, wait, err)
+		}
+		time.Sleep(wait)
+		wait = min(2*wait, maxRetryWait)
+	}
+}
+
+// isTransientDialError reports whether a failure to connect may go away on
+// its own: the server refusing or dropping the connection, the network being
+// unreachable, a timeout or a temporary DNS failure. Rejected credentials or
+// host keys and disallowed hosts are not.
+func isTransientDialError(err error) bool {
+	if ErrorCodeOf(err) == CodeTimeout {
+		return true
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return dnsErr.IsTemporary || dnsErr.IsTimeout
+	}
+	return errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.EHOSTUNREACH) ||
+		errors.Is(err, syscall.ENETUNREACH) ||
+		errors.Is(err, io.EOF)
+}
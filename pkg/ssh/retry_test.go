@@ -0,0 +1,137 @@
+package ssh
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestConnect_Retry(t *testing.T) {
+	// The host is down for the first two attempts and back for the third
+	server := newTestServer(t)
+	server.Close()
+
+	var retries []string
+	manager := newTestManager(t, WithConnectRetryHook(func(id string, attempt int, wait time.Duration, err error) {
+		if !strings.Contains(err.Error(), "connection refused") {
+			t.Errorf("attempt %d: expected a refused connection, got %v", attempt-1, err)
+		}
+		retries = append(retries, fmt.Sprintf("%s#%d after %s", id, attempt, wait))
+		if attempt == 3 {
+			server.restart()
+		}
+	}))
+	t.Cleanup(manager.CloseAll)
+
+	params := server.params("default")
+	params.MaxRetries = 5
+	params.RetryBackoff = 10 * time.Millisecond
+	if _, err := manager.Connect(params); err != nil {
+		t.Fatalf("expected the third attempt to connect, got %v", err)
+	}
+	if got, want := strings.Join(retries, ", "), "default#2 after 10ms, default#3 after 20ms"; got != want {
+		t.Errorf("retries = %q, want %q", got, want)
+	}
+}
+
+func TestConnect_RetryGivesUp(t *testing.T) {
+	server := newTestServer(t)
+
+	retries := 0
+	manager := newTestManager(t, WithConnectRetryHook(func(string, int, time.Duration, error) {
+		retries++
+	}))
+	t.Cleanup(manager.CloseAll)
+
+	// Rejected credentials are not retried
+	params := server.params("default")
+	params.Password = "wrong"
+	params.MaxRetries = 3
+	params.RetryBackoff = time.Millisecond
+	if _, err := manager.Connect(params); ErrorCodeOf(err) != CodeAuthFailed {
+		t.Fatalf("expected an authentication failure, got %v", err)
+	}
+	if retries != 0 {
+		t.Errorf("expected no retries after an authentication failure, got %d", retries)
+	}
+
+	// A host that stays down is retried MaxRetries times
+	server.Close()
+	params = server.params("default")
+	params.MaxRetries = 2
+	params.RetryBackoff = time.Millisecond
+	if _, err := manager.Connect(params); err == nil || !strings.Contains(err.Error(), "connection refused") {
+		t.Fatalf("expected the connection to be refused, got %v", err)
+	}
+	if retries != 2 {
+		t.Errorf("expected 2 retries, got %d", retries)
+	}
+}
+
+func TestConnect_RetryDoesNotBlock(t *testing.T) {
+	// The host is down while other tools use the manager
+	server := newTestServer(t)
+	server.Close()
+
+	retrying := make(chan struct{})
+	manager := newTestManager(t, WithConnectRetryHook(func(string, int, time.Duration, error) {
+		close(retrying)
+	}))
+	t.Cleanup(manager.CloseAll)
+
+	params := server.params("default")
+	params.MaxRetries = 1
+	params.RetryBackoff = time.Second
+	result := make(chan error, 1)
+	go func() {
+		_, err := manager.Connect(params)
+		result <- err
+	}()
+	<-retrying
+
+	started := time.Now()
+	if infos := manager.List(); len(infos) != 0 {
+		t.Errorf("expected no connections while retrying, got %+v", infos)
+	}
+	if elapsed := time.Since(started); elapsed > 500*time.Millisecond {
+		t.Errorf("expected List to return while Connect waits to retry, took %s", elapsed)
+	}
+
+	// Another connection with the same ID is added meanwhile, so the retry
+	// connects but cannot take the ID
+	connectTestServer(t, manager, newTestServer(t), "default")
+	server.restart()
+	if err := <-result; err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("expected the retried connection to conflict, got %v", err)
+	}
+	if manager.Count() != 1 {
+		t.Errorf("expected only the first connection to be kept, got %d", manager.Count())
+	}
+}
+
+func TestIsTransientDialError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "refused", err: fmt.Errorf("failed to connect: %w", &net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}), want: true},
+		{name: "timeout", err: &ConnectionError{Code: CodeTimeout, Err: errors.New("handshake timed out")}, want: true},
+		{name: "temporary dns", err: &net.DNSError{Err: "server misbehaving", Name: "example.com", IsTemporary: true}, want: true},
+		{name: "unknown host", err: &net.DNSError{Err: "no such host", Name: "example.invalid", IsNotFound: true}, want: false},
+		{name: "auth failed", err: &ConnectionError{Code: CodeAuthFailed, Err: errors.New("unable to authenticate")}, want: false},
+		{name: "host not allowed", err: &ConnectionError{Code: CodeHostNotAllowed, Err: errors.New("host not allowed")}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isTransientDialError(tt.err); got != tt.want {
+				t.Errorf("isTransientDialError(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package ssh
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultScriptInterpreter runs scripts given no interpreter
+	DefaultScriptInterpreter = "/bin/sh"
+
+	// MaxScriptSize is the largest script ExecuteScript accepts
+	MaxScriptSize = 512 * 1024
+)
+
+// scriptCommand wraps script to be written to a temporary file and run with
+// interpreter, its stderr merged into stdout. It runs in a subshell, so that
+// the script cannot change the persistent shell, and the file is removed when
+// the subshell exits. If the file cannot be created or written, the script
+// does not run.
+func scriptCommand(script, interpreter string) string {
+	return `( __mcp_script=$(mktemp) || exit
+trap 'rm -f "$__mcp_script"' EXIT
+printf '%s' ` + shellQuote(script) + ` > "$__mcp_script" || exit
+` + interpreter + ` "$__mcp_script" </dev/null 2>&1
+)`
+}
+
+// ExecuteScript runs a multi-line script on a connection with interpreter
+// (DefaultScriptInterpreter if empty). The script is written to a temporary
+// file on the remote rather than typed into the shell, so it runs as a
+// whole, as with "sh script.sh"; its working directory and variables do not
+// carry over. The result's Stdout holds the script's output and errors.
+func (m *Manager) ExecuteScript(id, script, interpreter string, timeout time.Duration) (*CommandResult, error) {
+	if interpreter == "" {
+		interpreter = DefaultScriptInterpreter
+	}
+	if err := validateProgram("interpreter", interpreter); err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(script) == "" {
+		return nil, fmt.Errorf("script cannot be empty")
+	}
+	if len(script) > MaxScriptSize {
+		return nil, fmt.Errorf("script exceeds maximum size of %d bytes", MaxScriptSize)
+	}
+	if strings.ContainsRune(script, 0) {
+		return nil, fmt.Errorf("script must not contain NUL bytes")
+	}
+
+	return m.ExecuteWithOptions(id, scriptCommand(script, interpreter), ExecuteOptions{Timeout: timeout})
+}
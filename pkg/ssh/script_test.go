@@ -0,0 +1,61 @@
+package ssh
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExecuteScript(t *testing.T) {
+	server := newTestServer(t)
+	manager := newTestManager(t)
+	connectTestServer(t, manager, server, "default")
+
+	script := `#!/bin/bash
+set -u
+total=0
+for i in 1 2 3 4; do
+	total=$((total + i))
+done
+if [[ $total -eq 10 ]]; then
+	echo "total is $total"
+fi
+echo "warning: almost done" >&2
+exit 3
+`
+	result, err := manager.ExecuteScript("default", script, "bash", 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.ExitCode != 3 {
+		t.Errorf("exit code = %d, want 3 (output %q, stderr %q)", result.ExitCode, result.Stdout, result.Stderr)
+	}
+	if want := "total is 10\nwarning: almost done"; result.Stdout != want {
+		t.Errorf("output = %q, want %q", result.Stdout, want)
+	}
+
+	// The default interpreter is /bin/sh
+	result, err = manager.ExecuteScript("default", "a=1\nb=2\necho $((a + b))\necho \"$0\"\n", "", 0)
+	if err != nil || result.ExitCode != 0 || !strings.HasPrefix(result.Stdout, "3\n") {
+		t.Fatalf("expected sh to run the script, got %+v, %v", result, err)
+	}
+	path := strings.TrimPrefix(result.Stdout, "3\n")
+
+	// The script ran apart from the persistent shell, and its file is gone
+	result, err = manager.Execute("default", `echo "${total:-unset}"; test -e `+shellQuote(path))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Stdout != "unset" || result.ExitCode != 1 {
+		t.Errorf("expected the shell unchanged and %s removed, got %+v", path, result)
+	}
+
+	for _, tt := range []struct{ script, interpreter, want string }{
+		{script: "echo hi", interpreter: "bash; rm -rf /", want: "invalid interpreter"},
+		{script: " \n", want: "script cannot be empty"},
+		{script: "echo \x00", want: "NUL"},
+	} {
+		if _, err := manager.ExecuteScript("default", tt.script, tt.interpreter, 0); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("ExecuteScript(%q, %q): expected an error containing %q, got %v", tt.script, tt.interpreter, tt.want, err)
+		}
+	}
+}
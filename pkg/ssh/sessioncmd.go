@@ -0,0 +1,356 @@
+package ssh
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// interactiveShells are shells that read commands from stdin when started
+// without a command or script
+var interactiveShells = map[string]bool{
+	"sh":   true,
+	"ash":  true,
+	"bash": true,
+	"dash": true,
+	"ksh":  true,
+	"mksh": true,
+	"zsh":  true,
+	"fish": true,
+	"csh":  true,
+	"tcsh": true,
+}
+
+// sudoOptionsWithArgument are sudo options that take a separate argument
+var sudoOptionsWithArgument = map[string]bool{
+	"-u": true, "-g": true, "-p": true, "-C": true, "-h": true,
+	"-r": true, "-t": true, "-U": true, "-D": true, "-R": true, "-T": true,
+}
+
+// checkSessionCommand rejects commands that would replace or take over the
+// persistent shell: exec'ing another program, redirecting the shell's own
+// stdin or stdout, starting a nested interactive shell (bash, su, sudo -i,
+// login, newgrp) or exiting the shell. Once that happens the end-of-command
+// delimiter never comes back and the connection is unusable.
+//
+// Detection is a best-effort scan of each simple command in the input; it
+// does not expand variables or aliases. Exiting or exec'ing inside a
+// subshell, e.g. "(exit 3)", only ends the subshell and is allowed, and so is
+// a shell reading its commands from a pipe or file, e.g. "curl URL | sh".
+func checkSessionCommand(command string) error {
+	for _, cmd := range splitSimpleCommands(command) {
+		if reason := sessionAlteringReason(cmd.words, cmd.subshell, cmd.piped); reason != "" {
+			return fmt.Errorf("command %s and would make the session unusable; "+
+				"run it in a subshell or with 'bash -c', or start the server with --allow-session-commands", reason)
+		}
+	}
+	return nil
+}
+
+// sessionAlteringReason explains why a simple command would alter the
+// session, or returns an empty string if it would not. piped is set when the
+// command's stdin is the output of the previous command in a pipeline.
+func sessionAlteringReason(words []string, subshell, piped bool) string {
+	// Skip variable assignments and wrappers that run the command as is
+	for len(words) > 0 && (isAssignment(words[0]) || words[0] == "command" || words[0] == "builtin") {
+		words = words[1:]
+	}
+	if len(words) == 0 {
+		return ""
+	}
+
+	name, args := words[0], words[1:]
+	if name == "exec" {
+		if subshell {
+			return ""
+		}
+		return execReason(args)
+	}
+
+	// A shell whose stdin is a pipe or a file runs the commands it reads
+	// from there and exits, leaving the persistent shell's input alone
+	args, redirected := stripRedirections(args)
+	readsInput := piped || redirected
+
+	switch base := path.Base(name); {
+	case (name == "exit" || name == "logout") && !subshell:
+		return fmt.Sprintf("'%s' exits the shell", name)
+	case name == "login" || name == "newgrp":
+		return fmt.Sprintf("'%s' starts a new login shell", name)
+	case base == "su":
+		if !hasCommandFlag(args) && !readsInput {
+			return "'su' without -c starts an interactive shell"
+		}
+	case base == "sudo":
+		return sudoReason(args, subshell, readsInput)
+	case interactiveShells[base]:
+		if !hasCommandFlag(args) && !hasOperand(args) && !readsInput {
+			return fmt.Sprintf("'%s' without a command or script starts an interactive shell", name)
+		}
+	}
+	return ""
+}
+
+// execReason explains why an exec invocation would alter the session
+func execReason(args []string) string {
+	for i := 0; i < len(args); i++ {
+		fd, target, ok := parseRedirection(args[i])
+		if !ok {
+			return fmt.Sprintf("'exec %s' replaces the shell", args[i])
+		}
+		if target == "" {
+			// The target is the next word, e.g. "exec > file"
+			i++
+		}
+		if fd == "0" || fd == "1" || fd == "&" {
+			return "'exec' redirects the shell's own input or output"
+		}
+	}
+	return ""
+}
+
+// sudoReason explains why a sudo invocation would start an interactive
+// shell: one given by -i or -s without a command, or one run as the command.
+// args must not hold redirections; readsInput is set when sudo's stdin is a
+// pipe or a file.
+func sudoReason(args []string, subshell, readsInput bool) string {
+	shellFlag := ""
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--":
+			return sessionAlteringReason(args[i+1:], subshell, readsInput)
+		case arg == "--login" || arg == "--shell":
+			shellFlag = arg
+		case strings.HasPrefix(arg, "--"):
+			continue
+		case strings.HasPrefix(arg, "-") && len(arg) > 1:
+			// Short options may be combined, e.g. -iu deploy, and one taking
+			// an argument consumes the rest of the word or the next word
+			for j := 1; j < len(arg); j++ {
+				if arg[j] == 'i' || arg[j] == 's' {
+					shellFlag = arg
+				}
+				if sudoOptionsWithArgument["-"+arg[j:j+1]] {
+					if j == len(arg)-1 {
+						i++
+					}
+					break
+				}
+			}
+		default:
+			// With -i or -s the command runs through the shell and only a
+			// nested interactive shell would take over
+			return sessionAlteringReason(args[i:], subshell, readsInput)
+		}
+	}
+	if shellFlag != "" && !readsInput {
+		return fmt.Sprintf("'sudo %s' without a command starts an interactive shell", shellFlag)
+	}
+	return ""
+}
+
+// hasCommandFlag reports whether args contain -c (possibly combined with
+// other short flags, e.g. -lc) or --command
+func hasCommandFlag(args []string) bool {
+	for _, arg := range args {
+		if arg == "--command" || strings.HasPrefix(arg, "--command=") {
+			return true
+		}
+		if strings.HasPrefix(arg, "-") && !strings.HasPrefix(arg, "--") && strings.Contains(arg, "c") {
+			return true
+		}
+	}
+	return false
+}
+
+// hasOperand reports whether args contain a non-option argument, such as a
+// script path
+func hasOperand(args []string) bool {
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "-") && !strings.HasPrefix(arg, "+") {
+			return true
+		}
+	}
+	return false
+}
+
+// isAssignment reports whether word is a shell variable assignment (NAME=value)
+func isAssignment(word string) bool {
+	name, _, found := strings.Cut(word, "=")
+	return found && isEnvName(name)
+}
+
+// isEnvName reports whether name is a valid shell variable name
+func isEnvName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, r := range name {
+		if !(r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || i > 0 && r >= '0' && r <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// stripRedirections removes redirections and their targets from a command's
+// arguments and reports whether one of them redirects stdin
+func stripRedirections(args []string) ([]string, bool) {
+	var rest []string
+	stdin := false
+	for i := 0; i < len(args); i++ {
+		fd, target, ok := parseRedirection(args[i])
+		if !ok {
+			rest = append(rest, args[i])
+			continue
+		}
+		if target == "" {
+			i++
+		}
+		if fd == "0" {
+			stdin = true
+		}
+	}
+	return rest, stdin
+}
+
+// parseRedirection splits a redirection word such as "2>&1", ">file" or "<"
+// into the redirected file descriptor ("&" for both stdout and stderr) and
+// its target, which is empty when the target is the next word
+func parseRedirection(word string) (fd, target string, ok bool) {
+	i := 0
+	for i < len(word) && word[i] >= '0' && word[i] <= '9' {
+		i++
+	}
+	fd = word[:i]
+	rest := word[i:]
+
+	switch {
+	case strings.HasPrefix(rest, "&>") && fd == "":
+		return "&", strings.TrimLeft(rest[2:], ">"), true
+	case strings.HasPrefix(rest, "<"):
+		if fd == "" {
+			fd = "0"
+		}
+		return fd, strings.TrimLeft(rest, "<>&"), true
+	case strings.HasPrefix(rest, ">"):
+		if fd == "" {
+			fd = "1"
+		}
+		return fd, strings.TrimLeft(rest, ">|&"), true
+	}
+	return "", "", false
+}
+
+// simpleCommand is a command without separators, split into words
+type simpleCommand struct {
+	words []string
+
+	// subshell is set for commands inside parentheses
+	subshell bool
+
+	// piped is set for commands reading the output of the previous command
+	// in a pipeline
+	piped bool
+}
+
+// splitSimpleCommands splits a command line into simple commands at
+// unquoted separators (newlines, ';', '&', '|', '||', parentheses and braces)
+// and each simple command into words with quotes removed
+func splitSimpleCommands(command string) []simpleCommand {
+	var commands []simpleCommand
+	var words []string
+	var word strings.Builder
+	inWord := false
+	depth := 0
+	piped := false
+	var quote byte
+
+	endWord := func() {
+		if inWord {
+			words = append(words, word.String())
+			word.Reset()
+			inWord = false
+		}
+	}
+	endCommand := func() {
+		endWord()
+		if len(words) > 0 {
+			commands = append(commands, simpleCommand{words: words, subshell: depth > 0, piped: piped})
+			words = nil
+			piped = false
+		}
+	}
+
+	for i := 0; i < len(command); i++ {
+		c := command[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else if c == '\\' && quote == '"' && i+1 < len(command) {
+				i++
+				word.WriteByte(command[i])
+			} else {
+				word.WriteByte(c)
+			}
+		case c == '\'' || c == '"':
+			quote = c
+			inWord = true
+		case c == '\\' && i+1 < len(command):
+			i++
+			if command[i] != '\n' {
+				word.WriteByte(command[i])
+				inWord = true
+			}
+		case c == '#' && !inWord:
+			// Skip comments up to the end of the line
+			for i+1 < len(command) && command[i+1] != '\n' {
+				i++
+			}
+		case c == ' ' || c == '\t':
+			endWord()
+		case c == '|':
+			endCommand()
+			if i+1 < len(command) && command[i+1] == '|' {
+				// "||" runs the next command on failure, with the shell's stdin
+				i++
+			} else {
+				// "|&" also pipes stderr
+				if i+1 < len(command) && command[i+1] == '&' {
+					i++
+				}
+				piped = true
+			}
+		case c == '\n' || c == ';':
+			endCommand()
+		case c == '(':
+			endCommand()
+			depth++
+		case c == ')':
+			endCommand()
+			if depth > 0 {
+				depth--
+			}
+		case c == '&':
+			// "&>" and ">&" are redirections, not separators
+			if i+1 < len(command) && command[i+1] == '>' || inWord && strings.HasSuffix(word.String(), ">") {
+				word.WriteByte(c)
+				inWord = true
+			} else {
+				endCommand()
+			}
+		case c == '{' && !inWord && (i+1 == len(command) || strings.IndexByte(" \t\n", command[i+1]) >= 0):
+			endCommand()
+		case c == '}' && !inWord:
+			endCommand()
+		default:
+			word.WriteByte(c)
+			inWord = true
+		}
+	}
+	endCommand()
+
+	return commands
+}
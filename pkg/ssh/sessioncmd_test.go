@@ -0,0 +1,106 @@
+package ssh
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckSessionCommand(t *testing.T) {
+	tests := []struct {
+		name        string
+		command     string
+		expectError bool
+	}{
+		{name: "plain command", command: "ls -la /tmp", expectError: false},
+		{name: "exec program", command: "exec zsh", expectError: true},
+		{name: "exec after separator", command: "cd /tmp && exec ./server", expectError: true},
+		{name: "exec stderr redirect", command: "exec 2>/tmp/errors", expectError: false},
+		{name: "exec extra descriptor", command: "exec 3< /etc/hosts", expectError: false},
+		{name: "exec stdout redirect", command: "exec > /tmp/out", expectError: true},
+		{name: "exec stdin redirect", command: "exec </tmp/script", expectError: true},
+		{name: "exit", command: "make test || exit 1", expectError: true},
+		{name: "exit in subshell", command: "(exit 3)", expectError: false},
+		{name: "exec in subshell", command: "(cd /srv && exec ./run.sh)", expectError: false},
+		{name: "bare bash", command: "bash", expectError: true},
+		{name: "login shell", command: "/bin/bash -l", expectError: true},
+		{name: "bash command", command: "bash -c 'exit 2'", expectError: false},
+		{name: "bash combined flags", command: "bash -lc 'echo hi'", expectError: false},
+		{name: "bash script", command: "bash ./deploy.sh", expectError: false},
+		{name: "shell reading pipe", command: "curl -fsSL https://example.com/install | sh", expectError: false},
+		{name: "bash reading pipe", command: "echo 'echo hi' | bash", expectError: false},
+		{name: "sudo bash reading pipe", command: "cat script | sudo bash", expectError: false},
+		{name: "shell reading stderr pipe", command: "generate |& sh", expectError: false},
+		{name: "shell reading file", command: "bash < ./deploy.sh", expectError: false},
+		{name: "shell reading here string", command: "sh <<< 'echo hi'", expectError: false},
+		{name: "shell first in pipeline", command: "bash | tee log", expectError: true},
+		{name: "shell after or", command: "false || bash", expectError: true},
+		{name: "shell with stderr redirect", command: "bash 2>/dev/null", expectError: true},
+		{name: "su", command: "su - postgres", expectError: true},
+		{name: "su command", command: "su postgres -c 'psql -l'", expectError: false},
+		{name: "su command flag first", command: "su -c 'psql -l' postgres", expectError: false},
+		{name: "sudo login", command: "sudo -i", expectError: true},
+		{name: "sudo combined login", command: "sudo -iu deploy", expectError: true},
+		{name: "sudo login command", command: "sudo -i whoami", expectError: false},
+		{name: "sudo shell command", command: "sudo -s -- ls /root", expectError: false},
+		{name: "sudo combined login command", command: "sudo -iu deploy whoami", expectError: false},
+		{name: "sudo login nested shell", command: "sudo -i bash", expectError: true},
+		{name: "sudo login reading pipe", command: "echo id | sudo -i", expectError: false},
+		{name: "sudo shell", command: "sudo -u deploy bash", expectError: true},
+		{name: "sudo su", command: "sudo su", expectError: true},
+		{name: "sudo command", command: "sudo -u deploy systemctl restart app", expectError: false},
+		{name: "sudo stdin password", command: "printf '%s\\n' pw | sudo -S -p 'pw:' -- sh -c 'id'", expectError: false},
+		{name: "assignment prefix", command: "FOO=1 exec env", expectError: true},
+		{name: "quoted", command: "echo 'exit' \"bash\"", expectError: false},
+		{name: "comment", command: "ls # then exit", expectError: false},
+		{name: "redirect with ampersand", command: "make 2>&1 | tee build.log", expectError: false},
+		{name: "background job", command: "sleep 10 & exit", expectError: true},
+		{name: "newgrp", command: "newgrp docker", expectError: true},
+		{name: "brace group", command: "{ echo a; exit; }", expectError: true},
+		{name: "brace expansion", command: "cp app.conf{,.bak}", expectError: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkSessionCommand(tt.command)
+			if tt.expectError && err == nil {
+				t.Errorf("expected error but got nil")
+			}
+			if !tt.expectError && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestExecute_SessionCommands(t *testing.T) {
+	server := newTestServer(t)
+	manager := newTestManager(t)
+	connectTestServer(t, manager, server, "default")
+
+	if _, err := manager.Execute("default", "exit 1"); err == nil {
+		t.Errorf("expected 'exit' to be rejected")
+	}
+
+	// The wrappers built around user commands must pass the check
+	out := filepath.Join(t.TempDir(), "out")
+	if _, _, err := manager.ExecuteToFile(context.Background(), "default", "echo file", out); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, _, err := manager.ExecuteTee(context.Background(), "default", "echo tee; (exit 3)", out, 10); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	result, err := manager.Execute("default", "echo alive")
+	if err != nil || result.Stdout != "alive" {
+		t.Errorf("expected the session to still work, got %+v, %v", result, err)
+	}
+
+	allowing := newTestManager(t, WithAllowSessionCommands(true))
+	connectTestServer(t, allowing, server, "allowing")
+	_, err = allowing.Execute("allowing", "exit 0")
+	if err == nil || strings.Contains(err.Error(), "--allow-session-commands") {
+		t.Errorf("expected 'exit' to be allowed and to end the shell, got %v", err)
+	}
+}
@@ -0,0 +1,290 @@
+package ssh
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/sftp"
+)
+
+const (
+	// DefaultReadFileSize is the default number of bytes read per file
+	DefaultReadFileSize = 256 * 1024
+
+	// MaxReadFileSize is the maximum number of bytes read per file
+	MaxReadFileSize = 1024 * 1024
+
+	// MaxReadFilesTotal bounds the bytes returned by a single ReadFiles call
+	MaxReadFilesTotal = MaxOutputSize
+
+	// maxConcurrentReads bounds the number of files read in parallel
+	maxConcurrentReads = 8
+
+	// MaxListDirEntries bounds the entries returned by a single ListDir call
+	MaxListDirEntries = 1000
+)
+
+// FileContent is the result of reading a single remote file
+type FileContent struct {
+	Path    string
+	Content []byte
+	Size    int64
+
+	// Truncated is set when the file is larger than the per-file limit
+	Truncated bool
+
+	// Error is set when the file could not be read; the other fields are
+	// then empty
+	Error string
+}
+
+// Types of the entries of a directory listing
+const (
+	EntryFile    = "file"
+	EntryDir     = "dir"
+	EntrySymlink = "symlink"
+	EntryOther   = "other"
+)
+
+// DirEntry is an entry of a remote directory. Symbolic links are reported
+// as such, not as their target.
+type DirEntry struct {
+	Name    string
+	Size    int64
+	Mode    os.FileMode
+	ModTime time.Time
+	IsDir   bool
+
+	// Type is EntryFile, EntryDir, EntrySymlink or EntryOther, e.g. for a
+	// socket or device
+	Type string
+}
+
+// DirListing is the result of listing a remote directory
+type DirListing struct {
+	// Path is the directory as resolved by the SFTP server
+	Path string
+
+	// Entries are sorted by name, at most MaxListDirEntries of them
+	Entries []DirEntry
+
+	// Total is the number of entries in the directory, more than returned
+	// when Truncated is set
+	Total     int
+	Truncated bool
+}
+
+// sftpClient returns the connection's SFTP client, opening it on first use
+func (c *Connection) sftpClient() (*sftp.Client, error) {
+	c.sftpMu.Lock()
+	defer c.sftpMu.Unlock()
+
+	if c.sftp == nil {
+		client, err := sftp.NewClient(c.client)
+		if err != nil {
+			return nil, fmt.Errorf("failed to start SFTP session: %w", err)
+		}
+		c.sftp = client
+	}
+	return c.sftp, nil
+}
+
+// sftpClient returns the SFTP client of an existing connection
+func (m *Manager) sftpClient(id string) (*sftp.Client, error) {
+	m.mu.RLock()
+	conn, exists := m.connections[id]
+	m.mu.RUnlock()
+
+	if !exists {
+		return nil, m.connectionNotFound(id)
+	}
+
+	conn.touch()
+	return conn.sftpClient()
+}
+
+// ReadFiles reads several remote files concurrently over SFTP. Each file is
+// read up to maxBytes; failures are reported per file and do not abort the
+// other reads. Results are returned in the order the paths were given.
+func (m *Manager) ReadFiles(id string, paths []string, maxBytes int64) ([]FileContent, error) {
+	client, err := m.sftpClient(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if maxBytes <= 0 || maxBytes > MaxReadFileSize {
+		maxBytes = MaxReadFileSize
+	}
+
+	results := make([]FileContent, len(paths))
+	var budget atomic.Int64
+	budget.Store(MaxReadFilesTotal)
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, maxConcurrentReads)
+	for i, remotePath := range paths {
+		wg.Add(1)
+		go func(i int, remotePath string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			result := FileContent{Path: remotePath}
+			if resolved, err := m.resolveRemotePath(client, remotePath); err != nil {
+				result.Error = err.Error()
+			} else {
+				result = readRemoteFile(client, resolved, maxBytes)
+				result.Path = remotePath
+			}
+			if size := int64(len(result.Content)); budget.Add(-size) < 0 {
+				budget.Add(size)
+				result = FileContent{
+					Path:  remotePath,
+					Error: fmt.Sprintf("total read limit of %d bytes exceeded", MaxReadFilesTotal),
+				}
+			}
+			results[i] = result
+		}(i, remotePath)
+	}
+	wg.Wait()
+
+	return results, nil
+}
+
+// ListDir lists a remote directory over SFTP; an empty path lists the home
+// directory. A missing directory, one that cannot be read and a path that is
+// not a directory each fail with their own error.
+func (m *Manager) ListDir(id, remotePath string) (*DirListing, error) {
+	client, err := m.sftpClient(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if remotePath == "" {
+		remotePath = "."
+	}
+	resolved, err := m.resolveRemotePath(client, remotePath)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := client.Stat(resolved)
+	if err == nil && !info.IsDir() {
+		return nil, fmt.Errorf("not a directory: remote path '%s' is a file, use ssh_read_files to read it", resolved)
+	}
+	var infos []os.FileInfo
+	if err == nil {
+		infos, err = client.ReadDir(resolved)
+	}
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return nil, fmt.Errorf("no such directory: remote directory '%s' does not exist", resolved)
+	case errors.Is(err, os.ErrPermission):
+		return nil, fmt.Errorf("permission denied: cannot list remote directory '%s'", resolved)
+	case err != nil:
+		return nil, fmt.Errorf("failed to list remote directory '%s': %w", resolved, err)
+	}
+
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name() < infos[j].Name() })
+	listing := &DirListing{Path: resolved, Total: len(infos)}
+	if len(infos) > MaxListDirEntries {
+		infos = infos[:MaxListDirEntries]
+		listing.Truncated = true
+	}
+	listing.Entries = make([]DirEntry, 0, len(infos))
+	for _, info := range infos {
+		listing.Entries = append(listing.Entries, DirEntry{
+			Name:    info.Name(),
+			Size:    info.Size(),
+			Mode:    info.Mode(),
+			ModTime: info.ModTime(),
+			IsDir:   info.IsDir(),
+			Type:    entryType(info.Mode()),
+		})
+	}
+	return listing, nil
+}
+
+// entryType returns the type of a directory entry with the given mode
+func entryType(mode os.FileMode) string {
+	switch {
+	case mode.IsRegular():
+		return EntryFile
+	case mode.IsDir():
+		return EntryDir
+	case mode&os.ModeSymlink != 0:
+		return EntrySymlink
+	default:
+		return EntryOther
+	}
+}
+
+// resolveRemotePath returns the absolute form of p as resolved by the SFTP
+// server and checks it against the path policy. Paths that do not exist yet
+// are resolved through their nearest existing ancestor.
+func (m *Manager) resolveRemotePath(client *sftp.Client, p string) (string, error) {
+	resolved, err := client.RealPath(p)
+	if err != nil {
+		dir, rest := path.Dir(p), path.Base(p)
+		for {
+			resolvedDir, dirErr := client.RealPath(dir)
+			if dirErr == nil {
+				resolved = path.Join(resolvedDir, rest)
+				break
+			}
+			parent := path.Dir(dir)
+			if parent == dir {
+				return "", fmt.Errorf("failed to resolve '%s': %w", p, err)
+			}
+			dir, rest = parent, path.Join(path.Base(dir), rest)
+		}
+	}
+	resolved = path.Clean(resolved)
+
+	if err := m.config.PathPolicy.Check(resolved); err != nil {
+		return "", err
+	}
+	return resolved, nil
+}
+
+// readRemoteFile reads up to maxBytes of a regular remote file
+func readRemoteFile(client *sftp.Client, path string, maxBytes int64) FileContent {
+	result := FileContent{Path: path}
+
+	file, err := client.Open(path)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	defer func() {
+		_ = file.Close() // Best effort cleanup
+	}()
+
+	info, err := file.Stat()
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	if !info.Mode().IsRegular() {
+		result.Error = "not a regular file"
+		return result
+	}
+
+	content, err := io.ReadAll(io.LimitReader(file, maxBytes))
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	result.Content = content
+	result.Size = info.Size()
+	result.Truncated = info.Size() > int64(len(content))
+	return result
+}
@@ -0,0 +1,134 @@
+package ssh
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadFiles(t *testing.T) {
+	dir := t.TempDir()
+	small := filepath.Join(dir, "small.conf")
+	large := filepath.Join(dir, "large.log")
+	if err := os.WriteFile(small, []byte("key=value\n"), 0o600); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	if err := os.WriteFile(large, []byte(strings.Repeat("x", 100)), 0o600); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	server := newTestServer(t)
+	manager := newTestManager(t)
+	connectTestServer(t, manager, server, "default")
+
+	paths := []string{small, large, filepath.Join(dir, "missing"), dir}
+	results, err := manager.ReadFiles("default", paths, 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(results) != len(paths) {
+		t.Fatalf("expected %d results, got %d", len(paths), len(results))
+	}
+
+	if results[0].Error != "" || string(results[0].Content) != "key=value\n" || results[0].Truncated {
+		t.Errorf("unexpected result for small file: %+v", results[0])
+	}
+	if results[1].Error != "" || len(results[1].Content) != 10 || !results[1].Truncated || results[1].Size != 100 {
+		t.Errorf("expected large file to be truncated to 10 bytes, got %+v", results[1])
+	}
+	if results[2].Error == "" {
+		t.Errorf("expected an error for a missing file, got %+v", results[2])
+	}
+	if results[3].Error != "not a regular file" {
+		t.Errorf("expected an error for a directory, got %+v", results[3])
+	}
+
+	if _, err := manager.ReadFiles("unknown", paths, 10); err == nil {
+		t.Errorf("expected an error for an unknown connection")
+	}
+}
+
+func TestListDir(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "b.txt")
+	if err := os.WriteFile(file, []byte("hello"), 0o640); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "a-dir"), 0o750); err != nil {
+		t.Fatalf("failed to create directory: %v", err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "c-dir"), 0o700); err != nil {
+		t.Fatalf("failed to create directory: %v", err)
+	}
+	if err := os.Symlink("a-dir", filepath.Join(dir, "d-link")); err != nil {
+		t.Fatalf("failed to create symlink: %v", err)
+	}
+
+	server := newTestServer(t)
+	manager := newTestManager(t)
+	connectTestServer(t, manager, server, "default")
+
+	listing, err := manager.ListDir("default", dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if listing.Path != dir || listing.Total != 4 || listing.Truncated || len(listing.Entries) != 4 {
+		t.Fatalf("unexpected listing: %+v", listing)
+	}
+	// The link to a directory is listed as a link
+	want := []struct {
+		name  string
+		isDir bool
+		typ   string
+		mode  os.FileMode
+	}{
+		{name: "a-dir", isDir: true, typ: EntryDir, mode: 0o750},
+		{name: "b.txt", typ: EntryFile, mode: 0o640},
+		{name: "c-dir", isDir: true, typ: EntryDir, mode: 0o700},
+		{name: "d-link", typ: EntrySymlink, mode: 0o777},
+	}
+	for i, entry := range listing.Entries {
+		if entry.Name != want[i].name || entry.IsDir != want[i].isDir || entry.Type != want[i].typ || entry.Mode.Perm() != want[i].mode {
+			t.Errorf("entry %d = %+v, want %+v", i, entry, want[i])
+		}
+	}
+	if entry := listing.Entries[1]; entry.Size != 5 || entry.ModTime.IsZero() {
+		t.Errorf("expected the file's size and modification time, got %+v", entry)
+	}
+
+	// An empty path lists the directory SFTP starts in, the home directory
+	// with sshd and the working directory with the test server
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("no working directory: %v", err)
+	}
+	if listing, err := manager.ListDir("default", ""); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	} else if listing.Path != wd {
+		t.Errorf("expected %s to be listed, got %s", wd, listing.Path)
+	}
+
+	if _, err := manager.ListDir("default", file); err == nil || !strings.HasPrefix(err.Error(), "not a directory") {
+		t.Errorf("expected a not a directory error, got %v", err)
+	}
+	if _, err := manager.ListDir("default", filepath.Join(dir, "missing")); err == nil || !strings.HasPrefix(err.Error(), "no such directory") {
+		t.Errorf("expected a no such directory error, got %v", err)
+	}
+
+	// Root may read any directory
+	if os.Geteuid() != 0 {
+		locked := filepath.Join(dir, "c-dir")
+		if err := os.Chmod(locked, 0o100); err != nil {
+			t.Fatalf("failed to change permissions: %v", err)
+		}
+		t.Cleanup(func() { _ = os.Chmod(locked, 0o700) })
+		if _, err := manager.ListDir("default", locked); err == nil || !strings.HasPrefix(err.Error(), "permission denied") {
+			t.Errorf("expected a permission denied error, got %v", err)
+		}
+	}
+
+	if _, err := manager.ListDir("unknown", dir); err == nil {
+		t.Errorf("expected an error for an unknown connection")
+	}
+}
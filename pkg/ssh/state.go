@@ -0,0 +1,198 @@
+package ssh
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ConnectionIndex remembers the connection IDs in use in a small state file,
+// so that after a restart the IDs of the connections that were lost can be
+// recognised. Only IDs are stored, never hosts or credentials.
+type ConnectionIndex struct {
+	path string
+
+	mu sync.Mutex
+
+	// lost are the IDs that were in use before the restart and have not
+	// been connected or closed since
+	lost map[string]bool
+}
+
+// connectionIndexFile is the format of the state file
+type connectionIndexFile struct {
+	Connections []string `json:"connections"`
+}
+
+// LoadConnectionIndex reads the state file at path, treating every ID in it
+// as lost by a restart. A missing file is an empty index. The file is
+// rewritten right away, so that an unwritable path is reported at startup.
+func LoadConnectionIndex(path string) (*ConnectionIndex, error) {
+	index := &ConnectionIndex{
+		path: path,
+		lost: make(map[string]bool),
+	}
+
+	// #nosec G304 - The state file path is set by the operator
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, fmt.Errorf("failed to read state file: %w", err)
+	default:
+		var file connectionIndexFile
+		if err := json.Unmarshal(data, &file); err != nil {
+			return nil, fmt.Errorf("failed to parse state file '%s': %w", path, err)
+		}
+		for _, id := range file.Connections {
+			index.lost[id] = true
+		}
+	}
+
+	if err := index.save(nil); err != nil {
+		return nil, err
+	}
+	return index, nil
+}
+
+// Lost returns the IDs lost by the restart that have not been reused, sorted
+func (x *ConnectionIndex) Lost() []string {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	ids := make([]string, 0, len(x.lost))
+	for id := range x.lost {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// wasLost reports whether id was in use before the restart and has not been
+// connected or closed since
+func (x *ConnectionIndex) wasLost(id string) bool {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	return x.lost[id]
+}
+
+// update stores the open connection IDs, forgetting forgotten from the IDs
+// lost by the restart. Failing to write the file only loses restart hints, so
+// errors are not reported to the operation that caused the update.
+func (x *ConnectionIndex) update(open []string, forgotten string) {
+	x.mu.Lock()
+	delete(x.lost, forgotten)
+	x.mu.Unlock()
+
+	_ = x.save(open)
+}
+
+// save writes the open and lost IDs to the state file, atomically replacing
+// it
+func (x *ConnectionIndex) save(open []string) error {
+	x.mu.Lock()
+	ids := append([]string(nil), open...)
+	for id := range x.lost {
+		ids = append(ids, id)
+	}
+	x.mu.Unlock()
+	slices.Sort(ids)
+	ids = slices.Compact(ids)
+
+	data, err := json.Marshal(connectionIndexFile{Connections: ids})
+	if err != nil {
+		return fmt.Errorf("failed to encode state file: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(x.path), filepath.Base(x.path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name()) // Fails once renamed
+	}()
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), x.path); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	return nil
+}
+
+// ConnectionNotFoundError is returned for an unknown connection ID
+type ConnectionNotFoundError struct {
+	ID string
+
+	// LostOnRestart is set when the ID was in use before the server restarted
+	LostOnRestart bool
+
+	// ExpiredAfter is set to the maximum session lifetime when the
+	// connection was closed for reaching it
+	ExpiredAfter time.Duration
+}
+
+func (e *ConnectionNotFoundError) Error() string {
+	if e.ExpiredAfter > 0 {
+		return fmt.Sprintf("connection '%s' not found: its session expired after the maximum lifetime of %s and was closed; connect again with ssh_connect", e.ID, e.ExpiredAfter)
+	}
+	if e.LostOnRestart {
+		return fmt.Sprintf("connection '%s' not found: it was open before the server restarted and is gone, along with its shell state; connect again with ssh_connect", e.ID)
+	}
+	return fmt.Sprintf("connection '%s' not found", e.ID)
+}
+
+// WithConnectionIndex records the connection IDs in use in index
+func WithConnectionIndex(index *ConnectionIndex) ManagerOption {
+	return func(c *ManagerConfig) {
+		c.ConnectionIndex = index
+	}
+}
+
+// connectionNotFound returns the error for an unknown connection ID
+func (m *Manager) connectionNotFound(id string) error {
+	index := m.config.ConnectionIndex
+	return &ConnectionError{
+		Code: CodeNotFound,
+		Err: &ConnectionNotFoundError{
+			ID:            id,
+			LostOnRestart: index != nil && index.wasLost(id),
+			ExpiredAfter:  m.expired.lifetime(id),
+		},
+	}
+}
+
+// LostOnRestart returns the connection IDs that were in use before the
+// server restarted and have not been connected again, nil without a
+// connection index
+func (m *Manager) LostOnRestart() []string {
+	if m.config.ConnectionIndex == nil {
+		return nil
+	}
+	return m.config.ConnectionIndex.Lost()
+}
+
+// updateIndex records the open connections in the connection index, if any,
+// forgetting that forgotten was lost on restart. m.mu must be held.
+func (m *Manager) updateIndex(forgotten string) {
+	index := m.config.ConnectionIndex
+	if index == nil {
+		return
+	}
+
+	open := make([]string, 0, len(m.connections))
+	for id := range m.connections {
+		open = append(open, id)
+	}
+	index.update(open, forgotten)
+}
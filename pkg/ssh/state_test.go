@@ -0,0 +1,141 @@
+package ssh
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func readConnectionIndex(t *testing.T, path string) []string {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read state file: %v", err)
+	}
+	var file connectionIndexFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		t.Fatalf("failed to parse state file: %v", err)
+	}
+	return file.Connections
+}
+
+func TestLoadConnectionIndex(t *testing.T) {
+	dir := t.TempDir()
+
+	t.Run("missing", func(t *testing.T) {
+		path := filepath.Join(dir, "missing.json")
+		index, err := LoadConnectionIndex(path)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if lost := index.Lost(); len(lost) != 0 {
+			t.Errorf("Lost() = %v, want none", lost)
+		}
+		if ids := readConnectionIndex(t, path); len(ids) != 0 {
+			t.Errorf("state file holds %v, want none", ids)
+		}
+	})
+
+	t.Run("existing", func(t *testing.T) {
+		path := filepath.Join(dir, "existing.json")
+		if err := os.WriteFile(path, []byte(`{"connections":["web","db"]}`), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		index, err := LoadConnectionIndex(path)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if lost := index.Lost(); !slices.Equal(lost, []string{"db", "web"}) {
+			t.Errorf("Lost() = %v, want [db web]", lost)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		path := filepath.Join(dir, "invalid.json")
+		if err := os.WriteFile(path, []byte("not json"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadConnectionIndex(path); err == nil || !strings.Contains(err.Error(), "failed to parse state file") {
+			t.Errorf("expected parse error, got %v", err)
+		}
+	})
+
+	t.Run("unwritable", func(t *testing.T) {
+		path := filepath.Join(dir, "nodir", "state.json")
+		if _, err := LoadConnectionIndex(path); err == nil {
+			t.Error("expected error for a state file in a missing directory")
+		}
+	})
+}
+
+func TestConnectionIndex_Restart(t *testing.T) {
+	server := newTestServer(t)
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	index, err := LoadConnectionIndex(path)
+	if err != nil {
+		t.Fatalf("failed to load state file: %v", err)
+	}
+	manager := newTestManager(t, WithConnectionIndex(index))
+	connectTestServer(t, manager, server, "web")
+	connectTestServer(t, manager, server, "db")
+	if ids := readConnectionIndex(t, path); !slices.Equal(ids, []string{"db", "web"}) {
+		t.Errorf("state file holds %v, want [db web]", ids)
+	}
+
+	// Shutting down keeps the IDs, as after a crash
+	manager.CloseAll()
+
+	index, err = LoadConnectionIndex(path)
+	if err != nil {
+		t.Fatalf("failed to reload state file: %v", err)
+	}
+	manager = newTestManager(t, WithConnectionIndex(index))
+	if lost := manager.LostOnRestart(); !slices.Equal(lost, []string{"db", "web"}) {
+		t.Fatalf("LostOnRestart() = %v, want [db web]", lost)
+	}
+
+	_, err = manager.Execute("web", "pwd")
+	var notFound *ConnectionNotFoundError
+	if !errors.As(err, &notFound) || !notFound.LostOnRestart {
+		t.Fatalf("expected a connection lost on restart, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "server restarted") {
+		t.Errorf("error %q does not mention the restart", err)
+	}
+
+	_, err = manager.Execute("other", "pwd")
+	if !errors.As(err, &notFound) || notFound.LostOnRestart {
+		t.Errorf("expected a plain not found error, got %v", err)
+	}
+
+	result, err := manager.Connect(server.params("web"))
+	if err != nil {
+		t.Fatalf("failed to reconnect: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = manager.Close("web")
+	})
+	if !result.LostOnRestart {
+		t.Error("expected LostOnRestart on reusing a lost ID")
+	}
+	if lost := manager.LostOnRestart(); !slices.Equal(lost, []string{"db"}) {
+		t.Errorf("LostOnRestart() = %v, want [db]", lost)
+	}
+
+	// A lost ID cannot be closed and stays lost until connected again
+	if err := manager.Close("db"); err == nil {
+		t.Error("expected closing a lost ID to fail")
+	}
+	if lost := manager.LostOnRestart(); !slices.Equal(lost, []string{"db"}) {
+		t.Errorf("LostOnRestart() = %v, want [db]", lost)
+	}
+	if ids := readConnectionIndex(t, path); !slices.Equal(ids, []string{"db", "web"}) {
+		t.Errorf("state file holds %v, want [db web]", ids)
+	}
+}
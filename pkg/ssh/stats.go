@@ -0,0 +1,226 @@
+package ssh
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// processListCommand lists every process with the fields needed for command
+// stats. Each -o keyword gets an empty header so that no header line is
+// printed; args comes last as it may contain spaces.
+const processListCommand = "LC_ALL=C ps -A -o pid= -o ppid= -o pgid= -o stat= -o pcpu= -o time= -o rss= -o etime= -o args="
+
+// maxProcessCommandLength truncates long command lines in process stats
+const maxProcessCommandLength = 256
+
+// ProcessStats describes the resource usage of one remote process
+type ProcessStats struct {
+	PID   int
+	PPID  int
+	PGID  int
+	State string
+
+	// CPUPercent is the CPU usage averaged over the process lifetime, as
+	// reported by ps. Compare CPUTime between two calls for recent usage.
+	CPUPercent float64
+	CPUTime    time.Duration
+
+	// RSSKB is the resident memory in kilobytes
+	RSSKB   int64
+	Elapsed time.Duration
+	Command string
+}
+
+// CommandStats describes the resource usage of the command running on a
+// connection's shell
+type CommandStats struct {
+	// Running is false when the shell is idle; the other fields are then empty
+	Running bool
+	Command string
+	Elapsed time.Duration
+
+	ShellPID int
+
+	// Processes are the descendants of the shell, i.e. the processes started
+	// by the running command. It is empty when the command only runs shell
+	// builtins.
+	Processes []ProcessStats
+
+	CPUPercent float64
+	CPUTime    time.Duration
+	RSSKB      int64
+}
+
+// CommandStats reports the CPU and memory usage of the command currently
+// running on a connection. The processes are listed over a separate session,
+// so this works while the shell is busy.
+func (m *Manager) CommandStats(id string) (*CommandStats, error) {
+	m.mu.RLock()
+	conn, exists := m.connections[id]
+	m.mu.RUnlock()
+
+	if !exists {
+		return nil, m.connectionNotFound(id)
+	}
+
+	running := conn.executor.Running()
+	if running == nil {
+		return &CommandStats{}, nil
+	}
+
+	processes, err := conn.shellProcesses()
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &CommandStats{
+		Running:   true,
+		Command:   running.Command,
+		Elapsed:   time.Since(running.Started),
+		ShellPID:  conn.executor.ShellPID(),
+		Processes: processes,
+	}
+
+	filter := conn.executor.Options().OutputFilter
+	for i := range stats.Processes {
+		process := &stats.Processes[i]
+		if filter != nil {
+			process.Command = string(filter(StreamStdout, []byte(process.Command)))
+		}
+		stats.CPUPercent += process.CPUPercent
+		stats.CPUTime += process.CPUTime
+		stats.RSSKB += process.RSSKB
+	}
+	return stats, nil
+}
+
+// shellProcesses lists the processes started by the connection's shell. The
+// list is taken over a separate session, so the shell may be busy.
+func (c *Connection) shellProcesses() ([]ProcessStats, error) {
+	shellPID := c.executor.ShellPID()
+	if shellPID == 0 {
+		return nil, fmt.Errorf("the process ID of the shell of connection '%s' is unknown", c.Info.ID)
+	}
+
+	output, err := c.runSession(processListCommand)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list processes: %w", err)
+	}
+	return descendantProcesses(parseProcessList(string(output)), shellPID), nil
+}
+
+// runSession runs a command in a new session of the connection, outside its
+// persistent shell, and returns its stdout
+func (c *Connection) runSession(command string) ([]byte, error) {
+	return runSession(c.client, command)
+}
+
+// runSession runs a command in a new session of client and returns its stdout
+func runSession(client *ssh.Client, command string) ([]byte, error) {
+	session, err := client.NewSession()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create session: %w", err)
+	}
+	defer func() {
+		_ = session.Close() // Best effort cleanup
+	}()
+
+	return session.Output(command)
+}
+
+// parseProcessList parses the output of processListCommand, skipping lines
+// it cannot make sense of
+func parseProcessList(output string) []ProcessStats {
+	var processes []ProcessStats
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 9 {
+			continue
+		}
+
+		pid, err := strconv.Atoi(fields[0])
+		if err != nil {
+			continue
+		}
+		ppid, _ := strconv.Atoi(fields[1])
+		pgid, _ := strconv.Atoi(fields[2])
+		cpu, _ := strconv.ParseFloat(fields[4], 64)
+		cpuTime, _ := parsePSDuration(fields[5])
+		rss, _ := strconv.ParseInt(fields[6], 10, 64)
+		elapsed, _ := parsePSDuration(fields[7])
+
+		command := strings.Join(fields[8:], " ")
+		if len(command) > maxProcessCommandLength {
+			command = command[:maxProcessCommandLength] + "..."
+		}
+
+		processes = append(processes, ProcessStats{
+			PID:        pid,
+			PPID:       ppid,
+			PGID:       pgid,
+			State:      fields[3],
+			CPUPercent: cpu,
+			CPUTime:    cpuTime,
+			RSSKB:      rss,
+			Elapsed:    elapsed,
+			Command:    command,
+		})
+	}
+	return processes
+}
+
+// descendantProcesses returns the processes below root in the process tree,
+// parents before their children
+func descendantProcesses(processes []ProcessStats, root int) []ProcessStats {
+	children := make(map[int][]ProcessStats)
+	for _, process := range processes {
+		if process.PID != root {
+			children[process.PPID] = append(children[process.PPID], process)
+		}
+	}
+
+	var descendants []ProcessStats
+	queue := []int{root}
+	for len(queue) > 0 {
+		parent := queue[0]
+		queue = queue[1:]
+		for _, child := range children[parent] {
+			descendants = append(descendants, child)
+			queue = append(queue, child.PID)
+		}
+	}
+	return descendants
+}
+
+// parsePSDuration parses the [[dd-]hh:]mm:ss format ps uses for time and
+// etime
+func parsePSDuration(value string) (time.Duration, error) {
+	var days int
+	if d, rest, found := strings.Cut(value, "-"); found {
+		n, err := strconv.Atoi(d)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", value)
+		}
+		days, value = n, rest
+	}
+
+	parts := strings.Split(value, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+
+	var seconds float64
+	for _, part := range parts {
+		n, err := strconv.ParseFloat(part, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", value)
+		}
+		seconds = seconds*60 + n
+	}
+	seconds += float64(days) * 24 * 60 * 60
+	return time.Duration(seconds * float64(time.Second)), nil
+}
@@ -0,0 +1,116 @@
+package ssh
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParsePSDuration(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected time.Duration
+		wantErr  bool
+	}{
+		{"00:05", 5 * time.Second, false},
+		{"01:02:03", time.Hour + 2*time.Minute + 3*time.Second, false},
+		{"2-00:00:01", 48*time.Hour + time.Second, false},
+		{"12:34.56", 12*time.Minute + 34560*time.Millisecond, false},
+		{"42", 0, true},
+		{"x-00:01", 0, true},
+		{"aa:bb", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parsePSDuration(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parsePSDuration(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+			if got != tt.expected {
+				t.Errorf("parsePSDuration(%q) = %v, want %v", tt.value, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestDescendantProcesses(t *testing.T) {
+	output := "    1     0     1 Ss    0.0 00:00:01  1024 10-01:00:00 /sbin/init\n" +
+		"  100     1   100 Ss    0.0 00:00:00  2048    05:00 sh\n" +
+		"  200   100   200 S+   12.5 00:00:30 40960    04:00 make -j4\n" +
+		"  300   200   200 R+   98.0 00:00:20 81920    00:20 cc -c main.c -o main.o\n" +
+		"  400     1   400 S     0.1 00:00:02  4096 01:00:00 sshd: user@notty\n" +
+		"garbage\n"
+
+	processes := parseProcessList(output)
+	if len(processes) != 5 {
+		t.Fatalf("expected 5 processes, got %+v", processes)
+	}
+	expected := ProcessStats{
+		PID:        300,
+		PPID:       200,
+		PGID:       200,
+		State:      "R+",
+		CPUPercent: 98.0,
+		CPUTime:    20 * time.Second,
+		RSSKB:      81920,
+		Elapsed:    20 * time.Second,
+		Command:    "cc -c main.c -o main.o",
+	}
+	if !reflect.DeepEqual(processes[3], expected) {
+		t.Errorf("parseProcessList() = %+v, want %+v", processes[3], expected)
+	}
+
+	var pids []int
+	for _, process := range descendantProcesses(processes, 100) {
+		pids = append(pids, process.PID)
+	}
+	if !reflect.DeepEqual(pids, []int{200, 300}) {
+		t.Errorf("expected the shell's descendants to be 200 and 300, got %v", pids)
+	}
+}
+
+func TestCommandStats(t *testing.T) {
+	server := newTestServer(t)
+	manager := newTestManager(t)
+	connectTestServer(t, manager, server, "default")
+
+	stats, err := manager.CommandStats("default")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stats.Running {
+		t.Errorf("expected an idle shell, got %+v", stats)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := manager.Execute("default", "sleep 1")
+		done <- err
+	}()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		stats, err = manager.CommandStats("default")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(stats.Processes) > 0 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	if !stats.Running || stats.Command != "sleep 1" || stats.ShellPID == 0 {
+		t.Errorf("expected the running command to be reported, got %+v", stats)
+	}
+	if len(stats.Processes) != 1 || !strings.HasPrefix(stats.Processes[0].Command, "sleep") ||
+		stats.Processes[0].PPID != stats.ShellPID {
+		t.Errorf("expected the sleep process to be listed, got %+v", stats.Processes)
+	}
+
+	if err := <-done; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
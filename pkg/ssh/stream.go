@@ -0,0 +1,83 @@
+package ssh
+
+import (
+	"bytes"
+	"unicode/utf8"
+)
+
+// maxStreamLine is the longest line handed to a line callback at once; longer
+// lines are passed on in pieces so that output without newlines still shows
+// progress
+const maxStreamLine = readBufferSize
+
+// ExecuteStream runs a command in the persistent shell like Execute, calling
+// onLine with each line of stdout, without its newline, as soon as the line
+// is complete. The result still holds the whole output and the exit code.
+func (e *ShellExecutor) ExecuteStream(command string, onLine func(line string)) (*CommandResult, error) {
+	return e.ExecuteWithOptions(command, ExecuteOptions{OnStdoutLine: onLine})
+}
+
+// lineSplitter cuts a command's stdout into lines for a line callback,
+// stopping at the delimiter line that ends the output
+type lineSplitter struct {
+	delimiter string
+	emit      func(line string)
+	filter    OutputFilter
+
+	pending []byte
+
+	// emitted counts the bytes handed to emit, which stops at MaxOutputSize
+	emitted int
+
+	// done is set once the delimiter has been seen
+	done bool
+}
+
+// write splits the next data received on stdout, emitting every line it
+// completes
+func (s *lineSplitter) write(data []byte) {
+	if s.done {
+		return
+	}
+
+	s.pending = append(s.pending, data...)
+	for {
+		newline := bytes.IndexByte(s.pending, '\n')
+		if newline < 0 {
+			break
+		}
+		line := s.pending[:newline]
+		s.pending = s.pending[newline+1:]
+		if index := bytes.Index(line, []byte(s.delimiter)); index >= 0 {
+			// Output not ending in a newline runs into the delimiter line
+			if index > 0 {
+				s.line(line[:index])
+			}
+			s.pending, s.done = nil, true
+			return
+		}
+		s.line(bytes.TrimSuffix(line, []byte("\r")))
+	}
+
+	// Part of a long line, short of what may be the start of the delimiter
+	// and of an incomplete character
+	if cut := len(s.pending) - delimiterWindow(s.delimiter); len(s.pending) > maxStreamLine && cut > 0 {
+		for cut > 0 && !utf8.RuneStart(s.pending[cut]) {
+			cut--
+		}
+		s.line(s.pending[:cut])
+		s.pending = append([]byte(nil), s.pending[cut:]...)
+	}
+}
+
+// line hands a line to emit, through the output filter if any
+func (s *lineSplitter) line(data []byte) {
+	if s.emitted >= MaxOutputSize {
+		return
+	}
+	s.emitted += len(data)
+	if s.filter != nil {
+		data = s.filter(StreamStdout, append([]byte(nil), data...))
+	}
+	s.emit(string(data))
+}
@@ -0,0 +1,75 @@
+package ssh
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestExecuteStream(t *testing.T) {
+	server := newTestServer(t)
+	manager := newTestManager(t)
+	connectTestServer(t, manager, server, "default")
+	executor := manager.connections["default"].executor
+
+	type line struct {
+		text string
+		at   time.Time
+	}
+	var lines []line
+	started := time.Now()
+	result, err := executor.ExecuteStream("for i in 1 2 3; do echo step $i; sleep 0.3; done; echo oops >&2; printf tail", func(text string) {
+		lines = append(lines, line{text: text, at: time.Now()})
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []string{"step 1", "step 2", "step 3", "tail"}
+	if len(lines) != len(expected) {
+		t.Fatalf("expected lines %q, got %+v", expected, lines)
+	}
+	for i, line := range lines {
+		if line.text != expected[i] {
+			t.Errorf("line %d: expected %q, got %q", i, expected[i], line.text)
+		}
+	}
+	// Each line arrives while the command is still running
+	if elapsed := lines[0].at.Sub(started); elapsed > 250*time.Millisecond {
+		t.Errorf("expected the first line before the first sleep ended, got it after %v", elapsed)
+	}
+	if lines[1].at.Sub(lines[0].at) < 200*time.Millisecond {
+		t.Errorf("expected the lines to be passed on as they arrive, got %+v", lines)
+	}
+
+	if result.Stdout != "step 1\nstep 2\nstep 3\ntail" || result.Stderr != "oops" || result.ExitCode != 0 {
+		t.Errorf("unexpected result: %+v", result)
+	}
+}
+
+func TestLineSplitter(t *testing.T) {
+	const delimiter = "__MCP_SSH_END_1__"
+
+	var lines []string
+	splitter := &lineSplitter{delimiter: delimiter, emit: func(line string) { lines = append(lines, line) }}
+	long := strings.Repeat("é", maxStreamLine)
+	for _, data := range []string{"one\r\ntw", "o\n", long[:len(long)-1], long[len(long)-1:], "\nlast" + delimiter[:5], delimiter[5:] + ":0\n", "after\n"} {
+		splitter.write([]byte(data))
+	}
+
+	if got := strings.Join(lines, ""); got != "onetwo"+long+"last" {
+		t.Errorf("unexpected output %q", got)
+	}
+	if lines[0] != "one" || lines[1] != "two" || lines[len(lines)-1] != "last" {
+		t.Errorf("unexpected lines %q", lines)
+	}
+	for _, line := range lines {
+		if !utf8.ValidString(line) {
+			t.Errorf("line cut inside a character: %q", line)
+		}
+	}
+	if len(lines) < 5 {
+		t.Errorf("expected the long line to be passed on in pieces, got %d lines", len(lines))
+	}
+}
@@ -0,0 +1,137 @@
+package ssh
+
+import (
+	"fmt"
+	"strings"
+)
+
+// sudoPrompt is the password prompt passed to sudo so that it can be
+// recognised and removed from the output
+const sudoPrompt = "[mcp-ssh] sudo password:"
+
+// sudoLecture holds the lines of the standard sudo lecture and related notices
+var sudoLecture = map[string]bool{
+	"We trust you have received the usual lecture from the local System": true,
+	"Administrator. It usually boils down to these three things:":        true,
+	"#1) Respect the privacy of others.":                                 true,
+	"#2) Think before you type.":                                         true,
+	"#3) With great power comes great responsibility.":                   true,
+	"For security reasons, the password you type will not be visible.":   true,
+	"Sorry, try again.": true,
+}
+
+// sudoAuthFailures are messages sudo prints when it refuses to run a command
+var sudoAuthFailures = []string{
+	"incorrect password attempt",
+	"no password was provided",
+	"a password is required",
+	"a terminal is required",
+	"is not in the sudoers file",
+	"is not allowed to run sudo",
+	"may not run sudo",
+	"Authentication failed",
+}
+
+// SudoOptions configures a command run through sudo
+type SudoOptions struct {
+	// Password is fed to sudo on stdin. When empty, sudo runs
+	// non-interactively and fails if a password would be required.
+	Password string
+
+	// StripNoise removes the sudo lecture, password prompts and retry
+	// messages from stderr
+	StripNoise bool
+}
+
+// SudoResult is the result of a command run through sudo
+type SudoResult struct {
+	*CommandResult
+
+	// AuthFailed is set when sudo refused to run the command, e.g. because
+	// of a wrong password. The command itself did not run.
+	AuthFailed bool
+
+	// AuthError is the message sudo gave for the authentication failure
+	AuthError string
+}
+
+// ExecuteSudo runs a command as root through sudo. The password is piped in
+// with printf, a shell builtin, so it never shows up in the remote process
+// list. The command's stdin is redirected from /dev/null so that it cannot
+// read a password sudo did not consume.
+//
+// The pipeline runs in the persistent shell itself, never in a child shell
+// whose arguments would carry the password, since sudo runs the command in a
+// shell of its own anyway. A command prefix is placed before sudo.
+func (e *ShellExecutor) ExecuteSudo(command string, opts SudoOptions) (*SudoResult, error) {
+	if strings.ContainsAny(opts.Password, "\r\n") {
+		return nil, fmt.Errorf("sudo password must not contain line breaks")
+	}
+
+	inner := shellQuote("exec </dev/null\n" + command)
+
+	sudo := "sudo"
+	if prefix := e.Prefix(); prefix != "" {
+		sudo = prefix + " sudo"
+	}
+
+	var full string
+	if opts.Password == "" {
+		full = fmt.Sprintf("%s -n -- sh -c %s", sudo, inner)
+	} else {
+		full = fmt.Sprintf("printf '%%s\\n' %s | %s -S -p %s -- sh -c %s",
+			shellQuote(opts.Password), sudo, shellQuote(sudoPrompt), inner)
+	}
+
+	result, err := e.ExecuteWithOptions(full, ExecuteOptions{inShell: true})
+	if err != nil {
+		return nil, err
+	}
+
+	sudoResult := &SudoResult{CommandResult: result}
+	if result.ExitCode != 0 {
+		sudoResult.AuthError = sudoAuthError(result.Stderr)
+		sudoResult.AuthFailed = sudoResult.AuthError != ""
+	}
+	if opts.StripNoise {
+		result.Stderr = stripSudoNoise(result.Stderr)
+	}
+	return sudoResult, nil
+}
+
+// sudoAuthError returns sudo's authentication failure message from stderr,
+// or an empty string if sudo did not report one
+func sudoAuthError(stderr string) string {
+	for _, line := range strings.Split(strings.ReplaceAll(stderr, sudoPrompt, ""), "\n") {
+		line = strings.TrimSpace(line)
+		for _, failure := range sudoAuthFailures {
+			if strings.Contains(line, failure) {
+				return line
+			}
+		}
+	}
+	return ""
+}
+
+// stripSudoNoise removes the sudo lecture, our password prompts, retry
+// messages and the "This incident will be reported" notice from stderr
+func stripSudoNoise(stderr string) string {
+	stderr = strings.ReplaceAll(stderr, sudoPrompt, "")
+
+	lines := strings.Split(stderr, "\n")
+	kept := make([]string, 0, len(lines))
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if sudoLecture[trimmed] {
+			continue
+		}
+		if strings.Contains(trimmed, "This incident will be reported") {
+			line = strings.TrimSpace(strings.ReplaceAll(trimmed, "This incident will be reported.", ""))
+			if line == "" {
+				continue
+			}
+		}
+		kept = append(kept, line)
+	}
+	return strings.TrimSpace(strings.Join(kept, "\n"))
+}
@@ -0,0 +1,177 @@
+package ssh
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fakeSudo accepts the password "hunter2" on stdin when run with -S and
+// executes the command following "--"
+const fakeSudo = `#!/bin/sh
+if [ "$1" = "-n" ]; then
+	echo "sudo: a password is required" >&2
+	exit 1
+fi
+printf '%s' "$3" >&2
+read -r password
+if [ "$password" != "hunter2" ]; then
+	echo "Sorry, try again." >&2
+	echo "sudo: 1 incorrect password attempt" >&2
+	exit 1
+fi
+shift 4
+exec "$@"
+`
+
+func TestStripSudoNoise(t *testing.T) {
+	tests := []struct {
+		name     string
+		stderr   string
+		expected string
+	}{
+		{
+			name: "lecture and prompt",
+			stderr: "\nWe trust you have received the usual lecture from the local System\n" +
+				"Administrator. It usually boils down to these three things:\n\n" +
+				"    #1) Respect the privacy of others.\n" +
+				"    #2) Think before you type.\n" +
+				"    #3) With great power comes great responsibility.\n\n" +
+				sudoPrompt + "warning: disk almost full",
+			expected: "warning: disk almost full",
+		},
+		{
+			name:     "retry",
+			stderr:   sudoPrompt + "Sorry, try again.\n" + sudoPrompt + "ls: cannot access 'x'",
+			expected: "ls: cannot access 'x'",
+		},
+		{
+			name:     "incident notice",
+			stderr:   sudoPrompt + "bob is not in the sudoers file.  This incident will be reported.",
+			expected: "bob is not in the sudoers file.",
+		},
+		{
+			name:     "plain command stderr",
+			stderr:   "error: first\nerror: second",
+			expected: "error: first\nerror: second",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := stripSudoNoise(tt.stderr); got != tt.expected {
+				t.Errorf("stripSudoNoise() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestSudoAuthError(t *testing.T) {
+	tests := []struct {
+		name     string
+		stderr   string
+		expected string
+	}{
+		{
+			name:     "wrong password",
+			stderr:   sudoPrompt + "Sorry, try again.\n" + sudoPrompt + "\nsudo: 1 incorrect password attempt",
+			expected: "sudo: 1 incorrect password attempt",
+		},
+		{
+			name:     "password required",
+			stderr:   "sudo: a password is required",
+			expected: "sudo: a password is required",
+		},
+		{
+			name:     "not a sudoer",
+			stderr:   sudoPrompt + "bob is not in the sudoers file.  This incident will be reported.",
+			expected: "bob is not in the sudoers file.  This incident will be reported.",
+		},
+		{
+			name:     "command failure",
+			stderr:   sudoPrompt + "cat: /nonexistent: No such file or directory",
+			expected: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sudoAuthError(tt.stderr); got != tt.expected {
+				t.Errorf("sudoAuthError() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestExecuteSudo(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "sudo"), []byte(fakeSudo), 0o700); err != nil {
+		t.Fatalf("failed to write fake sudo: %v", err)
+	}
+
+	server := newTestServer(t)
+	manager := newTestManager(t)
+	connectTestServer(t, manager, server, "default")
+	if _, err := manager.Execute("default", "export PATH="+shellQuote(dir)+":$PATH"); err != nil {
+		t.Fatalf("failed to set PATH: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		command    string
+		opts       SudoOptions
+		stdout     string
+		stderr     string
+		exitCode   int
+		authFailed bool
+	}{
+		{
+			name:    "correct password",
+			command: "echo \"it's me\"; cat; echo oops >&2; exit 2",
+			opts:    SudoOptions{Password: "hunter2", StripNoise: true},
+			stdout:  "it's me",
+			stderr:  "oops",
+			// The command must not be able to read the password
+			exitCode: 2,
+		},
+		{
+			name:       "wrong password",
+			command:    "echo never",
+			opts:       SudoOptions{Password: "wrong", StripNoise: true},
+			stderr:     "sudo: 1 incorrect password attempt",
+			exitCode:   1,
+			authFailed: true,
+		},
+		{
+			name:       "password required",
+			command:    "echo never",
+			opts:       SudoOptions{},
+			stderr:     "sudo: a password is required",
+			exitCode:   1,
+			authFailed: true,
+		},
+		{
+			name:     "keep noise",
+			command:  "true",
+			opts:     SudoOptions{Password: "hunter2"},
+			stderr:   sudoPrompt,
+			exitCode: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := manager.ExecuteSudo("default", tt.command, tt.opts)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if result.Stdout != tt.stdout || result.Stderr != tt.stderr || result.ExitCode != tt.exitCode {
+				t.Errorf("expected stdout %q, stderr %q, exit code %d, got %q, %q, %d",
+					tt.stdout, tt.stderr, tt.exitCode, result.Stdout, result.Stderr, result.ExitCode)
+			}
+			if result.AuthFailed != tt.authFailed {
+				t.Errorf("expected auth failed %v, got %v (%q)", tt.authFailed, result.AuthFailed, result.AuthError)
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package ssh
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Table is command output split into rows and cells
+type Table struct {
+	// Columns are the column names when the first row was used as a header
+	Columns []string
+	Rows    [][]string
+}
+
+// ParseTable splits output into rows at newlines, skipping blank lines, and
+// each row into cells at delimiter. An empty delimiter splits at runs of
+// whitespace, as most tabular commands align their columns with spaces.
+// Cells are trimmed of surrounding whitespace.
+//
+// With header, the first row names the columns. Rows are then split into at
+// most that many cells, the last one keeping the rest of the line, so that a
+// trailing free-form column such as the command of ps survives. Missing cells
+// are empty.
+func ParseTable(output, delimiter string, header bool) *Table {
+	table := &Table{}
+	limit := -1
+	for _, line := range strings.Split(output, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		cells := splitCells(line, delimiter, limit)
+		if header && table.Columns == nil {
+			table.Columns = columnNames(cells)
+			limit = len(cells)
+			continue
+		}
+		for len(cells) < limit {
+			cells = append(cells, "")
+		}
+		table.Rows = append(table.Rows, cells)
+	}
+	return table
+}
+
+// Records returns the rows as column name to cell maps. It is nil without a
+// header.
+func (t *Table) Records() []map[string]string {
+	if t.Columns == nil {
+		return nil
+	}
+
+	records := make([]map[string]string, len(t.Rows))
+	for i, row := range t.Rows {
+		record := make(map[string]string, len(t.Columns))
+		for j, column := range t.Columns {
+			record[column] = row[j]
+		}
+		records[i] = record
+	}
+	return records
+}
+
+// splitCells splits a line into at most limit cells (no limit if negative)
+func splitCells(line, delimiter string, limit int) []string {
+	var cells []string
+	if delimiter == "" {
+		cells = splitFields(line, limit)
+	} else {
+		cells = strings.SplitN(line, delimiter, limit)
+	}
+	for i, cell := range cells {
+		cells[i] = strings.TrimSpace(cell)
+	}
+	return cells
+}
+
+// splitFields splits s at runs of whitespace like strings.Fields, into at
+// most limit fields (no limit if negative)
+func splitFields(s string, limit int) []string {
+	var fields []string
+	rest := strings.TrimSpace(s)
+	for rest != "" {
+		if limit > 0 && len(fields) == limit-1 {
+			return append(fields, rest)
+		}
+		end := strings.IndexFunc(rest, unicode.IsSpace)
+		if end < 0 {
+			return append(fields, rest)
+		}
+		fields = append(fields, rest[:end])
+		rest = strings.TrimLeftFunc(rest[end:], unicode.IsSpace)
+	}
+	return fields
+}
+
+// columnNames turns a header row into unique column names. Empty names
+// become column_<n> and repeated names get a _<n> suffix.
+func columnNames(cells []string) []string {
+	names := make([]string, len(cells))
+	taken := make(map[string]bool, len(cells))
+	for i, cell := range cells {
+		name := cell
+		if name == "" {
+			name = "column_" + strconv.Itoa(i+1)
+		}
+		for base, n := name, 2; taken[name]; n++ {
+			name = base + "_" + strconv.Itoa(n)
+		}
+		taken[name] = true
+		names[i] = name
+	}
+	return names
+}
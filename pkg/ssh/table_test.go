@@ -0,0 +1,85 @@
+package ssh
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTable(t *testing.T) {
+	tests := []struct {
+		name      string
+		output    string
+		delimiter string
+		header    bool
+		columns   []string
+		rows      [][]string
+	}{
+		{
+			name:   "whitespace",
+			output: "/dev/sda1  41152736 12345  80%  /\n\n/dev/sdb1\t1024\t512\t50%\t/data\n",
+			rows: [][]string{
+				{"/dev/sda1", "41152736", "12345", "80%", "/"},
+				{"/dev/sdb1", "1024", "512", "50%", "/data"},
+			},
+		},
+		{
+			name:      "delimiter",
+			output:    "alice, 30 ,admin\nbob,,\n",
+			delimiter: ",",
+			rows: [][]string{
+				{"alice", "30", "admin"},
+				{"bob", "", ""},
+			},
+		},
+		{
+			name:    "header keeps the rest of the line in the last column",
+			output:  "USER PID COMMAND\nroot 1 /sbin/init splash\nwww 42\n",
+			header:  true,
+			columns: []string{"USER", "PID", "COMMAND"},
+			rows: [][]string{
+				{"root", "1", "/sbin/init splash"},
+				{"www", "42", ""},
+			},
+		},
+		{
+			name:      "header names are made unique",
+			output:    "a;;a;a_2\n1;2;3;4\n",
+			delimiter: ";",
+			header:    true,
+			columns:   []string{"a", "column_2", "a_2", "a_2_2"},
+			rows:      [][]string{{"1", "2", "3", "4"}},
+		},
+		{
+			name:   "empty output",
+			output: "\n",
+			header: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			table := ParseTable(tt.output, tt.delimiter, tt.header)
+			if !reflect.DeepEqual(table.Columns, tt.columns) {
+				t.Errorf("columns = %q, want %q", table.Columns, tt.columns)
+			}
+			if !reflect.DeepEqual(table.Rows, tt.rows) {
+				t.Errorf("rows = %q, want %q", table.Rows, tt.rows)
+			}
+		})
+	}
+}
+
+func TestTable_Records(t *testing.T) {
+	table := ParseTable("NAME STATUS\nweb up\ndb down\n", "", true)
+	expected := []map[string]string{
+		{"NAME": "web", "STATUS": "up"},
+		{"NAME": "db", "STATUS": "down"},
+	}
+	if records := table.Records(); !reflect.DeepEqual(records, expected) {
+		t.Errorf("Records() = %v, want %v", records, expected)
+	}
+
+	if records := ParseTable("web up\n", "", false).Records(); records != nil {
+		t.Errorf("expected no records without a header, got %v", records)
+	}
+}
@@ -0,0 +1,92 @@
+package ssh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+	"time"
+)
+
+// MaxTCPCheckTimeout is the longest timeout TCPCheck accepts
+const MaxTCPCheckTimeout = time.Minute
+
+// Reasons a TCP check failed, see TCPCheckResult.Reason
+const (
+	TCPCheckDNS         = "dns"
+	TCPCheckRefused     = "refused"
+	TCPCheckTimeout     = "timeout"
+	TCPCheckUnreachable = "unreachable"
+	TCPCheckError       = "error"
+)
+
+// TCPCheckResult describes whether a TCP port could be connected to
+type TCPCheckResult struct {
+	Reachable bool
+
+	// RemoteAddr is the address connected to, after name resolution
+	RemoteAddr string
+
+	// Latency is the time taken to establish the connection, or to fail
+	Latency time.Duration
+
+	// Reason classifies a failure (one of the TCPCheck constants) and Error
+	// describes it; both are empty when the port is reachable
+	Reason string
+	Error  string
+}
+
+// TCPCheck opens a plain TCP connection to host:port and closes it right
+// away, without starting an SSH handshake, to tell network problems apart
+// from SSH or authentication ones. An unreachable port is reported in the
+// result; an error is only returned for a host refused by the host
+// validator. A timeout of zero uses the dial timeout.
+func (m *Manager) TCPCheck(ctx context.Context, host string, port int, timeout time.Duration) (*TCPCheckResult, error) {
+	host = unbracketHost(host)
+	if err := m.validator.Validate(host, port); err != nil {
+		return nil, err
+	}
+	if timeout <= 0 {
+		timeout = m.config.DialTimeout
+	}
+
+	dialer := &net.Dialer{Timeout: timeout}
+	addr := net.JoinHostPort(host, fmt.Sprintf("%d", port))
+
+	started := time.Now()
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	result := &TCPCheckResult{Latency: time.Since(started)}
+	if err != nil {
+		result.Reason = dialFailureReason(err)
+		result.Error = err.Error()
+		return result, nil
+	}
+
+	result.Reachable = true
+	result.RemoteAddr = conn.RemoteAddr().String()
+	_ = conn.Close() // Best effort cleanup
+	return result, nil
+}
+
+// dialFailureReason classifies a dial error
+func dialFailureReason(err error) string {
+	var dnsErr *net.DNSError
+	switch {
+	case errors.As(err, &dnsErr):
+		return TCPCheckDNS
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return TCPCheckRefused
+	case errors.Is(err, os.ErrDeadlineExceeded), errors.Is(err, context.DeadlineExceeded):
+		return TCPCheckTimeout
+	case errors.Is(err, syscall.EHOSTUNREACH), errors.Is(err, syscall.ENETUNREACH):
+		return TCPCheckUnreachable
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return TCPCheckTimeout
+	}
+	return TCPCheckError
+}
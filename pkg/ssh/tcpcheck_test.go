@@ -0,0 +1,60 @@
+package ssh
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestManager_TCPCheck(t *testing.T) {
+	manager := newTestManager(t)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+
+	result, err := manager.TCPCheck(context.Background(), "127.0.0.1", port, time.Second)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !result.Reachable || result.RemoteAddr != listener.Addr().String() || result.Reason != "" || result.Latency <= 0 {
+		t.Errorf("expected the port to be reachable, got %+v", result)
+	}
+
+	// Nothing listens on the port once the listener is closed
+	_ = listener.Close()
+	result, err = manager.TCPCheck(context.Background(), "127.0.0.1", port, time.Second)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Reachable || result.Reason != TCPCheckRefused || result.Error == "" {
+		t.Errorf("expected the connection to be refused, got %+v", result)
+	}
+
+	if _, err := manager.TCPCheck(context.Background(), "192.0.2.1", 22, time.Second); err == nil {
+		t.Errorf("expected a host outside of the allowlist to be refused")
+	}
+}
+
+func TestDialFailureReason(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected string
+	}{
+		{"dns", &net.OpError{Op: "dial", Err: &net.DNSError{Err: "no such host", Name: "nope.invalid"}}, TCPCheckDNS},
+		{"timeout", context.DeadlineExceeded, TCPCheckTimeout},
+		{"other", net.ErrClosed, TCPCheckError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := dialFailureReason(tt.err); got != tt.expected {
+				t.Errorf("dialFailureReason() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
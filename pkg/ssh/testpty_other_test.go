@@ -0,0 +1,19 @@
+//go:build !linux
+
+package ssh
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+)
+
+// openPTY is only implemented on Linux; the test server refuses PTYs
+// elsewhere
+func openPTY() (*os.File, error) {
+	return nil, errors.New("PTYs are not supported by the test server on this platform")
+}
+
+func startOnPTY(cmd *exec.Cmd, master *os.File) error {
+	return errors.New("PTYs are not supported by the test server on this platform")
+}
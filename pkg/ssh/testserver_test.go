@@ -0,0 +1,443 @@
+package ssh
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	testUsername = "test"
+	testPassword = "secret"
+)
+
+// testServer is an in-process SSH server whose sessions run a local shell.
+// It is only meant for tests.
+type testServer struct {
+	t        *testing.T
+	listener net.Listener
+	config   *ssh.ServerConfig
+	hostKey  ssh.Signer
+
+	// shell is the command run for "shell" requests (default: sh)
+	shell []string
+
+	// latency, when set, delays every write to and from commands run
+	// without a PTY, like a slow link
+	latency time.Duration
+
+	// sftpHandlers, when set, serve the SFTP subsystem instead of the local
+	// filesystem, e.g. sftp.InMemHandler()
+	sftpHandlers *sftp.Handlers
+
+	// authorizedKeys are the public keys accepted for testUsername besides
+	// testPassword, as marshaled by ssh.PublicKey.Marshal (see authorize)
+	authorizedKeys map[string]bool
+
+	keepalives atomic.Int64
+	forwards   atomic.Int64
+	conns      []net.Conn
+	mu         sync.Mutex
+	wg         sync.WaitGroup
+}
+
+// newTestServer starts a test SSH server accepting testUsername/testPassword
+// and returns it once it is listening. It is closed when the test ends.
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate host key: %v", err)
+	}
+	hostKey, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatalf("failed to create host key signer: %v", err)
+	}
+
+	s := &testServer{
+		t:       t,
+		hostKey: hostKey,
+		shell:   []string{"sh"},
+	}
+	s.config = &ssh.ServerConfig{
+		PasswordCallback: func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if conn.User() == testUsername && string(password) == testPassword {
+				return nil, nil
+			}
+			return nil, errors.New("authentication failed")
+		},
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			if conn.User() == testUsername && s.authorizedKeys[string(key.Marshal())] {
+				return nil, nil
+			}
+			return nil, errors.New("authentication failed")
+		},
+	}
+	s.config.AddHostKey(hostKey)
+
+	s.start()
+	t.Cleanup(s.Close)
+	return s
+}
+
+// start begins accepting connections on a random local port
+func (s *testServer) start() {
+	s.listen("127.0.0.1:0")
+}
+
+// restart accepts connections again on the same port after Close, simulating
+// a host coming back up
+func (s *testServer) restart() {
+	s.listen(s.listener.Addr().String())
+}
+
+// listen begins accepting connections on addr
+func (s *testServer) listen(addr string) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		s.t.Fatalf("failed to listen: %v", err)
+	}
+	s.listener = listener
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.conns = append(s.conns, conn)
+			s.mu.Unlock()
+
+			s.wg.Add(1)
+			go func() {
+				defer s.wg.Done()
+				s.handleConn(conn)
+			}()
+		}
+	}()
+}
+
+// authorize accepts key for testUsername from now on
+func (s *testServer) authorize(key ssh.PublicKey) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.authorizedKeys == nil {
+		s.authorizedKeys = make(map[string]bool)
+	}
+	s.authorizedKeys[string(key.Marshal())] = true
+}
+
+// Port returns the port the server listens on
+func (s *testServer) Port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+// params returns connect parameters for this server
+func (s *testServer) params(id string) ConnectParams {
+	return ConnectParams{
+		ID:       id,
+		Host:     "127.0.0.1",
+		Port:     s.Port(),
+		Username: testUsername,
+		Password: testPassword,
+	}
+}
+
+// DropConnections closes all established connections without stopping the
+// listener, simulating a network failure
+func (s *testServer) DropConnections() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, conn := range s.conns {
+		_ = conn.Close()
+	}
+	s.conns = nil
+}
+
+// Close stops the server and closes all connections
+func (s *testServer) Close() {
+	_ = s.listener.Close()
+	s.DropConnections()
+	s.wg.Wait()
+}
+
+// handleConn serves a single SSH connection
+func (s *testServer) handleConn(netConn net.Conn) {
+	sshConn, chans, reqs, err := ssh.NewServerConn(netConn, s.config)
+	if err != nil {
+		_ = netConn.Close()
+		return
+	}
+	defer func() { _ = sshConn.Close() }()
+
+	go func() {
+		for req := range reqs {
+			if req.Type == "keepalive@openssh.com" {
+				s.keepalives.Add(1)
+			}
+			if req.WantReply {
+				_ = req.Reply(req.Type == "keepalive@openssh.com", nil)
+			}
+		}
+	}()
+
+	for newChannel := range chans {
+		if newChannel.ChannelType() == "direct-tcpip" {
+			s.wg.Add(1)
+			go func() {
+				defer s.wg.Done()
+				s.forward(newChannel)
+			}()
+			continue
+		}
+		if newChannel.ChannelType() != "session" {
+			_ = newChannel.Reject(ssh.UnknownChannelType, "unsupported channel type")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			continue
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.handleSession(channel, requests)
+		}()
+	}
+}
+
+// forward serves a direct-tcpip channel, as used by clients tunneling
+// through the server as a jump host, by connecting it to the requested
+// address
+func (s *testServer) forward(newChannel ssh.NewChannel) {
+	var target struct {
+		Host       string
+		Port       uint32
+		OriginHost string
+		OriginPort uint32
+	}
+	if err := ssh.Unmarshal(newChannel.ExtraData(), &target); err != nil {
+		_ = newChannel.Reject(ssh.ConnectionFailed, "malformed request")
+		return
+	}
+	conn, err := net.Dial("tcp", net.JoinHostPort(target.Host, strconv.Itoa(int(target.Port))))
+	if err != nil {
+		_ = newChannel.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+	defer func() { _ = conn.Close() }()
+	channel, requests, err := newChannel.Accept()
+	if err != nil {
+		return
+	}
+	defer func() { _ = channel.Close() }()
+	go ssh.DiscardRequests(requests)
+	s.forwards.Add(1)
+
+	done := make(chan struct{}, 2)
+	go func() {
+		_, _ = io.Copy(conn, channel)
+		done <- struct{}{}
+	}()
+	go func() {
+		_, _ = io.Copy(channel, conn)
+		done <- struct{}{}
+	}()
+	<-done
+}
+
+// handleSession serves the requests of a session channel
+func (s *testServer) handleSession(channel ssh.Channel, requests <-chan *ssh.Request) {
+	defer func() { _ = channel.Close() }()
+
+	// terminal is the PTY requested for the session, if any
+	var terminal *os.File
+	defer func() {
+		if terminal != nil {
+			_ = terminal.Close()
+		}
+	}()
+
+	for req := range requests {
+		switch req.Type {
+		case "pty-req":
+			master, err := openPTY()
+			if err == nil {
+				terminal = master
+			}
+			if req.WantReply {
+				_ = req.Reply(err == nil, nil)
+			}
+		case "shell":
+			_ = req.Reply(true, nil)
+			if terminal != nil {
+				s.runPTY(channel, terminal, s.shell)
+				return
+			}
+			s.run(channel, s.shell)
+			return
+		case "exec":
+			command := parseSSHString(req.Payload)
+			_ = req.Reply(true, nil)
+			s.run(channel, []string{"sh", "-c", command})
+			return
+		case "subsystem":
+			if parseSSHString(req.Payload) != "sftp" {
+				_ = req.Reply(false, nil)
+				continue
+			}
+			_ = req.Reply(true, nil)
+			s.serveSFTP(channel)
+			return
+		default:
+			if req.WantReply {
+				_ = req.Reply(req.Type == "env", nil)
+			}
+		}
+	}
+}
+
+// run executes a local command wired to the channel and reports its exit status
+func (s *testServer) run(channel ssh.Channel, args []string) {
+	cmd := exec.CommandContext(context.Background(), args[0], args[1:]...)
+	cmd.Stdout = s.delayed(channel)
+	cmd.Stderr = s.delayed(channel.Stderr())
+
+	// Feed stdin through a pipe so that the session ends as soon as the
+	// command exits, even if the client keeps the channel open
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return
+	}
+	go func() {
+		_, _ = io.Copy(s.delayed(stdin), channel)
+		_ = stdin.Close()
+	}()
+
+	sendExitStatus(channel, cmd.Run())
+}
+
+// delayed returns w, delaying each write by the server's latency if set
+func (s *testServer) delayed(w io.Writer) io.Writer {
+	if s.latency <= 0 {
+		return w
+	}
+	return &delayedWriter{w: w, delay: s.latency}
+}
+
+// delayedWriter sleeps before each write to the underlying writer
+type delayedWriter struct {
+	w     io.Writer
+	delay time.Duration
+}
+
+func (d *delayedWriter) Write(p []byte) (int, error) {
+	time.Sleep(d.delay)
+	return d.w.Write(p)
+}
+
+// runPTY executes a local command on the PTY whose master is terminal, wired
+// to the channel, and reports its exit status
+func (s *testServer) runPTY(channel ssh.Channel, terminal *os.File, args []string) {
+	cmd := exec.CommandContext(context.Background(), args[0], args[1:]...)
+	if err := startOnPTY(cmd, terminal); err != nil {
+		return
+	}
+
+	// A terminal has no end of input; like sshd hanging up, end the shell
+	// once the client closes the channel
+	go func() {
+		_, _ = io.Copy(terminal, channel)
+		_ = cmd.Process.Kill()
+	}()
+
+	// Reading the master fails once the terminal has no process left
+	output := make(chan struct{})
+	go func() {
+		_, _ = io.Copy(channel, terminal)
+		close(output)
+	}()
+
+	err := cmd.Wait()
+	<-output
+	sendExitStatus(channel, err)
+}
+
+// sendExitStatus reports the exit status of a command that ended with err
+func sendExitStatus(channel ssh.Channel, err error) {
+	status := 0
+	if err != nil {
+		status = 1
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			status = exitErr.ExitCode()
+		}
+	}
+
+	payload := make([]byte, 4)
+	binary.BigEndian.PutUint32(payload, uint32(status)) // #nosec G115 - exit codes are small
+	_, _ = channel.SendRequest("exit-status", false, payload)
+}
+
+// serveSFTP serves the SFTP subsystem on the channel from the local
+// filesystem, or from sftpHandlers if set
+func (s *testServer) serveSFTP(channel ssh.Channel) {
+	if s.sftpHandlers != nil {
+		server := sftp.NewRequestServer(channel, *s.sftpHandlers)
+		_ = server.Serve()
+		_ = server.Close()
+		return
+	}
+
+	server, err := sftp.NewServer(channel)
+	if err != nil {
+		return
+	}
+	_ = server.Serve()
+	_ = server.Close()
+}
+
+// parseSSHString decodes an SSH wire-format string
+func parseSSHString(payload []byte) string {
+	if len(payload) < 4 {
+		return ""
+	}
+	length := binary.BigEndian.Uint32(payload)
+	if int(length) > len(payload)-4 {
+		return ""
+	}
+	return string(payload[4 : 4+length])
+}
+
+// connectTestServer connects the manager to the test server under id
+func connectTestServer(t *testing.T, manager *Manager, server *testServer, id string) {
+	t.Helper()
+
+	if _, err := manager.Connect(server.params(id)); err != nil {
+		t.Fatalf("failed to connect to test server: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = manager.Close(id)
+	})
+}
@@ -0,0 +1,234 @@
+package ssh
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/pkg/sftp"
+)
+
+// DefaultMaxTransferSize bounds the size of a file transferred by Upload or
+// Download unless configured otherwise
+const DefaultMaxTransferSize int64 = 100 << 20
+
+// MaxInlineDownloadSize bounds the size of a file returned by DownloadInline
+const MaxInlineDownloadSize = MaxOutputSize
+
+// TransferResult describes a file copied between this machine and a remote
+// host
+type TransferResult struct {
+	// LocalPath is the absolute local path, empty for DownloadInline
+	LocalPath string
+
+	// RemotePath is the remote path as resolved by the SFTP server
+	RemotePath string
+
+	Bytes int64
+	Mode  os.FileMode
+
+	// Content is the file's content, set by DownloadInline
+	Content []byte
+}
+
+// WithMaxTransferSize bounds the size of the files Upload and Download
+// transfer
+func WithMaxTransferSize(maxBytes int64) ManagerOption {
+	return func(c *ManagerConfig) {
+		if maxBytes > 0 {
+			c.MaxTransferSize = maxBytes
+		}
+	}
+}
+
+// Upload copies a file from this machine to the remote host over SFTP.
+// localPath is read from below the configured local output directory, like
+// ExecuteToLocal writes there: relative to it or absolute within it. Missing
+// parent directories of remotePath are created and the file's permission
+// bits are preserved; an existing remote file is only replaced with
+// overwrite. Files larger than the configured transfer limit are refused.
+func (m *Manager) Upload(id, localPath, remotePath string, overwrite bool) (*TransferResult, error) {
+	if m.config.LocalOutputDir == "" {
+		return nil, fmt.Errorf("no local output directory is configured")
+	}
+	if strings.TrimSpace(remotePath) == "" {
+		return nil, fmt.Errorf("remote path cannot be empty")
+	}
+
+	client, err := m.sftpClient(id)
+	if err != nil {
+		return nil, err
+	}
+
+	local, localAbs, err := openLocalFile(m.config.LocalOutputDir, localPath)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = local.Close() // Best effort cleanup
+	}()
+
+	info, err := local.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat local file '%s': %w", localPath, err)
+	}
+	if !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("local path '%s' is not a regular file", localPath)
+	}
+	if info.Size() > m.config.MaxTransferSize {
+		return nil, fmt.Errorf("local file '%s' is %d bytes, more than the transfer limit of %d bytes", localPath, info.Size(), m.config.MaxTransferSize)
+	}
+
+	resolved, err := m.resolveRemotePath(client, remotePath)
+	if err != nil {
+		return nil, err
+	}
+	if existing, err := client.Stat(resolved); err == nil {
+		if existing.IsDir() {
+			return nil, fmt.Errorf("remote path '%s' is a directory", resolved)
+		}
+		if !overwrite {
+			return nil, fmt.Errorf("remote file '%s' already exists; set overwrite to replace it", resolved)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to stat remote file '%s': %w", resolved, err)
+	}
+
+	if err := client.MkdirAll(path.Dir(resolved)); err != nil {
+		return nil, fmt.Errorf("failed to create remote directory for '%s': %w", resolved, err)
+	}
+
+	remote, err := client.OpenFile(resolved, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create remote file '%s': %w", resolved, err)
+	}
+	written, err := io.Copy(remote, io.LimitReader(local, info.Size()))
+	if err == nil {
+		err = remote.Chmod(info.Mode().Perm())
+	}
+	if closeErr := remote.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to write remote file '%s': %w", resolved, err)
+	}
+
+	return &TransferResult{
+		LocalPath:  localAbs,
+		RemotePath: resolved,
+		Bytes:      written,
+		Mode:       info.Mode().Perm(),
+	}, nil
+}
+
+// Download copies a remote file to this machine over SFTP, streaming it into
+// localPath below the configured local output directory: relative to it or
+// absolute within it. Missing local parent directories are created and the
+// file's permission bits are preserved; an existing local file is only
+// replaced with overwrite. Files larger than the configured transfer limit
+// are refused.
+func (m *Manager) Download(id, remotePath, localPath string, overwrite bool) (*TransferResult, error) {
+	if m.config.LocalOutputDir == "" {
+		return nil, fmt.Errorf("no local output directory is configured")
+	}
+
+	remote, resolved, info, err := m.openRemoteFile(id, remotePath, m.config.MaxTransferSize, "transfer")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = remote.Close() // Best effort cleanup
+	}()
+
+	local, localAbs, err := createLocalFile(m.config.LocalOutputDir, localPath, overwrite)
+	if err != nil {
+		return nil, err
+	}
+	written, err := io.Copy(local, io.LimitReader(remote, info.Size()))
+	if err == nil {
+		err = local.Chmod(info.Mode().Perm())
+	}
+	if closeErr := local.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(localAbs) // Do not leave a partial file behind
+		return nil, fmt.Errorf("failed to download remote file '%s': %w", resolved, err)
+	}
+
+	return &TransferResult{
+		LocalPath:  localAbs,
+		RemotePath: resolved,
+		Bytes:      written,
+		Mode:       info.Mode().Perm(),
+	}, nil
+}
+
+// DownloadInline reads a remote file of at most MaxInlineDownloadSize bytes
+// over SFTP and returns its content, without writing anything locally
+func (m *Manager) DownloadInline(id, remotePath string) (*TransferResult, error) {
+	remote, resolved, info, err := m.openRemoteFile(id, remotePath, MaxInlineDownloadSize, "inline download")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = remote.Close() // Best effort cleanup
+	}()
+
+	content, err := io.ReadAll(io.LimitReader(remote, info.Size()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read remote file '%s': %w", resolved, err)
+	}
+
+	return &TransferResult{
+		RemotePath: resolved,
+		Bytes:      int64(len(content)),
+		Mode:       info.Mode().Perm(),
+		Content:    content,
+	}, nil
+}
+
+// openRemoteFile opens a regular remote file of at most maxBytes for
+// reading, returning it with its resolved path and file info. limitName
+// names the limit in the error for larger files.
+func (m *Manager) openRemoteFile(id, remotePath string, maxBytes int64, limitName string) (*sftp.File, string, os.FileInfo, error) {
+	if strings.TrimSpace(remotePath) == "" {
+		return nil, "", nil, fmt.Errorf("remote path cannot be empty")
+	}
+
+	client, err := m.sftpClient(id)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	resolved, err := m.resolveRemotePath(client, remotePath)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	remote, err := client.Open(resolved)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, "", nil, fmt.Errorf("no such file: remote file '%s' does not exist", resolved)
+	}
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("failed to open remote file '%s': %w", resolved, err)
+	}
+
+	info, err := remote.Stat()
+	switch {
+	case err != nil:
+		err = fmt.Errorf("failed to stat remote file '%s': %w", resolved, err)
+	case !info.Mode().IsRegular():
+		err = fmt.Errorf("remote path '%s' is not a regular file", resolved)
+	case info.Size() > maxBytes:
+		err = fmt.Errorf("remote file '%s' is %d bytes, more than the %s limit of %d bytes", resolved, info.Size(), limitName, maxBytes)
+	}
+	if err != nil {
+		_ = remote.Close() // Best effort cleanup
+		return nil, "", nil, err
+	}
+	return remote, resolved, info, nil
+}
@@ -0,0 +1,146 @@
+package ssh
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pkg/sftp"
+)
+
+func TestUpload(t *testing.T) {
+	server := newTestServer(t)
+	handlers := sftp.InMemHandler()
+	server.sftpHandlers = &handlers
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "app.conf"), []byte("port=8080\n"), 0o600); err != nil {
+		t.Fatalf("failed to write local file: %v", err)
+	}
+	manager := newTestManager(t, WithLocalOutput(dir, 0), WithMaxTransferSize(64))
+	connectTestServer(t, manager, server, "default")
+
+	// Missing parent directories are created
+	result, err := manager.Upload("default", "app.conf", "/etc/app/conf.d/app.conf", false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Bytes != 10 || result.RemotePath != "/etc/app/conf.d/app.conf" || result.LocalPath != filepath.Join(dir, "app.conf") {
+		t.Errorf("unexpected result %+v", result)
+	}
+	files, err := manager.ReadFiles("default", []string{"/etc/app/conf.d/app.conf"}, 0)
+	if err != nil || files[0].Error != "" || string(files[0].Content) != "port=8080\n" {
+		t.Fatalf("expected the uploaded content, got %+v, %v", files, err)
+	}
+
+	if _, err := manager.Upload("default", "app.conf", "/etc/app/conf.d/app.conf", false); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("expected an existing file to be kept without overwrite, got %v", err)
+	}
+	if _, err := manager.Upload("default", "app.conf", "/etc/app/conf.d/app.conf", true); err != nil {
+		t.Errorf("expected overwrite to replace the file, got %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "big.bin"), make([]byte, 65), 0o600); err != nil {
+		t.Fatalf("failed to write local file: %v", err)
+	}
+	tests := []struct {
+		name       string
+		localPath  string
+		remotePath string
+		wantErr    string
+	}{
+		{name: "too large", localPath: "big.bin", remotePath: "/big.bin", wantErr: "transfer limit"},
+		{name: "empty remote path", localPath: "app.conf", remotePath: " ", wantErr: "remote path cannot be empty"},
+		{name: "outside local directory", localPath: "../secret", remotePath: "/secret", wantErr: "outside of"},
+		{name: "missing local file", localPath: "missing.conf", remotePath: "/missing.conf", wantErr: "does not exist"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := manager.Upload("default", tt.localPath, tt.remotePath, false); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected an error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestUpload_PreservesMode(t *testing.T) {
+	server := newTestServer(t)
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "deploy.sh"), []byte("#!/bin/sh\necho deployed\n"), 0o750); err != nil {
+		t.Fatalf("failed to write local file: %v", err)
+	}
+	manager := newTestManager(t, WithLocalOutput(dir, 0))
+	connectTestServer(t, manager, server, "default")
+
+	remotePath := filepath.Join(t.TempDir(), "bin", "deploy.sh")
+	result, err := manager.Upload("default", "deploy.sh", remotePath, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Mode != 0o750 {
+		t.Errorf("expected mode 0750, got %o", result.Mode)
+	}
+
+	run, err := manager.Execute("default", shellQuote(remotePath))
+	if err != nil || run.Stdout != "deployed" {
+		t.Errorf("expected the uploaded script to be executable, got %+v, %v", run, err)
+	}
+}
+
+func TestDownload(t *testing.T) {
+	server := newTestServer(t)
+	dir := t.TempDir()
+	manager := newTestManager(t, WithLocalOutput(dir, 0), WithMaxTransferSize(64))
+	connectTestServer(t, manager, server, "default")
+
+	remoteDir := t.TempDir()
+	remotePath := filepath.Join(remoteDir, "run.sh")
+	if err := os.WriteFile(remotePath, []byte("#!/bin/sh\n"), 0o750); err != nil {
+		t.Fatalf("failed to write remote file: %v", err)
+	}
+
+	result, err := manager.Download("default", remotePath, "fetched/run.sh", false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Bytes != 10 || result.LocalPath != filepath.Join(dir, "fetched", "run.sh") || result.Mode != 0o750 {
+		t.Errorf("unexpected result %+v", result)
+	}
+	info, err := os.Stat(result.LocalPath)
+	if err != nil || info.Mode().Perm() != 0o750 {
+		t.Errorf("expected the local file with mode 0750, got %v, %v", info, err)
+	}
+
+	inline, err := manager.DownloadInline("default", remotePath)
+	if err != nil || string(inline.Content) != "#!/bin/sh\n" || inline.LocalPath != "" {
+		t.Errorf("expected the content inline, got %+v, %v", inline, err)
+	}
+
+	if err := os.WriteFile(filepath.Join(remoteDir, "big.bin"), make([]byte, 65), 0o600); err != nil {
+		t.Fatalf("failed to write remote file: %v", err)
+	}
+	tests := []struct {
+		name       string
+		remotePath string
+		localPath  string
+		wantErr    string
+	}{
+		{name: "missing", remotePath: filepath.Join(remoteDir, "missing.txt"), localPath: "missing.txt", wantErr: "no such file"},
+		{name: "directory", remotePath: remoteDir, localPath: "dir", wantErr: "not a regular file"},
+		{name: "too large", remotePath: filepath.Join(remoteDir, "big.bin"), localPath: "big.bin", wantErr: "transfer limit"},
+		{name: "existing", remotePath: remotePath, localPath: "fetched/run.sh", wantErr: "already exists"},
+		{name: "outside local directory", remotePath: remotePath, localPath: "../run.sh", wantErr: "outside of"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := manager.Download("default", tt.remotePath, tt.localPath, false); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected an error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+
+	if _, err := manager.DownloadInline("default", filepath.Join(remoteDir, "missing.txt")); err == nil || !strings.Contains(err.Error(), "no such file") {
+		t.Errorf("expected a no such file error inline, got %v", err)
+	}
+}
@@ -0,0 +1,44 @@
+package ssh
+
+import "unicode/utf8"
+
+// incompleteUTF8Suffix returns the length of the UTF-8 sequence that data
+// ends in the middle of, 0 if it ends on a character boundary. Invalid bytes,
+// as in binary output, are never held back.
+func incompleteUTF8Suffix(data []byte) int {
+	for i := len(data) - 1; i >= 0 && i >= len(data)-(utf8.UTFMax-1); i-- {
+		if !utf8.RuneStart(data[i]) {
+			continue
+		}
+		if utf8.FullRune(data[i:]) {
+			return 0
+		}
+		return len(data) - i
+	}
+	return 0
+}
+
+// utf8Holdback splits a stream read in arbitrary pieces at character
+// boundaries, holding back a trailing incomplete sequence until the next
+// piece completes it
+type utf8Holdback struct {
+	pending []byte
+}
+
+// next returns the held back bytes followed by data, minus any incomplete
+// sequence at its end, which is held back in turn
+func (h *utf8Holdback) next(data []byte) []byte {
+	if len(h.pending) > 0 {
+		data = append(h.pending, data...)
+	}
+	keep := incompleteUTF8Suffix(data)
+	h.pending = append([]byte(nil), data[len(data)-keep:]...)
+	return data[:len(data)-keep]
+}
+
+// flush returns the bytes still held back, for the end of the stream
+func (h *utf8Holdback) flush() []byte {
+	pending := h.pending
+	h.pending = nil
+	return pending
+}
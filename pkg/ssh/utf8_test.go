@@ -0,0 +1,119 @@
+package ssh
+
+import (
+	"bytes"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestIncompleteUTF8Suffix(t *testing.T) {
+	tests := []struct {
+		data     string
+		expected int
+	}{
+		{data: "", expected: 0},
+		{data: "ascii", expected: 0},
+		{data: "caf\xc3", expected: 1},
+		{data: "café", expected: 0},
+		{data: "\xe2\x82", expected: 2},
+		{data: "x\xf0\x9f\x98", expected: 3},
+		{data: "😀", expected: 0},
+		// Invalid bytes and stray continuation bytes are passed on as is
+		{data: "\xff", expected: 0},
+		{data: "a\x82\x82\x82", expected: 0},
+	}
+
+	for _, tt := range tests {
+		if got := incompleteUTF8Suffix([]byte(tt.data)); got != tt.expected {
+			t.Errorf("incompleteUTF8Suffix(%q) = %d, want %d", tt.data, got, tt.expected)
+		}
+	}
+}
+
+func TestUTF8Holdback(t *testing.T) {
+	text := []byte("héllo → wörld 😀 ✓")
+
+	// Split the text at every possible pair of offsets
+	for i := 0; i <= len(text); i++ {
+		for j := i; j <= len(text); j++ {
+			var h utf8Holdback
+			var out []byte
+			for _, piece := range [][]byte{text[:i], text[i:j], text[j:]} {
+				data := h.next(piece)
+				if !utf8.Valid(data) {
+					t.Fatalf("split at %d/%d: piece %q is not valid UTF-8", i, j, data)
+				}
+				out = append(out, data...)
+			}
+			out = append(out, h.flush()...)
+			if !bytes.Equal(out, text) {
+				t.Fatalf("split at %d/%d: got %q, want %q", i, j, out, text)
+			}
+		}
+	}
+
+	// An incomplete sequence at the end of the stream is flushed as is
+	var h utf8Holdback
+	if data := h.next([]byte("ok\xe2\x82")); string(data) != "ok" {
+		t.Errorf("expected the incomplete sequence to be held back, got %q", data)
+	}
+	if pending := h.flush(); string(pending) != "\xe2\x82" {
+		t.Errorf("expected the held back bytes to be flushed, got %q", pending)
+	}
+}
+
+func TestExecuteWithOptions_CaptureChunksSplitCharacter(t *testing.T) {
+	server := newTestServer(t)
+	manager := newTestManager(t)
+	connectTestServer(t, manager, server, "default")
+
+	// "é" is written in two halves with stderr output in between
+	command := `printf 'caf\303'; sleep 0.05; echo err >&2; sleep 0.05; printf '\251\n'`
+	result, err := manager.ExecuteWithOptions("default", command, ExecuteOptions{CaptureChunks: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []OutputChunk{
+		{Stream: StreamStdout, Data: "caf"},
+		{Stream: StreamStderr, Data: "err\n"},
+		{Stream: StreamStdout, Data: "é\n"},
+	}
+	if len(result.Chunks) != len(expected) {
+		t.Fatalf("expected %d chunks, got %+v", len(expected), result.Chunks)
+	}
+	for i, chunk := range result.Chunks {
+		if chunk.Stream != expected[i].Stream || chunk.Data != expected[i].Data {
+			t.Errorf("chunk %d: expected %s %q, got %s %q", i, expected[i].Stream, expected[i].Data, chunk.Stream, chunk.Data)
+		}
+	}
+	if result.Stdout != "café" {
+		t.Errorf("expected stdout %q, got %q", "café", result.Stdout)
+	}
+}
+
+func TestLineFilterWriter_LongLineSplitCharacter(t *testing.T) {
+	var out bytes.Buffer
+	w := &lineFilterWriter{w: &out, stream: StreamStdout, filter: func(_ string, data []byte) []byte {
+		if !utf8.Valid(data) {
+			t.Errorf("filter received invalid UTF-8 ending in %q", data[max(0, len(data)-4):])
+		}
+		return data
+	}}
+
+	// The limit falls in the middle of the second byte of "€"
+	line := bytes.Repeat([]byte("a"), filterLineLimit-2)
+	line = append(line, "€€\n"...)
+	if _, err := w.Write(line[:filterLineLimit]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(line[filterLineLimit:]); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.flush(); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out.Bytes(), line) {
+		t.Errorf("output differs from input")
+	}
+}
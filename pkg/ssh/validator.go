@@ -2,6 +2,9 @@ package ssh
 
 import (
 	"fmt"
+	"net"
+	"os"
+	"strconv"
 	"strings"
 
 	"github.com/gobwas/glob"
@@ -9,18 +12,91 @@ import (
 
 // HostValidator validates SSH hosts against allowed patterns
 type HostValidator struct {
-	patterns []glob.Glob
+	patterns []hostPattern
+}
+
+// hostPattern is a compiled allowed host pattern
+type hostPattern struct {
+	glob glob.Glob
+
+	// fold is set when the pattern was lowercased, so hosts must be too
+	fold bool
+
+	// port is the only port allowed on matching hosts, zero for any
+	port int
+}
+
+// ValidatorOption configures a HostValidator
+type ValidatorOption func(*validatorConfig)
+
+// validatorConfig holds the settings of a HostValidator while it is built
+type validatorConfig struct {
+	caseInsensitive bool
+}
+
+// WithCaseInsensitiveHosts matches hostnames regardless of case, as DNS
+// does, by lowercasing both the patterns and the hosts validated against
+// them. IP address patterns are matched as given, IPv6 ones being in
+// canonical form anyway.
+func WithCaseInsensitiveHosts(enabled bool) ValidatorOption {
+	return func(c *validatorConfig) {
+		c.caseInsensitive = enabled
+	}
 }
 
 // NewHostValidator creates a new host validator with the given allowed hosts
 // allowedHosts is a comma-separated list of host patterns (supports glob: *.example.com)
-func NewHostValidator(allowedHosts string) (*HostValidator, error) {
+// A pattern may end with a port, e.g. example.com:22 or [2001:db8::*]:2200,
+// to allow only that port; without one, any port is allowed.
+func NewHostValidator(allowedHosts string, options ...ValidatorOption) (*HostValidator, error) {
 	if allowedHosts == "" {
 		return nil, fmt.Errorf("no allowed hosts specified")
 	}
 
-	hosts := strings.Split(allowedHosts, ",")
-	patterns := make([]glob.Glob, 0, len(hosts))
+	return newHostValidator(strings.Split(allowedHosts, ","), options)
+}
+
+// NewHostValidatorFromFile creates a host validator with the patterns listed
+// in a file, one per line, plus the comma-separated allowedHosts if any.
+// Blank lines and comments starting with '#' are ignored.
+func NewHostValidatorFromFile(path, allowedHosts string, options ...ValidatorOption) (*HostValidator, error) {
+	// #nosec G304 - Allowed hosts file path is provided by the operator via CLI flag
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read allowed hosts file: %w", err)
+	}
+
+	hosts := parseHostsFile(string(data))
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("no host patterns in allowed hosts file '%s'", path)
+	}
+	if allowedHosts != "" {
+		hosts = append(hosts, strings.Split(allowedHosts, ",")...)
+	}
+	return newHostValidator(hosts, options)
+}
+
+// parseHostsFile returns the patterns of an allowed hosts file, without
+// blank lines and comments
+func parseHostsFile(content string) []string {
+	var hosts []string
+	for _, line := range strings.Split(content, "\n") {
+		line, _, _ = strings.Cut(line, "#")
+		if line = strings.TrimSpace(line); line != "" {
+			hosts = append(hosts, line)
+		}
+	}
+	return hosts
+}
+
+// newHostValidator compiles host patterns, skipping empty ones
+func newHostValidator(hosts []string, options []ValidatorOption) (*HostValidator, error) {
+	var config validatorConfig
+	for _, option := range options {
+		option(&config)
+	}
+
+	patterns := make([]hostPattern, 0, len(hosts))
 
 	for _, host := range hosts {
 		host = strings.TrimSpace(host)
@@ -28,12 +104,29 @@ func NewHostValidator(allowedHosts string) (*HostValidator, error) {
 			continue
 		}
 
+		host, port, err := splitHostPattern(host)
+		if err != nil {
+			return nil, err
+		}
+
+		fold := config.caseInsensitive && !isIPPattern(host)
+		if fold {
+			host = strings.ToLower(host)
+		}
+
+		// An IPv6 pattern may be bracketed as in a URL, where the brackets
+		// would otherwise be taken for a character class, and is matched
+		// against the canonical form of the address
+		if strings.Contains(host, ":") {
+			host = canonicalHost(strings.ToLower(unbracketHost(host)))
+		}
+
 		pattern, err := glob.Compile(host)
 		if err != nil {
 			return nil, fmt.Errorf("invalid host pattern '%s': %w", host, err)
 		}
 
-		patterns = append(patterns, pattern)
+		patterns = append(patterns, hostPattern{glob: pattern, fold: fold, port: port})
 	}
 
 	if len(patterns) == 0 {
@@ -45,17 +138,96 @@ func NewHostValidator(allowedHosts string) (*HostValidator, error) {
 	}, nil
 }
 
-// Validate checks if the given host is allowed
-func (v *HostValidator) Validate(host string) error {
+// splitHostPattern splits the port off a host pattern, returning zero if it
+// has none. IPv6 patterns, whose colons are taken for a port otherwise, must
+// be bracketed to have one, e.g. [::1]:22.
+func splitHostPattern(pattern string) (string, int, error) {
+	var host, port string
+	switch {
+	case strings.HasPrefix(pattern, "[") && strings.Contains(pattern, "]:"):
+		end := strings.LastIndex(pattern, "]:")
+		host, port = pattern[:end+1], pattern[end+2:]
+	case strings.Count(pattern, ":") == 1:
+		host, port, _ = strings.Cut(pattern, ":")
+	default:
+		return pattern, 0, nil
+	}
+
+	number, err := strconv.Atoi(port)
+	if err != nil || number < 1 || number > 65535 {
+		return "", 0, fmt.Errorf("invalid port '%s' in host pattern '%s'", port, pattern)
+	}
+	if host == "" {
+		return "", 0, fmt.Errorf("missing host in host pattern '%s'", pattern)
+	}
+	return host, number, nil
+}
+
+// isIPPattern reports whether a host pattern matches IP addresses: IPv4
+// patterns are made of digits, dots and glob syntax, and only IPv6
+// addresses contain colons
+func isIPPattern(pattern string) bool {
+	if strings.Contains(pattern, ":") {
+		return true
+	}
+	return strings.Trim(pattern, "0123456789.*?[]!-") == ""
+}
+
+// unbracketHost removes the brackets around an IPv6 address written as in a
+// URL, e.g. [::1]
+func unbracketHost(host string) string {
+	if len(host) > 2 && host[0] == '[' && host[len(host)-1] == ']' && strings.Contains(host, ":") {
+		return host[1 : len(host)-1]
+	}
+	return host
+}
+
+// canonicalHost returns the canonical form of an IPv6 address, lowercase
+// with the longest run of zero groups compressed, e.g. 2001:db8::1 for
+// 2001:0DB8:0:0:0:0:0:1. Other hosts are returned as given.
+func canonicalHost(host string) string {
+	if !strings.Contains(host, ":") {
+		return host
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.To4() != nil {
+		return host
+	}
+	return ip.String()
+}
+
+// Validate checks if the given host is allowed on port. IPv6 addresses may
+// be bracketed, and are matched in their canonical form.
+func (v *HostValidator) Validate(host string, port int) error {
 	if host == "" {
 		return fmt.Errorf("host cannot be empty")
 	}
 
+	original := host
+	host = canonicalHost(unbracketHost(host))
+	lower := strings.ToLower(host)
+	hostAllowed := false
 	for _, pattern := range v.patterns {
-		if pattern.Match(host) {
+		candidate := host
+		if pattern.fold {
+			candidate = lower
+		}
+		if !pattern.glob.Match(candidate) {
+			continue
+		}
+		if pattern.port == 0 || pattern.port == port {
 			return nil
 		}
+		hostAllowed = true
 	}
 
-	return fmt.Errorf("host '%s' is not in the allowed hosts list", host)
+	if hostAllowed {
+		return &ConnectionError{Code: CodeHostNotAllowed, Err: fmt.Errorf("port %d is not allowed for host '%s'", port, original)}
+	}
+	return &ConnectionError{Code: CodeHostNotAllowed, Err: fmt.Errorf("host '%s' is not in the allowed hosts list", original)}
+}
+
+// PatternCount returns the number of allowed host patterns
+func (v *HostValidator) PatternCount() int {
+	return len(v.patterns)
 }
@@ -1,6 +1,9 @@
 package ssh
 
 import (
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -168,7 +171,7 @@ func TestHostValidator_Validate(t *testing.T) {
 			name:         "case sensitivity",
 			allowedHosts: "example.com",
 			testHost:     "EXAMPLE.COM",
-			expectError:  true, // Glob matching is case-sensitive
+			expectError:  true, // Glob matching is case-sensitive by default
 		},
 
 		// Mixed patterns
@@ -205,7 +208,7 @@ func TestHostValidator_Validate(t *testing.T) {
 				t.Fatalf("failed to create validator: %v", err)
 			}
 
-			err = validator.Validate(tt.testHost)
+			err = validator.Validate(tt.testHost, 22)
 			if tt.expectError && err == nil {
 				t.Errorf("expected error but got nil")
 			}
@@ -216,6 +219,153 @@ func TestHostValidator_Validate(t *testing.T) {
 	}
 }
 
+func TestHostValidator_IPv6(t *testing.T) {
+	tests := []struct {
+		name         string
+		allowedHosts string
+		testHost     string
+		expectError  bool
+	}{
+		{name: "exact match", allowedHosts: "2001:db8::1", testHost: "2001:db8::1"},
+		{name: "exact match of another form", allowedHosts: "2001:db8::1", testHost: "2001:0DB8:0:0:0:0:0:1"},
+		{name: "exact match of a pattern in another form", allowedHosts: "2001:0db8:0000::0001", testHost: "2001:db8::1"},
+		{name: "bracketed pattern", allowedHosts: "[::1]", testHost: "::1"},
+		{name: "bracketed host", allowedHosts: "::1", testHost: "[::1]"},
+		{name: "prefix wildcard", allowedHosts: "2001:db8:*", testHost: "2001:db8::42"},
+		{name: "bracketed prefix wildcard", allowedHosts: "[2001:db8:*]", testHost: "[2001:db8:0:1::5]"},
+		{name: "mixed with hostnames", allowedHosts: "*.example.com,fe80::*", testHost: "fe80::1"},
+		{name: "mismatch", allowedHosts: "2001:db8:*", testHost: "2001:db9::1", expectError: true},
+		{name: "exact mismatch", allowedHosts: "::1", testHost: "::2", expectError: true},
+		{name: "ipv4 pattern", allowedHosts: "192.168.*", testHost: "::1", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			validator, err := NewHostValidator(tt.allowedHosts, WithCaseInsensitiveHosts(true))
+			if err != nil {
+				t.Fatalf("failed to create validator: %v", err)
+			}
+
+			err = validator.Validate(tt.testHost, 22)
+			if tt.expectError && err == nil {
+				t.Errorf("expected error but got nil")
+			}
+			if !tt.expectError && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestHostValidator_CaseInsensitive(t *testing.T) {
+	tests := []struct {
+		name            string
+		allowedHosts    string
+		testHost        string
+		caseInsensitive bool
+		expectError     bool
+	}{
+		{name: "upper case host", allowedHosts: "example.com", testHost: "EXAMPLE.COM", caseInsensitive: true},
+		{name: "upper case pattern", allowedHosts: "*.Example.COM", testHost: "www.example.com", caseInsensitive: true},
+		{name: "mixed case glob", allowedHosts: "web-*.example.com", testHost: "Web-01.Example.com", caseInsensitive: true},
+		{name: "ip pattern", allowedHosts: "192.168.1.*", testHost: "192.168.1.10", caseInsensitive: true},
+		{name: "ipv6 address in canonical form", allowedHosts: "fe80::*", testHost: "FE80::1", caseInsensitive: false},
+		{name: "strict upper case host", allowedHosts: "example.com", testHost: "EXAMPLE.COM", expectError: true},
+		{name: "strict upper case pattern", allowedHosts: "*.Example.COM", testHost: "www.example.com", expectError: true},
+		{name: "strict exact case", allowedHosts: "Example.com", testHost: "Example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			validator, err := NewHostValidator(tt.allowedHosts, WithCaseInsensitiveHosts(tt.caseInsensitive))
+			if err != nil {
+				t.Fatalf("failed to create validator: %v", err)
+			}
+
+			err = validator.Validate(tt.testHost, 22)
+			if tt.expectError && err == nil {
+				t.Errorf("expected error but got nil")
+			}
+			if !tt.expectError && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestNewHostValidatorFromFile(t *testing.T) {
+	tests := []struct {
+		name          string
+		content       string
+		allowedHosts  string
+		allowed       []string
+		denied        []string
+		errorContains string
+	}{
+		{
+			name:    "comments and blank lines",
+			content: "# production\n*.example.com\n\n  10.0.0.*  # internal\n\t\n",
+			allowed: []string{"web.example.com", "10.0.0.5"},
+			denied:  []string{"example.org", "10.0.1.5"},
+		},
+		{
+			name:         "combined with allowed hosts",
+			content:      "*.example.com\n",
+			allowedHosts: "bastion.example.org",
+			allowed:      []string{"web.example.com", "bastion.example.org"},
+			denied:       []string{"other.example.org"},
+		},
+		{
+			name:          "only comments",
+			content:       "# nothing allowed yet\n\n   # still nothing\n",
+			allowedHosts:  "example.com",
+			errorContains: "no host patterns",
+		},
+		{
+			name:          "invalid pattern",
+			content:       "[invalid\n",
+			errorContains: "invalid host pattern",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "allowed_hosts")
+			if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+				t.Fatalf("failed to write file: %v", err)
+			}
+
+			validator, err := NewHostValidatorFromFile(path, tt.allowedHosts)
+			if tt.errorContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errorContains) {
+					t.Fatalf("expected error containing %q, got %v", tt.errorContains, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			for _, host := range tt.allowed {
+				if err := validator.Validate(host, 22); err != nil {
+					t.Errorf("expected %s to be allowed: %v", host, err)
+				}
+			}
+			for _, host := range tt.denied {
+				if err := validator.Validate(host, 22); err == nil {
+					t.Errorf("expected %s to be denied", host)
+				}
+			}
+		})
+	}
+
+	t.Run("missing file", func(t *testing.T) {
+		_, err := NewHostValidatorFromFile(filepath.Join(t.TempDir(), "missing"), "")
+		if err == nil || !strings.Contains(err.Error(), "failed to read allowed hosts file") {
+			t.Errorf("expected a read error, got %v", err)
+		}
+	})
+}
+
 func TestHostValidator_ValidateConcurrent(t *testing.T) {
 	validator, err := NewHostValidator("*.example.com,192.168.*")
 	if err != nil {
@@ -234,7 +384,7 @@ func TestHostValidator_ValidateConcurrent(t *testing.T) {
 	for i := 0; i < 10; i++ {
 		go func() {
 			for _, host := range hosts {
-				_ = validator.Validate(host)
+				_ = validator.Validate(host, 22)
 			}
 			done <- true
 		}()
@@ -245,6 +395,56 @@ func TestHostValidator_ValidateConcurrent(t *testing.T) {
 	}
 }
 
+func TestHostValidator_Ports(t *testing.T) {
+	tests := []struct {
+		name         string
+		allowedHosts string
+		testHost     string
+		testPort     int
+		expectError  string
+	}{
+		{name: "matching port", allowedHosts: "example.com:22", testHost: "example.com", testPort: 22},
+		{name: "mismatching port", allowedHosts: "example.com:22", testHost: "example.com", testPort: 2222, expectError: "port 2222 is not allowed for host 'example.com'"},
+		{name: "wildcard host with port", allowedHosts: "10.0.*:2200", testHost: "10.0.3.4", testPort: 2200},
+		{name: "wildcard host with other port", allowedHosts: "10.0.*:2200", testHost: "10.0.3.4", testPort: 22, expectError: "port 22 is not allowed"},
+		{name: "host mismatch", allowedHosts: "10.0.*:2200", testHost: "10.1.3.4", testPort: 2200, expectError: "not in the allowed hosts list"},
+		{name: "no port allows any", allowedHosts: "example.com", testHost: "example.com", testPort: 2222},
+		{name: "another pattern allows the port", allowedHosts: "*.example.com:22,db.example.com:5432", testHost: "db.example.com", testPort: 5432},
+		{name: "bracketed IPv6 with port", allowedHosts: "[2001:db8::*]:22", testHost: "2001:db8::1", testPort: 22},
+		{name: "bracketed IPv6 with other port", allowedHosts: "[2001:db8::*]:22", testHost: "[2001:db8::1]", testPort: 23, expectError: "port 23 is not allowed"},
+		{name: "IPv6 without port", allowedHosts: "2001:db8::1", testHost: "2001:db8::1", testPort: 2222},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			validator, err := NewHostValidator(tt.allowedHosts)
+			if err != nil {
+				t.Fatalf("failed to create validator: %v", err)
+			}
+
+			err = validator.Validate(tt.testHost, tt.testPort)
+			if tt.expectError == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.expectError) {
+				t.Errorf("expected an error containing %q, got %v", tt.expectError, err)
+			}
+			if ErrorCodeOf(err) != CodeHostNotAllowed {
+				t.Errorf("expected code %s, got %q", CodeHostNotAllowed, ErrorCodeOf(err))
+			}
+		})
+	}
+
+	for _, pattern := range []string{"example.com:ssh", "example.com:0", "example.com:70000", ":22", "[::1]:x"} {
+		if _, err := NewHostValidator(pattern); err == nil {
+			t.Errorf("expected pattern %q to be rejected", pattern)
+		}
+	}
+}
+
 // Helper function to check if a string contains a substring
 func contains(s, substr string) bool {
 	return len(s) >= len(substr) && (s == substr || len(substr) == 0 || (len(s) > 0 && len(substr) > 0 && containsHelper(s, substr)))
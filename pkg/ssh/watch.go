@@ -0,0 +1,147 @@
+package ssh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/pkg/sftp"
+)
+
+const (
+	// DefaultWatchTimeout is how long WatchFile waits for a change by default
+	DefaultWatchTimeout = 30 * time.Second
+
+	// MaxWatchTimeout is the longest WatchFile may wait for a change
+	MaxWatchTimeout = 10 * time.Minute
+
+	// DefaultWatchInterval is the default delay between two polls
+	DefaultWatchInterval = time.Second
+
+	// MinWatchInterval is the shortest allowed delay between two polls
+	MinWatchInterval = 100 * time.Millisecond
+)
+
+// File change kinds reported by WatchFile
+const (
+	FileCreated  = "created"
+	FileModified = "modified"
+	FileDeleted  = "deleted"
+)
+
+// FileState is the observed state of a watched file
+type FileState struct {
+	Exists  bool
+	Size    int64
+	ModTime time.Time
+}
+
+// FileChange is the outcome of watching a file
+type FileChange struct {
+	// Changed is false if the timeout elapsed without a change
+	Changed bool
+
+	// Change is FileCreated, FileModified or FileDeleted when Changed is set
+	Change string
+
+	Before FileState
+	After  FileState
+	Waited time.Duration
+}
+
+// WatchFile polls a remote file's size and modification time over SFTP every
+// interval until it is created, modified or deleted, or until timeout
+// elapses. SFTP reports modification times in whole seconds, so a rewrite
+// that keeps the size within the same second goes unnoticed.
+func (m *Manager) WatchFile(ctx context.Context, id, remotePath string, timeout, interval time.Duration) (*FileChange, error) {
+	client, err := m.sftpClient(id)
+	if err != nil {
+		return nil, err
+	}
+
+	resolved, err := m.resolveRemotePath(client, remotePath)
+	if err != nil {
+		return nil, err
+	}
+
+	if timeout <= 0 {
+		timeout = DefaultWatchTimeout
+	}
+	if interval < MinWatchInterval {
+		interval = MinWatchInterval
+	}
+
+	started := time.Now()
+	before, err := statFile(client, resolved)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+
+		case <-deadline.C:
+			return &FileChange{
+				Before: before,
+				After:  before,
+				Waited: time.Since(started),
+			}, nil
+
+		case <-ticker.C:
+			after, err := statFile(client, resolved)
+			if err != nil {
+				return nil, err
+			}
+			if change := fileChange(before, after); change != "" {
+				return &FileChange{
+					Changed: true,
+					Change:  change,
+					Before:  before,
+					After:   after,
+					Waited:  time.Since(started),
+				}, nil
+			}
+		}
+	}
+}
+
+// statFile returns the current state of a remote file
+func statFile(client *sftp.Client, remotePath string) (FileState, error) {
+	info, err := client.Stat(remotePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return FileState{}, nil
+	}
+	if err != nil {
+		return FileState{}, fmt.Errorf("failed to stat '%s': %w", remotePath, err)
+	}
+
+	return FileState{
+		Exists:  true,
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+	}, nil
+}
+
+// fileChange classifies the difference between two states of a file, or
+// returns an empty string if there is none
+func fileChange(before, after FileState) string {
+	switch {
+	case !before.Exists && after.Exists:
+		return FileCreated
+	case before.Exists && !after.Exists:
+		return FileDeleted
+	case before.Exists && (before.Size != after.Size || !before.ModTime.Equal(after.ModTime)):
+		return FileModified
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,75 @@
+package ssh
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatchFile(t *testing.T) {
+	dir := t.TempDir()
+	flag := filepath.Join(dir, "ready")
+
+	server := newTestServer(t)
+	manager := newTestManager(t)
+	connectTestServer(t, manager, server, "default")
+
+	change, err := manager.WatchFile(context.Background(), "default", flag, 300*time.Millisecond, MinWatchInterval)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if change.Changed {
+		t.Errorf("expected no change, got %+v", change)
+	}
+
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		_ = os.WriteFile(flag, []byte("done"), 0o600)
+	}()
+	change, err = manager.WatchFile(context.Background(), "default", flag, 5*time.Second, MinWatchInterval)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !change.Changed || change.Change != FileCreated || change.After.Size != 4 {
+		t.Errorf("expected the file to be created, got %+v", change)
+	}
+
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		_ = os.Remove(flag)
+	}()
+	change, err = manager.WatchFile(context.Background(), "default", flag, 5*time.Second, MinWatchInterval)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !change.Changed || change.Change != FileDeleted {
+		t.Errorf("expected the file to be deleted, got %+v", change)
+	}
+}
+
+func TestFileChange(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name     string
+		before   FileState
+		after    FileState
+		expected string
+	}{
+		{"unchanged", FileState{Exists: true, Size: 1, ModTime: now}, FileState{Exists: true, Size: 1, ModTime: now}, ""},
+		{"still missing", FileState{}, FileState{}, ""},
+		{"created", FileState{}, FileState{Exists: true}, FileCreated},
+		{"deleted", FileState{Exists: true}, FileState{}, FileDeleted},
+		{"size", FileState{Exists: true, Size: 1, ModTime: now}, FileState{Exists: true, Size: 2, ModTime: now}, FileModified},
+		{"mtime", FileState{Exists: true, Size: 1, ModTime: now}, FileState{Exists: true, Size: 1, ModTime: now.Add(time.Second)}, FileModified},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := fileChange(tt.before, tt.after); got != tt.expected {
+				t.Errorf("fileChange() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
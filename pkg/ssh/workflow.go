@@ -0,0 +1,288 @@
+package ssh
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Workflow step statuses
+const (
+	StepSucceeded = "succeeded"
+	StepFailed    = "failed"
+	StepSkipped   = "skipped"
+)
+
+// WorkflowStep is a single command of a workflow, run on one connection once
+// all of the steps it depends on have succeeded
+type WorkflowStep struct {
+	ID           string
+	ConnectionID string
+	Command      string
+	DependsOn    []string
+}
+
+// WorkflowStepResult holds the outcome of a workflow step
+type WorkflowStepResult struct {
+	ID           string
+	ConnectionID string
+	Status       string
+	Result       *CommandResult
+	Error        string
+	Duration     time.Duration
+
+	// budgetExhausted is set when the step was cut short or not started
+	// because the workflow's time budget ran out
+	budgetExhausted bool
+}
+
+// WorkflowOptions tunes how a workflow runs
+type WorkflowOptions struct {
+	// StopOnFailure stops starting new steps after the first failure
+	StopOnFailure bool
+
+	// Budget, when positive, bounds the duration of the whole workflow. Each
+	// step's timeout is capped to the budget left when it starts; once the
+	// budget is used up, the remaining steps are skipped.
+	Budget time.Duration
+}
+
+// WorkflowResult holds the outcome of a workflow
+type WorkflowResult struct {
+	// Steps are the step results in the order the steps were given
+	Steps []WorkflowStepResult
+
+	// BudgetExhausted is set when the time budget ran out before all steps
+	// could finish
+	BudgetExhausted bool
+
+	Elapsed time.Duration
+}
+
+// RunWorkflow runs the given steps in dependency order. Steps whose
+// dependencies are all satisfied run concurrently. A step fails when it
+// cannot be executed or exits non-zero; steps depending on a failed step are
+// skipped. If stopOnFailure is set, no new steps are started after the first
+// failure. Results are returned in the order the steps were given.
+func (m *Manager) RunWorkflow(steps []WorkflowStep, stopOnFailure bool) ([]WorkflowStepResult, error) {
+	result, err := m.RunWorkflowWithOptions(steps, WorkflowOptions{StopOnFailure: stopOnFailure})
+	if err != nil {
+		return nil, err
+	}
+	return result.Steps, nil
+}
+
+// RunWorkflowWithOptions runs the given steps like RunWorkflow, optionally
+// within an overall time budget
+func (m *Manager) RunWorkflowWithOptions(steps []WorkflowStep, opts WorkflowOptions) (*WorkflowResult, error) {
+	if err := validateWorkflow(steps); err != nil {
+		return nil, err
+	}
+
+	started := time.Now()
+	var deadline time.Time
+	if opts.Budget > 0 {
+		deadline = started.Add(opts.Budget)
+	}
+	exhausted := false
+
+	results := make(map[string]*WorkflowStepResult, len(steps))
+	pending := make([]WorkflowStep, len(steps))
+	copy(pending, steps)
+	failed := false
+
+	for len(pending) > 0 {
+		var ready, waiting []WorkflowStep
+		for _, step := range pending {
+			switch {
+			case exhausted:
+				results[step.ID] = budgetSkippedStep(step)
+			case failed && opts.StopOnFailure:
+				results[step.ID] = skippedStep(step, "workflow stopped after an earlier step failed")
+			case dependencyFailed(step, results):
+				results[step.ID] = skippedStep(step, "a dependency did not succeed")
+			case dependenciesDone(step, results):
+				ready = append(ready, step)
+			default:
+				waiting = append(waiting, step)
+			}
+		}
+
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		for _, step := range ready {
+			wg.Add(1)
+			go func(step WorkflowStep) {
+				defer wg.Done()
+				result := m.runWorkflowStep(step, deadline)
+
+				mu.Lock()
+				results[step.ID] = result
+				mu.Unlock()
+			}(step)
+		}
+		wg.Wait()
+
+		for _, step := range ready {
+			if results[step.ID].Status == StepFailed {
+				failed = true
+			}
+		}
+		if !deadline.IsZero() && !time.Now().Before(deadline) && len(waiting) > 0 {
+			exhausted = true
+		}
+
+		pending = waiting
+	}
+
+	workflow := &WorkflowResult{
+		Steps:   make([]WorkflowStepResult, 0, len(steps)),
+		Elapsed: time.Since(started),
+	}
+	for _, step := range steps {
+		result := results[step.ID]
+		if result.budgetExhausted {
+			workflow.BudgetExhausted = true
+		}
+		workflow.Steps = append(workflow.Steps, *result)
+	}
+	return workflow, nil
+}
+
+// runWorkflowStep executes a single step. A non-zero deadline caps the
+// step's timeout to the time left in the workflow's budget.
+func (m *Manager) runWorkflowStep(step WorkflowStep, deadline time.Time) *WorkflowStepResult {
+	start := time.Now()
+
+	var opts ExecuteOptions
+	capped := false
+	if !deadline.IsZero() {
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return budgetSkippedStep(step)
+		}
+		if remaining < m.config.CommandTimeout {
+			opts.Timeout = remaining
+			capped = true
+		}
+	}
+
+	result, err := m.ExecuteWithOptions(step.ConnectionID, step.Command, opts)
+
+	stepResult := &WorkflowStepResult{
+		ID:           step.ID,
+		ConnectionID: step.ConnectionID,
+		Status:       StepSucceeded,
+		Result:       result,
+		Duration:     time.Since(start),
+	}
+	if err != nil {
+		stepResult.Status = StepFailed
+		stepResult.Error = err.Error()
+		if capped && !time.Now().Before(deadline) {
+			stepResult.Error += " (workflow time budget exhausted)"
+			stepResult.budgetExhausted = true
+		}
+	} else if result.ExitCode != 0 {
+		stepResult.Status = StepFailed
+		stepResult.Error = fmt.Sprintf("command exited with code %d", result.ExitCode)
+	}
+	return stepResult
+}
+
+// skippedStep builds the result of a step that was not run
+func skippedStep(step WorkflowStep, reason string) *WorkflowStepResult {
+	return &WorkflowStepResult{
+		ID:           step.ID,
+		ConnectionID: step.ConnectionID,
+		Status:       StepSkipped,
+		Error:        reason,
+	}
+}
+
+// budgetSkippedStep builds the result of a step that was not run because the
+// workflow's time budget ran out
+func budgetSkippedStep(step WorkflowStep) *WorkflowStepResult {
+	result := skippedStep(step, "workflow time budget exhausted")
+	result.budgetExhausted = true
+	return result
+}
+
+// dependencyFailed reports whether any finished dependency did not succeed
+func dependencyFailed(step WorkflowStep, results map[string]*WorkflowStepResult) bool {
+	for _, dep := range step.DependsOn {
+		if result, done := results[dep]; done && result.Status != StepSucceeded {
+			return true
+		}
+	}
+	return false
+}
+
+// dependenciesDone reports whether all dependencies have finished
+func dependenciesDone(step WorkflowStep, results map[string]*WorkflowStepResult) bool {
+	for _, dep := range step.DependsOn {
+		if _, done := results[dep]; !done {
+			return false
+		}
+	}
+	return true
+}
+
+// validateWorkflow checks step ids are unique, dependencies exist and the
+// dependency graph has no cycles
+func validateWorkflow(steps []WorkflowStep) error {
+	if len(steps) == 0 {
+		return fmt.Errorf("workflow has no steps")
+	}
+
+	deps := make(map[string][]string, len(steps))
+	for _, step := range steps {
+		if step.ID == "" {
+			return fmt.Errorf("workflow step id cannot be empty")
+		}
+		if _, exists := deps[step.ID]; exists {
+			return fmt.Errorf("duplicate workflow step id '%s'", step.ID)
+		}
+		deps[step.ID] = step.DependsOn
+	}
+
+	for _, step := range steps {
+		for _, dep := range step.DependsOn {
+			if _, exists := deps[dep]; !exists {
+				return fmt.Errorf("workflow step '%s' depends on unknown step '%s'", step.ID, dep)
+			}
+		}
+	}
+
+	// Depth-first search for cycles
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[string]int, len(steps))
+	var visit func(id string) error
+	visit = func(id string) error {
+		switch state[id] {
+		case visiting:
+			return fmt.Errorf("workflow has a dependency cycle involving step '%s'", id)
+		case visited:
+			return nil
+		}
+		state[id] = visiting
+		for _, dep := range deps[id] {
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		state[id] = visited
+		return nil
+	}
+	for _, step := range steps {
+		if err := visit(step.ID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
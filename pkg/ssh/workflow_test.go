@@ -0,0 +1,135 @@
+package ssh
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateWorkflow(t *testing.T) {
+	tests := []struct {
+		name          string
+		steps         []WorkflowStep
+		errorContains string
+	}{
+		{
+			name: "linear",
+			steps: []WorkflowStep{
+				{ID: "build", ConnectionID: "a", Command: "make"},
+				{ID: "deploy-b", ConnectionID: "b", Command: "deploy", DependsOn: []string{"build"}},
+				{ID: "deploy-c", ConnectionID: "c", Command: "deploy", DependsOn: []string{"build"}},
+			},
+		},
+		{
+			name:          "empty",
+			steps:         nil,
+			errorContains: "no steps",
+		},
+		{
+			name: "duplicate id",
+			steps: []WorkflowStep{
+				{ID: "build", ConnectionID: "a", Command: "make"},
+				{ID: "build", ConnectionID: "b", Command: "make"},
+			},
+			errorContains: "duplicate",
+		},
+		{
+			name: "unknown dependency",
+			steps: []WorkflowStep{
+				{ID: "deploy", ConnectionID: "a", Command: "deploy", DependsOn: []string{"build"}},
+			},
+			errorContains: "unknown step",
+		},
+		{
+			name: "cycle",
+			steps: []WorkflowStep{
+				{ID: "a", ConnectionID: "a", Command: "true", DependsOn: []string{"c"}},
+				{ID: "b", ConnectionID: "a", Command: "true", DependsOn: []string{"a"}},
+				{ID: "c", ConnectionID: "a", Command: "true", DependsOn: []string{"b"}},
+			},
+			errorContains: "cycle",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateWorkflow(tt.steps)
+			if tt.errorContains == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errorContains) {
+				t.Errorf("expected error containing %q but got %v", tt.errorContains, err)
+			}
+		})
+	}
+}
+
+func TestRunWorkflow_UnknownConnection(t *testing.T) {
+	manager := newTestManager(t)
+
+	steps := []WorkflowStep{
+		{ID: "build", ConnectionID: "missing", Command: "make"},
+		{ID: "deploy", ConnectionID: "missing", Command: "deploy", DependsOn: []string{"build"}},
+		{ID: "independent", ConnectionID: "missing", Command: "true"},
+	}
+
+	t.Run("stop", func(t *testing.T) {
+		results, err := manager.RunWorkflow(steps, true)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		expected := []string{StepFailed, StepSkipped, StepFailed}
+		for i, result := range results {
+			if result.Status != expected[i] {
+				t.Errorf("step %s: expected status %s, got %s", result.ID, expected[i], result.Status)
+			}
+		}
+	})
+
+	t.Run("continue", func(t *testing.T) {
+		results, err := manager.RunWorkflow(steps, false)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if results[1].Status != StepSkipped {
+			t.Errorf("expected dependent step to be skipped, got %s", results[1].Status)
+		}
+	})
+}
+
+func TestRunWorkflowWithOptions_Budget(t *testing.T) {
+	server := newTestServer(t)
+	manager := newTestManager(t)
+	connectTestServer(t, manager, server, "default")
+
+	steps := []WorkflowStep{
+		{ID: "quick", ConnectionID: "default", Command: "sleep 0.2"},
+		{ID: "slow", ConnectionID: "default", Command: "sleep 3", DependsOn: []string{"quick"}},
+		{ID: "after", ConnectionID: "default", Command: "true", DependsOn: []string{"slow"}},
+	}
+
+	result, err := manager.RunWorkflowWithOptions(steps, WorkflowOptions{StopOnFailure: true, Budget: time.Second})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !result.BudgetExhausted {
+		t.Errorf("expected the budget to be exhausted")
+	}
+	if result.Elapsed > 2*time.Second {
+		t.Errorf("expected the workflow to stop at its budget, took %v", result.Elapsed)
+	}
+
+	expected := []string{StepSucceeded, StepFailed, StepSkipped}
+	for i, step := range result.Steps {
+		if step.Status != expected[i] {
+			t.Errorf("step %s: expected status %s, got %s (%s)", step.ID, expected[i], step.Status, step.Error)
+		}
+	}
+	if !strings.Contains(result.Steps[1].Error, "budget exhausted") {
+		t.Errorf("expected the slow step to report the exhausted budget, got %q", result.Steps[1].Error)
+	}
+}
@@ -0,0 +1,171 @@
+// Package sshconfig reads OpenSSH client configuration files, so that host
+// aliases such as "Host prod" can be resolved to the host name, user, port
+// and keys they stand for
+package sshconfig
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/kevinburke/ssh_config"
+)
+
+// Config is a parsed SSH client configuration
+type Config struct {
+	config *ssh_config.Config
+	path   string
+}
+
+// Host holds the settings a configuration gives for an alias. Fields left
+// empty or zero are not set by any matching Host block.
+type Host struct {
+	// HostName is the real host to connect to, with %h expanded
+	HostName string
+	User     string
+	Port     int
+
+	// IdentityFiles are the private key paths in the order given, with ~ and
+	// the %d, %h, %r and %u tokens expanded
+	IdentityFiles []string
+}
+
+// DefaultPath returns the path of the user's configuration, ~/.ssh/config
+func DefaultPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find the home directory: %w", err)
+	}
+	return filepath.Join(home, ".ssh", "config"), nil
+}
+
+// Load parses the configuration file at path. A missing file is an empty
+// configuration, as for ssh.
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		data = nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read SSH config: %w", err)
+	}
+
+	config, err := Parse(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid SSH config %s: %w", path, err)
+	}
+	config.path = path
+	return config, nil
+}
+
+// Parse parses a configuration
+func Parse(r io.Reader) (*Config, error) {
+	config, err := ssh_config.Decode(r)
+	if err != nil {
+		return nil, err
+	}
+	return &Config{config: config}, nil
+}
+
+// Path returns the file the configuration was loaded from, empty if it was
+// parsed from elsewhere
+func (c *Config) Path() string {
+	return c.path
+}
+
+// Resolve returns the settings for alias. As with ssh, the first value found
+// for each setting wins, so specific Host blocks must come before wildcard
+// ones.
+func (c *Config) Resolve(alias string) (Host, error) {
+	var host Host
+	var err error
+
+	if host.HostName, err = c.config.Get(alias, "HostName"); err != nil {
+		return Host{}, err
+	}
+	host.HostName = expandTokens(host.HostName, map[byte]string{'h': alias})
+
+	if host.User, err = c.config.Get(alias, "User"); err != nil {
+		return Host{}, err
+	}
+
+	port, err := c.config.Get(alias, "Port")
+	if err != nil {
+		return Host{}, err
+	}
+	if port != "" {
+		if host.Port, err = strconv.Atoi(port); err != nil || host.Port < 1 || host.Port > 65535 {
+			return Host{}, fmt.Errorf("invalid Port '%s' for host '%s' in SSH config", port, alias)
+		}
+	}
+
+	identityFiles, err := c.config.GetAll(alias, "IdentityFile")
+	if err != nil {
+		return Host{}, err
+	}
+	if len(identityFiles) > 0 {
+		tokens := identityTokens(alias, host)
+		for _, path := range identityFiles {
+			host.IdentityFiles = append(host.IdentityFiles, expandTokens(path, tokens))
+		}
+	}
+
+	return host, nil
+}
+
+// identityTokens returns the values of the tokens allowed in IdentityFile
+// paths: the home directory, the host name and the remote and local users
+func identityTokens(alias string, host Host) map[byte]string {
+	tokens := map[byte]string{'h': alias}
+	if host.HostName != "" {
+		tokens['h'] = host.HostName
+	}
+	if local, err := user.Current(); err == nil {
+		tokens['u'] = local.Username
+		tokens['r'] = local.Username
+	}
+	if host.User != "" {
+		tokens['r'] = host.User
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		tokens['d'] = home
+		tokens['~'] = home
+	}
+	return tokens
+}
+
+// expandTokens replaces a leading ~ and the %-tokens of value with their
+// values; "%%" stands for a single %. Unknown tokens are left as they are.
+func expandTokens(value string, tokens map[byte]string) string {
+	if home, ok := tokens['~']; ok && (value == "~" || strings.HasPrefix(value, "~/")) {
+		value = home + value[1:]
+	}
+	if !strings.Contains(value, "%") {
+		return value
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] != '%' || i+1 == len(value) {
+			b.WriteByte(value[i])
+			continue
+		}
+		token := value[i+1]
+		if replacement, ok := tokens[token]; ok && token != '~' {
+			b.WriteString(replacement)
+			i++
+		} else if token == '%' {
+			b.WriteByte('%')
+			i++
+		} else {
+			b.WriteByte('%')
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,155 @@
+package sshconfig
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testConfig = `
+# Specific hosts come first, as the first value found wins
+Host prod
+    HostName prod-01.internal.example.com
+    User deploy
+    Port 2222
+    IdentityFile ~/.ssh/prod_ed25519
+
+Host db-* db
+    HostName %h.internal.example.com
+    User postgres
+
+Host *.example.com
+    User admin
+    IdentityFile ~/.ssh/%h_%r
+
+Host *
+    User fallback
+    Port 22
+    IdentityFile ~/.ssh/id_ed25519
+`
+
+func TestResolve(t *testing.T) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Skipf("no home directory: %v", err)
+	}
+	config, err := Parse(strings.NewReader(testConfig))
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+
+	tests := []struct {
+		alias string
+		want  Host
+	}{
+		{
+			alias: "prod",
+			want: Host{
+				HostName: "prod-01.internal.example.com",
+				User:     "deploy",
+				Port:     2222,
+				IdentityFiles: []string{
+					filepath.Join(home, ".ssh/prod_ed25519"),
+					filepath.Join(home, ".ssh/id_ed25519"),
+				},
+			},
+		},
+		{
+			alias: "db-2",
+			want: Host{
+				HostName:      "db-2.internal.example.com",
+				User:          "postgres",
+				Port:          22,
+				IdentityFiles: []string{filepath.Join(home, ".ssh/id_ed25519")},
+			},
+		},
+		{
+			alias: "web.example.com",
+			want: Host{
+				User: "admin",
+				Port: 22,
+				IdentityFiles: []string{
+					filepath.Join(home, ".ssh/web.example.com_admin"),
+					filepath.Join(home, ".ssh/id_ed25519"),
+				},
+			},
+		},
+		{
+			alias: "10.0.0.1",
+			want: Host{
+				User:          "fallback",
+				Port:          22,
+				IdentityFiles: []string{filepath.Join(home, ".ssh/id_ed25519")},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.alias, func(t *testing.T) {
+			got, err := config.Resolve(tt.alias)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Resolve(%q) = %+v, want %+v", tt.alias, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolve_InvalidPort(t *testing.T) {
+	config, err := Parse(strings.NewReader("Host bad\n    Port ssh\n"))
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+	if _, err := config.Resolve("bad"); err == nil || !strings.Contains(err.Error(), "invalid Port") {
+		t.Errorf("expected an invalid port error, got %v", err)
+	}
+	if host, err := config.Resolve("other"); err != nil || !reflect.DeepEqual(host, Host{}) {
+		t.Errorf("expected no settings for an unmatched host, got %+v, %v", host, err)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+
+	// A missing file is an empty configuration
+	config, err := Load(filepath.Join(dir, "missing"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if host, _ := config.Resolve("prod"); !reflect.DeepEqual(host, Host{}) {
+		t.Errorf("expected no settings, got %+v", host)
+	}
+
+	path := filepath.Join(dir, "config")
+	if err := os.WriteFile(path, []byte(testConfig), 0600); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	config, err = Load(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if config.Path() != path {
+		t.Errorf("Path() = %q, want %q", config.Path(), path)
+	}
+	if host, err := config.Resolve("prod"); err != nil || host.HostName != "prod-01.internal.example.com" {
+		t.Errorf("expected prod to resolve, got %+v, %v", host, err)
+	}
+}
+
+func TestExpandTokens(t *testing.T) {
+	tokens := map[byte]string{'h': "example.com", 'r': "root", '~': "/home/me", 'd': "/home/me"}
+	tests := []struct{ value, want string }{
+		{value: "~/.ssh/id_%h", want: "/home/me/.ssh/id_example.com"},
+		{value: "%d/.ssh/%r@%h", want: "/home/me/.ssh/root@example.com"},
+		{value: "100%% %x %", want: "100% %x %"},
+		{value: "/abs/~/path", want: "/abs/~/path"},
+	}
+	for _, tt := range tests {
+		if got := expandTokens(tt.value, tokens); got != tt.want {
+			t.Errorf("expandTokens(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}